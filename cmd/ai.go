@@ -51,9 +51,10 @@ homeyctl token create "AI Bot" --preset control --no-save
 
 With a readonly token, write operations return:
 ` + "```" + `
-Error: 403 Missing Scopes
+Error: request failed with status 403: {"error":"Missing Scopes"}
+Hint: this operation requires the 'homey.device.control' scope. ...
 ` + "```" + `
-This is expected - inform the user they need a higher access token.
+This is expected - inform the user they need a token with the scope named in the hint.
 
 ---
 
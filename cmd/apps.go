@@ -3,20 +3,14 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
-type App struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Enabled bool   `json:"enabled"`
-	Ready   bool   `json:"ready"`
-}
+type App = homey.App
 
 var appsCmd = &cobra.Command{
 	Use:   "apps",
@@ -54,32 +48,14 @@ var appsListCmd = &cobra.Command{
 			return err
 		}
 
-		if isTableFormat() {
-			var apps map[string]App
-			if err := json.Unmarshal(data, &apps); err != nil {
-				return fmt.Errorf("failed to parse apps: %w", err)
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tVERSION\tENABLED\tREADY\tID")
-			fmt.Fprintln(w, "----\t-------\t-------\t-----\t--")
-			for _, a := range apps {
-				enabled := "yes"
-				if !a.Enabled {
-					enabled = "no"
-				}
-				ready := "yes"
-				if !a.Ready {
-					ready = "no"
-				}
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.Name, a.Version, enabled, ready, a.ID)
-			}
-			w.Flush()
-			return nil
-		}
-
-		outputJSON(data)
-		return nil
+		return printList(data, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "VERSION", Path: ".version"},
+			{Header: "ENABLED", Value: yesNo("enabled")},
+			{Header: "READY", Value: yesNo("ready")},
+			{Header: "ID", Path: ".id"},
+			{Header: "ORIGIN", Path: ".origin", Wide: true},
+		})
 	},
 }
 
@@ -118,8 +94,7 @@ var appsGetCmd = &cobra.Command{
 			return err
 		}
 
-		outputJSON(appData)
-		return nil
+		return printOutput(appData)
 	},
 }
 
@@ -39,8 +39,7 @@ Examples:
 			return nil
 		}
 
-		outputJSON(result)
-		return nil
+		return printOutput(result)
 	},
 }
 
@@ -151,8 +150,7 @@ var appsSettingsListCmd = &cobra.Command{
 			return err
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -217,8 +215,7 @@ Examples:
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/backup"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupOutputFlag  string
+	restoreDryRunFlag bool
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up and restore your Homey's configuration",
+	Long: `Save your Homey's configuration to a .tar.gz archive and restore it later,
+e.g. after a factory reset or when moving to a new Homey Pro.
+
+A backup holds zones, devices, device settings, device groups, flows,
+advanced flows, flow folders, variables, moods, dashboards, users, apps and
+app settings. Insights history and app data are not included.`,
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a backup archive",
+	Long: `Create a backup archive of your Homey's configuration.
+
+The archive may hold secrets from app settings, so it is only readable by you.
+
+Examples:
+  homeyctl backup create
+  homeyctl backup create -o backup.tar.gz
+  homeyctl backup create -o - | ssh nas 'cat > homey.tar.gz'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output := backupOutputFlag
+		if output == "" {
+			output = fmt.Sprintf("homey-backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+
+		b, err := backup.Create(apiClient, backup.Options{
+			CreatedBy: "homeyctl " + versionInfo.Version,
+			Warn: func(msg string) {
+				fmt.Fprintln(os.Stderr, "Warning:", msg)
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if output == "-" {
+			return b.Write(os.Stdout)
+		}
+		if err := writeBackup(b, output); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Backed up %s to %s\n", backupSummary(b), output)
+		return nil
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore a backup archive",
+	Long: `Restore a backup archive onto this Homey.
+
+Zones, flow folders, device groups and variables that are missing are
+created. Devices and users are matched by name, so pair your devices and
+invite your users in the Homey app first. Moods, dashboards and flows are
+then created, or updated if one with the same name exists, with every
+device, zone, user and variable they refer to rewritten to its new ID.
+Device and app settings are restored for devices and apps that are found.
+
+Zones and flow folders are matched by name within their parent. When
+several on the Homey share a name, that entity is skipped with a warning
+rather than guessed at.
+
+Anything that can't be matched is listed as a warning; pair the missing
+devices and run restore again, it is safe to repeat.
+
+Examples:
+  homeyctl backup restore backup.tar.gz --dry-run
+  homeyctl backup restore backup.tar.gz
+  homeyctl backup restore backup.tar.gz --force`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open backup: %w", err)
+		}
+		defer f.Close()
+
+		b, err := backup.Read(f)
+		if err != nil {
+			return err
+		}
+
+		// The table format shows progress as text; the others print the report
+		text := isTableFormat()
+		if text {
+			fmt.Printf("Backup from %s", b.Manifest.Created.Local().Format("2006-01-02 15:04"))
+			if b.Manifest.HomeyModel != "" {
+				fmt.Printf(" (%s, v%s)", b.Manifest.HomeyModel, b.Manifest.HomeyVersion)
+			}
+			fmt.Printf(": %s\n", backupSummary(b))
+		}
+
+		force, _ := cmd.Flags().GetBool("force")
+		if !restoreDryRunFlag && !force {
+			if !isInteractive(os.Stdin) {
+				return fmt.Errorf("use --force to restore without confirmation, or --dry-run to see what would change")
+			}
+			if !confirm(os.Stdin, os.Stdout, "Restore onto this Homey?") {
+				fmt.Println("Nothing changed.")
+				return nil
+			}
+		}
+
+		opts := backup.RestoreOptions{DryRun: restoreDryRunFlag}
+		if text {
+			fmt.Println()
+			opts.Progress = func(msg string) {
+				if restoreDryRunFlag {
+					fmt.Println("Would", msg)
+				} else {
+					fmt.Println(msg)
+				}
+			}
+		}
+		report, err := backup.Restore(apiClient, b, opts)
+
+		if !text {
+			if printErr := printOutput(report); printErr != nil {
+				return printErr
+			}
+			return err
+		}
+		if len(report.Warnings) > 0 {
+			fmt.Println("\nWarnings:")
+			for _, w := range report.Warnings {
+				fmt.Println("  " + w)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if restoreDryRunFlag {
+			fmt.Printf("\nDry run: %d change(s) would be made.\n", len(report.Changes))
+		} else {
+			fmt.Printf("\nRestored with %d change(s), %d ID(s) remapped.\n", len(report.Changes), report.Remapped)
+		}
+		return nil
+	},
+}
+
+// writeBackup writes the archive next to path first, so a failed backup
+// never replaces a good one
+func writeBackup(b *backup.Backup, path string) error {
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".homey-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, &buf); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// backupSummary lists what a backup holds, e.g. "12 zones, 40 devices, ..."
+func backupSummary(b *backup.Backup) string {
+	counts := b.Counts()
+	var parts []string
+	for _, name := range []string{"zones", "devices", "flows", "advanced flows", "flow folders", "variables", "moods", "dashboards", "users", "apps"} {
+		if counts[name] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[name], name))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+
+	backupCreateCmd.Flags().StringVarP(&backupOutputFlag, "output", "o", "", "archive to write, - for stdout (default homey-backup-<date>.tar.gz)")
+	backupRestoreCmd.Flags().BoolVar(&restoreDryRunFlag, "dry-run", false, "show what would be restored without changing anything")
+	backupRestoreCmd.Flags().Bool("force", false, "restore without asking for confirmation")
+}
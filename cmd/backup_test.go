@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/langtind/homeyctl/internal/backup"
+)
+
+func TestBackupCommands_Exist(t *testing.T) {
+	for _, args := range [][]string{{"backup", "create"}, {"backup", "restore"}} {
+		cmd, _, err := rootCmd.Find(args)
+		if err != nil || cmd.Name() != args[1] {
+			t.Fatalf("%v command not found: %v", args, err)
+		}
+	}
+
+	if backupCreateCmd.Flags().ShorthandLookup("o") == nil {
+		t.Error("expected -o shorthand for --output")
+	}
+	for _, flag := range []string{"dry-run", "force"} {
+		if backupRestoreCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected --%s flag on backup restore", flag)
+		}
+	}
+}
+
+func TestBackupSummary(t *testing.T) {
+	b := &backup.Backup{
+		Zones:   []byte(`{"a": {}, "b": {}}`),
+		Devices: []byte(`{"c": {}}`),
+	}
+	if got := backupSummary(b); got != "2 zones, 1 devices" {
+		t.Errorf("backupSummary() = %q", got)
+	}
+	if got := backupSummary(&backup.Backup{}); got != "nothing" {
+		t.Errorf("backupSummary() of empty backup = %q", got)
+	}
+}
+
+func TestWriteBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "homey.tar.gz")
+	b := &backup.Backup{Manifest: backup.Manifest{Version: backup.FormatVersion}, Zones: []byte(`{}`)}
+	if err := writeBackup(b, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("backup not written: %v", err)
+	}
+	if _, err := backup.Read(bytes.NewReader(data)); err != nil {
+		t.Errorf("written backup can't be read: %v", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the backup in the directory, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/client"
+	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/oauth"
+)
+
+// Replaced in tests
+var (
+	connectCloud       = oauth.ConnectCloud
+	refreshAccessToken = oauth.RefreshAccessToken
+)
+
+// prepareCloud makes sure cfg can reach the Homey through the cloud relay.
+// When only an Athom account token is configured, it logs in to the Homey
+// through the relay and caches the session token in the config.
+func prepareCloud(cfg *config.Config) error {
+	if loggedIn, err := loginCloud(cfg); err != nil || !loggedIn {
+		return err
+	}
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to save cloud session: %w", err)
+	}
+	return nil
+}
+
+// loginCloud logs in to the Homey through the cloud relay when cloud mode
+// has no Homey token yet, and sets the session on cfg without saving it. It
+// reports whether it logged in.
+func loginCloud(cfg *config.Config) (bool, error) {
+	if cfg.EffectiveMode() != "cloud" {
+		return false, nil
+	}
+	if cfg.EffectiveToken() != "" && cfg.CloudURL() != "" {
+		return false, nil
+	}
+
+	if cfg.Cloud.AccessToken == "" {
+		if cfg.CloudURL() == "" {
+			return false, fmt.Errorf("cloud mode needs to know which Homey to use. Run: homeyctl login\n" +
+				"  or: homeyctl config set-cloud <token> --homey-id <id>")
+		}
+		return false, fmt.Errorf("no cloud token configured. Run: homeyctl login")
+	}
+
+	verbosef("logging in to Homey through the cloud relay")
+	homey, err := connectCloud(cfg.Cloud.AccessToken, cfg.Cloud.HomeyID)
+	if err != nil {
+		return false, fmt.Errorf("cloud login failed: %w", err)
+	}
+
+	cfg.Cloud.HomeyID = homey.ID
+	cfg.Cloud.RemoteURL = homey.RemoteURL
+	cfg.Cloud.Token = homey.Token
+	cfg.Cloud.Session = true
+	return true, nil
+}
+
+// sessionRenewer returns a renewer that logs in to the Homey again when its
+// session token is rejected, refreshing the Athom account token first if it
+// has expired. The new tokens are saved to the config. It returns nil when the
+// configured token is not a renewable session (e.g. an API key).
+//
+// The renewer works on a copy of cfg, so later changes such as --format
+// overrides are not saved along with the tokens.
+func sessionRenewer(current *config.Config) client.SessionRenewer {
+	if !current.Cloud.RenewableSession() {
+		return nil
+	}
+	saved := *current
+	cfg := &saved
+
+	return func(ctx context.Context) (string, error) {
+		oldToken := cfg.EffectiveToken()
+		refreshed := false
+
+		refresh := func() error {
+			if cfg.Cloud.RefreshToken == "" {
+				return fmt.Errorf("no refresh token stored, run: homeyctl login")
+			}
+			verbosef("refreshing Athom account token")
+			tokenResp, err := refreshAccessToken(cfg.Cloud.RefreshToken)
+			if err != nil {
+				return err
+			}
+			cfg.Cloud.AccessToken = tokenResp.AccessToken
+			cfg.Cloud.RefreshToken = tokenResp.RefreshToken
+			cfg.Cloud.AccessExpiry = tokenResp.Expiry()
+			refreshed = true
+			return nil
+		}
+
+		if cfg.Cloud.AccessToken == "" || accessTokenExpired(cfg.Cloud, time.Now()) {
+			if err := refresh(); err != nil {
+				return "", err
+			}
+		}
+
+		verbosef("renewing Homey session through the cloud relay")
+		homey, err := connectCloud(cfg.Cloud.AccessToken, cfg.Cloud.HomeyID)
+		if err != nil && !refreshed && cfg.Cloud.RefreshToken != "" {
+			// The account token may have been revoked before its expiry
+			if refreshErr := refresh(); refreshErr != nil {
+				return "", refreshErr
+			}
+			homey, err = connectCloud(cfg.Cloud.AccessToken, cfg.Cloud.HomeyID)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		// Replace the session wherever it was stored
+		if cfg.Cloud.Token == oldToken {
+			cfg.Cloud.Token = homey.Token
+		}
+		if cfg.Local.Token == oldToken {
+			cfg.Local.Token = homey.Token
+		}
+		if cfg.Token == oldToken {
+			cfg.Token = homey.Token
+		}
+		if err := config.Save(cfg); err != nil {
+			verbosef("failed to save renewed session: %v", err)
+		}
+		return homey.Token, nil
+	}
+}
+
+// accessTokenExpired reports whether the account token has expired, with a
+// minute of margin. An unknown expiry is treated as valid.
+func accessTokenExpired(cloud config.CloudConfig, now time.Time) bool {
+	return !cloud.AccessExpiry.IsZero() && now.Add(time.Minute).After(cloud.AccessExpiry)
+}
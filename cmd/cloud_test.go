@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/oauth"
+)
+
+func stubConnectCloud(t *testing.T, fn func(accessToken, homeyID string) (*oauth.Homey, error)) {
+	t.Helper()
+	orig := connectCloud
+	connectCloud = fn
+	t.Cleanup(func() { connectCloud = orig })
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOMEY_SECRETS_BACKEND", "plain")
+}
+
+func TestPrepareCloud_SkipsLocalMode(t *testing.T) {
+	stubConnectCloud(t, func(string, string) (*oauth.Homey, error) {
+		t.Fatal("should not connect in local mode")
+		return nil, nil
+	})
+
+	cfg := &config.Config{Mode: "local", Local: config.LocalConfig{Address: "http://10.0.0.2", Token: "tok"}}
+	if err := prepareCloud(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPrepareCloud_UsesConfiguredToken(t *testing.T) {
+	stubConnectCloud(t, func(string, string) (*oauth.Homey, error) {
+		t.Fatal("should not connect when a token and URL are configured")
+		return nil, nil
+	})
+
+	cfg := &config.Config{Mode: "cloud", Cloud: config.CloudConfig{Token: "pat", HomeyID: "abc"}}
+	if err := prepareCloud(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.BaseURL() != "https://abc.connect.athom.com" {
+		t.Errorf("unexpected base URL: %s", cfg.BaseURL())
+	}
+}
+
+func TestPrepareCloud_LogsInWithAccountToken(t *testing.T) {
+	stubConnectCloud(t, func(accessToken, homeyID string) (*oauth.Homey, error) {
+		if accessToken != "athom-token" || homeyID != "abc" {
+			t.Errorf("unexpected args: %q %q", accessToken, homeyID)
+		}
+		return &oauth.Homey{ID: "abc", RemoteURL: "https://abc.connect.athom.com", Token: "session"}, nil
+	})
+
+	cfg := &config.Config{Mode: "cloud", Cloud: config.CloudConfig{AccessToken: "athom-token", HomeyID: "abc"}}
+	if err := prepareCloud(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.EffectiveToken() != "session" {
+		t.Errorf("expected session token, got %q", cfg.EffectiveToken())
+	}
+	if cfg.BaseURL() != "https://abc.connect.athom.com" {
+		t.Errorf("unexpected base URL: %s", cfg.BaseURL())
+	}
+}
+
+func TestPrepareCloud_Errors(t *testing.T) {
+	stubConnectCloud(t, func(string, string) (*oauth.Homey, error) {
+		return nil, errors.New("delegation refused")
+	})
+
+	cfg := &config.Config{Mode: "cloud", Cloud: config.CloudConfig{Token: "pat"}}
+	if err := prepareCloud(cfg); err == nil || !strings.Contains(err.Error(), "--homey-id") {
+		t.Errorf("expected hint about --homey-id, got %v", err)
+	}
+
+	cfg = &config.Config{Mode: "cloud", Cloud: config.CloudConfig{AccessToken: "athom-token"}}
+	if err := prepareCloud(cfg); err == nil || !strings.Contains(err.Error(), "delegation refused") {
+		t.Errorf("expected connect error, got %v", err)
+	}
+}
+
+func TestSessionRenewer_OnlyForSessions(t *testing.T) {
+	cfg := &config.Config{Mode: "cloud", Cloud: config.CloudConfig{Token: "pat", AccessToken: "athom"}}
+	if sessionRenewer(cfg) != nil {
+		t.Error("expected no renewer for an API key")
+	}
+
+	cfg.Cloud.Session = true
+	if sessionRenewer(cfg) == nil {
+		t.Error("expected renewer for a session token")
+	}
+}
+
+func TestSessionRenewer_RefreshesExpiredAccessToken(t *testing.T) {
+	stubConnectCloud(t, func(accessToken, homeyID string) (*oauth.Homey, error) {
+		if accessToken != "new-access" {
+			t.Errorf("expected refreshed access token, got %q", accessToken)
+		}
+		return &oauth.Homey{ID: homeyID, Token: "new-session"}, nil
+	})
+	origRefresh := refreshAccessToken
+	refreshAccessToken = func(refreshToken string) (*oauth.TokenResponse, error) {
+		if refreshToken != "refresh" {
+			t.Errorf("unexpected refresh token %q", refreshToken)
+		}
+		return &oauth.TokenResponse{AccessToken: "new-access", RefreshToken: "refresh-2", ExpiresIn: 3600}, nil
+	}
+	t.Cleanup(func() { refreshAccessToken = origRefresh })
+
+	cfg := &config.Config{
+		Mode: "cloud",
+		Cloud: config.CloudConfig{
+			Token:        "old-session",
+			HomeyID:      "abc",
+			AccessToken:  "old-access",
+			RefreshToken: "refresh",
+			AccessExpiry: time.Now().Add(-time.Hour),
+			Session:      true,
+		},
+	}
+
+	token, err := sessionRenewer(cfg)(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "new-session" {
+		t.Errorf("expected new session token, got %q", token)
+	}
+
+	saved, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Cloud.Token != "new-session" || saved.Cloud.RefreshToken != "refresh-2" || !saved.Cloud.Session {
+		t.Errorf("renewed tokens not saved: %+v", saved.Cloud)
+	}
+}
+
+func TestAccessTokenExpired(t *testing.T) {
+	now := time.Now()
+	if accessTokenExpired(config.CloudConfig{}, now) {
+		t.Error("unknown expiry should count as valid")
+	}
+	if !accessTokenExpired(config.CloudConfig{AccessExpiry: now.Add(30 * time.Second)}, now) {
+		t.Error("expected token expiring within a minute to count as expired")
+	}
+	if accessTokenExpired(config.CloudConfig{AccessExpiry: now.Add(time.Hour)}, now) {
+		t.Error("expected valid token")
+	}
+}
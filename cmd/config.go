@@ -1,13 +1,19 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/langtind/homeyctl/internal/config"
 	"github.com/langtind/homeyctl/internal/discovery"
+	"github.com/langtind/homeyctl/internal/render"
 	"github.com/spf13/cobra"
 )
 
@@ -27,6 +33,22 @@ func maskToken(token string) string {
 	return token
 }
 
+// activeProfileName returns the selected profile, naming the top-level settings "default"
+func activeProfileName() string {
+	if name := config.ActiveProfile(); name != "" {
+		return name
+	}
+	return config.DefaultProfile
+}
+
+// secretLocation describes where a token is stored without revealing it
+func secretLocation(c *config.Config, token string) string {
+	if token == "" {
+		return maskToken(token)
+	}
+	return "stored in " + c.SecretsLocation()
+}
+
 var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current configuration",
@@ -44,29 +66,36 @@ var configShowCmd = &cobra.Command{
 
 		if format != "table" {
 			output := map[string]interface{}{
+				"profile":       activeProfileName(),
 				"mode":          loadedCfg.Mode,
 				"effectiveMode": loadedCfg.EffectiveMode(),
 				"local": map[string]interface{}{
 					"address": loadedCfg.Local.Address,
-					"token":   maskToken(loadedCfg.Local.Token),
+					"token":   secretLocation(loadedCfg, loadedCfg.Local.Token),
 				},
 				"cloud": map[string]interface{}{
-					"token": maskToken(loadedCfg.Cloud.Token),
+					"token":     secretLocation(loadedCfg, loadedCfg.Cloud.Token),
+					"homeyId":   loadedCfg.Cloud.HomeyID,
+					"remoteUrl": loadedCfg.CloudURL(),
 				},
 				"legacy": map[string]interface{}{
 					"host":  loadedCfg.Host,
 					"port":  loadedCfg.Port,
-					"token": maskToken(loadedCfg.Token),
+					"token": secretLocation(loadedCfg, loadedCfg.Token),
 				},
-				"format": loadedCfg.Format,
+				"format":  loadedCfg.Format,
+				"secrets": loadedCfg.SecretsLocation(),
 			}
-			out, _ := json.MarshalIndent(output, "", "  ")
-			fmt.Println(string(out))
-			return nil
+			r, err := rendererFor(format)
+			if err != nil {
+				return err
+			}
+			return r.Object(output)
 		}
 
 		fmt.Println("Connection Mode")
 		fmt.Println("===============")
+		fmt.Printf("Profile:        %s\n", activeProfileName())
 		mode := loadedCfg.Mode
 		if mode == "" {
 			mode = "auto"
@@ -82,12 +111,17 @@ var configShowCmd = &cobra.Command{
 		} else {
 			fmt.Printf("Address:        (not set)\n")
 		}
-		fmt.Printf("Token:          %s\n", maskToken(loadedCfg.Local.Token))
+		fmt.Printf("Token:          %s\n", secretLocation(loadedCfg, loadedCfg.Local.Token))
 		fmt.Println()
 
 		fmt.Println("Cloud")
 		fmt.Println("-----")
-		fmt.Printf("Token:          %s\n", maskToken(loadedCfg.Cloud.Token))
+		if url := loadedCfg.CloudURL(); url != "" {
+			fmt.Printf("Remote URL:     %s\n", url)
+		} else {
+			fmt.Printf("Remote URL:     (not set)\n")
+		}
+		fmt.Printf("Token:          %s\n", secretLocation(loadedCfg, loadedCfg.Cloud.Token))
 		fmt.Println()
 
 		// Show legacy if set
@@ -96,7 +130,7 @@ var configShowCmd = &cobra.Command{
 			fmt.Println("-------------------")
 			fmt.Printf("Host:           %s\n", loadedCfg.Host)
 			fmt.Printf("Port:           %d\n", loadedCfg.Port)
-			fmt.Printf("Token:          %s\n", maskToken(loadedCfg.Token))
+			fmt.Printf("Token:          %s\n", secretLocation(loadedCfg, loadedCfg.Token))
 		}
 
 		return nil
@@ -173,7 +207,11 @@ var configSetModeCmd = &cobra.Command{
 	Long: `Set the connection mode for Homey.
 
 Modes:
-  auto  - Prefer local if configured, fallback to cloud (default)
+  auto  - Use local when the Homey answers there, otherwise cloud (default).
+          The local address is pinged with a short timeout and the choice
+          is cached for a few minutes (see auto.probe_timeout and
+          auto.cache_ttl in config.toml). Use --verbose to see which
+          transport was picked.
   local - Always use local connection (LAN/VPN)
   cloud - Always use cloud connection
 
@@ -200,6 +238,10 @@ Examples:
 		}
 
 		fmt.Printf("Mode set to: %s\n", mode)
+		if mode == "cloud" && cfg.CloudURL() == "" && cfg.Cloud.AccessToken == "" {
+			fmt.Println("Note: no Homey selected for cloud mode. Run: homeyctl login")
+			fmt.Println("  or: homeyctl config set-cloud <token> --homey-id <id>")
+		}
 		return nil
 	},
 }
@@ -238,37 +280,72 @@ Examples:
 	},
 }
 
+var (
+	cloudHomeyIDFlag string
+	cloudURLFlag     string
+)
+
 var configSetCloudCmd = &cobra.Command{
-	Use:   "set-cloud <token>",
+	Use:   "set-cloud [token]",
 	Short: "Set cloud token",
-	Long: `Set the cloud token (PAT) for remote Homey access.
+	Long: `Set the cloud token (PAT) and Homey for remote access.
+
+Cloud mode sends all requests through the Athom cloud relay
+(https://<homey-id>.connect.athom.com), so it works when the Homey is not
+reachable on your network. 'homeyctl login' sets this up automatically.
 
 Create a cloud token at:
   https://my.homey.app → Select Homey → Settings → API Keys
 
+The Homey ID is shown in 'homeyctl config discover' or on
+https://my.homey.app in the address bar.
+
 Examples:
-  homeyctl config set-cloud "your-cloud-token"`,
-	Args: cobra.ExactArgs(1),
+  homeyctl config set-cloud "your-cloud-token" --homey-id 5f1a2b3c4d5e6f7a8b9c0d1e
+  homeyctl config set-cloud --url https://5f1a2b3c4d5e6f7a8b9c0d1e.connect.athom.com`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		token := args[0]
+		if len(args) == 0 && cloudHomeyIDFlag == "" && cloudURLFlag == "" {
+			return fmt.Errorf("nothing to set: provide a token, --homey-id or --url")
+		}
 
 		cfg, err := config.Load()
 		if err != nil {
 			cfg = &config.Config{}
 		}
 
-		cfg.Cloud.Token = token
+		if len(args) == 1 {
+			cfg.Cloud.Token = args[0]
+			fmt.Println("Cloud token saved")
+		}
+		if cloudHomeyIDFlag != "" {
+			cfg.Cloud.HomeyID = cloudHomeyIDFlag
+			cfg.Cloud.RemoteURL = ""
+		}
+		if cloudURLFlag != "" {
+			cfg.Cloud.RemoteURL = cloudURLFlag
+		}
 
 		if err := config.Save(cfg); err != nil {
 			return err
 		}
 
-		fmt.Println("Cloud token saved")
+		if url := cfg.CloudURL(); url != "" {
+			fmt.Printf("Cloud URL set to: %s\n", url)
+		}
 		return nil
 	},
 }
 
-var discoverTimeout int
+var (
+	discoverTimeout     int
+	discoverWatch       bool
+	discoverInterface   string
+	discoverScan        string
+	discoverScanWorkers int
+	discoverScanRate    int
+	discoverSave        bool
+)
 
 var configDiscoverCmd = &cobra.Command{
 	Use:   "discover",
@@ -280,17 +357,35 @@ and verifies them by checking the ping endpoint.
 
 Returns JSON array with discovered devices (easy for AI/scripts to parse).
 
+Queries go out over IPv4 and IPv6 on every network interface, or only on the
+one given with --interface.
+
+With --watch, keeps listening until Ctrl-C and prints a line each time a
+Homey appears or disappears (one JSON object per line, or text with
+--format table).
+
+When multicast doesn't reach the Homey (e.g. it is on another VLAN), use
+--scan to probe every host in a subnet on ports 80, 443 and 4859 instead.
+
+With --format table you are offered to save a found Homey as the local
+address; --save does so without asking when exactly one is found.
+
 Examples:
   homeyctl config discover
-  homeyctl config discover --timeout 10`,
+  homeyctl config discover --timeout 10
+  homeyctl config discover --interface en0
+  homeyctl config discover --watch
+  homeyctl config discover --scan 192.168.10.0/24
+  homeyctl config discover --scan 192.168.10.0/24 --save`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		timeout := time.Duration(discoverTimeout) * time.Second
-		ctx, cancel := context.WithTimeout(context.Background(), timeout+2*time.Second)
-		defer cancel()
-
-		// Load config to check format (config commands skip PersistentPreRunE)
-		loadedCfg, err := config.Load()
-		if err != nil {
+		// Load config to check format (config commands skip PersistentPreRunE).
+		// A config that didn't load is only good for display: saving it would
+		// overwrite the user's file.
+		loadedCfg, loadErr := config.Load()
+		if loadErr != nil {
+			if discoverSave {
+				return fmt.Errorf("failed to load config: %w", loadErr)
+			}
 			loadedCfg = &config.Config{Format: "json"}
 		}
 
@@ -300,11 +395,11 @@ Examples:
 		}
 		useTable := format == "table"
 
-		if useTable {
-			fmt.Printf("Searching for Homey devices (timeout: %ds)...\n", discoverTimeout)
+		if discoverWatch {
+			return watchDiscovery(cmd.Context(), useTable)
 		}
 
-		candidates, err := discovery.DiscoverAndVerify(ctx, timeout)
+		candidates, err := findHomeys(cmd.Context(), useTable)
 		if err != nil {
 			return fmt.Errorf("discovery failed: %w", err)
 		}
@@ -318,10 +413,24 @@ Examples:
 					"homeyId": c.HomeyID,
 					"host":    c.Host,
 					"port":    c.Port,
+					"name":    c.Name,
 				}
 			}
-			out, _ := json.MarshalIndent(result, "", "  ")
-			fmt.Println(string(out))
+			r, err := rendererFor(format)
+			if err != nil {
+				return err
+			}
+			err = r.List(result, []render.Column{
+				{Header: "NAME", Path: ".name"},
+				{Header: "ADDRESS", Path: ".address"},
+				{Header: "HOMEY ID", Path: ".homeyId"},
+			})
+			if err != nil {
+				return err
+			}
+			if discoverSave {
+				return saveDiscovered(loadedCfg, candidates, os.Stdin, os.Stderr, false)
+			}
 			return nil
 		}
 
@@ -331,6 +440,7 @@ Examples:
 			fmt.Println("\nTips:")
 			fmt.Println("  - Make sure you're on the same network as your Homey")
 			fmt.Println("  - Try increasing timeout: --timeout 10")
+			fmt.Println("  - If multicast is blocked, scan the subnet: --scan 192.168.1.0/24")
 			fmt.Println("  - Set address manually: homeyctl config set-local <address> <token>")
 			return nil
 		}
@@ -339,11 +449,22 @@ Examples:
 
 		for i, c := range candidates {
 			fmt.Printf("  [%d] %s\n", i+1, c.Address)
+			if c.Name != "" {
+				fmt.Printf("      Name:     %s\n", c.Name)
+			}
 			if c.HomeyID != "" {
 				fmt.Printf("      Homey ID: %s\n", c.HomeyID)
 			}
 		}
 
+		if discoverSave || isInteractive(os.Stdin) {
+			if loadErr != nil {
+				return fmt.Errorf("failed to load config, so the address can't be saved: %w", loadErr)
+			}
+			fmt.Println()
+			return saveDiscovered(loadedCfg, candidates, os.Stdin, os.Stdout, !discoverSave)
+		}
+
 		fmt.Println("\nTo use a discovered Homey:")
 		fmt.Println("  homeyctl config set-local <address> <your-api-key>")
 
@@ -351,6 +472,122 @@ Examples:
 	},
 }
 
+// findHomeys runs an mDNS search, or a subnet scan with --scan
+func findHomeys(ctx context.Context, useTable bool) ([]discovery.HomeyCandidate, error) {
+	if discoverScan != "" {
+		opts := discovery.ScanOptions{Workers: discoverScanWorkers, Rate: discoverScanRate}
+		if useTable {
+			fmt.Printf("Scanning %s for Homey devices...\n", discoverScan)
+			if isInteractive(os.Stderr) {
+				opts.Progress = func(done, total int) {
+					fmt.Fprintf(os.Stderr, "\r  %d/%d hosts", done, total)
+					if done == total {
+						fmt.Fprintln(os.Stderr)
+					}
+				}
+			}
+		}
+		return discovery.Scan(ctx, discoverScan, opts)
+	}
+
+	timeout := time.Duration(discoverTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout+2*time.Second)
+	defer cancel()
+
+	if useTable {
+		fmt.Printf("Searching for Homey devices (timeout: %ds)...\n", discoverTimeout)
+	}
+
+	return discovery.DiscoverAndVerify(ctx, discovery.Options{
+		Timeout:   timeout,
+		Interface: discoverInterface,
+	})
+}
+
+// saveDiscovered saves a found Homey as the local address, keeping the
+// local token. With ask, the user picks one (or none); otherwise there must
+// be exactly one.
+func saveDiscovered(loadedCfg *config.Config, candidates []discovery.HomeyCandidate, in io.Reader, out io.Writer, ask bool) error {
+	var chosen *discovery.HomeyCandidate
+	switch {
+	case ask:
+		chosen = promptCandidate(candidates, in, out)
+		if chosen == nil {
+			return nil
+		}
+	case len(candidates) == 1:
+		chosen = &candidates[0]
+	case len(candidates) == 0:
+		return fmt.Errorf("no Homey found to save")
+	default:
+		return fmt.Errorf("found %d Homeys, save one with: homeyctl config set-local <address> <token>", len(candidates))
+	}
+
+	loadedCfg.Local.Address = chosen.Address
+	if err := config.Save(loadedCfg); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Local address set to: %s\n", chosen.Address)
+	if loadedCfg.Local.Token == "" {
+		fmt.Fprintln(out, "No local token yet. Run: homeyctl login")
+	}
+	return nil
+}
+
+// promptCandidate asks which Homey to save; empty input skips
+func promptCandidate(candidates []discovery.HomeyCandidate, in io.Reader, out io.Writer) *discovery.HomeyCandidate {
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "Save as the local address? [1-%d, Enter to skip]: ", len(candidates))
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return nil
+		}
+		if n, convErr := strconv.Atoi(line); convErr == nil && n >= 1 && n <= len(candidates) {
+			return &candidates[n-1]
+		}
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintln(out, "Invalid choice.")
+	}
+}
+
+// watchDiscovery prints Homeys as they appear and disappear until ctx is done
+func watchDiscovery(ctx context.Context, useTable bool) error {
+	events, err := discovery.Watch(ctx, discovery.Options{Interface: discoverInterface})
+	if err != nil {
+		return fmt.Errorf("discovery failed: %w", err)
+	}
+
+	if useTable {
+		fmt.Fprintln(os.Stderr, "Watching for Homey devices, press Ctrl-C to stop")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for event := range events {
+		if !useTable {
+			if err := enc.Encode(event); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sign := "+"
+		if event.Type == discovery.Disappeared {
+			sign = "-"
+		}
+		name := event.Homey.Name
+		if name == "" {
+			name = event.Homey.Instance
+		}
+		fmt.Printf("%s  %s %-24s %s\n", event.Time.Format("15:04:05"), sign, name, event.Homey.Address)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configShowCmd)
@@ -359,6 +596,14 @@ func init() {
 	configCmd.AddCommand(configSetModeCmd)
 	configCmd.AddCommand(configSetLocalCmd)
 	configCmd.AddCommand(configSetCloudCmd)
+	configSetCloudCmd.Flags().StringVar(&cloudHomeyIDFlag, "homey-id", "", "Homey ID to reach through the cloud relay")
+	configSetCloudCmd.Flags().StringVar(&cloudURLFlag, "url", "", "Cloud relay URL (overrides --homey-id)")
 	configCmd.AddCommand(configDiscoverCmd)
 	configDiscoverCmd.Flags().IntVar(&discoverTimeout, "timeout", 5, "Discovery timeout in seconds")
+	configDiscoverCmd.Flags().BoolVar(&discoverWatch, "watch", false, "Keep listening and report Homeys as they appear and disappear")
+	configDiscoverCmd.Flags().StringVar(&discoverInterface, "interface", "", "Network interface to search on (default: all)")
+	configDiscoverCmd.Flags().StringVar(&discoverScan, "scan", "", "Probe every host in a subnet instead of using mDNS (e.g. 192.168.10.0/24)")
+	configDiscoverCmd.Flags().IntVar(&discoverScanWorkers, "scan-workers", 64, "Hosts probed at once with --scan")
+	configDiscoverCmd.Flags().IntVar(&discoverScanRate, "scan-rate", 200, "Maximum probes per second with --scan (1-10000)")
+	configDiscoverCmd.Flags().BoolVar(&discoverSave, "save", false, "Save the Homey found as the local address (exactly one must be found)")
 }
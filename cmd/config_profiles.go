@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var configProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Manage Homey profiles",
+	Long: `Manage named profiles, one per Homey.
+
+Each profile has its own mode, local and cloud settings and output format.
+The top-level settings in config.toml form the "default" profile.
+
+Select a profile for one command with --profile or HOMEY_PROFILE, or save
+it as the default with 'homeyctl config profiles use'.
+
+Examples:
+  homeyctl config profiles add cabin --mode cloud --cloud-token <token> --homey-id <id>
+  homeyctl config profiles use cabin
+  homeyctl devices list --profile home`,
+}
+
+// profileSummary describes a profile in 'profiles list'
+type profileSummary struct {
+	Name    string `json:"name"`
+	Mode    string `json:"mode"`
+	Address string `json:"address,omitempty"`
+	Active  bool   `json:"active"`
+}
+
+// profileAddress returns the address shown for a profile
+func profileAddress(p config.Profile) string {
+	cloudCfg := config.Config{Cloud: p.Cloud}
+	if p.Mode == "cloud" || p.Local.Address == "" {
+		return cloudCfg.CloudURL()
+	}
+	return p.Local.Address
+}
+
+// loadProfilesConfig loads config.toml for the profile commands without
+// applying the selected profile, so a --profile or HOMEY_PROFILE naming a
+// profile that doesn't exist yet doesn't stop it from being created. It
+// returns the top-level settings and the selected profile's name.
+func loadProfilesConfig(cmd *cobra.Command) (*config.Config, string, error) {
+	cmd.SilenceUsage = true
+
+	config.UseProfile(config.DefaultProfile)
+	loadedCfg, err := config.Load()
+	config.UseProfile(profileFlag)
+	if err != nil {
+		return nil, "", err
+	}
+	return loadedCfg, config.ActiveProfile(), nil
+}
+
+var configProfilesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loadedCfg, active, err := loadProfilesConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		// loadedCfg holds the top-level settings, which form the default profile
+		defaultMode := loadedCfg.Mode
+		if defaultMode == "" {
+			defaultMode = "auto"
+		}
+		defaultAddress := ""
+		if loadedCfg.HasLocal() {
+			defaultAddress = loadedCfg.LocalURL()
+		} else {
+			defaultAddress = loadedCfg.CloudURL()
+		}
+
+		summaries := []profileSummary{{
+			Name:    config.DefaultProfile,
+			Mode:    defaultMode,
+			Address: defaultAddress,
+			Active:  active == "",
+		}}
+		for _, name := range loadedCfg.ProfileNames() {
+			p := loadedCfg.Profiles[name]
+			mode := p.Mode
+			if mode == "" {
+				mode = "auto"
+			}
+			summaries = append(summaries, profileSummary{
+				Name:    name,
+				Mode:    mode,
+				Address: profileAddress(p),
+				Active:  name == active,
+			})
+		}
+
+		format := formatFlag
+		if format == "" {
+			format = loadedCfg.Format
+			if p := loadedCfg.Profiles[active]; p.Format != "" {
+				format = p.Format
+			}
+		}
+
+		r, err := rendererFor(format)
+		if err != nil {
+			return err
+		}
+		return r.List(summaries, []render.Column{
+			{Header: "", Value: func(p map[string]interface{}) interface{} {
+				if active, _ := p["active"].(bool); active {
+					return "*"
+				}
+				return ""
+			}},
+			{Header: "NAME", Path: ".name"},
+			{Header: "MODE", Path: ".mode"},
+			{Header: "ADDRESS", Path: ".address"},
+		})
+	},
+}
+
+var (
+	profileModeFlag         string
+	profileFormatFlag       string
+	profileLocalAddressFlag string
+	profileLocalTokenFlag   string
+	profileCloudTokenFlag   string
+	profileHomeyIDFlag      string
+	profileUseFlag          bool
+)
+
+var configProfilesAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add or replace a profile",
+	Long: `Add a profile, or replace an existing one with the same name.
+
+Examples:
+  homeyctl config profiles add home --local-address http://192.168.1.50 --local-token <token>
+  homeyctl config profiles add cabin --mode cloud --cloud-token <token> --homey-id <id>
+  homeyctl config profiles add office --local-address http://10.0.0.5 --local-token <token> --use`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(args[0])
+		if err := config.ValidateProfileName(name); err != nil {
+			return err
+		}
+
+		mode := profileModeFlag
+		if mode == "" {
+			mode = "auto"
+		}
+		if mode != "auto" && mode != "local" && mode != "cloud" {
+			return fmt.Errorf("invalid mode: %s (must be auto, local, or cloud)", mode)
+		}
+
+		// Load first so the rest of config.toml is kept when saving
+		if _, _, err := loadProfilesConfig(cmd); err != nil {
+			return err
+		}
+
+		p := config.Profile{
+			Mode:   mode,
+			Format: profileFormatFlag,
+			Local: config.LocalConfig{
+				Address: profileLocalAddressFlag,
+				Token:   profileLocalTokenFlag,
+			},
+			Cloud: config.CloudConfig{
+				Token:   profileCloudTokenFlag,
+				HomeyID: profileHomeyIDFlag,
+			},
+		}
+		if err := config.SaveProfile(name, p); err != nil {
+			return err
+		}
+		fmt.Printf("Profile %s saved\n", name)
+
+		if profileUseFlag {
+			if err := config.SetDefaultProfile(name); err != nil {
+				return err
+			}
+			fmt.Printf("Now using profile: %s\n", name)
+		}
+		return nil
+	},
+}
+
+var configProfilesUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the default profile",
+	Long: `Set the profile used when --profile and HOMEY_PROFILE are not given.
+
+Use "default" to go back to the top-level settings.
+
+Examples:
+  homeyctl config profiles use cabin
+  homeyctl config profiles use default`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(args[0])
+
+		loadedCfg, _, err := loadProfilesConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if _, ok := loadedCfg.Profiles[name]; !ok && name != config.DefaultProfile {
+			return fmt.Errorf("unknown profile %q (see: homeyctl config profiles list)", name)
+		}
+
+		if err := config.SetDefaultProfile(name); err != nil {
+			return err
+		}
+
+		fmt.Printf("Now using profile: %s\n", name)
+		return nil
+	},
+}
+
+var configProfilesRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a profile",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(args[0])
+
+		loadedCfg, _, err := loadProfilesConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if _, ok := loadedCfg.Profiles[name]; !ok {
+			return fmt.Errorf("unknown profile %q (see: homeyctl config profiles list)", name)
+		}
+
+		if err := config.RemoveProfile(name); err != nil {
+			return err
+		}
+
+		fmt.Printf("Profile %s removed\n", name)
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configProfilesCmd)
+	configProfilesCmd.AddCommand(configProfilesListCmd)
+	configProfilesCmd.AddCommand(configProfilesAddCmd)
+	configProfilesCmd.AddCommand(configProfilesUseCmd)
+	configProfilesCmd.AddCommand(configProfilesRemoveCmd)
+
+	configProfilesAddCmd.Flags().StringVar(&profileModeFlag, "mode", "auto", "Connection mode: auto, local, cloud")
+	configProfilesAddCmd.Flags().StringVar(&profileFormatFlag, "output-format", "", "Output format for this profile: json, table")
+	configProfilesAddCmd.Flags().StringVar(&profileLocalAddressFlag, "local-address", "", "Local URL, e.g. http://192.168.1.50")
+	configProfilesAddCmd.Flags().StringVar(&profileLocalTokenFlag, "local-token", "", "Local API key")
+	configProfilesAddCmd.Flags().StringVar(&profileCloudTokenFlag, "cloud-token", "", "Cloud API key")
+	configProfilesAddCmd.Flags().StringVar(&profileHomeyIDFlag, "homey-id", "", "Homey ID for the cloud relay")
+	configProfilesAddCmd.Flags().BoolVar(&profileUseFlag, "use", false, "Make this the default profile")
+}
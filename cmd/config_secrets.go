@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/langtind/homeyctl/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configSecretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage where tokens are stored",
+	Long: `Manage where tokens are stored.
+
+Backends:
+  keyring  - In the system secret store: Secret Service (libsecret) on
+             Linux, Keychain on macOS, Credential Manager on Windows
+  file     - In secrets.age next to config.toml, encrypted with age
+  plain    - In config.toml, readable only by your user
+
+Without a configured backend, tokens go to the keyring. If there is no
+keyring, they go to the file backend when a passphrase or age key is set,
+and otherwise to config.toml with a warning. Tokens left in config.toml are
+moved when the config is next saved.
+
+The file backend needs a passphrase in HOMEY_SECRETS_PASSPHRASE, or an age
+identity file (create one with age-keygen) set with --age-key-file or
+HOMEY_AGE_KEY_FILE.
+
+HOMEY_SECRETS_BACKEND overrides the configured backend.
+
+Examples:
+  homeyctl config secrets use keyring
+  HOMEY_SECRETS_PASSPHRASE=... homeyctl config secrets use file
+  homeyctl config secrets use file --age-key-file ~/.config/homeyctl/key.txt`,
+}
+
+var secretsAgeKeyFileFlag string
+
+var configSecretsUseCmd = &cobra.Command{
+	Use:   "use <plain|keyring|file>",
+	Short: "Move all tokens to another backend",
+	Long: `Move all tokens, for every profile, to another backend and use it from now on.
+
+Tokens are removed from the old location once they are stored in the new one.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backend := args[0]
+
+		// Load first so the rest of config.toml is kept when saving
+		if _, err := config.Load(); err != nil {
+			return err
+		}
+
+		if secretsAgeKeyFileFlag != "" {
+			if err := config.SetAgeKeyFile(secretsAgeKeyFileFlag); err != nil {
+				return err
+			}
+		}
+
+		if err := config.MigrateSecrets(backend); err != nil {
+			return fmt.Errorf("failed to move tokens: %w", err)
+		}
+
+		loadedCfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Tokens are now stored in %s\n", loadedCfg.SecretsLocation())
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configSecretsCmd)
+	configSecretsCmd.AddCommand(configSecretsUseCmd)
+	configSecretsUseCmd.Flags().StringVar(&secretsAgeKeyFileFlag, "age-key-file", "", "age identity file for the file backend")
+}
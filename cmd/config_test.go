@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
 	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/discovery"
 )
 
 func TestMaskToken_Empty(t *testing.T) {
@@ -83,3 +91,101 @@ func TestIsTableFormat_EmptyFormat(t *testing.T) {
 		t.Error("isTableFormat() with empty format should return false (default to json)")
 	}
 }
+
+func TestSaveDiscovered(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOMEY_SECRETS_BACKEND", "plain")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	candidates := []discovery.HomeyCandidate{
+		{Address: "http://192.168.10.5:80", Host: "192.168.10.5", Port: 80},
+		{Address: "http://192.168.10.20:4859", Host: "192.168.10.20", Port: 4859},
+	}
+	loadedCfg := &config.Config{Local: config.LocalConfig{Address: "http://old", Token: "tok"}}
+
+	// Enter skips
+	var out bytes.Buffer
+	if err := saveDiscovered(loadedCfg, candidates, strings.NewReader("\n"), &out, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loadedCfg.Local.Address != "http://old" {
+		t.Errorf("expected no change when skipped, got %s", loadedCfg.Local.Address)
+	}
+
+	out.Reset()
+	if err := saveDiscovered(loadedCfg, candidates, strings.NewReader("x\n2\n"), &out, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Invalid choice") {
+		t.Errorf("expected invalid choice to be reported, got %q", out.String())
+	}
+
+	saved, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved.Local.Address != "http://192.168.10.20:4859" || saved.Local.Token != "tok" {
+		t.Errorf("expected new address with the old token, got %s / %s", saved.Local.Address, saved.Local.Token)
+	}
+
+	// Without asking, exactly one Homey must be found
+	if err := saveDiscovered(loadedCfg, candidates, nil, &out, false); err == nil {
+		t.Error("expected an error when several Homeys are found")
+	}
+	if err := saveDiscovered(loadedCfg, candidates[:1], nil, &out, false); err != nil || loadedCfg.Local.Address != "http://192.168.10.5:80" {
+		t.Errorf("expected the only Homey to be saved, got %s (%v)", loadedCfg.Local.Address, err)
+	}
+}
+
+func TestConfigDiscover_SaveWithBrokenConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(dir, "homeyctl", "config.toml")
+	broken := []byte("token = 'keep-me'\n[local\n")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, broken, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	old := discoverSave
+	discoverSave = true
+	t.Cleanup(func() { discoverSave = old })
+
+	if err := configDiscoverCmd.RunE(configDiscoverCmd, nil); err == nil || !strings.Contains(err.Error(), "failed to load config") {
+		t.Errorf("expected the load error, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, broken) {
+		t.Errorf("expected the config to be left alone, got %q", data)
+	}
+}
+
+func TestConfigProfiles_SelectedProfileMissing(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOMEY_SECRETS_BACKEND", "plain")
+	t.Setenv("HOMEY_PROFILE", "office")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	for _, c := range []struct {
+		cmd  *cobra.Command
+		args []string
+	}{
+		{configProfilesListCmd, nil},
+		{configProfilesAddCmd, []string{"office"}},
+		{configProfilesUseCmd, []string{"office"}},
+		{configProfilesRemoveCmd, []string{"office"}},
+	} {
+		if err := c.cmd.RunE(c.cmd, c.args); err != nil {
+			t.Errorf("%s with HOMEY_PROFILE naming a missing profile: %v", c.cmd.Name(), err)
+		}
+		if !c.cmd.SilenceUsage {
+			t.Errorf("%s: expected the usage to be silenced", c.cmd.Name())
+		}
+	}
+}
@@ -5,17 +5,14 @@ import (
 	"fmt"
 	"os"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
 // Dashboard represents a Homey dashboard
-type Dashboard struct {
-	ID      string        `json:"id"`
-	Name    string        `json:"name"`
-	Columns []interface{} `json:"columns"`
-}
+type Dashboard = homey.Dashboard
 
 var dashboardsCmd = &cobra.Command{
 	Use:   "dashboards",
@@ -63,19 +60,13 @@ var dashboardsListCmd = &cobra.Command{
 				fmt.Println("No dashboards found.")
 				return nil
 			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tCOLUMNS\tID")
-			fmt.Fprintln(w, "----\t-------\t--")
-			for _, d := range dashboards {
-				fmt.Fprintf(w, "%s\t%d\t%s\n", d.Name, len(d.Columns), d.ID)
-			}
-			w.Flush()
-			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printList(data, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "COLUMNS", Value: count("columns")},
+			{Header: "ID", Path: ".id"},
+		})
 	},
 }
 
@@ -94,8 +85,7 @@ var dashboardsGetCmd = &cobra.Command{
 			return err
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -150,8 +140,7 @@ Examples:
 			return nil
 		}
 
-		outputJSON(result)
-		return nil
+		return printOutput(result)
 	},
 }
 
@@ -3,28 +3,22 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"sort"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
 // Device represents a Homey device
-type Device struct {
-	ID              string                `json:"id"`
-	Name            string                `json:"name"`
-	Class           string                `json:"class"`
-	Zone            string                `json:"zone"`
-	CapabilitiesObj map[string]Capability `json:"capabilitiesObj"`
-}
+type Device = homey.Device
 
 // Capability represents a device capability
-type Capability struct {
-	ID    string      `json:"id"`
-	Value interface{} `json:"value"`
-	Title string      `json:"title"`
-}
+type Capability = homey.Capability
+
+// CapabilityValue is one of the values of an enum capability
+type CapabilityValue = homey.EnumValue
 
 var devicesCmd = &cobra.Command{
 	Use:   "devices",
@@ -83,20 +77,16 @@ Examples:
 			}
 		}
 
-		if isTableFormat() {
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tCLASS\tID")
-			fmt.Fprintln(w, "----\t-----\t--")
-			for _, d := range filtered {
-				fmt.Fprintf(w, "%s\t%s\t%s\n", d.Name, d.Class, d.ID)
-			}
-			w.Flush()
-			return nil
-		}
+		sort.Slice(filtered, func(i, j int) bool {
+			return strings.ToLower(filtered[i].Name) < strings.ToLower(filtered[j].Name)
+		})
 
-		out, _ := json.MarshalIndent(filtered, "", "  ")
-		fmt.Println(string(out))
-		return nil
+		return printList(filtered, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "CLASS", Path: ".class"},
+			{Header: "ID", Path: ".id"},
+			zoneColumn(),
+		})
 	},
 }
 
@@ -110,70 +100,119 @@ var devicesGetCmd = &cobra.Command{
 			return err
 		}
 
-		if isTableFormat() {
+		if showTitles() {
 			fmt.Printf("Name:  %s\n", device.Name)
 			fmt.Printf("Class: %s\n", device.Class)
 			fmt.Printf("ID:    %s\n", device.ID)
 			fmt.Println("\nCapabilities:")
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "  CAPABILITY\tVALUE")
-			fmt.Fprintln(w, "  ----------\t-----")
-			for _, cap := range device.CapabilitiesObj {
-				fmt.Fprintf(w, "  %s\t%v\n", cap.ID, cap.Value)
-			}
-			w.Flush()
-			return nil
 		}
 
-		out, _ := json.MarshalIndent(device, "", "  ")
-		fmt.Println(string(out))
-		return nil
+		return printRows(device, capabilityRows(*device), capabilityColumns)
 	},
 }
 
+// capabilityRow is a capability value in table output
+type capabilityRow struct {
+	Device     string      `json:"device,omitempty"`
+	Capability string      `json:"capability"`
+	Value      interface{} `json:"value"`
+	Units      string      `json:"units,omitempty"`
+}
+
+var capabilityColumns = []render.Column{
+	{Header: "CAPABILITY", Path: ".capability"},
+	{Header: "VALUE", Path: ".value"},
+	{Header: "UNITS", Path: ".units", Wide: true},
+}
+
+// capabilityRows lists a device's capability values, sorted by capability
+func capabilityRows(device Device) []capabilityRow {
+	rows := make([]capabilityRow, 0, len(device.CapabilitiesObj))
+	for id, c := range device.CapabilitiesObj {
+		rows = append(rows, capabilityRow{Capability: id, Value: c.Value, Units: c.Units})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Capability < rows[j].Capability })
+	return rows
+}
+
 var devicesValuesCmd = &cobra.Command{
-	Use:   "values <name-or-id>",
+	Use:   "values [<name-or-id>]",
 	Short: "Get all capability values for a device",
 	Long: `Get all current capability values for a device.
 
 Useful for multi-sensors and devices with many capabilities.
+` + selectorHelp + `
+With --capability only that value is shown, also for a named device.
 
 Examples:
   homeyctl devices values "PultLED"
-  homeyctl devices values "Multisensor 6"`,
-	Args: cobra.ExactArgs(1),
+  homeyctl devices values "Multisensor 6"
+  homeyctl devices values "Multisensor 6" --capability measure_luminance
+  homeyctl devices values --capability measure_temperature`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		device, err := findDevice(args[0])
+		devices, many, err := resolveDevices(args, "")
 		if err != nil {
 			return err
 		}
+		if many {
+			return printSelectedValues(devices)
+		}
+		device := devices[0]
 
-		if isTableFormat() {
+		if showTitles() {
 			fmt.Printf("Values for %s:\n\n", device.Name)
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "CAPABILITY\tVALUE")
-			fmt.Fprintln(w, "----------\t-----")
-			for _, cap := range device.CapabilitiesObj {
-				fmt.Fprintf(w, "%s\t%v\n", cap.ID, cap.Value)
-			}
-			w.Flush()
-			return nil
 		}
 
 		// JSON output - just the values
-		values := make(map[string]interface{})
-		for _, cap := range device.CapabilitiesObj {
+		values := deviceValues(device, devicesSelector.capability)
+		return printRows(values, valueRows(values), capabilityColumns)
+	},
+}
+
+// deviceValues maps a device's capabilities to their values, or just the
+// one capability if given
+func deviceValues(device Device, capability string) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, cap := range device.CapabilitiesObj {
+		if capability == "" || cap.ID == capability {
 			values[cap.ID] = cap.Value
 		}
-		out, _ := json.MarshalIndent(map[string]interface{}{
-			"id":     device.ID,
-			"name":   device.Name,
-			"values": values,
-		}, "", "  ")
-		fmt.Println(string(out))
-		return nil
-	},
+	}
+	return map[string]interface{}{
+		"id":     device.ID,
+		"name":   device.Name,
+		"values": values,
+	}
+}
+
+// valueRows lists the values from deviceValues, sorted by capability
+func valueRows(values map[string]interface{}) []capabilityRow {
+	byCapability := values["values"].(map[string]interface{})
+	rows := make([]capabilityRow, 0, len(byCapability))
+	for id, value := range byCapability {
+		rows = append(rows, capabilityRow{Device: values["name"].(string), Capability: id, Value: value})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Capability < rows[j].Capability })
+	return rows
+}
+
+// printSelectedValues prints the values of several devices, one row per
+// device and capability in table format
+func printSelectedValues(devices []Device) error {
+	var list []map[string]interface{}
+	var rows []capabilityRow
+	for _, d := range devices {
+		values := deviceValues(d, devicesSelector.capability)
+		list = append(list, values)
+		rows = append(rows, valueRows(values)...)
+	}
+
+	return printRows(list, rows, []render.Column{
+		{Header: "DEVICE", Path: ".device"},
+		{Header: "CAPABILITY", Path: ".capability"},
+		{Header: "VALUE", Path: ".value"},
+	})
 }
 
 func init() {
@@ -182,4 +221,5 @@ func init() {
 	devicesListCmd.Flags().StringVar(&devicesMatchFilter, "match", "", "Filter devices by name (case-insensitive)")
 	devicesCmd.AddCommand(devicesGetCmd)
 	devicesCmd.AddCommand(devicesValuesCmd)
+	addDeviceSelectorFlags(devicesValuesCmd, false)
 }
@@ -2,6 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -15,99 +19,269 @@ func parseValue(valueStr string) interface{} {
 		return false
 	}
 
-	// Try as number
-	var num float64
-	if _, err := fmt.Sscanf(valueStr, "%f", &num); err == nil {
+	// Try as number; the whole string must parse, so "1e3abc" stays a string
+	if num, err := strconv.ParseFloat(valueStr, 64); err == nil {
 		return num
 	}
 
 	return valueStr
 }
 
+// capabilityValue validates a value given on the command line against the
+// capability's definition and converts it to the type Homey expects.
+// Capabilities without a known type fall back to parseValue; only those with
+// a type come with a definition that says whether they are setable.
+func capabilityValue(c Capability, input string) (interface{}, error) {
+	if c.Type != "" && !c.Setable {
+		return nil, fmt.Errorf("%s is read-only", c.ID)
+	}
+
+	input = strings.TrimSpace(input)
+	switch c.Type {
+	case "boolean":
+		switch strings.ToLower(input) {
+		case "true", "on", "yes", "1":
+			return true, nil
+		case "false", "off", "no", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid value %q for %s; valid values: true, false", input, c.ID)
+	case "enum":
+		var ids []string
+		for _, v := range c.Values {
+			if strings.EqualFold(v.ID, input) {
+				return v.ID, nil
+			}
+			ids = append(ids, v.ID)
+		}
+		return nil, fmt.Errorf("invalid value %q for %s; valid values: %s", input, c.ID, strings.Join(ids, ", "))
+	case "number":
+		return numberValue(c, input)
+	case "string":
+		return input, nil
+	}
+	return parseValue(input), nil
+}
+
+// numberValue parses a number, a percentage of the capability's range
+// ("50%") or a temperature in another unit ("72°F"), then rounds it to the
+// capability's step and checks its range
+func numberValue(c Capability, input string) (float64, error) {
+	text, unit := splitUnit(input)
+	num, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for %s; expected a number%s", input, c.ID, rangeHint(c))
+	}
+
+	switch {
+	case unit == "" || unit == c.Units:
+	case unit == "%":
+		if c.Min == nil || c.Max == nil {
+			return 0, fmt.Errorf("%s has no range, so it can't be set as a percentage", c.ID)
+		}
+		num = *c.Min + (*c.Max-*c.Min)*num/100
+	case isTemperature(unit) && isTemperature(c.Units):
+		num = convertTemperature(num, unit, c.Units)
+	default:
+		units := c.Units
+		if units == "" {
+			units = "no units"
+		}
+		return 0, fmt.Errorf("can't set %s in %s (it uses %s)", c.ID, unit, units)
+	}
+
+	if c.Step != nil && *c.Step > 0 {
+		step, base := *c.Step, 0.0
+		if c.Min != nil {
+			base = *c.Min
+		}
+		num = base + math.Round((num-base)/step)*step
+	}
+	if c.Decimals != nil {
+		scale := math.Pow(10, float64(*c.Decimals))
+		num = math.Round(num*scale) / scale
+	}
+
+	if (c.Min != nil && num < *c.Min) || (c.Max != nil && num > *c.Max) {
+		err := fmt.Sprintf("%s is out of range for %s%s", input, c.ID, rangeHint(c))
+		if unit == "" && c.Min != nil && c.Max != nil && *c.Min == 0 && *c.Max == 1 && num <= 100 {
+			err += fmt.Sprintf(" (for a percentage, use %s%%)", text)
+		}
+		return 0, fmt.Errorf("%s", err)
+	}
+	return num, nil
+}
+
+// splitUnit splits "22.5°C" into "22.5" and "°C"
+func splitUnit(input string) (string, string) {
+	end := strings.LastIndexAny(input, "0123456789.") + 1
+	return strings.TrimSpace(input[:end]), strings.TrimSpace(input[end:])
+}
+
+func isTemperature(unit string) bool {
+	switch strings.ToUpper(strings.TrimPrefix(unit, "°")) {
+	case "C", "F":
+		return true
+	}
+	return false
+}
+
+// convertTemperature converts between °C and °F
+func convertTemperature(value float64, from, to string) float64 {
+	from = strings.ToUpper(strings.TrimPrefix(from, "°"))
+	to = strings.ToUpper(strings.TrimPrefix(to, "°"))
+	switch {
+	case from == "F" && to == "C":
+		return (value - 32) * 5 / 9
+	case from == "C" && to == "F":
+		return value*9/5 + 32
+	}
+	return value
+}
+
+// rangeHint describes the valid range of a number capability, e.g.
+// " between 5 and 35 °C"
+func rangeHint(c Capability) string {
+	var hint string
+	switch {
+	case c.Min != nil && c.Max != nil:
+		hint = fmt.Sprintf(" between %v and %v", *c.Min, *c.Max)
+	case c.Min != nil:
+		hint = fmt.Sprintf(" of at least %v", *c.Min)
+	case c.Max != nil:
+		hint = fmt.Sprintf(" of at most %v", *c.Max)
+	}
+	if hint != "" && c.Units != "" {
+		hint += " " + c.Units
+	}
+	return hint
+}
+
+// deviceCapabilityValue looks up a capability on a device and converts
+// the value for it
+func deviceCapabilityValue(device Device, capability, input string) (interface{}, error) {
+	c, ok := device.CapabilitiesObj[capability]
+	if !ok {
+		var ids []string
+		for id := range device.CapabilitiesObj {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return nil, fmt.Errorf("device '%s' has no capability %s; capabilities: %s", device.Name, capability, strings.Join(ids, ", "))
+	}
+	if c.ID == "" {
+		c.ID = capability
+	}
+	return capabilityValue(c, input)
+}
+
 var devicesSetCmd = &cobra.Command{
-	Use:   "set <name-or-id> <capability> <value>",
+	Use:   "set [<name-or-id>] <capability> <value>",
 	Short: "Set device capability",
 	Long: `Set a device capability value.
 
+The value is checked against the capability: numbers must be in range and
+are rounded to its step, percentages such as 50% are scaled to the range,
+temperatures can be given in °F or °C, and enums such as thermostat_mode
+take one of their listed values. Read-only capabilities are refused.
+` + selectorHelp + `
+Devices without the capability are skipped.
+
 Examples:
   homeyctl devices set "PultLED" onoff true
   homeyctl devices set "PultLED" dim 0.5
-  homeyctl devices set "Aksels rom" target_temperature 22`,
-	Args: cobra.ExactArgs(3),
+  homeyctl devices set "PultLED" dim 50%
+  homeyctl devices set "Aksels rom" target_temperature 22
+  homeyctl devices set "Aksels rom" target_temperature 72°F
+  homeyctl devices set "Thermostat" thermostat_mode heat
+  homeyctl devices set --zone Kitchen dim 0.3`,
+	Args: cobra.RangeArgs(2, 3),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		nameOrID := args[0]
-		capability := args[1]
-		valueStr := args[2]
+		capability := args[len(args)-2]
+		valueStr := args[len(args)-1]
 
-		device, err := findDevice(nameOrID)
+		devices, many, err := resolveDevices(args[:len(args)-2], capability)
 		if err != nil {
 			return err
 		}
 
-		value := parseValue(valueStr)
-
-		if err := apiClient.SetCapability(device.ID, capability, value); err != nil {
-			return err
-		}
-
-		fmt.Printf("Set %s.%s = %v\n", device.Name, capability, value)
-		return nil
+		return applyToDevices(cmd, devices, many, func(device Device) (string, error) {
+			value, err := deviceCapabilityValue(device, capability, valueStr)
+			if err != nil {
+				return "", err
+			}
+			if err := apiClient.SetCapability(device.ID, capability, value); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Set %s.%s = %v", device.Name, capability, value), nil
+		})
 	},
 }
 
 var devicesOnCmd = &cobra.Command{
-	Use:   "on <name-or-id>",
+	Use:   "on [<name-or-id>]",
 	Short: "Turn device on",
 	Long: `Turn a device on (shorthand for 'devices set <name> onoff true').
+` + selectorHelp + `
+Devices without on/off are skipped.
 
 Examples:
   homeyctl devices on "Living Room Light"
-  homeyctl devices on "Aksels rom"`,
-	Args: cobra.ExactArgs(1),
+  homeyctl devices on "Aksels rom"
+  homeyctl devices on --class light --match "LED*"`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return setDeviceOnOff(args[0], true)
+		return setDeviceOnOff(cmd, args, true)
 	},
 }
 
 var devicesOffCmd = &cobra.Command{
-	Use:   "off <name-or-id>",
+	Use:   "off [<name-or-id>]",
 	Short: "Turn device off",
 	Long: `Turn a device off (shorthand for 'devices set <name> onoff false').
+` + selectorHelp + `
+Devices without on/off are skipped.
 
 Examples:
   homeyctl devices off "Living Room Light"
-  homeyctl devices off "Aksels rom"`,
-	Args: cobra.ExactArgs(1),
+  homeyctl devices off "Aksels rom"
+  homeyctl devices off --zone "Ground Floor" --class light`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return setDeviceOnOff(args[0], false)
+		return setDeviceOnOff(cmd, args, false)
 	},
 }
 
-func setDeviceOnOff(nameOrID string, on bool) error {
-	device, err := findDevice(nameOrID)
+func setDeviceOnOff(cmd *cobra.Command, args []string, on bool) error {
+	devices, many, err := resolveDevices(args, "onoff")
 	if err != nil {
 		return err
 	}
 
-	// Check if device supports onoff
-	if _, hasOnOff := device.CapabilitiesObj["onoff"]; !hasOnOff {
-		return fmt.Errorf("device '%s' does not support on/off", device.Name)
-	}
-
-	if err := apiClient.SetCapability(device.ID, "onoff", on); err != nil {
-		return err
-	}
-
 	state := "on"
 	if !on {
 		state = "off"
 	}
-	fmt.Printf("Turned %s %s\n", device.Name, state)
-	return nil
+
+	return applyToDevices(cmd, devices, many, func(device Device) (string, error) {
+		// Check if device supports onoff
+		if _, hasOnOff := device.CapabilitiesObj["onoff"]; !hasOnOff {
+			return "", fmt.Errorf("device '%s' does not support on/off", device.Name)
+		}
+
+		if err := apiClient.SetCapability(device.ID, "onoff", on); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Turned %s %s", device.Name, state), nil
+	})
 }
 
 func init() {
 	devicesCmd.AddCommand(devicesSetCmd)
 	devicesCmd.AddCommand(devicesOnCmd)
 	devicesCmd.AddCommand(devicesOffCmd)
+
+	for _, cmd := range []*cobra.Command{devicesSetCmd, devicesOnCmd, devicesOffCmd} {
+		addDeviceSelectorFlags(cmd, true)
+	}
 }
@@ -3,10 +3,9 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
 	"github.com/spf13/cobra"
 )
 
@@ -69,25 +68,18 @@ Device groups are virtual devices that control multiple physical devices togethe
 			}
 		}
 
-		if isTableFormat() {
-			if len(groups) == 0 {
-				fmt.Println("No device groups found.")
-				return nil
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tCLASS\tDEVICES\tID")
-			fmt.Fprintln(w, "----\t-----\t-------\t--")
-			for _, g := range groups {
-				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", g.Name, g.Class, len(g.Devices), g.ID)
-			}
-			w.Flush()
+		if isTableFormat() && len(groups) == 0 {
+			fmt.Println("No device groups found.")
 			return nil
 		}
 
-		out, _ := json.MarshalIndent(groups, "", "  ")
-		fmt.Println(string(out))
-		return nil
+		return printList(groups, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "CLASS", Path: ".class"},
+			{Header: "DEVICES", Value: count("devices")},
+			{Header: "ID", Path: ".id"},
+			zoneColumn(),
+		})
 	},
 }
 
@@ -162,8 +154,7 @@ Examples:
 			return nil
 		}
 
-		outputJSON(result)
-		return nil
+		return printOutput(result)
 	},
 }
 
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	healthBattery float64
+	healthStale   time.Duration
+)
+
+// deviceProblem is one problem found on a device
+type deviceProblem struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Zone    string `json:"zone"`
+	Problem string `json:"problem"`
+	Detail  string `json:"detail"`
+}
+
+// Problems reported by devices health
+const (
+	problemUnavailable  = "unavailable"
+	problemNotReady     = "not ready"
+	problemLowBattery   = "low battery"
+	problemBatteryAlarm = "battery alarm"
+	problemStale        = "stale"
+	problemAppDisabled  = "app disabled"
+	problemAppCrashed   = "app crashed"
+)
+
+var devicesHealthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Report unavailable devices, low batteries and stale sensors",
+	Long: `Check every device and list the ones that need attention:
+
+  unavailable     Homey marks the device unavailable
+  not ready       the device hasn't finished starting
+  low battery     measure_battery is below --battery percent
+  battery alarm   alarm_battery is set
+  stale           no sensor reading (measure_* or alarm_*) for --stale
+  app disabled    the app that drives the device is disabled
+  app crashed     the app that drives the device has crashed
+
+Exits non-zero if any problem is found, so it can run from cron or a
+monitoring system.
+
+Examples:
+  homeyctl devices health
+  homeyctl devices health --battery 15 --stale 12h
+  homeyctl devices health --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := apiClient.GetDevices()
+		if err != nil {
+			return err
+		}
+		var devices map[string]Device
+		if err := json.Unmarshal(data, &devices); err != nil {
+			return fmt.Errorf("failed to parse devices: %w", err)
+		}
+
+		// Without access to apps the other checks are still useful
+		var apps map[string]App
+		if data, err := apiClient.GetApps(); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: app checks skipped:", err)
+		} else if err := json.Unmarshal(data, &apps); err != nil {
+			return fmt.Errorf("failed to parse apps: %w", err)
+		}
+
+		problems := checkDeviceHealth(devices, apps, healthBattery, healthStale, time.Now())
+
+		if isTableFormat() && len(problems) == 0 {
+			fmt.Printf("All %d devices are healthy.\n", len(devices))
+			return nil
+		}
+		if err := printList(problems, []render.Column{
+			{Header: "DEVICE", Path: ".name"},
+			{Header: "PROBLEM", Path: ".problem"},
+			{Header: "DETAIL", Path: ".detail"},
+			zoneColumn(),
+			{Header: "ID", Path: ".id", Wide: true},
+		}); err != nil {
+			return err
+		}
+
+		if len(problems) > 0 {
+			// Exit non-zero for monitoring; the report already says why
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d problem(s) found", len(problems))
+		}
+		return nil
+	},
+}
+
+// checkDeviceHealth returns the problems found on devices, sorted by device
+// name. A nil apps map skips the app checks; a zero stale skips the stale
+// sensor check.
+func checkDeviceHealth(devices map[string]Device, apps map[string]App, battery float64, stale time.Duration, now time.Time) []deviceProblem {
+	problems := []deviceProblem{}
+	for _, d := range devices {
+		report := func(problem, detail string) {
+			problems = append(problems, deviceProblem{ID: d.ID, Name: d.Name, Zone: d.Zone, Problem: problem, Detail: detail})
+		}
+
+		if !d.Available {
+			report(problemUnavailable, d.UnavailableMessage)
+		} else if !d.Ready {
+			report(problemNotReady, "")
+		}
+
+		if c, ok := d.CapabilitiesObj["measure_battery"]; ok {
+			if level, ok := c.Value.(float64); ok && level < battery {
+				report(problemLowBattery, fmt.Sprintf("%v%%", level))
+			}
+		}
+		if c, ok := d.CapabilitiesObj["alarm_battery"]; ok && c.Value == true {
+			report(problemBatteryAlarm, "")
+		}
+
+		if last := lastReading(d); stale > 0 && !last.IsZero() && now.Sub(last) > stale {
+			report(problemStale, "no reading since "+last.Local().Format("2006-01-02 15:04"))
+		}
+
+		if app, ok := apps[deviceAppID(d)]; ok {
+			switch {
+			case !app.Enabled:
+				report(problemAppDisabled, app.Name)
+			case app.Crashed:
+				detail := app.Name
+				if app.CrashedMessage != "" {
+					detail += ": " + app.CrashedMessage
+				}
+				report(problemAppCrashed, detail)
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if !strings.EqualFold(a.Name, b.Name) {
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Problem < b.Problem
+	})
+	return problems
+}
+
+// lastReading returns when a device's sensor capabilities last updated, or
+// the zero time if it has none
+func lastReading(d Device) time.Time {
+	var last time.Time
+	for id, c := range d.CapabilitiesObj {
+		if !strings.HasPrefix(id, "measure_") && !strings.HasPrefix(id, "alarm_") {
+			continue
+		}
+		if c.LastUpdated != nil && c.LastUpdated.After(last) {
+			last = *c.LastUpdated
+		}
+	}
+	return last
+}
+
+// deviceAppID returns the ID of the app that drives a device, from
+// "homey:app:<id>" or "homey:app:<id>:<driver>"
+func deviceAppID(d Device) string {
+	for _, uri := range []string{d.OwnerURI, d.DriverID} {
+		if rest, ok := strings.CutPrefix(uri, "homey:app:"); ok {
+			id, _, _ := strings.Cut(rest, ":")
+			return id
+		}
+	}
+	return ""
+}
+
+func init() {
+	devicesCmd.AddCommand(devicesHealthCmd)
+	devicesHealthCmd.Flags().Float64Var(&healthBattery, "battery", 20, "Report batteries below this percentage")
+	devicesHealthCmd.Flags().DurationVar(&healthStale, "stale", 24*time.Hour, "Report sensors without a reading for this long (0 disables)")
+}
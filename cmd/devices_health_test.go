@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCheckDeviceHealth(t *testing.T) {
+	now := time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC)
+	var devices map[string]Device
+	if err := json.Unmarshal([]byte(`{
+		"1": {"id": "1", "name": "Hall Motion", "available": true, "ready": true, "driverId": "homey:app:com.xiaomi-mi-zigbee:motion",
+			"capabilitiesObj": {
+				"alarm_motion": {"value": false, "lastUpdated": "2026-10-13T06:00:00.000Z"},
+				"measure_battery": {"value": 12, "lastUpdated": "2026-10-13T05:00:00.000Z"}
+			}},
+		"2": {"id": "2", "name": "Kitchen Lamp", "available": false, "unavailableMessage": "Device is offline", "ownerUri": "homey:app:com.ikea.tradfri",
+			"capabilitiesObj": {"onoff": {"value": true, "lastUpdated": "2026-01-01T00:00:00.000Z"}}},
+		"3": {"id": "3", "name": "Bath Sensor", "available": true, "ready": false, "driverId": "homey:app:com.aqara:th",
+			"capabilitiesObj": {
+				"measure_temperature": {"value": 21.5, "lastUpdated": "2026-10-16T05:30:00.000Z"},
+				"alarm_battery": {"value": true}
+			}},
+		"4": {"id": "4", "name": "Thermostat", "available": true, "ready": true,
+			"capabilitiesObj": {"measure_temperature": {"value": 20, "lastUpdated": "2026-10-16T05:59:00.000Z"}, "measure_battery": {"value": 80}}}
+	}`), &devices); err != nil {
+		t.Fatalf("failed to parse devices: %v", err)
+	}
+	apps := map[string]App{
+		"com.xiaomi-mi-zigbee": {Name: "Xiaomi", Enabled: true, Crashed: true, CrashedMessage: "out of memory"},
+		"com.ikea.tradfri":     {Name: "IKEA", Enabled: false},
+		"com.aqara":            {Name: "Aqara", Enabled: true, Ready: true},
+	}
+
+	got := checkDeviceHealth(devices, apps, 20, 24*time.Hour, now)
+	want := []struct{ name, problem, detail string }{
+		{"Bath Sensor", problemBatteryAlarm, ""},
+		{"Bath Sensor", problemNotReady, ""},
+		{"Hall Motion", problemAppCrashed, "Xiaomi: out of memory"},
+		{"Hall Motion", problemLowBattery, "12%"},
+		{"Hall Motion", problemStale, "no reading since " + time.Date(2026, 10, 13, 6, 0, 0, 0, time.UTC).Local().Format("2006-01-02 15:04")},
+		{"Kitchen Lamp", problemAppDisabled, "IKEA"},
+		{"Kitchen Lamp", problemUnavailable, "Device is offline"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d problems, got %d: %+v", len(want), len(got), got)
+	}
+	for i, w := range want {
+		if got[i].Name != w.name || got[i].Problem != w.problem || got[i].Detail != w.detail {
+			t.Errorf("problem %d: got %+v, want %+v", i, got[i], w)
+		}
+	}
+
+	// Without apps and the stale check, only device state is reported
+	got = checkDeviceHealth(devices, nil, 10, 0, now)
+	if len(got) != 3 {
+		t.Errorf("expected 3 problems, got %+v", got)
+	}
+
+	if got := checkDeviceHealth(nil, nil, 20, time.Hour, now); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty list, got %#v", got)
+	}
+}
+
+func TestDeviceAppID(t *testing.T) {
+	tests := []struct {
+		device Device
+		want   string
+	}{
+		{Device{OwnerURI: "homey:app:com.fibaro"}, "com.fibaro"},
+		{Device{DriverID: "homey:app:com.fibaro:FGD-212"}, "com.fibaro"},
+		{Device{DriverID: "homey:manager:vdevice:virtual"}, ""},
+		{Device{}, ""},
+	}
+	for _, tt := range tests {
+		if got := deviceAppID(tt.device); got != tt.want {
+			t.Errorf("deviceAppID(%+v) = %q, want %q", tt.device, got, tt.want)
+		}
+	}
+}
@@ -38,23 +38,25 @@ Examples:
 }
 
 var devicesMoveCmd = &cobra.Command{
-	Use:   "move <device> <zone>",
+	Use:   "move [<device>] <zone>",
 	Short: "Move a device to a different zone",
 	Long: `Move a device to a different zone.
 
 The zone can be specified by name or ID.
+` + selectorHelp + `
 
 Examples:
   homeyctl devices move "Living Room Light" "Kitchen"
-  homeyctl devices move "Sensor" "Bedroom"`,
-	Args: cobra.ExactArgs(2),
+  homeyctl devices move "Sensor" "Bedroom"
+  homeyctl devices move --match "Garden*" "Garden"`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		device, err := findDevice(args[0])
+		devices, many, err := resolveDevices(args[:len(args)-1], "")
 		if err != nil {
 			return err
 		}
 
-		zone, err := findZone(args[1])
+		zone, err := findZone(args[len(args)-1])
 		if err != nil {
 			return err
 		}
@@ -63,12 +65,12 @@ Examples:
 			"zone": zone.ID,
 		}
 
-		if err := apiClient.UpdateDevice(device.ID, updates); err != nil {
-			return err
-		}
-
-		fmt.Printf("Moved device '%s' to zone '%s'\n", device.Name, zone.Name)
-		return nil
+		return applyToDevices(cmd, devices, many, func(device Device) (string, error) {
+			if err := apiClient.UpdateDevice(device.ID, updates); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Moved device '%s' to zone '%s'", device.Name, zone.Name), nil
+		})
 	},
 }
 
@@ -141,59 +143,56 @@ Examples:
 }
 
 var devicesHideCmd = &cobra.Command{
-	Use:   "hide <device>",
+	Use:   "hide [<device>]",
 	Short: "Hide a device from the UI",
 	Long: `Hide a device from the Homey UI.
 
 The device will still function normally but won't appear in the device list.
+` + selectorHelp + `
 
 Examples:
-  homeyctl devices hide "Hidden Sensor"`,
-	Args: cobra.ExactArgs(1),
+  homeyctl devices hide "Hidden Sensor"
+  homeyctl devices hide --zone Technical`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		device, err := findDevice(args[0])
-		if err != nil {
-			return err
-		}
-
-		updates := map[string]interface{}{
-			"hidden": true,
-		}
-
-		if err := apiClient.UpdateDevice(device.ID, updates); err != nil {
-			return err
-		}
-
-		fmt.Printf("Hidden device '%s' from UI\n", device.Name)
-		return nil
+		return setDeviceHidden(cmd, args, true)
 	},
 }
 
 var devicesUnhideCmd = &cobra.Command{
-	Use:   "unhide <device>",
+	Use:   "unhide [<device>]",
 	Short: "Show a hidden device in the UI",
 	Long: `Make a hidden device visible again in the Homey UI.
+` + selectorHelp + `
 
 Examples:
-  homeyctl devices unhide "Hidden Sensor"`,
-	Args: cobra.ExactArgs(1),
+  homeyctl devices unhide "Hidden Sensor"
+  homeyctl devices unhide --all`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		device, err := findDevice(args[0])
-		if err != nil {
-			return err
-		}
+		return setDeviceHidden(cmd, args, false)
+	},
+}
 
-		updates := map[string]interface{}{
-			"hidden": false,
-		}
+func setDeviceHidden(cmd *cobra.Command, args []string, hidden bool) error {
+	devices, many, err := resolveDevices(args, "")
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"hidden": hidden,
+	}
 
+	return applyToDevices(cmd, devices, many, func(device Device) (string, error) {
 		if err := apiClient.UpdateDevice(device.ID, updates); err != nil {
-			return err
+			return "", err
 		}
-
-		fmt.Printf("Unhidden device '%s' - now visible in UI\n", device.Name)
-		return nil
-	},
+		if hidden {
+			return fmt.Sprintf("Hidden device '%s' from UI", device.Name), nil
+		}
+		return fmt.Sprintf("Unhidden device '%s' - now visible in UI", device.Name), nil
+	})
 }
 
 var devicesDeleteCmd = &cobra.Command{
@@ -223,4 +222,8 @@ func init() {
 	devicesCmd.AddCommand(devicesHideCmd)
 	devicesCmd.AddCommand(devicesUnhideCmd)
 	devicesCmd.AddCommand(devicesDeleteCmd)
+
+	for _, cmd := range []*cobra.Command{devicesMoveCmd, devicesHideCmd, devicesUnhideCmd} {
+		addDeviceSelectorFlags(cmd, true)
+	}
 }
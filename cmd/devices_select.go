@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/spf13/cobra"
+)
+
+// deviceSelector picks devices by zone, class, capability or name instead
+// of a single name or ID
+type deviceSelector struct {
+	zone       string
+	class      string
+	capability string
+	match      string
+	all        bool
+}
+
+var (
+	devicesSelector    deviceSelector
+	devicesConcurrency int
+)
+
+const selectorHelp = `
+Instead of one device, select many with --zone (including child zones),
+--class, --capability, --match (a name pattern such as "LED*") or --all.
+Selection flags combine, and each selected device gets its own result line.`
+
+// addDeviceSelectorFlags registers the selection flags on a device command
+func addDeviceSelectorFlags(cmd *cobra.Command, concurrent bool) {
+	cmd.Flags().StringVar(&devicesSelector.zone, "zone", "", "Select devices in a zone (name or ID) and its child zones")
+	cmd.Flags().StringVar(&devicesSelector.class, "class", "", "Select devices of a class (e.g. light, socket)")
+	cmd.Flags().StringVar(&devicesSelector.capability, "capability", "", "Select devices with a capability (e.g. dim)")
+	cmd.Flags().StringVar(&devicesSelector.match, "match", "", "Select devices by name pattern, case-insensitive (e.g. \"LED*\")")
+	cmd.Flags().BoolVar(&devicesSelector.all, "all", false, "Select all devices")
+	if concurrent {
+		cmd.Flags().IntVar(&devicesConcurrency, "concurrency", 4, "Number of devices to update at once")
+	}
+}
+
+func (s deviceSelector) isSet() bool {
+	return s.zone != "" || s.class != "" || s.capability != "" || s.match != "" || s.all
+}
+
+// resolveDevices returns the device named in args, or the devices the
+// selection flags pick; many is true for a selection. In selection mode,
+// devices without the required capability are left out. A named device may
+// be combined with --capability, which it must have.
+func resolveDevices(args []string, required string) (devices []Device, many bool, err error) {
+	if len(args) > 0 {
+		s := devicesSelector
+		if s.zone != "" || s.class != "" || s.match != "" || s.all {
+			return nil, false, fmt.Errorf("specify either a device or selection flags, not both")
+		}
+		device, err := findDevice(args[0])
+		if err != nil {
+			return nil, false, err
+		}
+		if _, ok := device.CapabilitiesObj[s.capability]; s.capability != "" && !ok {
+			return nil, false, fmt.Errorf("device '%s' has no capability %s", device.Name, s.capability)
+		}
+		return []Device{*device}, false, nil
+	}
+	if !devicesSelector.isSet() {
+		return nil, false, fmt.Errorf("specify a device, or select devices with --zone, --class, --capability, --match or --all")
+	}
+
+	selected, err := selectDevices(required)
+	return selected, true, err
+}
+
+// selectDevices returns the devices the selection flags pick
+func selectDevices(required string) ([]Device, error) {
+	data, err := apiClient.GetDevices()
+	if err != nil {
+		return nil, err
+	}
+	var devices map[string]Device
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("failed to parse devices: %w", err)
+	}
+
+	var zones map[string]Zone
+	if devicesSelector.zone != "" {
+		data, err := apiClient.GetZones()
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &zones); err != nil {
+			return nil, fmt.Errorf("failed to parse zones: %w", err)
+		}
+	}
+
+	selected, err := devicesSelector.selectFrom(devices, zones)
+	if err != nil {
+		return nil, err
+	}
+	if required != "" {
+		var capable []Device
+		for _, d := range selected {
+			if _, ok := d.CapabilitiesObj[required]; ok {
+				capable = append(capable, d)
+			}
+		}
+		selected = capable
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no devices match the selection")
+	}
+	return selected, nil
+}
+
+// selectFrom returns the devices matching every selection flag, sorted by name
+func (s deviceSelector) selectFrom(devices map[string]Device, zones map[string]Zone) ([]Device, error) {
+	pattern := strings.ToLower(s.match)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid --match pattern %q: %w", s.match, err)
+	}
+
+	var inZone map[string]bool
+	if s.zone != "" {
+		zone, err := matchZone(zones, s.zone)
+		if err != nil {
+			return nil, err
+		}
+		inZone = zoneTree(zones, zone.ID)
+	}
+
+	var selected []Device
+	for _, d := range devices {
+		if inZone != nil && !inZone[d.Zone] {
+			continue
+		}
+		if s.class != "" && !strings.EqualFold(d.Class, s.class) {
+			continue
+		}
+		if s.capability != "" {
+			if _, ok := d.CapabilitiesObj[s.capability]; !ok {
+				continue
+			}
+		}
+		if s.match != "" {
+			if ok, _ := path.Match(pattern, strings.ToLower(d.Name)); !ok {
+				continue
+			}
+		}
+		selected = append(selected, d)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return strings.ToLower(selected[i].Name) < strings.ToLower(selected[j].Name)
+	})
+	return selected, nil
+}
+
+// zoneTree returns the IDs of a zone and every zone below it
+func zoneTree(zones map[string]Zone, root string) map[string]bool {
+	tree := map[string]bool{root: true}
+	for id := range zones {
+		// Walk up the parents; the step limit guards against cycles
+		for parent, steps := id, 0; parent != "" && steps <= len(zones); steps++ {
+			if parent == root {
+				tree[id] = true
+				break
+			}
+			parent = zones[parent].Parent
+		}
+	}
+	return tree
+}
+
+// deviceResult is the outcome of an operation on one selected device
+type deviceResult struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// eachDevice runs fn on every device, at most concurrency at a time, and
+// returns the results in the order of devices
+func eachDevice(devices []Device, concurrency int, fn func(Device) error) []deviceResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]deviceResult, len(devices))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(devices); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				d := devices[n]
+				results[n] = deviceResult{ID: d.ID, Name: d.Name, OK: true}
+				if err := fn(d); err != nil {
+					results[n].OK = false
+					results[n].Error = err.Error()
+				}
+			}
+		}()
+	}
+	for n := range devices {
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// applyToDevices runs fn on the resolved devices. A single named device
+// prints fn's message as before; a selection (many) prints a result per
+// device and fails if any device failed, without cmd's usage.
+func applyToDevices(cmd *cobra.Command, devices []Device, many bool, fn func(Device) (string, error)) error {
+	if !many {
+		message, err := fn(devices[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(message)
+		return nil
+	}
+
+	results := eachDevice(devices, devicesConcurrency, func(d Device) error {
+		_, err := fn(d)
+		return err
+	})
+	if err := printList(results, []render.Column{
+		{Header: "NAME", Path: ".name"},
+		{Header: "RESULT", Value: func(item map[string]interface{}) interface{} {
+			if ok, _ := item["ok"].(bool); ok {
+				return "ok"
+			}
+			return item["error"]
+		}},
+		{Header: "ID", Path: ".id", Wide: true},
+	}); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if !r.OK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		// The results show what went wrong; the usage would only bury them
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d of %d devices failed", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var selectorZones = map[string]Zone{
+	"home":    {ID: "home", Name: "Home"},
+	"ground":  {ID: "ground", Name: "Ground Floor", Parent: "home"},
+	"kitchen": {ID: "kitchen", Name: "Kitchen", Parent: "ground"},
+	"pantry":  {ID: "pantry", Name: "Pantry", Parent: "kitchen"},
+	"upstair": {ID: "upstair", Name: "Upstairs", Parent: "home"},
+}
+
+var selectorDevices = map[string]Device{
+	"1": {ID: "1", Name: "LED Strip", Class: "light", Zone: "kitchen", CapabilitiesObj: map[string]Capability{"onoff": {}, "dim": {}}},
+	"2": {ID: "2", Name: "Pantry Bulb", Class: "light", Zone: "pantry", CapabilitiesObj: map[string]Capability{"onoff": {}}},
+	"3": {ID: "3", Name: "Bedroom LED", Class: "light", Zone: "upstair", CapabilitiesObj: map[string]Capability{"onoff": {}, "dim": {}}},
+	"4": {ID: "4", Name: "Kitchen Sensor", Class: "sensor", Zone: "kitchen", CapabilitiesObj: map[string]Capability{"measure_temperature": {}}},
+}
+
+func selectedNames(t *testing.T, s deviceSelector) []string {
+	t.Helper()
+	devices, err := s.selectFrom(selectorDevices, selectorZones)
+	if err != nil {
+		t.Fatalf("selectFrom(%+v) failed: %v", s, err)
+	}
+	var names []string
+	for _, d := range devices {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+func TestDeviceSelector(t *testing.T) {
+	tests := []struct {
+		selector deviceSelector
+		want     []string
+	}{
+		{deviceSelector{all: true}, []string{"Bedroom LED", "Kitchen Sensor", "LED Strip", "Pantry Bulb"}},
+		{deviceSelector{zone: "ground floor", class: "light"}, []string{"LED Strip", "Pantry Bulb"}},
+		{deviceSelector{zone: "kitchen"}, []string{"Kitchen Sensor", "LED Strip", "Pantry Bulb"}},
+		{deviceSelector{zone: "pantry"}, []string{"Pantry Bulb"}},
+		{deviceSelector{capability: "dim"}, []string{"Bedroom LED", "LED Strip"}},
+		{deviceSelector{match: "led*"}, []string{"LED Strip"}},
+		{deviceSelector{match: "*LED*", zone: "Upstairs"}, []string{"Bedroom LED"}},
+		{deviceSelector{class: "socket"}, nil},
+	}
+	for _, tt := range tests {
+		got := selectedNames(t, tt.selector)
+		if len(got) != len(tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.selector, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%+v: got %v, want %v", tt.selector, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDeviceSelector_Errors(t *testing.T) {
+	if _, err := (deviceSelector{zone: "Attic"}).selectFrom(selectorDevices, selectorZones); err == nil {
+		t.Error("expected error for unknown zone")
+	}
+	if _, err := (deviceSelector{match: "[LED"}).selectFrom(selectorDevices, selectorZones); err == nil {
+		t.Error("expected error for bad pattern")
+	}
+}
+
+func TestDeviceSelector_AmbiguousZone(t *testing.T) {
+	zones := map[string]Zone{
+		"home":  {ID: "home", Name: "Home"},
+		"bath1": {ID: "bath1", Name: "Bathroom", Parent: "home"},
+		"bath2": {ID: "bath2", Name: "bathroom", Parent: "home"},
+	}
+	devices := map[string]Device{
+		"1": {ID: "1", Name: "Fan", Zone: "bath1"},
+		"2": {ID: "2", Name: "Heater", Zone: "bath2"},
+	}
+
+	_, err := (deviceSelector{zone: "Bathroom"}).selectFrom(devices, zones)
+	if err == nil || !strings.Contains(err.Error(), "bath1, bath2") {
+		t.Fatalf("expected ambiguous zone error listing both IDs, got %v", err)
+	}
+
+	selected, err := (deviceSelector{zone: "bath2"}).selectFrom(devices, zones)
+	if err != nil {
+		t.Fatalf("selecting by ID failed: %v", err)
+	}
+	if len(selected) != 1 || selected[0].Name != "Heater" {
+		t.Errorf("got %v, want only Heater", selected)
+	}
+}
+
+func TestZoneTree_Cycle(t *testing.T) {
+	zones := map[string]Zone{
+		"a": {ID: "a", Parent: "b"},
+		"b": {ID: "b", Parent: "a"},
+		"c": {ID: "c"},
+	}
+	tree := zoneTree(zones, "c")
+	if len(tree) != 1 || !tree["c"] {
+		t.Errorf("unexpected tree: %v", tree)
+	}
+}
+
+func TestEachDevice(t *testing.T) {
+	var devices []Device
+	for _, id := range []string{"a", "b", "c", "d", "e", "f"} {
+		devices = append(devices, Device{ID: id, Name: id})
+	}
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	results := eachDevice(devices, 2, func(d Device) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if d.ID == "c" {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+
+	if peak > 2 {
+		t.Errorf("expected at most 2 devices at once, got %d", peak)
+	}
+	if len(results) != len(devices) {
+		t.Fatalf("expected %d results, got %d", len(devices), len(results))
+	}
+	for i, r := range results {
+		if r.ID != devices[i].ID {
+			t.Errorf("result %d is for %s, want %s", i, r.ID, devices[i].ID)
+		}
+		if r.OK != (r.ID != "c") {
+			t.Errorf("unexpected result for %s: %+v", r.ID, r)
+		}
+	}
+	if results[2].Error != "unavailable" {
+		t.Errorf("expected error message, got %q", results[2].Error)
+	}
+}
+
+func TestApplyToDevices_PartialFailure(t *testing.T) {
+	cmd := &cobra.Command{}
+	devices := []Device{{ID: "a", Name: "a"}, {ID: "b", Name: "b"}}
+	err := applyToDevices(cmd, devices, true, func(d Device) (string, error) {
+		if d.ID == "b" {
+			return "", errors.New("unavailable")
+		}
+		return "ok", nil
+	})
+	if err == nil || err.Error() != "1 of 2 devices failed" {
+		t.Errorf("expected a partial failure, got %v", err)
+	}
+	if !cmd.SilenceUsage {
+		t.Error("expected the usage to be silenced after the results")
+	}
+}
+
+func TestDeviceCommands_AcceptSelectors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"set", []string{"dim", "0.5"}},
+		{"on", nil},
+		{"off", nil},
+		{"values", nil},
+		{"hide", nil},
+		{"unhide", nil},
+		{"move", []string{"Kitchen"}},
+	}
+	for _, tt := range tests {
+		cmd, _, err := devicesCmd.Find([]string{tt.name})
+		if err != nil {
+			t.Fatalf("%s command not found: %v", tt.name, err)
+		}
+		for _, flag := range []string{"zone", "class", "capability", "match", "all"} {
+			if cmd.Flags().Lookup(flag) == nil {
+				t.Errorf("%s: expected --%s flag", tt.name, flag)
+			}
+		}
+		if err := cmd.Args(cmd, tt.args); err != nil {
+			t.Errorf("%s should accept %v without a device: %v", tt.name, tt.args, err)
+		}
+		if err := cmd.Args(cmd, append([]string{"Lamp"}, tt.args...)); err != nil {
+			t.Errorf("%s should accept a device: %v", tt.name, err)
+		}
+	}
+}
+
+func TestValueRows(t *testing.T) {
+	device := selectorDevices["1"]
+	device.CapabilitiesObj = map[string]Capability{"onoff": {ID: "onoff", Value: true}, "dim": {ID: "dim", Value: 0.4}}
+
+	rows := valueRows(deviceValues(device, ""))
+	if len(rows) != 2 || rows[0].Capability != "dim" || rows[0].Device != "LED Strip" || rows[1].Value != true {
+		t.Errorf("unexpected rows: %+v", rows)
+	}
+
+	rows = valueRows(deviceValues(device, "dim"))
+	if len(rows) != 1 || rows[0].Value != 0.4 {
+		t.Errorf("expected only the dim value, got %+v", rows)
+	}
+}
+
+func TestResolveDevices_NamedDeviceWithSelectors(t *testing.T) {
+	old := devicesSelector
+	defer func() { devicesSelector = old }()
+
+	devicesSelector = deviceSelector{zone: "Kitchen"}
+	if _, _, err := resolveDevices([]string{"Lamp"}, ""); err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Errorf("expected a device and --zone to conflict, got %v", err)
+	}
+
+	devicesSelector = deviceSelector{}
+	if _, _, err := resolveDevices(nil, ""); err == nil || !strings.Contains(err.Error(), "specify a device") {
+		t.Errorf("expected an error without a device or selection, got %v", err)
+	}
+}
@@ -3,10 +3,10 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
-	"text/tabwriter"
+	"sort"
 
+	"github.com/langtind/homeyctl/internal/client"
+	"github.com/langtind/homeyctl/internal/render"
 	"github.com/spf13/cobra"
 )
 
@@ -29,25 +29,24 @@ and driver-specific settings.`,
 			return err
 		}
 
-		if isTableFormat() {
-			var settingsMap map[string]interface{}
-			if err := json.Unmarshal(settings, &settingsMap); err != nil {
-				return fmt.Errorf("failed to parse settings: %w", err)
-			}
+		var settingsMap map[string]interface{}
+		if err := json.Unmarshal(settings, &settingsMap); err != nil {
+			return fmt.Errorf("failed to parse settings: %w", err)
+		}
+		rows := make([]map[string]interface{}, 0, len(settingsMap))
+		for key, val := range settingsMap {
+			rows = append(rows, map[string]interface{}{"setting": key, "value": val})
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i]["setting"].(string) < rows[j]["setting"].(string) })
 
+		if showTitles() {
 			fmt.Printf("Settings for %s:\n\n", device.Name)
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "SETTING\tVALUE")
-			fmt.Fprintln(w, "-------\t-----")
-			for key, val := range settingsMap {
-				fmt.Fprintf(w, "%s\t%v\n", key, val)
-			}
-			w.Flush()
-			return nil
 		}
 
-		outputJSON(settings)
-		return nil
+		return printRows(settings, rows, []render.Column{
+			{Header: "SETTING", Path: ".setting"},
+			{Header: "VALUE", Path: ".value"},
+		})
 	},
 }
 
@@ -82,7 +81,7 @@ Examples:
 		}
 
 		if err := apiClient.SetDeviceSetting(device.ID, settings); err != nil {
-			if strings.Contains(err.Error(), "Missing Scopes") {
+			if client.IsForbidden(err) {
 				return fmt.Errorf(`permission denied: changing device settings requires 'homey.device' scope
 
 OAuth tokens only support 'homey.device.control' (for on/off, dim, etc.),
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/langtind/homeyctl/internal/client"
 )
 
 func TestDevicesRenameCommand_Exists(t *testing.T) {
@@ -74,3 +77,183 @@ func TestDevicesListCommand_Exists(t *testing.T) {
 		t.Errorf("expected command name 'list', got '%s'", cmd.Name())
 	}
 }
+
+func TestDevicesWatchCommand_Exists(t *testing.T) {
+	cmd, _, err := devicesCmd.Find([]string{"watch"})
+	if err != nil {
+		t.Fatalf("watch command not found: %v", err)
+	}
+	if cmd.Name() != "watch" {
+		t.Errorf("expected command name 'watch', got '%s'", cmd.Name())
+	}
+}
+
+func TestParseUntil(t *testing.T) {
+	capability, value, err := parseUntil("alarm_contact=false")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if capability != "alarm_contact" || value != false {
+		t.Errorf("got %s=%v, want alarm_contact=false", capability, value)
+	}
+
+	if _, _, err := parseUntil("onoff"); err == nil {
+		t.Error("expected error for missing value")
+	}
+	if _, _, err := parseUntil("=true"); err == nil {
+		t.Error("expected error for missing capability")
+	}
+}
+
+func TestValuesEqual(t *testing.T) {
+	if !valuesEqual(float64(1), float64(1.0)) {
+		t.Error("expected 1 and 1.0 to be equal")
+	}
+	if !valuesEqual(true, true) {
+		t.Error("expected true and true to be equal")
+	}
+	if valuesEqual("on", true) {
+		t.Error("expected \"on\" and true to differ")
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{"true", true},
+		{"false", false},
+		{"0.5", 0.5},
+		{"-3", -3.0},
+		{"1e3", 1000.0},
+		{"1e3abc", "1e3abc"},
+		{"12 apples", "12 apples"},
+	}
+	for _, tt := range tests {
+		if got := parseValue(tt.in); got != tt.want {
+			t.Errorf("parseValue(%q) = %v (%T), want %v", tt.in, got, got, tt.want)
+		}
+	}
+}
+
+func TestCapabilityValue(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	two := 2
+	dim := Capability{ID: "dim", Type: "number", Setable: true, Min: f(0), Max: f(1), Decimals: &two}
+	target := Capability{ID: "target_temperature", Type: "number", Setable: true, Units: "°C", Min: f(5), Max: f(35), Step: f(0.5)}
+	mode := Capability{ID: "thermostat_mode", Type: "enum", Setable: true, Values: []CapabilityValue{{ID: "auto"}, {ID: "heat"}, {ID: "cool"}, {ID: "off"}}}
+	onoff := Capability{ID: "onoff", Type: "boolean", Setable: true}
+
+	tests := []struct {
+		c    Capability
+		in   string
+		want interface{}
+	}{
+		{dim, "0.5", 0.5},
+		{dim, "50%", 0.5},
+		{dim, "33.333%", 0.33},
+		{target, "22", 22.0},
+		{target, "22.3", 22.5},
+		{target, "21°C", 21.0},
+		{target, "72°F", 22.0},
+		{target, "72 F", 22.0},
+		{mode, "Heat", "heat"},
+		{onoff, "on", true},
+		{onoff, "false", false},
+		{Capability{ID: "custom"}, "12", 12.0},
+		{Capability{ID: "label", Type: "string", Setable: true}, "12", "12"},
+	}
+	for _, tt := range tests {
+		got, err := capabilityValue(tt.c, tt.in)
+		if err != nil {
+			t.Errorf("%s %q: unexpected error: %v", tt.c.ID, tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s %q = %v, want %v", tt.c.ID, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCapabilityValue_Errors(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	dim := Capability{ID: "dim", Type: "number", Setable: true, Min: f(0), Max: f(1)}
+	mode := Capability{ID: "thermostat_mode", Type: "enum", Setable: true, Values: []CapabilityValue{{ID: "auto"}, {ID: "heat"}}}
+
+	tests := []struct {
+		c    Capability
+		in   string
+		want string
+	}{
+		{dim, "50", "for a percentage, use 50%"},
+		{dim, "1e3abc", "can't set dim in abc"},
+		{dim, "bright", "expected a number between 0 and 1"},
+		{dim, "20°F", "can't set dim in °F"},
+		{Capability{ID: "target_temperature", Type: "number", Setable: true, Units: "°C", Max: f(35)}, "120°F", "of at most 35 °C"},
+		{Capability{ID: "volume", Type: "number", Setable: true}, "50%", "no range"},
+		{mode, "eco", "valid values: auto, heat"},
+		{Capability{ID: "onoff", Type: "boolean", Setable: true}, "maybe", "valid values: true, false"},
+		{Capability{ID: "measure_power", Type: "number"}, "5", "read-only"},
+	}
+	for _, tt := range tests {
+		_, err := capabilityValue(tt.c, tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %q: expected error containing %q, got %v", tt.c.ID, tt.in, tt.want, err)
+		}
+	}
+}
+
+func TestDeviceCapabilityValue_UnknownCapability(t *testing.T) {
+	device := Device{Name: "Lamp", CapabilitiesObj: map[string]Capability{"onoff": {ID: "onoff"}, "dim": {ID: "dim"}}}
+	_, err := deviceCapabilityValue(device, "target_temperature", "21")
+	if err == nil || !strings.Contains(err.Error(), "capabilities: dim, onoff") {
+		t.Errorf("expected the device's capabilities in the error, got %v", err)
+	}
+}
+
+func TestWatchState_UntilIgnoresCapabilityFilter(t *testing.T) {
+	selected := map[string]Device{"door": {ID: "door", Name: "Door", CapabilitiesObj: map[string]Capability{
+		"alarm_contact":       {Value: true},
+		"measure_temperature": {Value: 20.0},
+	}}}
+	state := newWatchState(selected, map[string]bool{"measure_temperature": true}, "alarm_contact", false)
+
+	change, done := state.handle(client.Event{Type: client.EventCapability, ID: "door", Capability: "measure_temperature", Value: 21.0})
+	if change == nil || change.OldValue != 20.0 || done {
+		t.Errorf("expected a shown temperature change, got %+v done=%v", change, done)
+	}
+
+	change, done = state.handle(client.Event{Type: client.EventCapability, ID: "door", Capability: "alarm_contact", Value: false})
+	if change != nil {
+		t.Errorf("filtered capability should not be shown, got %+v", change)
+	}
+	if !done {
+		t.Error("expected --until to be met by a filtered capability")
+	}
+}
+
+func TestWatchState_UntilAlreadyMet(t *testing.T) {
+	selected := map[string]Device{"door": {ID: "door", CapabilitiesObj: map[string]Capability{"alarm_contact": {Value: false}}}}
+
+	if !newWatchState(selected, nil, "alarm_contact", false).untilMet() {
+		t.Error("expected the starting value to meet --until")
+	}
+	if newWatchState(selected, nil, "alarm_contact", true).untilMet() {
+		t.Error("expected --until not to be met")
+	}
+	if newWatchState(selected, nil, "", nil).untilMet() {
+		t.Error("expected no --until to never be met")
+	}
+}
+
+func TestCapabilityRows(t *testing.T) {
+	device := Device{CapabilitiesObj: map[string]Capability{
+		"onoff":               {Value: true},
+		"measure_temperature": {Value: 21.5, Units: "°C"},
+	}}
+	rows := capabilityRows(device)
+	if len(rows) != 2 || rows[0].Capability != "measure_temperature" || rows[0].Units != "°C" || rows[1].Value != true {
+		t.Errorf("unexpected rows: %+v", rows)
+	}
+}
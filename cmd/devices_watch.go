@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchZoneFilter       string
+	watchClassFilter      string
+	watchCapabilityFilter string
+	watchUntil            string
+)
+
+// CapabilityChange is a single capability value change printed by devices watch
+type CapabilityChange struct {
+	Time       time.Time   `json:"time"`
+	DeviceID   string      `json:"deviceId"`
+	Device     string      `json:"device"`
+	Capability string      `json:"capability"`
+	OldValue   interface{} `json:"oldValue"`
+	NewValue   interface{} `json:"newValue"`
+}
+
+// parseUntil parses a --until condition of the form <capability>=<value>
+func parseUntil(s string) (string, interface{}, error) {
+	capability, valueStr, ok := strings.Cut(s, "=")
+	capability = strings.TrimSpace(capability)
+	if !ok || capability == "" {
+		return "", nil, fmt.Errorf("invalid --until condition: %s (use <capability>=<value>)", s)
+	}
+	return capability, parseValue(strings.TrimSpace(valueStr)), nil
+}
+
+// valuesEqual compares capability values loosely, so 1 matches 1.0
+func valuesEqual(a, b interface{}) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// watchState tracks the values of watched devices and the --until condition
+type watchState struct {
+	selected        map[string]Device
+	values          map[string]map[string]interface{}
+	capabilities    map[string]bool
+	untilCapability string
+	untilValue      interface{}
+}
+
+func newWatchState(selected map[string]Device, capabilities map[string]bool, untilCapability string, untilValue interface{}) *watchState {
+	// Remember current values so each change can show the old value
+	values := make(map[string]map[string]interface{})
+	for id, d := range selected {
+		values[id] = make(map[string]interface{})
+		for capID, c := range d.CapabilitiesObj {
+			values[id][capID] = c.Value
+		}
+	}
+	return &watchState{
+		selected:        selected,
+		values:          values,
+		capabilities:    capabilities,
+		untilCapability: untilCapability,
+		untilValue:      untilValue,
+	}
+}
+
+// untilMet reports whether a watched device already has the --until value
+func (s *watchState) untilMet() bool {
+	if s.untilCapability == "" {
+		return false
+	}
+	for _, values := range s.values {
+		if v, ok := values[s.untilCapability]; ok && valuesEqual(v, s.untilValue) {
+			return true
+		}
+	}
+	return false
+}
+
+// handle records a capability event. It returns the change to print, or nil
+// if the --capability filter hides it, and whether --until is now met.
+func (s *watchState) handle(event client.Event) (*CapabilityChange, bool) {
+	if event.Type != client.EventCapability {
+		return nil, false
+	}
+	device, ok := s.selected[event.ID]
+	if !ok {
+		return nil, false
+	}
+
+	change := &CapabilityChange{
+		Time:       event.Time,
+		DeviceID:   device.ID,
+		Device:     device.Name,
+		Capability: event.Capability,
+		OldValue:   s.values[device.ID][event.Capability],
+		NewValue:   event.Value,
+	}
+	s.values[device.ID][event.Capability] = event.Value
+
+	// --until is checked before the filter, so it may watch a capability
+	// that isn't shown
+	done := s.untilCapability != "" && event.Capability == s.untilCapability && valuesEqual(event.Value, s.untilValue)
+	if len(s.capabilities) > 0 && !s.capabilities[event.Capability] {
+		return nil, done
+	}
+	return change, done
+}
+
+var devicesWatchCmd = &cobra.Command{
+	Use:   "watch [name-or-id]",
+	Short: "Stream capability value changes",
+	Long: `Stream capability value changes for one or more devices.
+
+Select devices by name/ID, or with --zone and --class. Prints one line per
+change with timestamp, device, capability, old value, and new value.
+Use --format json for NDJSON output.
+
+With --until, the command exits as soon as a capability reaches the given
+value, which is handy for shell automations that wait for a state.
+
+Examples:
+  homeyctl devices watch "Front Door Sensor"
+  homeyctl devices watch --zone "Kitchen" --capability measure_power,alarm_motion
+  homeyctl devices watch --class sensor --format json
+  homeyctl devices watch "Garage Door" --until alarm_contact=false`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && watchZoneFilter == "" && watchClassFilter == "" {
+			return fmt.Errorf("specify a device, --zone, or --class")
+		}
+
+		var untilCapability string
+		var untilValue interface{}
+		if watchUntil != "" {
+			var err error
+			untilCapability, untilValue, err = parseUntil(watchUntil)
+			if err != nil {
+				return err
+			}
+		}
+
+		capabilities := make(map[string]bool)
+		if watchCapabilityFilter != "" {
+			for _, c := range strings.Split(watchCapabilityFilter, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					capabilities[c] = true
+				}
+			}
+		}
+
+		data, err := apiClient.GetDevices()
+		if err != nil {
+			return err
+		}
+
+		var devices map[string]Device
+		if err := json.Unmarshal(data, &devices); err != nil {
+			return fmt.Errorf("failed to parse devices: %w", err)
+		}
+
+		var zoneID string
+		if watchZoneFilter != "" {
+			zone, err := findZone(watchZoneFilter)
+			if err != nil {
+				return err
+			}
+			zoneID = zone.ID
+		}
+
+		selected := make(map[string]Device)
+		for _, d := range devices {
+			if len(args) == 1 && d.ID != args[0] && !strings.EqualFold(d.Name, args[0]) {
+				continue
+			}
+			if zoneID != "" && d.Zone != zoneID {
+				continue
+			}
+			if watchClassFilter != "" && !strings.EqualFold(d.Class, watchClassFilter) {
+				continue
+			}
+			selected[d.ID] = d
+		}
+
+		if len(selected) == 0 {
+			return fmt.Errorf("no devices match the given filters")
+		}
+
+		state := newWatchState(selected, capabilities, untilCapability, untilValue)
+		if state.untilMet() {
+			if isTableFormat() {
+				fmt.Fprintf(os.Stderr, "%s is already %v\n", untilCapability, untilValue)
+			}
+			return nil
+		}
+
+		var ids []string
+		for id := range selected {
+			ids = append(ids, id)
+		}
+
+		events, err := apiClient.Subscribe(cmd.Context(), client.SubscribeOptions{
+			Managers:  []string{"devices"},
+			DeviceIDs: ids,
+			OnError: func(err error) {
+				fmt.Fprintf(os.Stderr, "Reconnecting: %v\n", err)
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if isTableFormat() {
+			fmt.Fprintf(os.Stderr, "Watching %d device(s), press Ctrl-C to stop\n", len(selected))
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		for event := range events {
+			change, done := state.handle(event)
+			if change != nil {
+				if isTableFormat() {
+					fmt.Printf("%s  %-24s  %-24s  %v -> %v\n",
+						change.Time.Format("15:04:05"), change.Device, change.Capability, change.OldValue, change.NewValue)
+				} else if err := enc.Encode(change); err != nil {
+					return err
+				}
+			}
+			if done {
+				return nil
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	devicesCmd.AddCommand(devicesWatchCmd)
+	devicesWatchCmd.Flags().StringVar(&watchZoneFilter, "zone", "", "Watch devices in this zone (name or ID)")
+	devicesWatchCmd.Flags().StringVar(&watchClassFilter, "class", "", "Watch devices of this class (e.g. light, sensor)")
+	devicesWatchCmd.Flags().StringVar(&watchCapabilityFilter, "capability", "", "Only show these capabilities (comma-separated)")
+	devicesWatchCmd.Flags().StringVar(&watchUntil, "until", "", "Exit when a capability reaches a value: <capability>=<value>")
+}
@@ -0,0 +1,592 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/langtind/homeyctl/internal/client"
+	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/discovery"
+	"github.com/langtind/homeyctl/internal/render"
+)
+
+// Check results, from best to worst
+const (
+	checkPass = "pass"
+	checkSkip = "skip"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// maxClockSkew is how far the local clock may drift from Homey's before it
+// is reported. Login tokens are time-limited, so a bad clock breaks login.
+const maxClockSkew = 30 * time.Second
+
+// certExpiryWarning is how soon before expiry a TLS certificate is reported
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// doctorCheck is the outcome of one diagnostic
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// discoverHomeys finds Homeys over mDNS (replaced in tests)
+var discoverHomeys = discovery.DiscoverHomeys
+
+// doctorCommands are everyday commands and the request each makes; the
+// token's scopes are compared against the scopes these need
+var doctorCommands = []struct {
+	command string
+	method  string
+	path    string
+}{
+	{"devices list", http.MethodGet, "/api/manager/devices/device/"},
+	{"devices set", http.MethodPut, "/api/manager/devices/device/x/capability/onoff"},
+	{"flows list", http.MethodGet, "/api/manager/flow/flow/"},
+	{"flows trigger", http.MethodPost, "/api/manager/flow/flow/x/trigger"},
+	{"zones list", http.MethodGet, "/api/manager/zones/zone/"},
+	{"moods set", http.MethodPost, "/api/manager/moods/mood/x/set"},
+	{"variables set", http.MethodPut, "/api/manager/logic/variable/x"},
+	{"apps list", http.MethodGet, "/api/manager/apps/app/"},
+	{"energy live", http.MethodGet, "/api/manager/energy/live"},
+	{"insights list", http.MethodGet, "/api/manager/insights/log"},
+	{"system info", http.MethodGet, "/api/manager/system/"},
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the connection and configuration",
+	Long: `Check that homeyctl can reach and use your Homey, and suggest fixes.
+
+Checks:
+  config      config.toml loads and has a token
+  legacy      no unmigrated homey-cli config is left behind
+  discovery   a Homey answers mDNS on the local network
+  reachable   the Homey answers /api/manager/system/ping
+  tls         HTTPS certificates are valid
+  token       Homey accepts the token
+  scopes      the token can run everyday commands
+  clock       the local clock agrees with Homey's
+  firmware    Homey's model and firmware version
+
+Exits non-zero if any check fails. The table format prints a report with
+fixes; the other formats print the checks.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checks, loadedCfg := runDoctor(cmd.Context())
+
+		// doctor loads the config itself, so a broken one can be reported;
+		// without one the report is printed as a table
+		cfg = loadedCfg
+		if cfg == nil {
+			cfg = &config.Config{Format: "table"}
+		}
+		if formatFlag != "" {
+			cfg.Format = formatFlag
+		}
+
+		if isTableFormat() {
+			printDoctorReport(checks)
+		} else if err := printList(checks, []render.Column{
+			{Header: "STATUS", Path: ".status"},
+			{Header: "CHECK", Path: ".name"},
+			{Header: "DETAIL", Path: ".detail"},
+			{Header: "FIX", Path: ".fix", Wide: true},
+		}); err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, c := range checks {
+			if c.Status == checkFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+// runDoctor runs all checks in order and returns them with the config, or
+// nil if it didn't load. Checks that need a working token are skipped when it
+// doesn't work.
+func runDoctor(ctx context.Context) ([]doctorCheck, *config.Config) {
+	var checks []doctorCheck
+
+	loadedCfg, configCheck := checkConfig()
+	checks = append(checks, configCheck, checkLegacyConfig())
+	checks = append(checks, checkDiscovery(ctx, loadedCfg))
+	if loadedCfg == nil {
+		return checks, nil
+	}
+
+	resolveTransport(ctx, loadedCfg)
+
+	var pinged *homeyPing
+	for _, target := range doctorTargets(loadedCfg) {
+		ping, check := checkReachable(ctx, target.name, target.address)
+		checks = append(checks, check)
+		if ping != nil && pinged == nil {
+			pinged = ping
+		}
+	}
+	for _, target := range doctorTargets(loadedCfg) {
+		checks = append(checks, checkTLS(ctx, target.name, target.address))
+	}
+
+	tokenCheck, scopesCheck, api := checkToken(ctx, loadedCfg)
+	checks = append(checks, tokenCheck, scopesCheck)
+	checks = append(checks, checkClock(pinged, time.Now()))
+	checks = append(checks, checkFirmware(loadedCfg, api))
+	return checks, loadedCfg
+}
+
+// printDoctorReport prints the checks with their fixes
+func printDoctorReport(checks []doctorCheck) {
+	counts := map[string]int{}
+	for _, c := range checks {
+		counts[c.Status]++
+		fmt.Printf("%-4s  %-18s %s\n", strings.ToUpper(c.Status), c.Name, c.Detail)
+		if c.Fix != "" {
+			for _, line := range strings.Split(c.Fix, "\n") {
+				fmt.Printf("      %-18s %s\n", "", line)
+			}
+		}
+	}
+	fmt.Println()
+	fmt.Printf("%d passed, %d warnings, %d failed, %d skipped\n",
+		counts[checkPass], counts[checkWarn], counts[checkFail], counts[checkSkip])
+}
+
+func checkConfig() (*config.Config, doctorCheck) {
+	check := doctorCheck{Name: "config"}
+
+	loadedCfg, err := config.Load()
+	if err != nil {
+		check.Status = checkFail
+		check.Detail = err.Error()
+		check.Fix = "Fix the file by hand, or move it aside and run: homeyctl login"
+		return nil, check
+	}
+
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		file = "no config file (environment only)"
+	}
+	check.Detail = fmt.Sprintf("%s (profile %s, mode %s)", file, activeProfileName(), loadedCfg.EffectiveMode())
+
+	if loadedCfg.EffectiveToken() == "" && loadedCfg.Cloud.AccessToken == "" {
+		check.Status = checkFail
+		check.Detail += ": no token configured"
+		check.Fix = "Run: homeyctl login"
+		return loadedCfg, check
+	}
+
+	check.Status = checkPass
+	return loadedCfg, check
+}
+
+func checkLegacyConfig() doctorCheck {
+	check := doctorCheck{Name: "legacy config"}
+
+	oldDir := config.LegacyConfigDir()
+	if oldDir == "" {
+		check.Status = checkPass
+		check.Detail = "no homey-cli config left to migrate"
+		return check
+	}
+
+	check.Status = checkWarn
+	check.Detail = "found config from homey-cli (the old name of homeyctl) in " + oldDir
+	check.Fix = fmt.Sprintf("Run: mv %s %s", oldDir, filepath.Join(filepath.Dir(oldDir), "homeyctl"))
+	return check
+}
+
+func checkDiscovery(ctx context.Context, loadedCfg *config.Config) doctorCheck {
+	check := doctorCheck{Name: "discovery"}
+
+	candidates, err := discoverHomeys(ctx, 3*time.Second)
+	if err != nil {
+		check.Status = checkWarn
+		check.Detail = "mDNS discovery failed: " + err.Error()
+		return check
+	}
+
+	usesLocal := loadedCfg != nil && loadedCfg.HasLocal()
+	if len(candidates) == 0 {
+		if !usesLocal {
+			check.Status = checkSkip
+			check.Detail = "no Homey answered mDNS (not needed in cloud mode)"
+			return check
+		}
+		check.Status = checkWarn
+		check.Detail = "no Homey answered mDNS on this network"
+		check.Fix = "Multicast may be blocked (VPN, guest Wi-Fi or client isolation). The configured address is still used."
+		return check
+	}
+
+	var found []string
+	for _, c := range candidates {
+		found = append(found, fmt.Sprintf("%s (%s)", c.Name, c.Address))
+	}
+	check.Detail = "found " + strings.Join(found, ", ")
+	check.Status = checkPass
+
+	if !usesLocal {
+		return check
+	}
+	configured := urlHost(loadedCfg.LocalURL())
+	for _, c := range candidates {
+		if urlHost(c.Address) == configured || c.Host == configured {
+			return check
+		}
+	}
+	check.Status = checkWarn
+	check.Detail += fmt.Sprintf("; none at the configured %s", loadedCfg.LocalURL())
+	check.Fix = fmt.Sprintf("If the Homey got a new address, run: homeyctl config set-local %s <token>", candidates[0].Address)
+	return check
+}
+
+// doctorTarget is an address homeyctl talks to
+type doctorTarget struct {
+	name    string
+	address string
+}
+
+// doctorTargets returns the configured local and cloud addresses
+func doctorTargets(loadedCfg *config.Config) []doctorTarget {
+	var targets []doctorTarget
+	if loadedCfg.HasLocal() {
+		targets = append(targets, doctorTarget{"local", loadedCfg.LocalURL()})
+	}
+	if loadedCfg.HasCloud() {
+		if cloudURL := loadedCfg.CloudURL(); cloudURL != "" {
+			targets = append(targets, doctorTarget{"cloud", cloudURL})
+		}
+	}
+	return targets
+}
+
+// homeyPing is a successful answer to /api/manager/system/ping
+type homeyPing struct {
+	homeyID string
+	latency time.Duration
+	date    time.Time // Homey's clock, from the Date header
+	sentAt  time.Time
+}
+
+// pingHomey calls the unauthenticated ping endpoint
+func pingHomey(ctx context.Context, address string) (*homeyPing, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address+"/api/manager/system/ping", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ping returned HTTP %d", resp.StatusCode)
+	}
+
+	ping := &homeyPing{
+		homeyID: resp.Header.Get("X-Homey-ID"),
+		latency: time.Since(start),
+		sentAt:  start,
+	}
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		ping.date = date
+	}
+	return ping, nil
+}
+
+func checkReachable(ctx context.Context, name, address string) (*homeyPing, doctorCheck) {
+	check := doctorCheck{Name: "reachable (" + name + ")"}
+
+	ping, err := pingHomey(ctx, address)
+	if err != nil {
+		check.Status = checkFail
+		check.Detail = fmt.Sprintf("%s: %v", address, err)
+		if name == "local" {
+			check.Fix = "Check that Homey is on and on the same network, or find it again with: homeyctl config discover"
+		} else {
+			check.Fix = "Check your internet connection and that Homey is online in the Homey app"
+		}
+		return nil, check
+	}
+
+	check.Status = checkPass
+	check.Detail = fmt.Sprintf("%s answered in %s", address, ping.latency.Round(time.Millisecond))
+	if ping.homeyID != "" {
+		check.Detail += " (Homey " + ping.homeyID + ")"
+	}
+	return ping, check
+}
+
+func checkTLS(ctx context.Context, name, address string) doctorCheck {
+	check := doctorCheck{Name: "tls (" + name + ")"}
+
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme != "https" {
+		check.Status = checkSkip
+		check.Detail = address + " uses plain HTTP"
+		return check
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: 5 * time.Second},
+		Config:    &tls.Config{ServerName: u.Hostname()},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		check.Status = checkFail
+		check.Detail = fmt.Sprintf("%s: %v", address, err)
+		if name == "local" {
+			check.Fix = "Use the address from 'homeyctl login' (https://<ip>.homey.homeylocal.com) or plain http://<ip>:\n  homeyctl config set-local http://<ip> <token>"
+		}
+		return check
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		check.Status = checkFail
+		check.Detail = address + " sent no certificate"
+		return check
+	}
+
+	expires := certs[0].NotAfter
+	check.Detail = fmt.Sprintf("certificate valid until %s", expires.Format("2006-01-02"))
+	if time.Until(expires) < certExpiryWarning {
+		check.Status = checkWarn
+		check.Detail = fmt.Sprintf("certificate expires soon (%s)", expires.Format("2006-01-02"))
+		check.Fix = "Homey renews its certificate while it is online; make sure it is connected to the internet"
+		return check
+	}
+	check.Status = checkPass
+	return check
+}
+
+// sessionInfo is the part of the session Homey reports that doctor needs
+type sessionInfo struct {
+	Type   string   `json:"type"`
+	Scopes []string `json:"scopes"`
+}
+
+// checkToken verifies the token and compares its scopes with doctorCommands.
+// It returns the client for later checks, or nil if the token doesn't work.
+// A cloud login is made on a copy of the config and not saved, so doctor
+// leaves the config as it found it.
+func checkToken(ctx context.Context, current *config.Config) (doctorCheck, doctorCheck, *client.Client) {
+	tokenCheck := doctorCheck{Name: "token"}
+	scopesCheck := doctorCheck{Name: "scopes", Status: checkSkip}
+
+	checked := *current
+	loadedCfg := &checked
+	if _, err := loginCloud(loadedCfg); err != nil {
+		tokenCheck.Status = checkFail
+		tokenCheck.Detail = err.Error()
+		tokenCheck.Fix = "Run: homeyctl login"
+		scopesCheck.Detail = "token not available"
+		return tokenCheck, scopesCheck, nil
+	}
+	if loadedCfg.EffectiveToken() == "" {
+		tokenCheck.Status = checkSkip
+		tokenCheck.Detail = "no token configured"
+		scopesCheck.Detail = "no token configured"
+		return tokenCheck, scopesCheck, nil
+	}
+
+	api := client.New(loadedCfg).WithContext(ctx)
+	api.SetTimeout(10 * time.Second)
+	api.SetRetryPolicy(client.RetryPolicy{})
+
+	data, err := api.GetSessionMe()
+	if err != nil {
+		if client.IsUnauthorized(err) {
+			tokenCheck.Status = checkFail
+			tokenCheck.Detail = "Homey rejected the token (revoked or expired)"
+			tokenCheck.Fix = "Run: homeyctl login"
+			scopesCheck.Detail = "token rejected"
+			return tokenCheck, scopesCheck, nil
+		}
+		if _, ok := client.AsAPIError(err); !ok {
+			tokenCheck.Status = checkSkip
+			tokenCheck.Detail = "could not reach Homey to check the token"
+			scopesCheck.Detail = "could not reach Homey"
+			return tokenCheck, scopesCheck, nil
+		}
+
+		// Older firmware doesn't report sessions; any authenticated call proves the token works
+		if _, err := api.GetUserMe(); err != nil && client.IsUnauthorized(err) {
+			tokenCheck.Status = checkFail
+			tokenCheck.Detail = "Homey rejected the token (revoked or expired)"
+			tokenCheck.Fix = "Run: homeyctl login"
+			scopesCheck.Detail = "token rejected"
+			return tokenCheck, scopesCheck, nil
+		}
+		tokenCheck.Status = checkPass
+		tokenCheck.Detail = fmt.Sprintf("accepted by %s", loadedCfg.BaseURL())
+		scopesCheck.Detail = "Homey did not report the token's scopes"
+		return tokenCheck, scopesCheck, api
+	}
+
+	var session sessionInfo
+	if err := json.Unmarshal(data, &session); err != nil {
+		tokenCheck.Status = checkWarn
+		tokenCheck.Detail = "accepted, but the session could not be parsed: " + err.Error()
+		return tokenCheck, scopesCheck, api
+	}
+
+	tokenCheck.Status = checkPass
+	tokenCheck.Detail = fmt.Sprintf("accepted by %s", loadedCfg.BaseURL())
+	if session.Type != "" {
+		tokenCheck.Detail += " (" + session.Type + " session)"
+	}
+	return tokenCheck, checkScopes(session.Scopes), api
+}
+
+// checkScopes reports the everyday commands the scopes don't allow
+func checkScopes(granted []string) doctorCheck {
+	check := doctorCheck{Name: "scopes"}
+
+	var blocked, needed []string
+	seen := map[string]bool{}
+	for _, c := range doctorCommands {
+		scope := requiredScope(c.method, c.path)
+		if scope == "" || scopeAllows(granted, scope) {
+			continue
+		}
+		blocked = append(blocked, fmt.Sprintf("%s (%s)", c.command, scope))
+		if !seen[scope] {
+			seen[scope] = true
+			needed = append(needed, scope)
+		}
+	}
+
+	if len(blocked) == 0 {
+		check.Status = checkPass
+		check.Detail = fmt.Sprintf("token allows all everyday commands (%d scopes)", len(granted))
+		return check
+	}
+
+	check.Status = checkWarn
+	check.Detail = "token can't run: " + strings.Join(blocked, ", ")
+	check.Fix = fmt.Sprintf("If you need these, create a token with the scopes:\n  homeyctl token create \"<name>\" --scopes %s", strings.Join(append(granted, needed...), ","))
+	return check
+}
+
+// scopeAllows reports whether granted scopes include scope. A scope also
+// grants its sub-scopes: "homey.device" allows "homey.device.control".
+func scopeAllows(granted []string, scope string) bool {
+	for _, g := range granted {
+		if g == scope || strings.HasPrefix(scope, g+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func checkClock(ping *homeyPing, now time.Time) doctorCheck {
+	check := doctorCheck{Name: "clock"}
+
+	if ping == nil || ping.date.IsZero() {
+		check.Status = checkSkip
+		check.Detail = "Homey's time is unknown"
+		return check
+	}
+
+	// The Date header is truncated to the second and sent partway through the request
+	homeyNow := ping.date.Add(now.Sub(ping.sentAt) - ping.latency/2)
+	skew := now.Sub(homeyNow).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if skew > maxClockSkew {
+		check.Status = checkWarn
+		check.Detail = fmt.Sprintf("local clock is %s off from Homey's", skew)
+		check.Fix = "Enable network time sync (e.g. timedatectl set-ntp true); login tokens fail with a wrong clock"
+		return check
+	}
+	check.Status = checkPass
+	check.Detail = fmt.Sprintf("within %s of Homey's", maxClockSkew)
+	return check
+}
+
+func checkFirmware(loadedCfg *config.Config, api *client.Client) doctorCheck {
+	check := doctorCheck{Name: "firmware"}
+
+	if api == nil {
+		check.Status = checkSkip
+		check.Detail = "needs a working token"
+		return check
+	}
+
+	data, err := api.GetSystem()
+	if err != nil {
+		check.Status = checkWarn
+		check.Detail = "could not read system info: " + err.Error()
+		if client.IsForbidden(err) {
+			check.Detail = "token lacks homey.system.readonly"
+		}
+		return check
+	}
+
+	var info SystemInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		check.Status = checkWarn
+		check.Detail = "could not parse system info: " + err.Error()
+		return check
+	}
+
+	check.Status = checkPass
+	check.Detail = fmt.Sprintf("%s running Homey %s", info.HomeyModelName, info.HomeyVersion)
+	if loadedCfg.HasCloud() && !info.CloudConnected {
+		check.Status = checkWarn
+		check.Detail += ", not connected to the Athom cloud"
+		check.Fix = "Cloud mode won't work until Homey is back online; check its internet connection"
+	}
+	return check
+}
+
+// urlHost returns the host part of an address, or the address itself
+func urlHost(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.Hostname() == "" {
+		return address
+	}
+	return u.Hostname()
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
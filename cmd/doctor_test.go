@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/discovery"
+	"github.com/langtind/homeyctl/internal/oauth"
+)
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		granted []string
+		scope   string
+		want    bool
+	}{
+		{[]string{"homey"}, "homey.device.control", true},
+		{[]string{"homey.device"}, "homey.device.readonly", true},
+		{[]string{"homey.device.readonly"}, "homey.device.readonly", true},
+		{[]string{"homey.device.readonly"}, "homey.device.control", false},
+		{[]string{"homey.dev"}, "homey.device.readonly", false},
+		{nil, "homey.device.readonly", false},
+	}
+
+	for _, tt := range tests {
+		if got := scopeAllows(tt.granted, tt.scope); got != tt.want {
+			t.Errorf("scopeAllows(%v, %q) = %v, want %v", tt.granted, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestCheckScopes(t *testing.T) {
+	if check := checkScopes([]string{"homey"}); check.Status != checkPass {
+		t.Errorf("expected pass for full access, got %+v", check)
+	}
+
+	check := checkScopes(scopePresets["readonly"])
+	if check.Status != checkWarn {
+		t.Fatalf("expected warn for a readonly token, got %+v", check)
+	}
+	if !strings.Contains(check.Detail, "devices set (homey.device.control)") {
+		t.Errorf("expected devices set to be reported, got %q", check.Detail)
+	}
+	if strings.Contains(check.Detail, "devices list") {
+		t.Errorf("devices list should be allowed, got %q", check.Detail)
+	}
+	if !strings.Contains(check.Fix, "--scopes") {
+		t.Errorf("expected a token create hint, got %q", check.Fix)
+	}
+}
+
+func TestCheckClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	inSync := &homeyPing{date: now.Add(-time.Second), sentAt: now.Add(-time.Second)}
+	if check := checkClock(inSync, now); check.Status != checkPass {
+		t.Errorf("expected pass, got %+v", check)
+	}
+
+	behind := &homeyPing{date: now.Add(-5 * time.Minute), sentAt: now}
+	check := checkClock(behind, now)
+	if check.Status != checkWarn || !strings.Contains(check.Detail, "5m0s") {
+		t.Errorf("expected a 5m skew warning, got %+v", check)
+	}
+
+	if check := checkClock(nil, now); check.Status != checkSkip {
+		t.Errorf("expected skip without a ping, got %+v", check)
+	}
+}
+
+func TestCheckReachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/manager/system/ping" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Homey-ID", "abc123")
+		w.Write([]byte(`"pong"`))
+	}))
+	defer server.Close()
+
+	ping, check := checkReachable(context.Background(), "local", server.URL)
+	if check.Status != checkPass || ping == nil {
+		t.Fatalf("expected pass, got %+v", check)
+	}
+	if ping.homeyID != "abc123" || ping.date.IsZero() {
+		t.Errorf("expected Homey ID and date, got %+v", ping)
+	}
+
+	server.Close()
+	if _, check := checkReachable(context.Background(), "local", server.URL); check.Status != checkFail || check.Fix == "" {
+		t.Errorf("expected fail with a fix for a closed server, got %+v", check)
+	}
+}
+
+func TestCheckTLS_PlainHTTP(t *testing.T) {
+	if check := checkTLS(context.Background(), "local", "http://192.168.1.50"); check.Status != checkSkip {
+		t.Errorf("expected skip for plain HTTP, got %+v", check)
+	}
+}
+
+func TestCheckTLS_UntrustedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if check := checkTLS(context.Background(), "local", server.URL); check.Status != checkFail {
+		t.Errorf("expected fail for a self-signed certificate, got %+v", check)
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOMEY_SECRETS_BACKEND", "plain")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Invalid token"}`))
+			return
+		}
+		w.Write([]byte(`{"type":"pat","scopes":["homey.device.readonly"]}`))
+	}))
+	defer server.Close()
+
+	good := &config.Config{Mode: "local", Local: config.LocalConfig{Address: server.URL, Token: "good"}}
+	tokenCheck, scopesCheck, api := checkToken(context.Background(), good)
+	if tokenCheck.Status != checkPass || api == nil {
+		t.Errorf("expected token pass, got %+v", tokenCheck)
+	}
+	if scopesCheck.Status != checkWarn {
+		t.Errorf("expected scopes warning for a readonly device token, got %+v", scopesCheck)
+	}
+
+	bad := &config.Config{Mode: "local", Local: config.LocalConfig{Address: server.URL, Token: "bad"}}
+	tokenCheck, scopesCheck, api = checkToken(context.Background(), bad)
+	if tokenCheck.Status != checkFail || api != nil {
+		t.Errorf("expected token fail, got %+v", tokenCheck)
+	}
+	if scopesCheck.Status != checkSkip {
+		t.Errorf("expected scopes skipped, got %+v", scopesCheck)
+	}
+}
+
+func TestCheckToken_CloudLoginNotSaved(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type":"session","scopes":["homey"]}`))
+	}))
+	defer server.Close()
+	stubConnectCloud(t, func(string, string) (*oauth.Homey, error) {
+		return &oauth.Homey{ID: "abc", RemoteURL: server.URL, Token: "session"}, nil
+	})
+
+	cfg := &config.Config{Mode: "cloud", Cloud: config.CloudConfig{AccessToken: "athom-token", HomeyID: "abc"}}
+	if tokenCheck, _, _ := checkToken(context.Background(), cfg); tokenCheck.Status != checkPass {
+		t.Errorf("expected token pass, got %+v", tokenCheck)
+	}
+	if cfg.Cloud.Token != "" {
+		t.Errorf("expected the config to be left alone, got token %q", cfg.Cloud.Token)
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "homeyctl")); !os.IsNotExist(err) {
+		t.Errorf("expected no config to be saved, got %v", err)
+	}
+}
+
+func TestCheckDiscovery_ConfiguredAddressMissing(t *testing.T) {
+	orig := discoverHomeys
+	discoverHomeys = func(ctx context.Context, timeout time.Duration) ([]discovery.HomeyCandidate, error) {
+		return []discovery.HomeyCandidate{{Name: "Homey", Address: "http://192.168.1.60", Host: "192.168.1.60"}}, nil
+	}
+	t.Cleanup(func() { discoverHomeys = orig })
+
+	moved := &config.Config{Mode: "local", Local: config.LocalConfig{Address: "http://192.168.1.50", Token: "tok"}}
+	check := checkDiscovery(context.Background(), moved)
+	if check.Status != checkWarn || !strings.Contains(check.Fix, "set-local http://192.168.1.60") {
+		t.Errorf("expected a warning suggesting the new address, got %+v", check)
+	}
+
+	same := &config.Config{Mode: "local", Local: config.LocalConfig{Address: "http://192.168.1.60", Token: "tok"}}
+	if check := checkDiscovery(context.Background(), same); check.Status != checkPass {
+		t.Errorf("expected pass, got %+v", check)
+	}
+}
@@ -3,10 +3,11 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"text/tabwriter"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/langtind/homeyctl/internal/render"
 	"github.com/spf13/cobra"
 )
 
@@ -25,24 +26,24 @@ var energyLiveCmd = &cobra.Command{
 			return err
 		}
 
-		if isTableFormat() {
-			var report struct {
-				ZoneName       string               `json:"zoneName"`
-				TotalConsumed  struct{ W *float64 } `json:"totalConsumed"`
-				TotalGenerated struct{ W *float64 } `json:"totalGenerated"`
-				Items          []struct {
-					Type   string  `json:"type"`
-					ID     string  `json:"id"`
-					Name   *string `json:"name"`
-					Values struct {
-						W *float64 `json:"W"`
-					} `json:"values"`
-				} `json:"items"`
-			}
-			if err := json.Unmarshal(data, &report); err != nil {
-				return fmt.Errorf("failed to parse energy data: %w", err)
-			}
+		var report struct {
+			ZoneName       string               `json:"zoneName"`
+			TotalConsumed  struct{ W *float64 } `json:"totalConsumed"`
+			TotalGenerated struct{ W *float64 } `json:"totalGenerated"`
+			Items          []struct {
+				Type   string  `json:"type"`
+				ID     string  `json:"id"`
+				Name   *string `json:"name"`
+				Values struct {
+					W *float64 `json:"W"`
+				} `json:"values"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(data, &report); err != nil {
+			return printOutput(data)
+		}
 
+		if showTitles() {
 			fmt.Printf("Zone: %s\n", report.ZoneName)
 			if report.TotalConsumed.W != nil {
 				fmt.Printf("Total consumed: %.1f W\n", *report.TotalConsumed.W)
@@ -51,21 +52,20 @@ var energyLiveCmd = &cobra.Command{
 				fmt.Printf("Total generated: %.1f W\n", *report.TotalGenerated.W)
 			}
 			fmt.Println()
+		}
 
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "DEVICE\tPOWER (W)")
-			fmt.Fprintln(w, "------\t---------")
-			for _, item := range report.Items {
-				if item.Type == "device" && item.Name != nil && item.Values.W != nil {
-					fmt.Fprintf(w, "%s\t%.1f\n", *item.Name, *item.Values.W)
-				}
+		rows := []map[string]interface{}{}
+		for _, item := range report.Items {
+			if item.Type == "device" && item.Name != nil && item.Values.W != nil {
+				rows = append(rows, map[string]interface{}{"id": item.ID, "device": *item.Name, "power": *item.Values.W})
 			}
-			w.Flush()
-			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printRows(data, rows, []render.Column{
+			{Header: "DEVICE", Path: ".device"},
+			{Header: "POWER (W)", Value: decimals(".power", 1)},
+			{Header: "ID", Path: ".id", Wide: true},
+		})
 	},
 }
 
@@ -124,12 +124,7 @@ Examples:
 			return err
 		}
 
-		if isTableFormat() {
-			return printEnergyReportTable(data, period, date)
-		}
-
-		outputJSON(data)
-		return nil
+		return printEnergyReport(data, period)
 	},
 }
 
@@ -139,7 +134,9 @@ type deviceEnergy struct {
 	Total  *float64 `json:"total"`
 }
 
-func printEnergyReportTable(data json.RawMessage, period, date string) error {
+// printEnergyReport prints a day, week or month report. The table lists
+// the energy of each device and EV charger.
+func printEnergyReport(data json.RawMessage, period string) error {
 	var report struct {
 		Date        string `json:"date"`
 		Electricity struct {
@@ -154,64 +151,47 @@ func printEnergyReportTable(data json.RawMessage, period, date string) error {
 		} `json:"electricity"`
 	}
 	if err := json.Unmarshal(data, &report); err != nil {
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	}
 
-	fmt.Printf("Energy Report: %s (%s)\n", report.Date, period)
-	fmt.Println()
+	if showTitles() {
+		fmt.Printf("Energy Report: %s (%s)\n", report.Date, period)
+		fmt.Println()
 
-	if report.Electricity.ConsumedPeriod != nil {
-		fmt.Printf("Total consumed: %.2f kWh\n", *report.Electricity.ConsumedPeriod)
-	}
-	if report.Electricity.ImportedPeriod != nil {
-		fmt.Printf("Total imported: %.2f kWh\n", *report.Electricity.ImportedPeriod)
-	}
-	if report.Electricity.GeneratedPeriod != nil && *report.Electricity.GeneratedPeriod > 0 {
-		fmt.Printf("Total generated: %.2f kWh\n", *report.Electricity.GeneratedPeriod)
-	}
-
-	// Show consumed devices
-	if len(report.Electricity.Devices.Consumed) > 0 {
-		fmt.Println("\nDevices:")
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "  DEVICE\tPERIOD\tTOTAL")
-		fmt.Fprintln(w, "  ------\t------\t-----")
-		for _, d := range report.Electricity.Devices.Consumed {
-			periodStr := "-"
-			totalStr := "-"
-			if d.Period != nil {
-				periodStr = fmt.Sprintf("%.2f kWh", *d.Period)
-			}
-			if d.Total != nil {
-				totalStr = fmt.Sprintf("%.2f kWh", *d.Total)
-			}
-			fmt.Fprintf(w, "  %s\t%s\t%s\n", d.Name, periodStr, totalStr)
+		if report.Electricity.ConsumedPeriod != nil {
+			fmt.Printf("Total consumed: %.2f kWh\n", *report.Electricity.ConsumedPeriod)
 		}
-		w.Flush()
-	}
-
-	// Show EV chargers separately if present
-	if len(report.Electricity.Devices.EVChargerCharged) > 0 {
-		fmt.Println("\nEV Chargers:")
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "  CHARGER\tPERIOD\tTOTAL")
-		fmt.Fprintln(w, "  -------\t------\t-----")
-		for _, d := range report.Electricity.Devices.EVChargerCharged {
-			periodStr := "-"
-			totalStr := "-"
-			if d.Period != nil {
-				periodStr = fmt.Sprintf("%.2f kWh", *d.Period)
-			}
-			if d.Total != nil {
-				totalStr = fmt.Sprintf("%.2f kWh", *d.Total)
-			}
-			fmt.Fprintf(w, "  %s\t%s\t%s\n", d.Name, periodStr, totalStr)
+		if report.Electricity.ImportedPeriod != nil {
+			fmt.Printf("Total imported: %.2f kWh\n", *report.Electricity.ImportedPeriod)
 		}
-		w.Flush()
+		if report.Electricity.GeneratedPeriod != nil && *report.Electricity.GeneratedPeriod > 0 {
+			fmt.Printf("Total generated: %.2f kWh\n", *report.Electricity.GeneratedPeriod)
+		}
+		fmt.Println()
 	}
 
-	return nil
+	rows := energyRows(report.Electricity.Devices.Consumed, "device")
+	rows = append(rows, energyRows(report.Electricity.Devices.EVChargerCharged, "EV charger")...)
+
+	return printRows(data, rows, []render.Column{
+		{Header: "DEVICE", Path: ".name"},
+		{Header: "KIND", Path: ".kind"},
+		{Header: "PERIOD (kWh)", Value: decimals(".period", 2)},
+		{Header: "TOTAL (kWh)", Value: decimals(".total", 2)},
+		{Header: "ID", Path: ".id", Wide: true},
+	})
+}
+
+// energyRows lists the devices of a report section, sorted by name
+func energyRows(devices map[string]deviceEnergy, kind string) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(devices))
+	for id, d := range devices {
+		rows = append(rows, map[string]interface{}{"id": id, "name": d.Name, "kind": kind, "period": d.Period, "total": d.Total})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return strings.ToLower(rows[i]["name"].(string)) < strings.ToLower(rows[j]["name"].(string))
+	})
+	return rows
 }
 
 var energyReportYearCmd = &cobra.Command{
@@ -244,8 +224,7 @@ Examples:
 				} `json:"electricity"`
 			}
 			if err := json.Unmarshal(data, &report); err != nil {
-				outputJSON(data)
-				return nil
+				return printOutput(data)
 			}
 
 			fmt.Printf("Energy Report: Year %s\n\n", year)
@@ -261,8 +240,7 @@ Examples:
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -302,15 +280,13 @@ var energyCurrencyCmd = &cobra.Command{
 		if isTableFormat() {
 			var currency string
 			if err := json.Unmarshal(data, &currency); err != nil {
-				outputJSON(data)
-				return nil
+				return printOutput(data)
 			}
 			fmt.Printf("Energy currency: %s\n", currency)
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -324,45 +300,44 @@ var energyPriceCmd = &cobra.Command{
 			return err
 		}
 
-		if isTableFormat() {
-			var prices struct {
-				PriceUnit         string `json:"priceUnit"`
-				PricesPerInterval []struct {
-					PeriodStart string  `json:"periodStart"`
-					PeriodEnd   string  `json:"periodEnd"`
-					Value       float64 `json:"value"`
-				} `json:"pricesPerInterval"`
-			}
-			if err := json.Unmarshal(data, &prices); err != nil {
-				outputJSON(data)
-				return nil
-			}
+		var prices struct {
+			PriceUnit         string `json:"priceUnit"`
+			PricesPerInterval []struct {
+				PeriodStart string  `json:"periodStart"`
+				PeriodEnd   string  `json:"periodEnd"`
+				Value       float64 `json:"value"`
+			} `json:"pricesPerInterval"`
+		}
+		if err := json.Unmarshal(data, &prices); err != nil {
+			return printOutput(data)
+		}
 
-			now := time.Now()
+		if showTitles() {
 			fmt.Printf("Electricity prices for %s (%s)\n\n", date, prices.PriceUnit)
+		}
 
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "TIME\tPRICE")
-			fmt.Fprintln(w, "----\t-----")
-			for _, p := range prices.PricesPerInterval {
-				start, _ := time.Parse(time.RFC3339, p.PeriodStart)
-				end, _ := time.Parse(time.RFC3339, p.PeriodEnd)
-				marker := ""
-				if now.After(start) && now.Before(end) {
-					marker = " <-- now"
-				}
-				fmt.Fprintf(w, "%s-%s\t%.2f%s\n",
-					start.Local().Format("15:04"),
-					end.Local().Format("15:04"),
-					p.Value,
-					marker)
-			}
-			w.Flush()
-			return nil
+		now := time.Now()
+		rows := []map[string]interface{}{}
+		for _, p := range prices.PricesPerInterval {
+			start, _ := time.Parse(time.RFC3339, p.PeriodStart)
+			end, _ := time.Parse(time.RFC3339, p.PeriodEnd)
+			rows = append(rows, map[string]interface{}{
+				"time":  start.Local().Format("15:04") + "-" + end.Local().Format("15:04"),
+				"price": p.Value,
+				"now":   now.After(start) && now.Before(end),
+			})
 		}
 
-		outputJSON(data)
-		return nil
+		return printRows(data, rows, []render.Column{
+			{Header: "TIME", Path: ".time"},
+			{Header: "PRICE", Value: decimals(".price", 2)},
+			{Header: "NOW", Value: func(item map[string]interface{}) interface{} {
+				if now, _ := item["now"].(bool); now {
+					return "<--"
+				}
+				return ""
+			}},
+		})
 	},
 }
 
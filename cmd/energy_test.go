@@ -98,3 +98,22 @@ func TestEnergyPriceTypeCommand_Exists(t *testing.T) {
 		t.Errorf("expected command name 'type', got '%s'", cmd.Name())
 	}
 }
+
+func TestEnergyRows(t *testing.T) {
+	period, total := 1.5, 120.25
+	rows := energyRows(map[string]deviceEnergy{
+		"b": {Name: "heater", Period: &period, Total: &total},
+		"a": {Name: "Dryer"},
+	}, "device")
+	if len(rows) != 2 || rows[0]["name"] != "Dryer" || rows[1]["id"] != "b" || rows[1]["kind"] != "device" {
+		t.Fatalf("unexpected rows: %+v", rows)
+	}
+
+	format := decimals(".total", 2)
+	if got := format(map[string]interface{}{"total": 120.25}); got != "120.25" {
+		t.Errorf("unexpected value: %v", got)
+	}
+	if got := format(map[string]interface{}{"total": nil}); got != nil {
+		t.Errorf("expected nothing for a missing value, got %v", got)
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/langtind/homeyctl/internal/client"
+)
+
+// managerScopes maps API manager names to their base scope
+var managerScopes = map[string]string{
+	"alarms":        "homey.alarm",
+	"apps":          "homey.app",
+	"dashboards":    "homey.dashboard",
+	"devices":       "homey.device",
+	"energy":        "homey.energy",
+	"flow":          "homey.flow",
+	"geolocation":   "homey.geolocation",
+	"insights":      "homey.insights",
+	"logic":         "homey.logic",
+	"moods":         "homey.mood",
+	"notifications": "homey.notifications",
+	"presence":      "homey.presence",
+	"system":        "homey.system",
+	"updates":       "homey.updates",
+	"users":         "homey.user",
+	"zones":         "homey.zone",
+}
+
+// requiredScope returns the narrowest scope that allows a request, or "" if unknown
+func requiredScope(method, path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 || parts[0] != "api" || parts[1] != "manager" {
+		return ""
+	}
+
+	base, ok := managerScopes[parts[2]]
+	if !ok {
+		return ""
+	}
+
+	if method == http.MethodGet {
+		return base + ".readonly"
+	}
+
+	last := parts[len(parts)-1]
+	switch parts[2] {
+	case "devices":
+		if len(parts) >= 6 && parts[len(parts)-2] == "capability" {
+			return "homey.device.control"
+		}
+	case "flow":
+		if last == "trigger" {
+			return "homey.flow.start"
+		}
+	case "apps":
+		if last == "restart" || last == "enable" || last == "disable" {
+			return "homey.app.control"
+		}
+	case "moods":
+		if last == "set" {
+			return "homey.mood.set"
+		}
+	case "presence":
+		if len(parts) >= 4 && parts[3] == "me" {
+			return "homey.presence.self"
+		}
+	}
+
+	return base
+}
+
+// errorHint returns extra guidance for API errors, or "" if there is none
+func errorHint(err error) string {
+	apiErr, ok := client.AsAPIError(err)
+	if !ok {
+		return ""
+	}
+
+	switch {
+	case client.IsForbidden(err):
+		scope := requiredScope(apiErr.Method, apiErr.Path)
+		if scope == "" {
+			return "Hint: your token is missing a scope required for this operation."
+		}
+		return fmt.Sprintf("Hint: this operation requires the '%s' scope. Ask the Homey owner for a token with it:\n"+
+			"  homeyctl token create \"<name>\" --scopes %s", scope, scope)
+	case client.IsUnauthorized(err):
+		return "Hint: your token was rejected or has expired. Run: homeyctl login"
+	case client.IsNotFound(err):
+		return "Hint: the item was not found. It may have been deleted or the ID is wrong."
+	}
+
+	return ""
+}
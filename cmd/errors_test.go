@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/langtind/homeyctl/internal/client"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"GET", "/api/manager/devices/device/", "homey.device.readonly"},
+		{"PUT", "/api/manager/devices/device/abc/capability/onoff", "homey.device.control"},
+		{"PUT", "/api/manager/devices/device/abc", "homey.device"},
+		{"POST", "/api/manager/flow/flow/abc/trigger", "homey.flow.start"},
+		{"POST", "/api/manager/moods/mood/abc/set", "homey.mood.set"},
+		{"POST", "/api/manager/apps/app/abc/restart", "homey.app.control"},
+		{"PUT", "/api/manager/presence/me/present", "homey.presence.self"},
+		{"GET", "/api/manager/energy/report/day?date=2024-01-01", "homey.energy.readonly"},
+		{"DELETE", "/api/manager/logic/variable/abc", "homey.logic"},
+		{"GET", "/api/unknown", ""},
+	}
+
+	for _, tc := range tests {
+		if got := requiredScope(tc.method, tc.path); got != tc.want {
+			t.Errorf("requiredScope(%s, %s) = %q, want %q", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestErrorHint_NonAPIError(t *testing.T) {
+	if hint := errorHint(fmt.Errorf("device not found: x")); hint != "" {
+		t.Errorf("expected no hint, got %q", hint)
+	}
+}
+
+func TestErrorHint_Forbidden(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &client.APIError{
+		StatusCode: 403,
+		Method:     "POST",
+		Path:       "/api/manager/flow/flow/abc/trigger",
+		Message:    "Missing Scopes",
+	})
+	hint := errorHint(err)
+	if !strings.Contains(hint, "homey.flow.start") {
+		t.Errorf("expected hint to name homey.flow.start, got %q", hint)
+	}
+}
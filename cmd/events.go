@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/langtind/homeyctl/internal/client"
+	"github.com/spf13/cobra"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Stream realtime events",
+	Long:  `Stream realtime events from Homey (device changes, flows, variables, presence, notifications).`,
+}
+
+var eventsManagerFilter string
+
+var eventsWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Print realtime events as NDJSON",
+	Long: `Connect to Homey's realtime API and print one JSON object per event.
+
+The connection is re-established automatically if it drops. Press Ctrl-C to stop.
+
+Managers:
+  devices        - Capability changes, device create/update/delete
+  flow           - Flow events
+  logic          - Variable updates
+  presence       - Presence and sleep changes
+  notifications  - New notifications
+
+Examples:
+  homeyctl events watch
+  homeyctl events watch --manager devices
+  homeyctl events watch --manager devices,flow,logic | jq 'select(.type == "capability")'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var managers []string
+		if eventsManagerFilter != "" {
+			managers = strings.Split(eventsManagerFilter, ",")
+		}
+
+		events, err := apiClient.Subscribe(cmd.Context(), client.SubscribeOptions{
+			Managers: managers,
+			OnError: func(err error) {
+				fmt.Fprintf(os.Stderr, "Reconnecting: %v\n", err)
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		for event := range events {
+			if err := enc.Encode(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+	eventsCmd.AddCommand(eventsWatchCmd)
+	eventsWatchCmd.Flags().StringVar(&eventsManagerFilter, "manager", "", "Comma-separated managers to watch: devices, flow, logic, presence, notifications (default: all)")
+}
@@ -4,27 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
-type Flow struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Enabled     bool   `json:"enabled"`
-	Triggerable bool   `json:"triggerable"`
-	Broken      bool   `json:"broken"`
-}
+type Flow = homey.Flow
 
-type AdvancedFlow struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Enabled     bool   `json:"enabled"`
-	Triggerable bool   `json:"triggerable"`
-	Broken      bool   `json:"broken"`
-}
+type AdvancedFlow = homey.AdvancedFlow
 
 var flowsCmd = &cobra.Command{
 	Use:   "flows",
@@ -97,68 +87,156 @@ Examples:
 			}
 		}
 
-		if isTableFormat() {
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tTYPE\tENABLED\tID")
-			fmt.Fprintln(w, "----\t----\t-------\t--")
+		return printList(allFlows, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "TYPE", Path: ".type"},
+			{Header: "ENABLED", Value: yesNo("enabled")},
+			{Header: "ID", Path: ".id"},
+			{Header: "TRIGGERABLE", Value: yesNo("triggerable"), Wide: true},
+			{Header: "BROKEN", Value: yesNo("broken"), Wide: true},
+		})
+	},
+}
 
-			for _, f := range allFlows {
-				enabled := "yes"
-				if !f.Enabled {
-					enabled = "no"
-				}
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Name, f.Type, enabled, f.ID)
-			}
+// getAllFlows fetches both simple and advanced flows
+func getAllFlows() (map[string]Flow, map[string]AdvancedFlow, error) {
+	normalData, err := apiClient.GetFlows()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get flows: %w", err)
+	}
 
-			w.Flush()
-			return nil
+	advancedData, err := apiClient.GetAdvancedFlows()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get advanced flows: %w", err)
+	}
+
+	var normalFlows map[string]Flow
+	if err := json.Unmarshal(normalData, &normalFlows); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse flows: %w", err)
+	}
+
+	var advancedFlows map[string]AdvancedFlow
+	if err := json.Unmarshal(advancedData, &advancedFlows); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse advanced flows: %w", err)
+	}
+
+	return normalFlows, advancedFlows, nil
+}
+
+// matchFlow resolves a flow by ID or case-insensitive name across simple and
+// advanced flows. An exact ID match wins; a name shared by several flows is
+// reported as ambiguous.
+func matchFlow(normalFlows map[string]Flow, advancedFlows map[string]AdvancedFlow, nameOrID string) (*FlowListItem, error) {
+	var all []FlowListItem
+	for id, f := range normalFlows {
+		if f.ID == "" {
+			f.ID = id
+		}
+		all = append(all, FlowListItem{
+			ID:          f.ID,
+			Name:        f.Name,
+			Type:        "simple",
+			Enabled:     f.Enabled,
+			Triggerable: f.Triggerable,
+			Broken:      f.Broken,
+		})
+	}
+	for id, f := range advancedFlows {
+		if f.ID == "" {
+			f.ID = id
+		}
+		all = append(all, FlowListItem{
+			ID:          f.ID,
+			Name:        f.Name,
+			Type:        "advanced",
+			Enabled:     f.Enabled,
+			Triggerable: f.Triggerable,
+			Broken:      f.Broken,
+		})
+	}
+
+	for _, f := range all {
+		if f.ID == nameOrID {
+			return &f, nil
+		}
+	}
+
+	var matches []FlowListItem
+	for _, f := range all {
+		if strings.EqualFold(f.Name, nameOrID) {
+			matches = append(matches, f)
 		}
+	}
 
-		out, _ := json.MarshalIndent(allFlows, "", "  ")
-		fmt.Println(string(out))
-		return nil
-	},
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("flow not found: %s", nameOrID)
+	case 1:
+		return &matches[0], nil
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+	var ids []string
+	for _, f := range matches {
+		ids = append(ids, fmt.Sprintf("%s (%s)", f.ID, f.Type))
+	}
+	return nil, fmt.Errorf("multiple flows named '%s', use an ID instead: %s", nameOrID, strings.Join(ids, ", "))
+}
+
+// checkFlowTriggerable returns an error if the flow cannot be started manually
+func checkFlowTriggerable(f *FlowListItem) error {
+	switch {
+	case !f.Enabled:
+		return fmt.Errorf("flow '%s' is disabled", f.Name)
+	case f.Broken:
+		return fmt.Errorf("flow '%s' is broken (check for missing devices or apps)", f.Name)
+	case !f.Triggerable:
+		return fmt.Errorf("flow '%s' is not triggerable (its trigger card cannot be started manually)", f.Name)
+	}
+	return nil
 }
 
 var flowsTriggerCmd = &cobra.Command{
 	Use:   "trigger <name-or-id>",
 	Short: "Trigger a flow",
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		nameOrID := args[0]
+	Long: `Trigger a simple or advanced flow by name or ID.
 
-		// Get all flows to find by name
-		normalData, _ := apiClient.GetFlows()
-		advancedData, _ := apiClient.GetAdvancedFlows()
+Names are matched case-insensitively. If several flows share the same
+name, use the flow ID instead. Disabled, broken, and non-triggerable
+flows are refused.
 
-		var normalFlows map[string]Flow
-		var advancedFlows map[string]AdvancedFlow
-		json.Unmarshal(normalData, &normalFlows)
-		json.Unmarshal(advancedData, &advancedFlows)
+Examples:
+  homeyctl flows trigger "Good night"
+  homeyctl flows trigger abc123-flow-id`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		normalFlows, advancedFlows, err := getAllFlows()
+		if err != nil {
+			return err
+		}
 
-		// Try normal flows first
-		for _, f := range normalFlows {
-			if f.ID == nameOrID || strings.EqualFold(f.Name, nameOrID) {
-				if err := apiClient.TriggerFlow(f.ID); err != nil {
-					return err
-				}
-				fmt.Printf("Triggered flow: %s\n", f.Name)
-				return nil
-			}
+		flow, err := matchFlow(normalFlows, advancedFlows, args[0])
+		if err != nil {
+			return err
 		}
 
-		// Try advanced flows
-		for _, f := range advancedFlows {
-			if f.ID == nameOrID || strings.EqualFold(f.Name, nameOrID) {
-				if err := apiClient.TriggerAdvancedFlow(f.ID); err != nil {
-					return err
-				}
-				fmt.Printf("Triggered advanced flow: %s\n", f.Name)
-				return nil
+		if err := checkFlowTriggerable(flow); err != nil {
+			return err
+		}
+
+		if flow.Type == "advanced" {
+			if err := apiClient.TriggerAdvancedFlow(flow.ID); err != nil {
+				return err
 			}
+			fmt.Printf("Triggered advanced flow: %s\n", flow.Name)
+			return nil
 		}
 
-		return fmt.Errorf("flow not found: %s", nameOrID)
+		if err := apiClient.TriggerFlow(flow.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Triggered flow: %s\n", flow.Name)
+		return nil
 	},
 }
 
@@ -182,8 +260,7 @@ var flowsGetCmd = &cobra.Command{
 			var f Flow
 			json.Unmarshal(raw, &f)
 			if id == nameOrID || strings.EqualFold(f.Name, nameOrID) {
-				outputJSON(raw)
-				return nil
+				return printOutput(raw)
 			}
 		}
 
@@ -192,8 +269,7 @@ var flowsGetCmd = &cobra.Command{
 			var f AdvancedFlow
 			json.Unmarshal(raw, &f)
 			if id == nameOrID || strings.EqualFold(f.Name, nameOrID) {
-				outputJSON(raw)
-				return nil
+				return printOutput(raw)
 			}
 		}
 
@@ -578,32 +654,28 @@ Examples:
 			return err
 		}
 
-		if isTableFormat() {
-			var cards []struct {
-				ID    string `json:"id"`
-				Title string `json:"title"`
-			}
-			if err := json.Unmarshal(data, &cards); err != nil {
-				return err
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "TITLE\tID")
-			fmt.Fprintln(w, "-----\t--")
+		columns := []render.Column{
+			{Header: "TITLE", Path: ".title"},
+			{Header: "ID", Path: ".id"},
+		}
+		if filter == "" {
+			return printList(data, columns)
+		}
 
-			for _, c := range cards {
-				if filter != "" && !strings.Contains(strings.ToLower(c.ID), strings.ToLower(filter)) &&
-					!strings.Contains(strings.ToLower(c.Title), strings.ToLower(filter)) {
-					continue
-				}
-				fmt.Fprintf(w, "%s\t%s\n", c.Title, c.ID)
+		var cards []map[string]interface{}
+		if err := json.Unmarshal(data, &cards); err != nil {
+			return err
+		}
+		var matched []map[string]interface{}
+		for _, c := range cards {
+			id, _ := c["id"].(string)
+			title, _ := c["title"].(string)
+			if strings.Contains(strings.ToLower(id), strings.ToLower(filter)) ||
+				strings.Contains(strings.ToLower(title), strings.ToLower(filter)) {
+				matched = append(matched, c)
 			}
-			w.Flush()
-			return nil
 		}
-
-		outputJSON(data)
-		return nil
+		return printList(matched, columns)
 	},
 }
 
@@ -3,19 +3,15 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
 // FlowFolder represents a Homey flow folder
-type FlowFolder struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Parent string `json:"parent"`
-}
+type FlowFolder = homey.FlowFolder
 
 var flowsFoldersCmd = &cobra.Command{
 	Use:   "folders",
@@ -63,23 +59,18 @@ var flowsFoldersListCmd = &cobra.Command{
 				fmt.Println("No flow folders found.")
 				return nil
 			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tPARENT\tID")
-			fmt.Fprintln(w, "----\t------\t--")
-			for _, f := range folders {
-				parent := f.Parent
-				if parent == "" {
-					parent = "(root)"
-				}
-				fmt.Fprintf(w, "%s\t%s\t%s\n", f.Name, parent, f.ID)
-			}
-			w.Flush()
-			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printList(data, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "PARENT", Value: func(f map[string]interface{}) interface{} {
+				if parent, _ := f["parent"].(string); parent != "" {
+					return parent
+				}
+				return "(root)"
+			}},
+			{Header: "ID", Path: ".id"},
+		})
 	},
 }
 
@@ -98,8 +89,7 @@ var flowsFoldersGetCmd = &cobra.Command{
 			return err
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -138,8 +128,7 @@ Examples:
 			return nil
 		}
 
-		outputJSON(result)
-		return nil
+		return printOutput(result)
 	},
 }
 
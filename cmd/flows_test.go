@@ -183,3 +183,94 @@ func TestNormalizeSimpleFlow_PreservesExistingGroup(t *testing.T) {
 		t.Errorf("expected group 'else' to be preserved, got %v", action["group"])
 	}
 }
+
+func TestMatchFlow_ByIDAndName(t *testing.T) {
+	normal := map[string]Flow{
+		"f1": {ID: "f1", Name: "Good Night", Enabled: true, Triggerable: true},
+	}
+	advanced := map[string]AdvancedFlow{
+		"a1": {ID: "a1", Name: "Morning", Enabled: true, Triggerable: true},
+	}
+
+	f, err := matchFlow(normal, advanced, "good night")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.ID != "f1" || f.Type != "simple" {
+		t.Errorf("expected simple flow f1, got %s (%s)", f.ID, f.Type)
+	}
+
+	f, err = matchFlow(normal, advanced, "a1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Type != "advanced" {
+		t.Errorf("expected advanced flow, got %s", f.Type)
+	}
+}
+
+func TestMatchFlow_UsesMapKeyWhenIDMissing(t *testing.T) {
+	normal := map[string]Flow{
+		"f1": {Name: "Good Night"},
+	}
+
+	f, err := matchFlow(normal, nil, "f1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.ID != "f1" {
+		t.Errorf("expected ID f1, got %q", f.ID)
+	}
+}
+
+func TestMatchFlow_Ambiguous(t *testing.T) {
+	normal := map[string]Flow{
+		"f1": {ID: "f1", Name: "Lights"},
+	}
+	advanced := map[string]AdvancedFlow{
+		"a1": {ID: "a1", Name: "lights"},
+	}
+
+	_, err := matchFlow(normal, advanced, "Lights")
+	if err == nil {
+		t.Fatal("expected error for ambiguous name")
+	}
+	if !strings.Contains(err.Error(), "multiple flows") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMatchFlow_NotFound(t *testing.T) {
+	_, err := matchFlow(map[string]Flow{}, map[string]AdvancedFlow{}, "missing")
+	if err == nil || !strings.Contains(err.Error(), "flow not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestCheckFlowTriggerable(t *testing.T) {
+	tests := []struct {
+		name    string
+		flow    FlowListItem
+		wantErr string
+	}{
+		{"ok", FlowListItem{Name: "A", Enabled: true, Triggerable: true}, ""},
+		{"disabled", FlowListItem{Name: "A", Enabled: false, Triggerable: true}, "disabled"},
+		{"broken", FlowListItem{Name: "A", Enabled: true, Triggerable: true, Broken: true}, "broken"},
+		{"not triggerable", FlowListItem{Name: "A", Enabled: true}, "not triggerable"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkFlowTriggerable(&tc.flow)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
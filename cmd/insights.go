@@ -3,21 +3,14 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"text/tabwriter"
 	"time"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
-type InsightLog struct {
-	ID       string `json:"id"`
-	OwnerURI string `json:"ownerUri"`
-	OwnerID  string `json:"ownerId"`
-	Title    string `json:"title"`
-	Type     string `json:"type"`
-	Units    string `json:"units"`
-}
+type InsightLog = homey.InsightLog
 
 var insightsCmd = &cobra.Command{
 	Use:   "insights",
@@ -34,24 +27,12 @@ var insightsListCmd = &cobra.Command{
 			return err
 		}
 
-		if isTableFormat() {
-			var logs []InsightLog
-			if err := json.Unmarshal(data, &logs); err != nil {
-				return fmt.Errorf("failed to parse insights: %w", err)
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "TITLE\tTYPE\tUNITS\tID")
-			fmt.Fprintln(w, "-----\t----\t-----\t--")
-			for _, l := range logs {
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", l.Title, l.Type, l.Units, l.ID)
-			}
-			w.Flush()
-			return nil
-		}
-
-		outputJSON(data)
-		return nil
+		return printList(data, []render.Column{
+			{Header: "TITLE", Path: ".title"},
+			{Header: "TYPE", Path: ".type"},
+			{Header: "UNITS", Path: ".units"},
+			{Header: "ID", Path: ".id"},
+		})
 	},
 }
 
@@ -107,27 +88,17 @@ Examples:
 			return err
 		}
 
-		if isTableFormat() {
-			var entryList []struct {
-				T time.Time   `json:"t"`
-				V interface{} `json:"v"`
-			}
-			if err := json.Unmarshal(entries, &entryList); err != nil {
-				return fmt.Errorf("failed to parse entries: %w", err)
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "TIME\tVALUE")
-			fmt.Fprintln(w, "----\t-----")
-			for _, e := range entryList {
-				fmt.Fprintf(w, "%s\t%v\n", e.T.Local().Format("2006-01-02 15:04"), e.V)
-			}
-			w.Flush()
-			return nil
-		}
-
-		outputJSON(entries)
-		return nil
+		return printList(entries, []render.Column{
+			{Header: "TIME", Value: func(e map[string]interface{}) interface{} {
+				s, _ := e["t"].(string)
+				t, err := time.Parse(time.RFC3339, s)
+				if err != nil {
+					return s
+				}
+				return t.Local().Format("2006-01-02 15:04")
+			}},
+			{Header: "VALUE", Path: ".v"},
+		})
 	},
 }
 
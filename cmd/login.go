@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
-	"net/url"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/langtind/homeyctl/internal/client"
 	"github.com/langtind/homeyctl/internal/config"
@@ -11,6 +16,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	loginHomeyFlag        string
+	loginCallbackPortFlag int
+	loginNoBrowserFlag    bool
+)
+
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Log in to your Homey",
@@ -18,13 +29,31 @@ var loginCmd = &cobra.Command{
 
 This is the easiest way to get started with homeyctl:
   1. Opens your browser to log in with your Athom account
-  2. Creates an API token with device control access
-  3. Saves it to your config
+  2. Asks which Homey to use if your account has more than one
+  3. Creates an API token with device control access
+  4. Saves it to a profile named after the Homey
+
+Each Homey gets its own profile, so logging in to another Homey later
+does not overwrite the first. The last Homey you logged in to becomes
+the default; switch with 'homeyctl config profiles use <name>'.
 
 After login, you can immediately use homeyctl:
   homeyctl devices list
   homeyctl flows list
 
+For scripts, pick the Homey up front:
+  homeyctl login --homey "Cabin"
+  homeyctl login --homey 5f1a2b3c4d5e6f7a8b9c0d1e --profile cabin
+
+Over SSH or on a machine without a browser, use --no-browser: open the
+printed URL anywhere, then paste the address the browser ends up on
+(http://localhost:8484/callback?code=...) back into the terminal.
+  homeyctl login --no-browser
+
+If port 8484 is taken, use --no-browser. Athom only accepts the registered
+callback on port 8484, so --callback-port is only useful when something
+forwards that address to the chosen port.
+
 For read-only access (safer for AI bots), use:
   homeyctl token create "AI Bot" --preset readonly`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -32,82 +61,44 @@ For read-only access (safer for AI bots), use:
 		fmt.Println()
 
 		// Do OAuth login
-		homey, err := oauth.Login()
+		homey, err := oauth.Login(loginOptions(loginHomeyFlag))
 		if err != nil {
 			return fmt.Errorf("login failed: %w", err)
 		}
 
 		// Determine which URL to use
-		homeyURL := homey.LocalURLSecure
-		if homeyURL == "" {
-			homeyURL = homey.LocalURL
+		localURL := homey.LocalURLSecure
+		if localURL == "" {
+			localURL = homey.LocalURL
 		}
+		homeyURL := localURL
 		if homeyURL == "" {
 			homeyURL = homey.RemoteURL
 		}
 
-		// Parse URL
-		parsedURL, err := url.Parse(homeyURL)
-		if err != nil {
-			return fmt.Errorf("failed to parse Homey URL: %w", err)
-		}
-
-		host := parsedURL.Hostname()
-		port := 443
-		if parsedURL.Port() != "" {
-			fmt.Sscanf(parsedURL.Port(), "%d", &port)
-		} else if parsedURL.Scheme == "http" {
-			port = 80
-		}
-
-		// Create temporary config for API client
-		tempCfg := &config.Config{
-			Host:  host,
-			Port:  port,
-			Token: homey.Token,
-			TLS:   parsedURL.Scheme == "https",
-		}
-
-		// Create a "control" preset token for the user
-		tempClient := client.New(tempCfg)
-		scopes := scopePresets["control"]
-
-		data, err := tempClient.CreatePAT("homeyctl", scopes)
+		// Create a "control" preset token for the user. If that fails, save
+		// the OAuth session token instead (less ideal but still works).
+		token := homey.Token
+		tempClient := client.NewWithToken(homeyURL, homey.Token)
+		data, err := tempClient.CreatePAT("homeyctl", scopePresets["control"])
 		if err != nil {
-			// If token creation fails, save the OAuth session token instead
-			// (less ideal but still works)
 			fmt.Println("Note: Could not create scoped token, using session token.")
-			if saveErr := config.Save(tempCfg); saveErr != nil {
-				return fmt.Errorf("failed to save config: %w", saveErr)
+		} else {
+			var resp PATCreateResponse
+			if err := json.Unmarshal(data, &resp); err != nil {
+				return fmt.Errorf("failed to parse response: %w", err)
 			}
-			fmt.Println()
-			fmt.Printf("Logged in to: %s\n", homey.Name)
-			fmt.Println()
-			fmt.Println("You're ready to use homeyctl!")
-			fmt.Println("Try: homeyctl devices list")
-			return nil
+			token = resp.Token
 		}
 
-		var resp PATCreateResponse
-		if err := json.Unmarshal(data, &resp); err != nil {
-			return fmt.Errorf("failed to parse response: %w", err)
-		}
-
-		// Save the PAT to config
-		newCfg := &config.Config{
-			Host:   host,
-			Port:   port,
-			Token:  resp.Token,
-			Format: "json",
-			TLS:    parsedURL.Scheme == "https",
-		}
-
-		if err := config.Save(newCfg); err != nil {
-			return fmt.Errorf("failed to save config: %w", err)
+		name, err := saveLoginProfile(homey, localURL, token)
+		if err != nil {
+			return err
 		}
 
 		fmt.Println()
 		fmt.Printf("Logged in to: %s\n", homey.Name)
+		fmt.Printf("Saved as profile: %s (now the default)\n", name)
 		fmt.Println()
 		fmt.Println("You're ready to use homeyctl!")
 		fmt.Println("Try: homeyctl devices list")
@@ -116,6 +107,133 @@ For read-only access (safer for AI bots), use:
 	},
 }
 
+// loginOptions builds the OAuth login options from the login flags
+func loginOptions(nameOrID string) oauth.LoginOptions {
+	return oauth.LoginOptions{
+		CallbackPort: loginCallbackPortFlag,
+		NoBrowser:    loginNoBrowserFlag,
+		Choose:       chooseHomey(nameOrID, os.Stdin, os.Stdout, isInteractive(os.Stdin)),
+	}
+}
+
+// addLoginFlags adds the flags that control the OAuth login to cmd
+func addLoginFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&loginCallbackPortFlag, "callback-port", oauth.DefaultCallbackPort, "localhost port for the login callback (Athom redirects to port 8484 only)")
+	cmd.Flags().BoolVar(&loginNoBrowserFlag, "no-browser", false, "print the login URL and paste the redirect URL instead of opening a browser")
+}
+
+// chooseHomey picks the Homey matching nameOrID. Without one, it prompts when
+// the account has several Homeys, or fails if the session isn't interactive.
+func chooseHomey(nameOrID string, in io.Reader, out io.Writer, interactive bool) oauth.ChooseHomey {
+	return func(homeys []oauth.Homey) (*oauth.Homey, error) {
+		if nameOrID != "" || len(homeys) <= 1 {
+			return oauth.FindHomey(homeys, nameOrID)
+		}
+		if !interactive {
+			return nil, fmt.Errorf("found %d Homeys, choose one with --homey <name-or-id>", len(homeys))
+		}
+		return promptHomey(homeys, in, out)
+	}
+}
+
+// promptHomey asks the user to pick a Homey by number
+func promptHomey(homeys []oauth.Homey, in io.Reader, out io.Writer) (*oauth.Homey, error) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Found multiple Homeys:")
+	for i, h := range homeys {
+		fmt.Fprintf(out, "  %d. %s (%s)\n", i+1, h.Name, h.ID)
+	}
+
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "Choose a Homey [1-%d]: ", len(homeys))
+		line, err := reader.ReadString('\n')
+		if n, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && n >= 1 && n <= len(homeys) {
+			return &homeys[n-1], nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("no Homey chosen")
+		}
+		fmt.Fprintln(out, "Invalid choice.")
+	}
+}
+
+// isInteractive reports whether f is a terminal
+func isInteractive(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+var nonProfileChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// loginProfileName returns the profile a Homey is saved to: --profile if given,
+// otherwise a name derived from the Homey's name. A profile that already
+// belongs to a different Homey is not reused.
+func loginProfileName(homey *oauth.Homey, profiles map[string]config.Profile) string {
+	if profileFlag != "" {
+		return strings.ToLower(profileFlag)
+	}
+
+	name := strings.Trim(nonProfileChars.ReplaceAllString(strings.ToLower(homey.Name), "-"), "-")
+	shortID := strings.ToLower(homey.ID)
+	if len(shortID) > 6 {
+		shortID = shortID[:6]
+	}
+	if config.ValidateProfileName(name) != nil {
+		name = "homey-" + shortID
+	}
+
+	if p, ok := profiles[name]; ok && p.Cloud.HomeyID != "" && p.Cloud.HomeyID != homey.ID {
+		name += "-" + shortID
+	}
+	return name
+}
+
+// saveLoginProfile stores the Homey's connection settings in its own profile
+// and makes it the default
+func saveLoginProfile(homey *oauth.Homey, localURL, token string) (string, error) {
+	// Read the existing config without applying a profile; --profile may name
+	// one that doesn't exist yet
+	config.UseProfile(config.DefaultProfile)
+	existing, err := config.Load()
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+
+	name := loginProfileName(homey, existing.Profiles)
+	if err := config.ValidateProfileName(name); err != nil {
+		return "", err
+	}
+
+	p := config.Profile{
+		Mode: "auto",
+		Local: config.LocalConfig{
+			Address: localURL,
+			Token:   token,
+		},
+		Cloud: config.CloudConfig{
+			Token:       token,
+			HomeyID:     homey.ID,
+			RemoteURL:   homey.RemoteURL,
+			AccessToken: homey.AccessToken,
+
+			RefreshToken: homey.RefreshToken,
+			AccessExpiry: homey.AccessExpiry,
+			// Without an API key the session token is used; it is renewed when it expires
+			Session: token == homey.Token,
+		},
+	}
+	if err := config.SaveProfile(name, p); err != nil {
+		return "", fmt.Errorf("failed to save config: %w", err)
+	}
+	if err := config.SetDefaultProfile(name); err != nil {
+		return "", fmt.Errorf("failed to save config: %w", err)
+	}
+	return name, nil
+}
+
 func init() {
 	rootCmd.AddCommand(loginCmd)
+	loginCmd.Flags().StringVar(&loginHomeyFlag, "homey", "", "Homey to log in to (name or ID), skips the prompt")
+	addLoginFlags(loginCmd)
 }
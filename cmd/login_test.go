@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/oauth"
+)
+
+var testHomeys = []oauth.Homey{
+	{ID: "aaa111", Name: "Home"},
+	{ID: "bbb222", Name: "Cabin"},
+}
+
+func TestChooseHomey_ByFlag(t *testing.T) {
+	h, err := chooseHomey("cabin", nil, nil, false)(testHomeys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h.ID != "bbb222" {
+		t.Errorf("expected Cabin, got %s", h.Name)
+	}
+
+	if _, err := chooseHomey("office", nil, nil, false)(testHomeys); err == nil {
+		t.Error("expected error for unknown Homey")
+	}
+}
+
+func TestChooseHomey_NonInteractive(t *testing.T) {
+	_, err := chooseHomey("", nil, nil, false)(testHomeys)
+	if err == nil || !strings.Contains(err.Error(), "--homey") {
+		t.Errorf("expected hint about --homey, got %v", err)
+	}
+
+	h, err := chooseHomey("", nil, nil, false)(testHomeys[:1])
+	if err != nil || h.ID != "aaa111" {
+		t.Errorf("expected the only Homey to be used, got %v, %v", h, err)
+	}
+}
+
+func TestChooseHomey_Prompt(t *testing.T) {
+	var out bytes.Buffer
+	in := strings.NewReader("x\n3\n2\n")
+
+	h, err := chooseHomey("", in, &out, true)(testHomeys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h.ID != "bbb222" {
+		t.Errorf("expected Cabin, got %s", h.Name)
+	}
+	if got := strings.Count(out.String(), "Invalid choice."); got != 2 {
+		t.Errorf("expected 2 invalid choices, got %d\n%s", got, out.String())
+	}
+
+	if _, err := chooseHomey("", strings.NewReader(""), &out, true)(testHomeys); err == nil {
+		t.Error("expected error when input ends")
+	}
+}
+
+func TestLoginProfileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		homey    oauth.Homey
+		profiles map[string]config.Profile
+		expected string
+	}{
+		{"from name", oauth.Homey{ID: "aaa111", Name: "Homey Pro (Cabin)"}, nil, "homey-pro-cabin"},
+		{"unusable name", oauth.Homey{ID: "ABC123DEF", Name: "Hjem ✓"}, nil, "hjem"},
+		{"empty name", oauth.Homey{ID: "ABC123DEF", Name: "✓"}, nil, "homey-abc123"},
+		{"same homey reuses profile",
+			oauth.Homey{ID: "aaa111", Name: "Home"},
+			map[string]config.Profile{"home": {Cloud: config.CloudConfig{HomeyID: "aaa111"}}},
+			"home"},
+		{"other homey with same name",
+			oauth.Homey{ID: "bbb222", Name: "Home"},
+			map[string]config.Profile{"home": {Cloud: config.CloudConfig{HomeyID: "aaa111"}}},
+			"home-bbb222"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loginProfileName(&tt.homey, tt.profiles); got != tt.expected {
+				t.Errorf("loginProfileName() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -5,20 +5,14 @@ import (
 	"fmt"
 	"os"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
 // Mood represents a Homey mood
-type Mood struct {
-	ID     string                 `json:"id"`
-	Name   string                 `json:"name"`
-	Preset string                 `json:"preset"`
-	Zone   string                 `json:"zone"`
-	Active bool                   `json:"active"`
-	Devices map[string]interface{} `json:"devices"`
-}
+type Mood = homey.Mood
 
 var moodsCmd = &cobra.Command{
 	Use:   "moods",
@@ -66,27 +60,20 @@ var moodsListCmd = &cobra.Command{
 				fmt.Println("No moods found.")
 				return nil
 			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tPRESET\tACTIVE\tID")
-			fmt.Fprintln(w, "----\t------\t------\t--")
-			for _, m := range moods {
-				active := "no"
-				if m.Active {
-					active = "yes"
-				}
-				preset := m.Preset
-				if preset == "" {
-					preset = "-"
-				}
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Name, preset, active, m.ID)
-			}
-			w.Flush()
-			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printList(data, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "PRESET", Value: func(m map[string]interface{}) interface{} {
+				if preset, _ := m["preset"].(string); preset != "" {
+					return preset
+				}
+				return "-"
+			}},
+			{Header: "ACTIVE", Value: yesNo("active")},
+			{Header: "ID", Path: ".id"},
+			{Header: "DEVICES", Value: count("devices"), Wide: true},
+		})
 	},
 }
 
@@ -105,8 +92,7 @@ var moodsGetCmd = &cobra.Command{
 			return err
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -175,8 +161,7 @@ Examples:
 			return nil
 		}
 
-		outputJSON(result)
-		return nil
+		return printOutput(result)
 	},
 }
 
@@ -3,19 +3,14 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
 // Notification represents a Homey notification
-type Notification struct {
-	ID       string `json:"id"`
-	Excerpt  string `json:"excerpt"`
-	OwnerUri string `json:"ownerUri"`
-	Date     string `json:"date"`
-}
+type Notification = homey.Notification
 
 var notifyCmd = &cobra.Command{
 	Use:     "notify",
@@ -59,23 +54,21 @@ var notifyListCmd = &cobra.Command{
 				fmt.Println("No notifications found.")
 				return nil
 			}
+		}
 
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "DATE\tMESSAGE\tID")
-			fmt.Fprintln(w, "----\t-------\t--")
-			for _, n := range notifications {
-				excerpt := n.Excerpt
-				if len(excerpt) > 50 {
+		return printList(data, []render.Column{
+			{Header: "DATE", Path: ".date"},
+			{Header: "MESSAGE", Value: func(n map[string]interface{}) interface{} {
+				// --wide shows the whole message
+				excerpt, _ := n["excerpt"].(string)
+				if len(excerpt) > 50 && !wideFlag {
 					excerpt = excerpt[:47] + "..."
 				}
-				fmt.Fprintf(w, "%s\t%s\t%s\n", n.Date, excerpt, n.ID)
-			}
-			w.Flush()
-			return nil
-		}
-
-		outputJSON(data)
-		return nil
+				return excerpt
+			}},
+			{Header: "ID", Path: ".id"},
+			{Header: "FROM", Path: ".ownerUri", Wide: true},
+		})
 	},
 }
 
@@ -116,8 +109,7 @@ var notifyOwnersCmd = &cobra.Command{
 			return err
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/langtind/homeyctl/internal/gitops"
+	"github.com/spf13/cobra"
+)
+
+var (
+	planFileFlag  string
+	planPruneFlag bool
+)
+
+const specHelp = `The files describe zones, devices, device groups, variables, moods, flow
+folders and flows by name. A directory is read recursively and every .yaml
+and .yml file in it is merged:
+
+  zones:
+    - name: Ground Floor
+    - name: Kitchen
+      parent: Ground Floor
+      icon: kitchen
+  devices:
+    - name: Ceiling Light        # found by name, or pin it with id: to rename
+      zone: Kitchen
+  groups:
+    - name: Kitchen Lights
+      class: light
+      zone: Kitchen
+      devices: [Ceiling Light, Spots]
+  variables:
+    - name: guests
+      type: boolean               # value is only used when creating
+  moods:
+    - name: Dinner
+      zone: Kitchen
+      devices:
+        Ceiling Light: {onoff: true, dim: 0.4}
+  flowFolders:
+    - name: Lights
+  flows:
+    - name: Lights on at sunset
+      folder: Lights
+      enabled: true
+      trigger: {id: "homey:manager:geolocation:sunset"}
+      actions:
+        - id: "homey:device:<id>:on"
+
+Flows take the same fields 'homeyctl flows get' prints; add advanced: true
+and cards: for advanced flows. Only the fields in the files are compared, so
+IDs and other fields Homey fills in can be left out.
+
+Devices are paired in the Homey app; apply only renames and moves them.`
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show what apply would change",
+	Long: `Compare YAML files describing your home with the Homey and show the
+changes 'homeyctl apply' would make. Nothing is changed.
+
+` + specHelp + `
+
+Resources on the Homey that the files don't list are only deleted with
+--prune, and only for kinds the files mention. Write an empty list, e.g.
+"moods: []", to manage a kind and delete all of it.
+
+Examples:
+  homeyctl plan -f home/
+  homeyctl plan -f home/ --prune
+  homeyctl plan -f home/zones.yaml --format json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, err := computePlan()
+		if err != nil {
+			return err
+		}
+
+		if !isTableFormat() {
+			return printOutput(plan)
+		}
+		printPlan(os.Stdout, plan)
+		return nil
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Make the Homey match YAML files describing your home",
+	Long: `Create, update and delete zones, devices, device groups, variables, moods,
+flow folders and flows so the Homey matches the YAML files.
+
+` + specHelp + `
+
+Changes run in dependency order: zones, flow folders, devices, groups,
+variables, moods, then flows. Deletes run last, in reverse. Apply shows the
+plan and asks before making changes; use --force to skip the question in
+scripts and CI.
+
+If a change fails, apply stops. Changes made before it stay made; run plan
+again to see what is left.
+
+Examples:
+  homeyctl apply -f home/
+  homeyctl apply -f home/ --prune
+  homeyctl apply -f home/ --force`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, err := computePlan()
+		if err != nil {
+			return err
+		}
+
+		printPlan(os.Stdout, plan)
+		if plan.Empty() {
+			return nil
+		}
+
+		force, _ := cmd.Flags().GetBool("force")
+		if !force {
+			if !isInteractive(os.Stdin) {
+				return fmt.Errorf("use --force to apply without confirmation")
+			}
+			if !confirm(os.Stdin, os.Stdout, "Apply these changes?") {
+				fmt.Println("Nothing changed.")
+				return nil
+			}
+		}
+
+		fmt.Println()
+		applied := 0
+		err = plan.Apply(apiClient, func(c gitops.Change) {
+			applied++
+			fmt.Printf("%s %s %q\n", pastTense(c.Action), c.Kind, c.Name)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nApplied %d change(s).\n", applied)
+		return nil
+	},
+}
+
+// computePlan loads the spec from --file and compares it with the Homey
+func computePlan() (*gitops.Plan, error) {
+	if planFileFlag == "" {
+		return nil, fmt.Errorf("--file is required")
+	}
+	spec, err := gitops.Load(planFileFlag)
+	if err != nil {
+		return nil, err
+	}
+	return gitops.Compute(apiClient, spec, gitops.Options{
+		Prune:       planPruneFlag,
+		PrepareFlow: prepareFlow,
+	})
+}
+
+// prepareFlow validates a flow from the spec and fills in the fields Homey
+// needs, the same way 'flows create' does
+func prepareFlow(flow map[string]interface{}, advanced bool) error {
+	if err := validateFlow(flow, advanced); err != nil {
+		return err
+	}
+	if !advanced {
+		normalizeSimpleFlow(flow)
+	}
+	return nil
+}
+
+// printPlan writes a plan as a readable diff
+func printPlan(w io.Writer, plan *gitops.Plan) {
+	if plan.Empty() {
+		fmt.Fprintln(w, "No changes. The Homey matches the files.")
+	}
+	for _, c := range plan.Changes {
+		symbol := map[gitops.Action]string{
+			gitops.ActionCreate: "+",
+			gitops.ActionUpdate: "~",
+			gitops.ActionDelete: "-",
+		}[c.Action]
+		fmt.Fprintf(w, "%s %s %q\n", symbol, c.Kind, c.Name)
+		for _, f := range c.Fields {
+			fmt.Fprintf(w, "    %s: %s\n", f.Field, fieldChange(f))
+		}
+	}
+
+	if !plan.Empty() {
+		create, update, remove := plan.Counts()
+		fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete.\n", create, update, remove)
+	}
+	if n := len(plan.Unmanaged); n > 0 {
+		fmt.Fprintf(w, "\n%d resource(s) on the Homey are not in the files; use --prune to delete them:\n", n)
+		for _, c := range plan.Unmanaged {
+			fmt.Fprintf(w, "  %s %q\n", c.Kind, c.Name)
+		}
+	}
+}
+
+// fieldChange formats the old and new value of a field
+func fieldChange(f gitops.FieldChange) string {
+	if f.From == nil {
+		return fmt.Sprint(f.To)
+	}
+	return fmt.Sprintf("%s -> %s", planValue(f.From), planValue(f.To))
+}
+
+func planValue(v interface{}) string {
+	if v == nil || v == "" {
+		return "(none)"
+	}
+	return fmt.Sprint(v)
+}
+
+func pastTense(a gitops.Action) string {
+	switch a {
+	case gitops.ActionCreate:
+		return "Created"
+	case gitops.ActionUpdate:
+		return "Updated"
+	default:
+		return "Deleted"
+	}
+}
+
+// confirm asks a yes/no question, defaulting to no
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "\n%s [y/N]: ", question)
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+
+	for _, c := range []*cobra.Command{planCmd, applyCmd} {
+		c.Flags().StringVarP(&planFileFlag, "file", "f", "", "YAML file or directory describing the home")
+		c.Flags().BoolVar(&planPruneFlag, "prune", false, "delete resources the files don't list")
+	}
+	applyCmd.Flags().Bool("force", false, "apply without asking for confirmation")
+}
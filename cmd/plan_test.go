@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/langtind/homeyctl/internal/gitops"
+)
+
+func TestPlanAndApplyCommands_Exist(t *testing.T) {
+	for _, name := range []string{"plan", "apply"} {
+		cmd, _, err := rootCmd.Find([]string{name})
+		if err != nil || cmd.Name() != name {
+			t.Fatalf("%s command not found: %v", name, err)
+		}
+		for _, flag := range []string{"file", "prune"} {
+			if cmd.Flags().Lookup(flag) == nil {
+				t.Errorf("expected --%s flag on %s", flag, name)
+			}
+		}
+	}
+
+	if applyCmd.Flags().Lookup("force") == nil {
+		t.Error("expected --force flag on apply")
+	}
+	if planCmd.Flags().ShorthandLookup("f") == nil {
+		t.Error("expected -f shorthand for --file")
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	plan := &gitops.Plan{
+		Changes: []gitops.Change{
+			{Action: gitops.ActionCreate, Kind: gitops.KindZone, Name: "Office", Fields: []gitops.FieldChange{{Field: "parent", To: "Home"}}},
+			{Action: gitops.ActionUpdate, Kind: gitops.KindFlowFolder, Name: "Night", Fields: []gitops.FieldChange{{Field: "parent", From: "", To: "Lights"}}},
+			{Action: gitops.ActionUpdate, Kind: gitops.KindFlow, Name: "Sunset", Fields: []gitops.FieldChange{{Field: "actions", To: "changed"}}},
+			{Action: gitops.ActionDelete, Kind: gitops.KindMood, Name: "Old"},
+		},
+		Unmanaged: []gitops.Change{{Action: gitops.ActionDelete, Kind: gitops.KindVariable, Name: "tmp"}},
+	}
+
+	var out bytes.Buffer
+	printPlan(&out, plan)
+	got := out.String()
+
+	for _, want := range []string{
+		"+ zone \"Office\"\n    parent: Home\n",
+		"~ flowFolder \"Night\"\n    parent: (none) -> Lights\n",
+		"    actions: changed\n",
+		"- mood \"Old\"\n",
+		"Plan: 1 to create, 2 to update, 1 to delete.",
+		"1 resource(s) on the Homey are not in the files",
+		"  variable \"tmp\"\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestPrintPlan_Empty(t *testing.T) {
+	var out bytes.Buffer
+	printPlan(&out, &gitops.Plan{})
+	if !strings.Contains(out.String(), "No changes") || strings.Contains(out.String(), "Plan:") {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := map[string]bool{
+		"y\n":   true,
+		"YES\n": true,
+		"n\n":   false,
+		"\n":    false,
+		"":      false,
+	}
+	for input, want := range tests {
+		var out bytes.Buffer
+		if got := confirm(strings.NewReader(input), &out, "Apply?"); got != want {
+			t.Errorf("confirm(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestPrepareFlow_NormalizesSimpleFlows(t *testing.T) {
+	flow := map[string]interface{}{
+		"name":    "Sunset",
+		"trigger": map[string]interface{}{"id": "homey:manager:geolocation:sunset"},
+		"actions": []interface{}{map[string]interface{}{"id": "homey:device:x:on"}},
+	}
+	if err := prepareFlow(flow, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	action := flow["actions"].([]interface{})[0].(map[string]interface{})
+	if action["group"] != "then" {
+		t.Errorf("expected action group to be filled in, got %v", action)
+	}
+
+	if err := prepareFlow(map[string]interface{}{"name": "x"}, false); err == nil {
+		t.Error("expected error for a flow without a trigger")
+	}
+}
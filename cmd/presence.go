@@ -87,9 +87,7 @@ Examples:
 			"present": present.Value,
 			"asleep":  asleep.Value,
 		}
-		out, _ := json.MarshalIndent(result, "", "  ")
-		fmt.Println(string(out))
-		return nil
+		return printOutput(result)
 	},
 }
 
@@ -198,8 +196,7 @@ var presenceAsleepGetCmd = &cobra.Command{
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
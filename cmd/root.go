@@ -1,21 +1,36 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/langtind/homeyctl/internal/client"
 	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/render"
 )
 
 var (
 	cfg       *config.Config
 	apiClient *client.Client
 
-	formatFlag string
+	formatFlag    string
+	noHeadersFlag bool
+	sortByFlag    string
+	wideFlag      bool
+	queryFlag     string
+	profileFlag   string
+	timeoutFlag   time.Duration
+	retriesFlag   int
+	verboseFlag   bool
 
 	versionInfo struct {
 		Version string
@@ -54,7 +69,7 @@ var rootCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		// Check if configured, show setup instructions if not
 		loadedCfg, _ := config.Load()
-		if loadedCfg == nil || loadedCfg.Token == "" {
+		if loadedCfg == nil || (loadedCfg.EffectiveToken() == "" && loadedCfg.Cloud.AccessToken == "") {
 			// Check for legacy config and show migration instructions
 			config.CheckLegacyConfig()
 			fmt.Print(setupInstructions)
@@ -64,12 +79,20 @@ var rootCmd = &cobra.Command{
 		cmd.Help()
 	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		config.UseProfile(profileFlag)
+
+		// Catch a bad --format before any request is made
+		if _, err := rendererFor(formatFlag); err != nil {
+			return err
+		}
+
 		// Skip config for config and version commands
 		cmdPath := cmd.CommandPath()
 		if cmd.Name() == "config" || cmd.Name() == "version" || cmd.Name() == "help" ||
 			cmd.Name() == "set-token" || cmd.Name() == "set-host" || cmd.Name() == "show" ||
 			cmd.Name() == "completion" || cmd.Name() == "ai" || cmd.Name() == "scopes" ||
-			cmd.Name() == "login" || cmdPath == "homeyctl token create" || cmdPath == "homeyctl" {
+			cmd.Name() == "login" || cmd.Name() == "doctor" || cmdPath == "homeyctl token create" || cmdPath == "homeyctl" ||
+			strings.HasPrefix(cmdPath, "homeyctl config ") {
 			return nil
 		}
 
@@ -79,41 +102,176 @@ var rootCmd = &cobra.Command{
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		if cfg.Token == "" {
+		resolveTransport(cmd.Context(), cfg)
+
+		if err := prepareCloud(cfg); err != nil {
+			return err
+		}
+
+		if cfg.EffectiveToken() == "" {
 			return fmt.Errorf("no API token configured. Run: homeyctl config set-token <token>")
 		}
 
+		// Built before flags adjust cfg, since it saves a copy with renewed tokens
+		renewer := sessionRenewer(cfg)
+
 		if formatFlag != "" {
 			cfg.Format = formatFlag
 		}
 
-		apiClient = client.New(cfg)
+		if cmd.Flags().Changed("retries") {
+			cfg.Retry.Retries = &retriesFlag
+		}
+
+		verbosef("transport: %s (%s)", cfg.EffectiveMode(), cfg.BaseURL())
+
+		apiClient = client.New(cfg).WithContext(cmd.Context())
+		apiClient.SetTimeout(timeoutFlag)
+		if renewer != nil {
+			apiClient.SetSessionRenewer(renewer)
+		}
+		if verboseFlag {
+			apiClient.SetLogger(verbosef)
+		}
 		return nil
 	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// Ctrl-C cancels in-flight requests; a second Ctrl-C exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		forgetTransport(err)
+		if hint := errorHint(err); hint != "" {
+			fmt.Fprintln(os.Stderr, hint)
+		}
 		os.Exit(1)
 	}
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&formatFlag, "format", "", "Output format: json, table (default: json)")
+	rootCmd.PersistentFlags().StringVar(&formatFlag, "format", "", render.FormatHelp)
+	rootCmd.PersistentFlags().BoolVar(&noHeadersFlag, "no-headers", false, "Leave out the header row of table and csv output")
+	rootCmd.PersistentFlags().StringVar(&sortByFlag, "sort-by", "", "Sort list output by a column (e.g. NAME) or field (e.g. .zone)")
+	rootCmd.PersistentFlags().BoolVar(&wideFlag, "wide", false, "Show extra columns in table and csv output")
+	rootCmd.PersistentFlags().StringVar(&queryFlag, "query", "", "jq expression to apply to the JSON output (e.g. '.[] | select(.class == \"light\") | .name')")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "Config profile to use (default: $HOMEY_PROFILE or the saved default)")
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", client.DefaultTimeout, "Timeout for each API request (e.g. 10s, 2m; 0 disables)")
+	rootCmd.PersistentFlags().IntVar(&retriesFlag, "retries", client.DefaultRetryPolicy.MaxRetries, "Retries for transient failures on idempotent requests (0 disables)")
+	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "Print diagnostic output to stderr")
+}
+
+// rendererFor returns a renderer for the output flags, in the given format
+func rendererFor(format string) (*render.Renderer, error) {
+	return newRenderer(os.Stdout, format)
+}
+
+// newRenderer returns a renderer writing to out with the output flags
+func newRenderer(out io.Writer, format string) (*render.Renderer, error) {
+	return render.New(out, render.Options{
+		Format:    format,
+		NoHeaders: noHeadersFlag,
+		SortBy:    sortByFlag,
+		Wide:      wideFlag,
+		Query:     queryFlag,
+	})
+}
+
+// outputFormat returns --format, or the configured format
+func outputFormat() string {
+	if formatFlag == "" && cfg != nil {
+		return cfg.Format
+	}
+	return formatFlag
+}
+
+// printOutput prints a single value, such as an API response, in the output
+// format. The table format prints JSON, for commands without a table view.
+func printOutput(data interface{}) error {
+	r, err := rendererFor(outputFormat())
+	if err != nil {
+		return err
+	}
+	return r.Object(data)
+}
+
+// printList prints a collection in the output format, as a table with
+// columns for the table and csv formats
+func printList(data interface{}, columns []render.Column) error {
+	r, err := rendererFor(outputFormat())
+	if err != nil {
+		return err
+	}
+	return r.List(data, columns)
+}
+
+// printRows prints a single value whose table view is a list of rows: data
+// for JSON, YAML and queries, rows with columns for the other formats
+func printRows(data, rows interface{}, columns []render.Column) error {
+	r, err := rendererFor(outputFormat())
+	if err != nil {
+		return err
+	}
+	return r.Rows(data, rows, columns)
+}
+
+// showTitles reports whether to print the lines around a table, such as
+// "Values for Lamp:". They are left out with --no-headers.
+func showTitles() bool {
+	return isTableFormat() && !noHeadersFlag
+}
+
+// yesNo is a column value showing a boolean field as yes or no
+func yesNo(field string) func(map[string]interface{}) interface{} {
+	return func(item map[string]interface{}) interface{} {
+		if on, _ := item[field].(bool); on {
+			return "yes"
+		}
+		return "no"
+	}
+}
+
+// decimals is a column value showing a number field with a fixed number of
+// decimals, or nothing if it is missing
+func decimals(field string, places int) func(map[string]interface{}) interface{} {
+	return func(item map[string]interface{}) interface{} {
+		if v, ok := render.Lookup(item, field).(float64); ok {
+			return strconv.FormatFloat(v, 'f', places, 64)
+		}
+		return nil
+	}
+}
+
+// count is a column value showing the number of entries in a list or
+// object field
+func count(field string) func(map[string]interface{}) interface{} {
+	return func(item map[string]interface{}) interface{} {
+		switch v := item[field].(type) {
+		case []interface{}:
+			return len(v)
+		case map[string]interface{}:
+			return len(v)
+		}
+		return 0
+	}
 }
 
-// outputJSON pretty-prints JSON data
-func outputJSON(data []byte) {
-	var v interface{}
-	if err := json.Unmarshal(data, &v); err == nil {
-		pretty, _ := json.MarshalIndent(v, "", "  ")
-		fmt.Println(string(pretty))
-		return
+// verbosef prints a diagnostic line to stderr when --verbose is set
+func verbosef(format string, args ...interface{}) {
+	if verboseFlag {
+		fmt.Fprintf(os.Stderr, "[verbose] "+format+"\n", args...)
 	}
-	fmt.Println(string(data))
 }
 
-// isTableFormat returns true if table format is requested
+// isTableFormat returns true if table format is requested. A --query works
+// on the JSON output, so it replaces a command's own table view.
 func isTableFormat() bool {
-	return cfg != nil && cfg.Format == "table"
+	return cfg != nil && cfg.Format == "table" && queryFlag == ""
 }
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"testing"
+
+	"github.com/langtind/homeyctl/internal/config"
 )
 
 func TestCommandSkipsConfigLoading(t *testing.T) {
@@ -24,6 +26,7 @@ func TestCommandSkipsConfigLoading(t *testing.T) {
 		{"login command", "homeyctl login", "login", true},
 		{"token create command", "homeyctl token create", "create", true},
 		{"token scopes command", "homeyctl token scopes", "scopes", true},
+		{"doctor command", "homeyctl doctor", "doctor", true},
 		{"root command", "homeyctl", "homeyctl", true},
 
 		// Commands that should NOT skip config loading (need API client)
@@ -78,7 +81,7 @@ func shouldSkipConfigLoading(cmdPath, cmdName string) bool {
 	if cmdName == "config" || cmdName == "version" || cmdName == "help" ||
 		cmdName == "set-token" || cmdName == "set-host" || cmdName == "show" ||
 		cmdName == "completion" || cmdName == "ai" || cmdName == "scopes" ||
-		cmdName == "login" || cmdPath == "homeyctl" {
+		cmdName == "login" || cmdName == "doctor" || cmdPath == "homeyctl" {
 		return true
 	}
 
@@ -90,3 +93,56 @@ func shouldSkipConfigLoading(cmdPath, cmdName string) bool {
 
 	return false
 }
+
+func TestOutputFlags(t *testing.T) {
+	for _, name := range []string{"format", "no-headers", "sort-by", "wide"} {
+		if rootCmd.PersistentFlags().Lookup(name) == nil {
+			t.Errorf("expected global --%s flag", name)
+		}
+	}
+
+	if _, err := rendererFor("xml"); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+	if _, err := rendererFor("custom-columns=NAME:.name"); err != nil {
+		t.Errorf("unexpected error for custom columns: %v", err)
+	}
+}
+
+func TestColumnValues(t *testing.T) {
+	item := map[string]interface{}{
+		"enabled": true,
+		"devices": []interface{}{"a", "b"},
+		"columns": map[string]interface{}{"x": 1},
+	}
+	if got := yesNo("enabled")(item); got != "yes" {
+		t.Errorf("yesNo(enabled) = %v", got)
+	}
+	if got := yesNo("missing")(item); got != "no" {
+		t.Errorf("yesNo(missing) = %v", got)
+	}
+	if got := count("devices")(item); got != 2 {
+		t.Errorf("count(devices) = %v", got)
+	}
+	if got := count("columns")(item); got != 1 {
+		t.Errorf("count(columns) = %v", got)
+	}
+	if got := count("missing")(item); got != 0 {
+		t.Errorf("count(missing) = %v", got)
+	}
+}
+
+func TestQueryReplacesTableView(t *testing.T) {
+	oldCfg, oldQuery := cfg, queryFlag
+	defer func() { cfg, queryFlag = oldCfg, oldQuery }()
+
+	cfg = &config.Config{Format: "table"}
+	queryFlag = ""
+	if !isTableFormat() {
+		t.Error("expected table format")
+	}
+	queryFlag = ".[].name"
+	if isTableFormat() {
+		t.Error("a query should replace the table view")
+	}
+}
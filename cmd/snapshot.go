@@ -3,11 +3,17 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/langtind/homeyctl/internal/snapshot"
 	"github.com/spf13/cobra"
 )
 
-var snapshotIncludeFlows bool
+var (
+	snapshotIncludeFlows bool
+	snapshotSaveFlag     string
+)
 
 var snapshotCmd = &cobra.Command{
 	Use:   "snapshot",
@@ -16,59 +22,43 @@ var snapshotCmd = &cobra.Command{
 
 Useful for AI assistants and scripts that need a complete overview.
 
+--save writes the snapshot, including flows and variables, to a file instead.
+Compare saved snapshots with 'homeyctl snapshot diff'.
+
 Examples:
   homeyctl snapshot
   homeyctl snapshot --include-flows
-  homeyctl snapshot --format json`,
+  homeyctl snapshot --format json
+  homeyctl snapshot --save before.json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Get system status
-		systemData, err := apiClient.GetSystem()
-		if err != nil {
-			return fmt.Errorf("failed to get system status: %w", err)
-		}
-
-		// Get zones
-		zonesData, err := apiClient.GetZones()
-		if err != nil {
-			return fmt.Errorf("failed to get zones: %w", err)
+		if snapshotSaveFlag != "" {
+			snap, err := takeSnapshot(true, true)
+			if err != nil {
+				return err
+			}
+			snap["savedAt"], _ = json.Marshal(time.Now().UTC())
+			out, _ := json.MarshalIndent(snap, "", "  ")
+			if err := os.WriteFile(snapshotSaveFlag, append(out, '\n'), 0644); err != nil {
+				return fmt.Errorf("failed to save snapshot: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Saved snapshot to %s\n", snapshotSaveFlag)
+			return nil
 		}
 
-		// Get devices
-		devicesData, err := apiClient.GetDevices()
+		snap, err := takeSnapshot(snapshotIncludeFlows, false)
 		if err != nil {
-			return fmt.Errorf("failed to get devices: %w", err)
+			return err
 		}
 
 		// Parse for counting
 		var zones map[string]interface{}
 		var devices map[string]interface{}
-		json.Unmarshal(zonesData, &zones)
-		json.Unmarshal(devicesData, &devices)
-
-		snapshot := map[string]json.RawMessage{
-			"system":  systemData,
-			"zones":   zonesData,
-			"devices": devicesData,
-		}
-
-		// Optionally include flows
-		if snapshotIncludeFlows {
-			flowsData, err := apiClient.GetFlows()
-			if err != nil {
-				return fmt.Errorf("failed to get flows: %w", err)
-			}
-			snapshot["flows"] = flowsData
-
-			advFlowsData, err := apiClient.GetAdvancedFlows()
-			if err != nil {
-				return fmt.Errorf("failed to get advanced flows: %w", err)
-			}
-			snapshot["advancedFlows"] = advFlowsData
-		}
+		json.Unmarshal(snap["zones"], &zones)
+		json.Unmarshal(snap["devices"], &devices)
 
 		if isTableFormat() {
 			var system map[string]interface{}
-			json.Unmarshal(systemData, &system)
+			json.Unmarshal(snap["system"], &system)
 
 			fmt.Println("Homey Snapshot")
 			fmt.Println("==============")
@@ -81,21 +71,72 @@ Examples:
 
 			if snapshotIncludeFlows {
 				var flows, advFlows map[string]interface{}
-				json.Unmarshal(snapshot["flows"], &flows)
-				json.Unmarshal(snapshot["advancedFlows"], &advFlows)
+				json.Unmarshal(snap["flows"], &flows)
+				json.Unmarshal(snap["advancedFlows"], &advFlows)
 				fmt.Printf("Flows:     %d\n", len(flows))
 				fmt.Printf("Advanced:  %d\n", len(advFlows))
 			}
 			return nil
 		}
 
-		out, _ := json.MarshalIndent(snapshot, "", "  ")
-		fmt.Println(string(out))
-		return nil
+		return printOutput(snap)
 	},
 }
 
+// takeSnapshot reads system info, zones and devices, and optionally flows
+// and variables
+func takeSnapshot(includeFlows, includeVariables bool) (snapshot.Snapshot, error) {
+	// Get system status
+	systemData, err := apiClient.GetSystem()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system status: %w", err)
+	}
+
+	// Get zones
+	zonesData, err := apiClient.GetZones()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zones: %w", err)
+	}
+
+	// Get devices
+	devicesData, err := apiClient.GetDevices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+
+	snap := snapshot.Snapshot{
+		"system":  systemData,
+		"zones":   zonesData,
+		"devices": devicesData,
+	}
+
+	// Optionally include flows
+	if includeFlows {
+		flowsData, err := apiClient.GetFlows()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get flows: %w", err)
+		}
+		snap["flows"] = flowsData
+
+		advFlowsData, err := apiClient.GetAdvancedFlows()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get advanced flows: %w", err)
+		}
+		snap["advancedFlows"] = advFlowsData
+	}
+
+	if includeVariables {
+		variablesData, err := apiClient.GetVariables()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get variables: %w", err)
+		}
+		snap["variables"] = variablesData
+	}
+	return snap, nil
+}
+
 func init() {
 	rootCmd.AddCommand(snapshotCmd)
 	snapshotCmd.Flags().BoolVar(&snapshotIncludeFlows, "include-flows", false, "Include flows in snapshot")
+	snapshotCmd.Flags().StringVar(&snapshotSaveFlag, "save", "", "Save the snapshot, with flows and variables, to a file")
 }
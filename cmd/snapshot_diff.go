@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff <a> <b|live>",
+	Short: "Show what changed between two snapshots",
+	Long: `Show what changed between two snapshots saved with 'homeyctl snapshot --save',
+or between a saved snapshot and the Homey right now.
+
+Reports devices that were added, removed, renamed, moved to another zone or
+became unavailable, capability values that changed, flows that were added,
+removed, broken or disabled, and variables that changed.
+
+Table output lists one change per line. With --format json the changes are
+printed as a JSON Patch (RFC 6902) from <a> to <b>.
+
+Examples:
+  homeyctl snapshot --save before.json
+  homeyctl snapshot diff before.json live
+  homeyctl snapshot diff before.json after.json --format json`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := loadSnapshot(args[0])
+		if err != nil {
+			return err
+		}
+		b, err := loadSnapshot(args[1])
+		if err != nil {
+			return err
+		}
+
+		diff, err := snapshot.Compare(a, b)
+		if err != nil {
+			return err
+		}
+
+		if showTitles() {
+			fmt.Printf("Comparing %s with %s\n\n", snapshotLabel(args[0], a), snapshotLabel(args[1], b))
+		}
+		return printSnapshotDiff(os.Stdout, diff)
+	},
+}
+
+// loadSnapshot reads a saved snapshot, or takes one when name is "live"
+func loadSnapshot(name string) (snapshot.Snapshot, error) {
+	if name == "live" {
+		return takeSnapshot(true, true)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap snapshot.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil || snap[snapshot.SectionDevices] == nil {
+		return nil, fmt.Errorf("%s is not a snapshot (save one with: homeyctl snapshot --save %s)", name, name)
+	}
+	return snap, nil
+}
+
+// snapshotLabel names a snapshot with the time it was saved, if known
+func snapshotLabel(name string, snap snapshot.Snapshot) string {
+	var savedAt time.Time
+	if err := json.Unmarshal(snap["savedAt"], &savedAt); err != nil {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, savedAt.Local().Format("2006-01-02 15:04"))
+}
+
+var snapshotDiffColumns = []render.Column{
+	{Header: "KIND", Path: ".kind"},
+	{Header: "NAME", Path: ".name"},
+	{Header: "CHANGE", Value: func(item map[string]interface{}) interface{} {
+		what, _ := item["change"].(string)
+		capability, _ := item["capability"].(string)
+		return describeChange(snapshot.Change{What: what, Capability: capability})
+	}},
+	{Header: "FROM", Path: ".from"},
+	{Header: "TO", Path: ".to"},
+	{Header: "ID", Path: ".id", Wide: true},
+}
+
+// printSnapshotDiff prints one change per row, or the JSON Patch for JSON
+// and YAML
+func printSnapshotDiff(w io.Writer, diff *snapshot.Diff) error {
+	table := isTableFormat()
+	if table && len(diff.Changes) == 0 {
+		fmt.Fprintln(w, "No changes.")
+	} else {
+		r, err := newRenderer(w, outputFormat())
+		if err != nil {
+			return err
+		}
+		if err := r.Rows(diff.Patch(), diff.Changes, snapshotDiffColumns); err != nil {
+			return err
+		}
+		if table && !noHeadersFlag {
+			fmt.Fprintf(w, "\n%d change(s)\n", len(diff.Changes))
+		}
+	}
+
+	if table && len(diff.Skipped) > 0 {
+		fmt.Fprintf(w, "Not compared, missing from a snapshot: %s\n", strings.Join(diff.Skipped, ", "))
+	}
+	return nil
+}
+
+// describeChange names a change for the CHANGE column. Value changes show
+// just the capability, e.g. "onoff".
+func describeChange(c snapshot.Change) string {
+	switch {
+	case c.Capability == "":
+		return c.What
+	case c.What == snapshot.ValueChanged:
+		return c.Capability
+	default:
+		return c.What + " " + c.Capability
+	}
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotDiffCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/snapshot"
+)
+
+func TestSnapshotCommands_Exist(t *testing.T) {
+	if snapshotCmd.Flags().Lookup("save") == nil {
+		t.Error("expected --save flag on snapshot")
+	}
+	cmd, _, err := rootCmd.Find([]string{"snapshot", "diff"})
+	if err != nil || cmd.Name() != "diff" {
+		t.Fatalf("snapshot diff command not found: %v", err)
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(`{"devices": {}, "savedAt": "2026-01-02T03:04:05Z"}`), 0644)
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"name": "not a snapshot"}`), 0644)
+
+	snap, err := loadSnapshot(good)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if label := snapshotLabel("good.json", snap); !strings.HasPrefix(label, "good.json (2026-01-0") {
+		t.Errorf("unexpected label: %q", label)
+	}
+
+	if _, err := loadSnapshot(bad); err == nil || !strings.Contains(err.Error(), "not a snapshot") {
+		t.Errorf("expected error for a file that isn't a snapshot, got %v", err)
+	}
+	if _, err := loadSnapshot(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestPrintSnapshotDiff(t *testing.T) {
+	diff := &snapshot.Diff{
+		Changes: []snapshot.Change{
+			{Kind: snapshot.KindDevice, Name: "Lamp", What: snapshot.Moved, From: "Kitchen", To: "Hall"},
+			{Kind: snapshot.KindDevice, Name: "Lamp", What: snapshot.ValueChanged, Capability: "onoff", From: true, To: false},
+			{Kind: snapshot.KindFlow, Name: "Sunset", What: snapshot.Broken},
+		},
+		Skipped: []string{"variables"},
+	}
+
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	cfg = &config.Config{Format: "table"}
+
+	var out bytes.Buffer
+	if err := printSnapshotDiff(&out, diff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := out.String()
+
+	for _, want := range []string{
+		"KIND    NAME    CHANGE  FROM     TO\n",
+		"device  Lamp    moved   Kitchen  Hall\n",
+		"device  Lamp    onoff   true     false\n",
+		"flow    Sunset  broken",
+		"3 change(s)\n",
+		"Not compared, missing from a snapshot: variables\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+
+	out.Reset()
+	printSnapshotDiff(&out, &snapshot.Diff{})
+	if out.String() != "No changes.\n" {
+		t.Errorf("unexpected output for no changes: %q", out.String())
+	}
+
+	// Other formats print the changes as rows
+	cfg = &config.Config{Format: "csv"}
+	out.Reset()
+	printSnapshotDiff(&out, diff)
+	if !strings.HasPrefix(out.String(), "KIND,NAME,CHANGE,FROM,TO\ndevice,Lamp,moved,Kitchen,Hall\n") {
+		t.Errorf("unexpected CSV: %q", out.String())
+	}
+}
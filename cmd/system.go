@@ -53,8 +53,7 @@ var systemInfoCmd = &cobra.Command{
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -85,8 +84,7 @@ var systemUsersCmd = &cobra.Command{
 			return err
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -99,8 +97,7 @@ var systemInsightsCmd = &cobra.Command{
 			return err
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -127,8 +124,7 @@ var systemNameGetCmd = &cobra.Command{
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
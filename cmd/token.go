@@ -4,14 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
-	"os"
 	"strings"
-	"text/tabwriter"
 	"time"
 
 	"github.com/langtind/homeyctl/internal/client"
 	"github.com/langtind/homeyctl/internal/config"
 	"github.com/langtind/homeyctl/internal/oauth"
+	"github.com/langtind/homeyctl/internal/render"
 	"github.com/spf13/cobra"
 )
 
@@ -140,21 +139,28 @@ var tokenListCmd = &cobra.Command{
 				fmt.Println("No tokens found.")
 				return nil
 			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED")
-			fmt.Fprintln(w, "--\t----\t------\t-------")
-			for _, p := range pats {
-				scopes := formatScopes(p.Scopes)
-				created := formatTime(p.CreatedAt)
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.ID, p.Name, scopes, created)
-			}
-			w.Flush()
-			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printList(data, []render.Column{
+			{Header: "ID", Path: ".id"},
+			{Header: "NAME", Path: ".name"},
+			{Header: "SCOPES", Value: func(p map[string]interface{}) interface{} {
+				list, _ := p["scopes"].([]interface{})
+				var scopes []string
+				for _, s := range list {
+					scopes = append(scopes, fmt.Sprint(s))
+				}
+				// --wide lists every scope
+				if wideFlag {
+					return strings.Join(scopes, ", ")
+				}
+				return formatScopes(scopes)
+			}},
+			{Header: "CREATED", Value: func(p map[string]interface{}) interface{} {
+				created, _ := p["createdAt"].(string)
+				return formatTime(created)
+			}},
+		})
 	},
 }
 
@@ -230,7 +236,7 @@ Examples:
 		// Need OAuth login
 		if needsOAuth {
 			fmt.Println("OAuth authentication required to create tokens...")
-			homey, err := oauth.Login()
+			homey, err := oauth.Login(loginOptions(""))
 			if err != nil {
 				return fmt.Errorf("login failed: %w", err)
 			}
@@ -381,7 +387,7 @@ Example:
   homeyctl token login
   homeyctl token create "AI Bot" --preset readonly`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		homey, err := oauth.Login()
+		homey, err := oauth.Login(loginOptions(""))
 		if err != nil {
 			return fmt.Errorf("login failed: %w", err)
 		}
@@ -465,4 +471,6 @@ func init() {
 	tokenCreateCmd.Flags().String("preset", "", "Scope preset: readonly, control, or full")
 	tokenCreateCmd.Flags().String("scopes", "", "Comma-separated list of scopes")
 	tokenCreateCmd.Flags().Bool("no-save", false, "Don't save token to config (for external use)")
+	addLoginFlags(tokenCreateCmd)
+	addLoginFlags(tokenLoginCmd)
 }
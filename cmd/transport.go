@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/client"
+	"github.com/langtind/homeyctl/internal/config"
+	"github.com/langtind/homeyctl/internal/discovery"
+)
+
+// probeLocal reports whether a Homey answers on the local address (replaced in tests)
+var probeLocal = func(ctx context.Context, address string, timeout time.Duration) bool {
+	_, ok := discovery.VerifyHomey(ctx, address, timeout)
+	return ok
+}
+
+// transportProbed is set when auto mode picked the transport in this run
+var transportProbed bool
+
+// resolveTransport picks local or cloud for auto mode. When both are configured
+// it pings the local address and falls back to cloud if the Homey doesn't answer.
+// The choice is cached for cfg.Auto.CacheTTL.
+func resolveTransport(ctx context.Context, cfg *config.Config) {
+	if cfg.Mode != "" && cfg.Mode != "auto" {
+		return
+	}
+	if !cfg.HasLocal() || !cfg.HasCloud() {
+		return
+	}
+
+	local := cfg.LocalURL()
+	now := time.Now()
+
+	if cached, err := config.LoadTransportCache(); err == nil && cached.Valid(local, cfg.Auto.CacheTTL, now) {
+		verbosef("auto mode: using %s (cached, probed %s ago)", cached.Mode, now.Sub(cached.CheckedAt).Round(time.Second))
+		cfg.SetTransport(cached.Mode)
+		transportProbed = true
+		return
+	}
+
+	mode := "cloud"
+	if probeLocal(ctx, local, cfg.Auto.ProbeTimeout) {
+		mode = "local"
+		verbosef("auto mode: %s answered, using local", local)
+	} else {
+		verbosef("auto mode: %s did not answer, falling back to cloud", local)
+	}
+	cfg.SetTransport(mode)
+	transportProbed = true
+
+	if cfg.Auto.CacheTTL > 0 {
+		if err := config.SaveTransportCache(&config.TransportCache{Mode: mode, Local: local, CheckedAt: now}); err != nil {
+			verbosef("auto mode: failed to cache transport: %v", err)
+		}
+	}
+}
+
+// forgetTransport clears the cached auto mode choice after a connection
+// failure, so the next run probes again instead of reusing a stale choice
+func forgetTransport(err error) {
+	if !transportProbed || err == nil {
+		return
+	}
+	if _, ok := client.AsAPIError(err); ok {
+		return
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		config.ClearTransportCache()
+	}
+}
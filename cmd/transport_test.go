@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/config"
+)
+
+func stubProbeLocal(t *testing.T, up bool) *int {
+	t.Helper()
+	calls := 0
+	orig := probeLocal
+	probeLocal = func(ctx context.Context, address string, timeout time.Duration) bool {
+		calls++
+		return up
+	}
+	t.Cleanup(func() {
+		probeLocal = orig
+		transportProbed = false
+	})
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	return &calls
+}
+
+func autoConfig() *config.Config {
+	return &config.Config{
+		Mode:  "auto",
+		Local: config.LocalConfig{Address: "http://192.168.1.50", Token: "local"},
+		Cloud: config.CloudConfig{Token: "cloud", HomeyID: "abc"},
+		Auto:  config.AutoConfig{CacheTTL: time.Minute},
+	}
+}
+
+func TestResolveTransport_LocalReachable(t *testing.T) {
+	stubProbeLocal(t, true)
+
+	cfg := autoConfig()
+	resolveTransport(context.Background(), cfg)
+
+	if cfg.EffectiveMode() != "local" || cfg.BaseURL() != "http://192.168.1.50" {
+		t.Errorf("expected local, got %s (%s)", cfg.EffectiveMode(), cfg.BaseURL())
+	}
+}
+
+func TestResolveTransport_FallsBackToCloud(t *testing.T) {
+	stubProbeLocal(t, false)
+
+	cfg := autoConfig()
+	resolveTransport(context.Background(), cfg)
+
+	if cfg.EffectiveMode() != "cloud" || cfg.EffectiveToken() != "cloud" {
+		t.Errorf("expected cloud, got %s", cfg.EffectiveMode())
+	}
+	if cfg.BaseURL() != "https://abc.connect.athom.com" {
+		t.Errorf("unexpected base URL: %s", cfg.BaseURL())
+	}
+}
+
+func TestResolveTransport_UsesCache(t *testing.T) {
+	calls := stubProbeLocal(t, false)
+
+	resolveTransport(context.Background(), autoConfig())
+	cfg := autoConfig()
+	resolveTransport(context.Background(), cfg)
+
+	if *calls != 1 {
+		t.Errorf("expected 1 probe, got %d", *calls)
+	}
+	if cfg.EffectiveMode() != "cloud" {
+		t.Errorf("expected cached cloud choice, got %s", cfg.EffectiveMode())
+	}
+
+	// Zero TTL disables the cache
+	cfg = autoConfig()
+	cfg.Auto.CacheTTL = 0
+	resolveTransport(context.Background(), cfg)
+	if *calls != 2 {
+		t.Errorf("expected probe with caching disabled, got %d probes", *calls)
+	}
+}
+
+func TestResolveTransport_SkipsWithoutChoice(t *testing.T) {
+	calls := stubProbeLocal(t, false)
+
+	cfg := autoConfig()
+	cfg.Mode = "local"
+	resolveTransport(context.Background(), cfg)
+
+	cfg = autoConfig()
+	cfg.Cloud = config.CloudConfig{}
+	resolveTransport(context.Background(), cfg)
+
+	if *calls != 0 {
+		t.Errorf("expected no probes, got %d", *calls)
+	}
+	if cfg.EffectiveMode() != "local" {
+		t.Errorf("expected local, got %s", cfg.EffectiveMode())
+	}
+}
@@ -1,22 +1,12 @@
 package cmd
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"text/tabwriter"
-
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
-type User struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Email   string `json:"email"`
-	Role    string `json:"role"`
-	Present bool   `json:"present"`
-	Asleep  bool   `json:"asleep"`
-}
+type User = homey.User
 
 var usersCmd = &cobra.Command{
 	Use:   "users",
@@ -33,28 +23,13 @@ var usersListCmd = &cobra.Command{
 			return err
 		}
 
-		if isTableFormat() {
-			var users map[string]User
-			if err := json.Unmarshal(data, &users); err != nil {
-				return fmt.Errorf("failed to parse users: %w", err)
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tROLE\tPRESENT\tID")
-			fmt.Fprintln(w, "----\t----\t-------\t--")
-			for _, u := range users {
-				present := "no"
-				if u.Present {
-					present = "yes"
-				}
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.Name, u.Role, present, u.ID)
-			}
-			w.Flush()
-			return nil
-		}
-
-		outputJSON(data)
-		return nil
+		return printList(data, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "ROLE", Path: ".role"},
+			{Header: "PRESENT", Value: yesNo("present")},
+			{Header: "ID", Path: ".id"},
+			{Header: "ASLEEP", Value: yesNo("asleep"), Wide: true},
+		})
 	},
 }
 
@@ -3,10 +3,9 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
 	"github.com/spf13/cobra"
 )
 
@@ -66,8 +65,7 @@ var usersGetCmd = &cobra.Command{
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -98,8 +96,7 @@ var usersMeCmd = &cobra.Command{
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -148,8 +145,7 @@ Examples:
 			return nil
 		}
 
-		outputJSON(result)
-		return nil
+		return printOutput(result)
 	},
 }
 
@@ -225,38 +221,6 @@ var usersPresenceCmd = &cobra.Command{
 			return fmt.Errorf("failed to parse users: %w", err)
 		}
 
-		if isTableFormat() {
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tPRESENT\tASLEEP\tID")
-			fmt.Fprintln(w, "----\t-------\t------\t--")
-
-			for _, u := range users {
-				// Get presence status
-				presentData, _ := apiClient.GetPresent(u.ID)
-				asleepData, _ := apiClient.GetAsleep(u.ID)
-
-				var present, asleep struct {
-					Value bool `json:"value"`
-				}
-				json.Unmarshal(presentData, &present)
-				json.Unmarshal(asleepData, &asleep)
-
-				presentStr := "no"
-				if present.Value {
-					presentStr = "yes"
-				}
-				asleepStr := "no"
-				if asleep.Value {
-					asleepStr = "yes"
-				}
-
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.Name, presentStr, asleepStr, u.ID)
-			}
-			w.Flush()
-			return nil
-		}
-
-		// JSON output - build presence map
 		presenceMap := make(map[string]interface{})
 		for _, u := range users {
 			presentData, _ := apiClient.GetPresent(u.ID)
@@ -269,15 +233,19 @@ var usersPresenceCmd = &cobra.Command{
 			json.Unmarshal(asleepData, &asleep)
 
 			presenceMap[u.ID] = map[string]interface{}{
+				"id":      u.ID,
 				"name":    u.Name,
 				"present": present.Value,
 				"asleep":  asleep.Value,
 			}
 		}
 
-		out, _ := json.MarshalIndent(presenceMap, "", "  ")
-		fmt.Println(string(out))
-		return nil
+		return printList(presenceMap, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "PRESENT", Value: yesNo("present")},
+			{Header: "ASLEEP", Value: yesNo("asleep")},
+			{Header: "ID", Path: ".id"},
+		})
 	},
 }
 
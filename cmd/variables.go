@@ -3,19 +3,14 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
-type Variable struct {
-	ID    string      `json:"id"`
-	Name  string      `json:"name"`
-	Type  string      `json:"type"`
-	Value interface{} `json:"value"`
-}
+type Variable = homey.Variable
 
 var varsCmd = &cobra.Command{
 	Use:     "variables",
@@ -33,24 +28,12 @@ var varsListCmd = &cobra.Command{
 			return err
 		}
 
-		if isTableFormat() {
-			var vars map[string]Variable
-			if err := json.Unmarshal(data, &vars); err != nil {
-				return fmt.Errorf("failed to parse variables: %w", err)
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tTYPE\tVALUE\tID")
-			fmt.Fprintln(w, "----\t----\t-----\t--")
-			for _, v := range vars {
-				fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", v.Name, v.Type, v.Value, v.ID)
-			}
-			w.Flush()
-			return nil
-		}
-
-		outputJSON(data)
-		return nil
+		return printList(data, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "TYPE", Path: ".type"},
+			{Header: "VALUE", Path: ".value"},
+			{Header: "ID", Path: ".id"},
+		})
 	},
 }
 
@@ -92,9 +75,7 @@ var varsGetCmd = &cobra.Command{
 			return nil
 		}
 
-		out, _ := json.MarshalIndent(variable, "", "  ")
-		fmt.Println(string(out))
-		return nil
+		return printOutput(variable)
 	},
 }
 
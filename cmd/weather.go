@@ -40,8 +40,7 @@ var weatherCurrentCmd = &cobra.Command{
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -78,8 +77,7 @@ var weatherForecastCmd = &cobra.Command{
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -3,20 +3,16 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"sort"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/langtind/homeyctl/internal/render"
+	"github.com/langtind/homeyctl/pkg/homey"
 	"github.com/spf13/cobra"
 )
 
 // Zone represents a Homey zone
-type Zone struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Parent string `json:"parent"`
-	Icon   string `json:"icon"`
-}
+type Zone = homey.Zone
 
 // KnownZoneIcons contains all known zone icons available in Homey
 var KnownZoneIcons = []string{
@@ -61,6 +57,32 @@ var zonesCmd = &cobra.Command{
 	Long:  `List, view, create, and manage Homey zones.`,
 }
 
+// zoneColumn is a wide column with the name of an item's zone. Zones are
+// only fetched when --wide is set; IDs are shown if that fails.
+func zoneColumn() render.Column {
+	column := render.Column{Header: "ZONE", Path: ".zone", Wide: true}
+	if !wideFlag {
+		return column
+	}
+
+	data, err := apiClient.GetZones()
+	if err != nil {
+		return column
+	}
+	var zones map[string]Zone
+	if err := json.Unmarshal(data, &zones); err != nil {
+		return column
+	}
+	column.Value = func(item map[string]interface{}) interface{} {
+		id, _ := item["zone"].(string)
+		if z, ok := zones[id]; ok {
+			return z.Name
+		}
+		return id
+	}
+	return column
+}
+
 // findZone finds a zone by name or ID from the list of all zones
 func findZone(nameOrID string) (*Zone, error) {
 	data, err := apiClient.GetZones()
@@ -73,13 +95,37 @@ func findZone(nameOrID string) (*Zone, error) {
 		return nil, fmt.Errorf("failed to parse zones: %w", err)
 	}
 
-	for _, z := range zones {
-		if z.ID == nameOrID || strings.EqualFold(z.Name, nameOrID) {
+	return matchZone(zones, nameOrID)
+}
+
+// matchZone finds a zone by ID, or by name when exactly one zone has it
+func matchZone(zones map[string]Zone, nameOrID string) (*Zone, error) {
+	var matches []Zone
+	for id, z := range zones {
+		if z.ID == "" {
+			z.ID = id
+		}
+		if z.ID == nameOrID {
 			return &z, nil
 		}
+		if strings.EqualFold(z.Name, nameOrID) {
+			matches = append(matches, z)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("zone not found: %s", nameOrID)
+	case 1:
+		return &matches[0], nil
 	}
 
-	return nil, fmt.Errorf("zone not found: %s", nameOrID)
+	var ids []string
+	for _, z := range matches {
+		ids = append(ids, z.ID)
+	}
+	sort.Strings(ids)
+	return nil, fmt.Errorf("multiple zones named '%s', use an ID instead: %s", nameOrID, strings.Join(ids, ", "))
 }
 
 var zonesListCmd = &cobra.Command{
@@ -91,24 +137,23 @@ var zonesListCmd = &cobra.Command{
 			return err
 		}
 
-		if isTableFormat() {
-			var zones map[string]Zone
-			if err := json.Unmarshal(data, &zones); err != nil {
-				return fmt.Errorf("failed to parse zones: %w", err)
-			}
-
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tICON\tID")
-			fmt.Fprintln(w, "----\t----\t--")
-			for _, z := range zones {
-				fmt.Fprintf(w, "%s\t%s\t%s\n", z.Name, z.Icon, z.ID)
-			}
-			w.Flush()
-			return nil
+		var zones map[string]Zone
+		if err := json.Unmarshal(data, &zones); err != nil {
+			return fmt.Errorf("failed to parse zones: %w", err)
 		}
 
-		outputJSON(data)
-		return nil
+		return printList(data, []render.Column{
+			{Header: "NAME", Path: ".name"},
+			{Header: "ICON", Path: ".icon"},
+			{Header: "ID", Path: ".id"},
+			{Header: "PARENT", Wide: true, Value: func(z map[string]interface{}) interface{} {
+				parent, _ := z["parent"].(string)
+				if p, ok := zones[parent]; ok {
+					return p.Name
+				}
+				return parent
+			}},
+		})
 	},
 }
 
@@ -153,8 +198,7 @@ Examples:
 			return nil
 		}
 
-		outputJSON(data)
-		return nil
+		return printOutput(data)
 	},
 }
 
@@ -177,9 +221,7 @@ Note: This list may not be exhaustive. Homey may support additional icons.`,
 			return nil
 		}
 
-		jsonData, _ := json.MarshalIndent(KnownZoneIcons, "", "  ")
-		fmt.Println(string(jsonData))
-		return nil
+		return printOutput(KnownZoneIcons)
 	},
 }
 
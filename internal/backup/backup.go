@@ -0,0 +1,353 @@
+// Package backup saves a Homey's user configuration to a .tar.gz archive and
+// restores it, onto the same Homey or a new one. IDs in the archive are
+// remapped to the matching entities on the Homey being restored.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the archive layout version written to the manifest
+const FormatVersion = 1
+
+// API is the part of the Homey client backup and restore use.
+// *client.Client satisfies it.
+type API interface {
+	GetSystem() (json.RawMessage, error)
+	GetZones() (json.RawMessage, error)
+	GetDevices() (json.RawMessage, error)
+	GetDeviceSettings(id string) (json.RawMessage, error)
+	GetFlows() (json.RawMessage, error)
+	GetAdvancedFlows() (json.RawMessage, error)
+	GetFlowFolders() (json.RawMessage, error)
+	GetVariables() (json.RawMessage, error)
+	GetMoods() (json.RawMessage, error)
+	GetDashboards() (json.RawMessage, error)
+	GetUsers() (json.RawMessage, error)
+	GetApps() (json.RawMessage, error)
+	GetAppSettings(id string) (json.RawMessage, error)
+
+	CreateZone(zone map[string]interface{}) (json.RawMessage, error)
+	UpdateDevice(id string, updates map[string]interface{}) error
+	SetDeviceSetting(deviceID string, settings map[string]interface{}) error
+	CreateDeviceGroup(group map[string]interface{}) (json.RawMessage, error)
+	CreateFlowFolder(folder map[string]interface{}) (json.RawMessage, error)
+	CreateVariable(name string, varType string, value interface{}) (json.RawMessage, error)
+	SetAppSetting(appID, settingName string, value interface{}) error
+	CreateMood(mood map[string]interface{}) (json.RawMessage, error)
+	UpdateMood(id string, updates map[string]interface{}) error
+	CreateDashboard(dashboard map[string]interface{}) (json.RawMessage, error)
+	UpdateDashboard(id string, updates map[string]interface{}) error
+	CreateFlow(flow map[string]interface{}) (json.RawMessage, error)
+	UpdateFlow(id string, flow map[string]interface{}) (json.RawMessage, error)
+	CreateAdvancedFlow(flow map[string]interface{}) (json.RawMessage, error)
+	UpdateAdvancedFlow(id string, flow map[string]interface{}) (json.RawMessage, error)
+}
+
+// Manifest describes an archive
+type Manifest struct {
+	Version      int       `json:"version"`
+	Created      time.Time `json:"created"`
+	CreatedBy    string    `json:"createdBy,omitempty"`
+	HomeyModel   string    `json:"homeyModel,omitempty"`
+	HomeyVersion string    `json:"homeyVersion,omitempty"`
+}
+
+// Backup is everything in an archive. Each section is the API response as
+// Homey sent it, keyed by ID.
+type Backup struct {
+	Manifest Manifest
+
+	System        json.RawMessage
+	Zones         json.RawMessage
+	Devices       json.RawMessage
+	Flows         json.RawMessage
+	AdvancedFlows json.RawMessage
+	FlowFolders   json.RawMessage
+	Variables     json.RawMessage
+	Moods         json.RawMessage
+	Dashboards    json.RawMessage
+	Users         json.RawMessage
+	Apps          json.RawMessage
+
+	// DeviceSettings and AppSettings are keyed by device and app ID
+	DeviceSettings map[string]json.RawMessage
+	AppSettings    map[string]json.RawMessage
+}
+
+// sections maps archive file names to the sections they hold
+func (b *Backup) sections() map[string]*json.RawMessage {
+	return map[string]*json.RawMessage{
+		"system.json":         &b.System,
+		"zones.json":          &b.Zones,
+		"devices.json":        &b.Devices,
+		"flows.json":          &b.Flows,
+		"advanced-flows.json": &b.AdvancedFlows,
+		"flow-folders.json":   &b.FlowFolders,
+		"variables.json":      &b.Variables,
+		"moods.json":          &b.Moods,
+		"dashboards.json":     &b.Dashboards,
+		"users.json":          &b.Users,
+		"apps.json":           &b.Apps,
+	}
+}
+
+const (
+	deviceSettingsDir = "device-settings/"
+	appSettingsDir    = "app-settings/"
+)
+
+// Options controls Create
+type Options struct {
+	// CreatedBy is recorded in the manifest, e.g. "homeyctl 1.2.3"
+	CreatedBy string
+
+	// Warn is called for settings that couldn't be read. They are left out
+	// of the backup rather than failing it.
+	Warn func(msg string)
+}
+
+// Create reads the user configuration from a Homey
+func Create(api API, opts Options) (*Backup, error) {
+	b := &Backup{
+		Manifest: Manifest{
+			Version:   FormatVersion,
+			Created:   time.Now().UTC(),
+			CreatedBy: opts.CreatedBy,
+		},
+		DeviceSettings: map[string]json.RawMessage{},
+		AppSettings:    map[string]json.RawMessage{},
+	}
+	warn := opts.Warn
+	if warn == nil {
+		warn = func(string) {}
+	}
+
+	gets := []struct {
+		what string
+		get  func() (json.RawMessage, error)
+		into *json.RawMessage
+	}{
+		{"system info", api.GetSystem, &b.System},
+		{"zones", api.GetZones, &b.Zones},
+		{"devices", api.GetDevices, &b.Devices},
+		{"flows", api.GetFlows, &b.Flows},
+		{"advanced flows", api.GetAdvancedFlows, &b.AdvancedFlows},
+		{"flow folders", api.GetFlowFolders, &b.FlowFolders},
+		{"variables", api.GetVariables, &b.Variables},
+		{"moods", api.GetMoods, &b.Moods},
+		{"dashboards", api.GetDashboards, &b.Dashboards},
+		{"users", api.GetUsers, &b.Users},
+		{"apps", api.GetApps, &b.Apps},
+	}
+	for _, g := range gets {
+		data, err := g.get()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", g.what, err)
+		}
+		*g.into = data
+	}
+
+	var system struct {
+		HomeyModelName string `json:"homeyModelName"`
+		HomeyVersion   string `json:"homeyVersion"`
+	}
+	json.Unmarshal(b.System, &system)
+	b.Manifest.HomeyModel = system.HomeyModelName
+	b.Manifest.HomeyVersion = system.HomeyVersion
+
+	devices, err := decodeMap(b.Devices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse devices: %w", err)
+	}
+	for _, id := range sortedKeys(devices) {
+		data, err := api.GetDeviceSettings(id)
+		if err != nil {
+			warn(fmt.Sprintf("skipped settings of device %s: %v", str(devices[id], "name"), err))
+			continue
+		}
+		b.DeviceSettings[id] = data
+	}
+
+	apps, err := decodeMap(b.Apps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apps: %w", err)
+	}
+	for _, id := range sortedKeys(apps) {
+		data, err := api.GetAppSettings(id)
+		if err != nil {
+			warn(fmt.Sprintf("skipped settings of app %s: %v", id, err))
+			continue
+		}
+		b.AppSettings[id] = data
+	}
+
+	return b, nil
+}
+
+// Write writes the backup as a .tar.gz archive
+func (b *Backup) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: b.Manifest.Created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := add("manifest.json", manifest); err != nil {
+		return err
+	}
+	sections := b.sections()
+	for _, name := range sortedKeys(sections) {
+		if data := *sections[name]; data != nil {
+			if err := add(name, data); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range sortedKeys(b.DeviceSettings) {
+		if err := add(deviceSettingsDir+id+".json", b.DeviceSettings[id]); err != nil {
+			return err
+		}
+	}
+	for _, id := range sortedKeys(b.AppSettings) {
+		if err := add(appSettingsDir+id+".json", b.AppSettings[id]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Read reads a backup written by Write
+func Read(r io.Reader) (*Backup, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	b := &Backup{
+		DeviceSettings: map[string]json.RawMessage{},
+		AppSettings:    map[string]json.RawMessage{},
+	}
+	sections := b.sections()
+	hasManifest := false
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+
+		name := path.Clean(hdr.Name)
+		switch {
+		case name == "manifest.json":
+			if err := json.Unmarshal(data, &b.Manifest); err != nil {
+				return nil, fmt.Errorf("failed to parse manifest: %w", err)
+			}
+			hasManifest = true
+		case sections[name] != nil:
+			*sections[name] = data
+		case strings.HasPrefix(name, deviceSettingsDir):
+			b.DeviceSettings[strings.TrimSuffix(strings.TrimPrefix(name, deviceSettingsDir), ".json")] = data
+		case strings.HasPrefix(name, appSettingsDir):
+			b.AppSettings[strings.TrimSuffix(strings.TrimPrefix(name, appSettingsDir), ".json")] = data
+		}
+	}
+
+	if !hasManifest {
+		return nil, fmt.Errorf("not a homeyctl backup: manifest.json is missing")
+	}
+	if b.Manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("backup format %d is newer than this homeyctl supports (%d), upgrade homeyctl", b.Manifest.Version, FormatVersion)
+	}
+	return b, nil
+}
+
+// Counts returns how many entities each section holds, for summaries
+func (b *Backup) Counts() map[string]int {
+	counts := map[string]int{}
+	for name, data := range map[string]json.RawMessage{
+		"zones":          b.Zones,
+		"devices":        b.Devices,
+		"flows":          b.Flows,
+		"advanced flows": b.AdvancedFlows,
+		"flow folders":   b.FlowFolders,
+		"variables":      b.Variables,
+		"moods":          b.Moods,
+		"dashboards":     b.Dashboards,
+		"users":          b.Users,
+		"apps":           b.Apps,
+	} {
+		m, _ := decodeMap(data)
+		counts[name] = len(m)
+	}
+	counts["device settings"] = len(b.DeviceSettings)
+	counts["app settings"] = len(b.AppSettings)
+	return counts
+}
+
+// decodeMap decodes a section into objects keyed by ID. An empty section
+// decodes to an empty map.
+func decodeMap(data json.RawMessage) (map[string]map[string]interface{}, error) {
+	m := map[string]map[string]interface{}{}
+	if len(data) == 0 {
+		return m, nil
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// str returns a string field of a decoded JSON object
+func str(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
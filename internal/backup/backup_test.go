@@ -0,0 +1,399 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeHomey serves canned GET responses and records every change made
+type fakeHomey struct {
+	sections       map[string]string
+	deviceSettings map[string]string
+	appSettings    map[string]string
+
+	calls   []string
+	bodies  []interface{}
+	created int
+}
+
+func (f *fakeHomey) get(name string) (json.RawMessage, error) {
+	body, ok := f.sections[name]
+	if !ok {
+		body = "{}"
+	}
+	return json.RawMessage(body), nil
+}
+
+func (f *fakeHomey) record(call string, body interface{}) error {
+	f.calls = append(f.calls, call)
+	f.bodies = append(f.bodies, body)
+	return nil
+}
+
+func (f *fakeHomey) create(call string, body interface{}) (json.RawMessage, error) {
+	f.created++
+	f.record(call, body)
+	return json.RawMessage(fmt.Sprintf(`{"id":"new%d"}`, f.created)), nil
+}
+
+func (f *fakeHomey) GetSystem() (json.RawMessage, error)        { return f.get("system") }
+func (f *fakeHomey) GetZones() (json.RawMessage, error)         { return f.get("zones") }
+func (f *fakeHomey) GetDevices() (json.RawMessage, error)       { return f.get("devices") }
+func (f *fakeHomey) GetFlows() (json.RawMessage, error)         { return f.get("flows") }
+func (f *fakeHomey) GetAdvancedFlows() (json.RawMessage, error) { return f.get("advancedFlows") }
+func (f *fakeHomey) GetFlowFolders() (json.RawMessage, error)   { return f.get("folders") }
+func (f *fakeHomey) GetVariables() (json.RawMessage, error)     { return f.get("variables") }
+func (f *fakeHomey) GetMoods() (json.RawMessage, error)         { return f.get("moods") }
+func (f *fakeHomey) GetDashboards() (json.RawMessage, error)    { return f.get("dashboards") }
+func (f *fakeHomey) GetUsers() (json.RawMessage, error)         { return f.get("users") }
+func (f *fakeHomey) GetApps() (json.RawMessage, error)          { return f.get("apps") }
+
+func (f *fakeHomey) GetDeviceSettings(id string) (json.RawMessage, error) {
+	if s, ok := f.deviceSettings[id]; ok {
+		return json.RawMessage(s), nil
+	}
+	return nil, fmt.Errorf("no settings")
+}
+
+func (f *fakeHomey) GetAppSettings(id string) (json.RawMessage, error) {
+	if s, ok := f.appSettings[id]; ok {
+		return json.RawMessage(s), nil
+	}
+	return json.RawMessage("{}"), nil
+}
+
+func (f *fakeHomey) CreateZone(zone map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateZone", zone)
+}
+func (f *fakeHomey) UpdateDevice(id string, updates map[string]interface{}) error {
+	return f.record("UpdateDevice "+id, updates)
+}
+func (f *fakeHomey) SetDeviceSetting(id string, settings map[string]interface{}) error {
+	return f.record("SetDeviceSetting "+id, settings)
+}
+func (f *fakeHomey) CreateDeviceGroup(group map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateDeviceGroup", group)
+}
+func (f *fakeHomey) CreateFlowFolder(folder map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateFlowFolder", folder)
+}
+func (f *fakeHomey) CreateVariable(name, varType string, value interface{}) (json.RawMessage, error) {
+	return f.create("CreateVariable", map[string]interface{}{"name": name, "type": varType, "value": value})
+}
+func (f *fakeHomey) SetAppSetting(appID, name string, value interface{}) error {
+	return f.record("SetAppSetting "+appID+" "+name, value)
+}
+func (f *fakeHomey) CreateMood(mood map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateMood", mood)
+}
+func (f *fakeHomey) UpdateMood(id string, updates map[string]interface{}) error {
+	return f.record("UpdateMood "+id, updates)
+}
+func (f *fakeHomey) CreateDashboard(dashboard map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateDashboard", dashboard)
+}
+func (f *fakeHomey) UpdateDashboard(id string, updates map[string]interface{}) error {
+	return f.record("UpdateDashboard "+id, updates)
+}
+func (f *fakeHomey) CreateFlow(flow map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateFlow", flow)
+}
+func (f *fakeHomey) UpdateFlow(id string, flow map[string]interface{}) (json.RawMessage, error) {
+	return nil, f.record("UpdateFlow "+id, flow)
+}
+func (f *fakeHomey) CreateAdvancedFlow(flow map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateAdvancedFlow", flow)
+}
+func (f *fakeHomey) UpdateAdvancedFlow(id string, flow map[string]interface{}) (json.RawMessage, error) {
+	return nil, f.record("UpdateAdvancedFlow "+id, flow)
+}
+
+// oldHomey is the Homey the backup is taken from
+func oldHomey() *fakeHomey {
+	return &fakeHomey{
+		sections: map[string]string{
+			"system": `{"homeyModelName": "Homey Pro", "homeyVersion": "12.0.0"}`,
+			"zones": `{
+				"z-home": {"id": "z-home", "name": "Home", "parent": null},
+				"z-kitchen": {"id": "z-kitchen", "name": "Kitchen", "parent": "z-home", "icon": "kitchen"}
+			}`,
+			"devices": `{
+				"d-lamp": {"id": "d-lamp", "name": "Lamp", "zone": "z-kitchen"},
+				"d-gone": {"id": "d-gone", "name": "Old Sensor", "zone": "z-home"},
+				"d-group": {"id": "d-group", "name": "Lights", "zone": "z-kitchen", "class": "light", "virtualClass": "group", "devices": ["d-lamp"]}
+			}`,
+			"users":     `{"u-anna": {"id": "u-anna", "name": "Anna"}}`,
+			"variables": `{"v-guests": {"id": "v-guests", "name": "guests", "type": "boolean", "value": true}}`,
+			"folders":   `{"f-lights": {"id": "f-lights", "name": "Lights", "parent": null}}`,
+			"flows": `{"fl-1": {
+				"id": "fl-1", "name": "Sunset", "folder": "f-lights",
+				"trigger": {"id": "homey:manager:geolocation:sunset"},
+				"conditions": [{"id": "homey:manager:logic:lt", "droptoken": "homey:device:d-lamp|measure_power"}],
+				"actions": [{"id": "homey:device:d-lamp:on"}]
+			}}`,
+			"advancedFlows": `{"af-1": {"id": "af-1", "name": "Sensor", "cards": {"c1": {"ownerUri": "homey:device:d-gone"}}}}`,
+			"moods":         `{"m-1": {"id": "m-1", "name": "Dinner", "zone": "z-kitchen", "devices": {"d-lamp": {"onoff": true}}}}`,
+			"apps":          `{"com.example": {"id": "com.example"}}`,
+		},
+		deviceSettings: map[string]string{"d-lamp": `{"zone_activity_disabled": true}`},
+		appSettings:    map[string]string{"com.example": `{"pollInterval": 60}`},
+	}
+}
+
+// newHomey is a freshly reset Homey with the lamp re-paired
+func newHomey() *fakeHomey {
+	return &fakeHomey{
+		sections: map[string]string{
+			"zones":   `{"n-home": {"id": "n-home", "name": "Home", "parent": null}}`,
+			"devices": `{"n-lamp": {"id": "n-lamp", "name": "Lamp", "zone": "n-home"}}`,
+			"users":   `{"n-anna": {"id": "n-anna", "name": "Anna"}}`,
+			"apps":    `{"com.example": {"id": "com.example"}}`,
+		},
+	}
+}
+
+func TestCreateWriteRead(t *testing.T) {
+	var warnings []string
+	b, err := Create(oldHomey(), Options{CreatedBy: "homeyctl test", Warn: func(msg string) { warnings = append(warnings, msg) }})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.Manifest.HomeyModel != "Homey Pro" || b.Manifest.Version != FormatVersion {
+		t.Errorf("unexpected manifest: %+v", b.Manifest)
+	}
+	// Old Sensor and the group have no settings
+	if len(b.DeviceSettings) != 1 || len(warnings) != 2 {
+		t.Errorf("expected settings of one device and two warnings, got %v and %v", b.DeviceSettings, warnings)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if string(read.Flows) != string(b.Flows) || string(read.DeviceSettings["d-lamp"]) != `{"zone_activity_disabled": true}` {
+		t.Error("archive did not round trip")
+	}
+	if string(read.AppSettings["com.example"]) != `{"pollInterval": 60}` {
+		t.Errorf("unexpected app settings: %s", read.AppSettings["com.example"])
+	}
+	if counts := read.Counts(); counts["devices"] != 3 || counts["flows"] != 1 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+}
+
+func TestRead_RejectsOtherArchives(t *testing.T) {
+	if _, err := Read(strings.NewReader("not gzip")); err == nil {
+		t.Error("expected error for a non-gzip file")
+	}
+
+	b := &Backup{Manifest: Manifest{Version: FormatVersion + 1}}
+	var buf bytes.Buffer
+	b.Write(&buf)
+	if _, err := Read(&buf); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected error for a newer format, got %v", err)
+	}
+}
+
+func TestRestore_RemapsIDs(t *testing.T) {
+	b, err := Create(oldHomey(), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	target := newHomey()
+	report, err := Restore(target, b, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	byCall := map[string]interface{}{}
+	for i, call := range target.calls {
+		byCall[call] = target.bodies[i]
+	}
+
+	// Kitchen is created under the new root; the lamp is moved into it
+	if zone := byCall["CreateZone"].(map[string]interface{}); zone["parent"] != "n-home" {
+		t.Errorf("unexpected zone: %v", zone)
+	}
+	if move := byCall["UpdateDevice n-lamp"].(map[string]interface{}); move["zone"] != "new1" {
+		t.Errorf("unexpected device move: %v", move)
+	}
+	group := byCall["CreateDeviceGroup"].(map[string]interface{})
+	if group["zoneId"] != "new1" || fmt.Sprint(group["deviceIds"]) != "[n-lamp]" {
+		t.Errorf("unexpected group: %v", group)
+	}
+	if _, ok := byCall["SetDeviceSetting n-lamp"]; !ok {
+		t.Error("expected lamp settings to be restored")
+	}
+	if v := byCall["SetAppSetting com.example pollInterval"]; v != 60.0 {
+		t.Errorf("expected app setting to be restored, got %v", v)
+	}
+
+	mood := byCall["CreateMood"].(map[string]interface{})
+	if _, ok := mood["devices"].(map[string]interface{})["n-lamp"]; !ok || mood["zone"] != "new1" {
+		t.Errorf("expected mood to be remapped, got %v", mood)
+	}
+
+	flow, _ := json.Marshal(byCall["CreateFlow"])
+	for _, want := range []string{`"homey:device:n-lamp|measure_power"`, `"homey:device:n-lamp:on"`, `"folder":"new3"`} {
+		if !strings.Contains(string(flow), want) {
+			t.Errorf("expected flow to contain %s, got %s", want, flow)
+		}
+	}
+	if strings.Contains(string(flow), `"id":"fl-1"`) {
+		t.Error("old flow ID should not be sent")
+	}
+
+	warnings := strings.Join(report.Warnings, "\n")
+	if !strings.Contains(warnings, `device "Old Sensor" is not on this Homey`) {
+		t.Errorf("expected missing device warning, got:\n%s", warnings)
+	}
+	if !strings.Contains(warnings, `advanced flow "Sensor" refers to device Old Sensor`) {
+		t.Errorf("expected warning for flow using the missing device, got:\n%s", warnings)
+	}
+	if report.Remapped == 0 {
+		t.Error("expected IDs to be remapped")
+	}
+}
+
+func TestRestore_DryRun(t *testing.T) {
+	b, err := Create(oldHomey(), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	target := newHomey()
+	report, err := Restore(target, b, RestoreOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(target.calls) != 0 {
+		t.Errorf("dry run changed the Homey: %v", target.calls)
+	}
+	if len(report.Changes) == 0 {
+		t.Error("expected the dry run to list changes")
+	}
+}
+
+func TestRestore_SameHomeyUpdatesInPlace(t *testing.T) {
+	b, err := Create(oldHomey(), Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	target := oldHomey()
+	report, err := Restore(target, b, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, call := range target.calls {
+		if strings.HasPrefix(call, "Create") {
+			t.Errorf("nothing should be created on the same Homey, got %s", call)
+		}
+	}
+	if report.Remapped != 0 {
+		t.Errorf("expected no remapped IDs, got %d", report.Remapped)
+	}
+}
+
+func TestRestore_DuplicateNames(t *testing.T) {
+	source := &fakeHomey{sections: map[string]string{
+		"zones": `{
+			"z-home": {"id": "z-home", "name": "Home", "parent": null},
+			"z-ground": {"id": "z-ground", "name": "Ground Floor", "parent": "z-home"},
+			"z-bath": {"id": "z-bath", "name": "Bathroom", "parent": "z-ground"}
+		}`,
+		"flows":     `{"fl-1": {"id": "fl-1", "name": "Sunset"}}`,
+		"variables": `{"v-1": {"id": "v-1", "name": "guests", "type": "boolean", "value": true}}`,
+	}}
+	b, err := Create(source, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	target := &fakeHomey{sections: map[string]string{
+		"zones": `{
+			"n-home": {"id": "n-home", "name": "Home", "parent": null},
+			"n-ground": {"id": "n-ground", "name": "Ground Floor", "parent": "n-home"},
+			"n-up": {"id": "n-up", "name": "Upstairs", "parent": "n-home"},
+			"n-bath1": {"id": "n-bath1", "name": "Bathroom", "parent": "n-ground"},
+			"n-bath2": {"id": "n-bath2", "name": "Bathroom", "parent": "n-up"}
+		}`,
+		"flows":     `{"n-f1": {"id": "n-f1", "name": "Sunset"}, "n-f2": {"id": "n-f2", "name": "Sunset"}}`,
+		"variables": `{"n-v1": {"id": "n-v1", "name": "guests"}, "n-v2": {"id": "n-v2", "name": "Guests"}}`,
+	}}
+	report, err := Restore(target, b, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Bathroom matches the one under Ground Floor; the duplicate flow and
+	// variable are left alone instead of getting another copy
+	if len(target.calls) != 0 {
+		t.Errorf("expected no changes, got %v", target.calls)
+	}
+	warnings := strings.Join(report.Warnings, "\n")
+	for _, want := range []string{`flow "Sunset": several flows`, `variable "guests": several variables`} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("expected warning %q, got:\n%s", want, warnings)
+		}
+	}
+	if strings.Contains(warnings, "zone") {
+		t.Errorf("expected Bathroom to be matched by its parent, got:\n%s", warnings)
+	}
+}
+
+func TestRestore_AmbiguousZoneSkipsChildren(t *testing.T) {
+	source := &fakeHomey{sections: map[string]string{
+		"zones": `{
+			"z-home": {"id": "z-home", "name": "Home", "parent": null},
+			"z-bath": {"id": "z-bath", "name": "Bathroom", "parent": "z-home"},
+			"z-shower": {"id": "z-shower", "name": "Shower", "parent": "z-bath"}
+		}`,
+	}}
+	b, err := Create(source, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	target := &fakeHomey{sections: map[string]string{
+		"zones": `{
+			"n-home": {"id": "n-home", "name": "Home", "parent": null},
+			"n-bath1": {"id": "n-bath1", "name": "Bathroom", "parent": "n-home"},
+			"n-bath2": {"id": "n-bath2", "name": "bathroom", "parent": "n-home"}
+		}`,
+	}}
+	report, err := Restore(target, b, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(target.calls) != 0 {
+		t.Errorf("expected no zones to be created, got %v", target.calls)
+	}
+	warnings := strings.Join(report.Warnings, "\n")
+	if !strings.Contains(warnings, `zone "Bathroom": several zones`) || !strings.Contains(warnings, `zone "Shower": skipped along with its parent`) {
+		t.Errorf("unexpected warnings:\n%s", warnings)
+	}
+}
+
+func TestSettingValues(t *testing.T) {
+	for _, input := range []string{
+		`{"a": 1, "b": {"value": 2}}`,
+		`[{"id": "a", "value": 1}, {"id": "b", "value": 2}]`,
+	} {
+		values, err := settingValues(json.RawMessage(input))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if values["a"] != 1.0 || values["b"] != 2.0 {
+			t.Errorf("settingValues(%s) = %v", input, values)
+		}
+	}
+}
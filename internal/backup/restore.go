@@ -0,0 +1,791 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RestoreOptions controls Restore
+type RestoreOptions struct {
+	// DryRun reports what would be restored without changing anything
+	DryRun bool
+
+	// Progress is called with each change as it is made
+	Progress func(msg string)
+}
+
+// Report is what a restore did
+type Report struct {
+	Changes  []string `json:"changes"`
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Remapped is how many IDs in the backup now point at a different
+	// entity, e.g. a re-paired device
+	Remapped int `json:"remapped"`
+}
+
+// restorer holds the state of one Restore
+type restorer struct {
+	api    API
+	b      *Backup
+	opts   RestoreOptions
+	report *Report
+
+	// ids maps IDs in the backup to IDs on the Homey
+	ids map[string]string
+	// missing holds devices and users in the backup that have no match
+	missing map[string]string
+	// skipped holds zones and flow folders that matched several on the
+	// Homey, so they and their children are left alone
+	skipped map[string]bool
+	// replacer rewrites old IDs to new ones, built once entities are mapped
+	replacer *strings.Replacer
+}
+
+// Restore recreates a backup on a Homey. Zones, flow folders, device groups
+// and variables that don't exist yet are created, and devices and users are
+// matched by name. Moods, dashboards and flows are then created or updated
+// with every ID they refer to rewritten to the matching entity.
+//
+// Zones and flow folders are matched by name within their parent. Anything
+// several entities on the Homey match is skipped with a warning, so a repeat
+// restore doesn't add copies.
+//
+// Devices have to be paired and users invited in the Homey app first;
+// anything that refers to one that is missing is reported in the warnings.
+func Restore(api API, b *Backup, opts RestoreOptions) (*Report, error) {
+	r := &restorer{
+		api:     api,
+		b:       b,
+		opts:    opts,
+		report:  &Report{Changes: []string{}},
+		ids:     map[string]string{},
+		missing: map[string]string{},
+		skipped: map[string]bool{},
+	}
+
+	steps := []func() error{
+		r.users,
+		r.zones,
+		r.devices,
+		r.groups,
+		r.folders,
+		r.variables,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return r.report, err
+		}
+	}
+
+	// Everything that can be referred to is mapped now; the rest is restored
+	// with its references rewritten
+	r.buildReplacer()
+	for _, step := range []func() error{r.deviceSettings, r.appSettings, r.moods, r.dashboards, r.flows} {
+		if err := step(); err != nil {
+			return r.report, err
+		}
+	}
+	return r.report, nil
+}
+
+// change records a change that is about to be made
+func (r *restorer) change(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	r.report.Changes = append(r.report.Changes, msg)
+	if r.opts.Progress != nil {
+		r.opts.Progress(msg)
+	}
+}
+
+func (r *restorer) warn(format string, args ...interface{}) {
+	r.report.Warnings = append(r.report.Warnings, fmt.Sprintf(format, args...))
+}
+
+// warnAmbiguous warns that an entity is skipped because several on the Homey
+// match it
+func (r *restorer) warnAmbiguous(what, name string) {
+	r.warn("%s %q: several %ss on this Homey have that name, rename them and restore again", what, name, what)
+}
+
+// mapID records that an ID in the backup is newID on the Homey
+func (r *restorer) mapID(oldID, newID string) {
+	r.ids[oldID] = newID
+	if oldID != newID {
+		r.report.Remapped++
+	}
+}
+
+// id returns the Homey ID for an ID in the backup
+func (r *restorer) id(oldID string) string {
+	if id, ok := r.ids[oldID]; ok {
+		return id
+	}
+	return oldID
+}
+
+// create runs a create call and maps oldID to the new entity. In a dry run
+// the old ID stands in for the new one.
+func (r *restorer) create(what, name, oldID string, create func() (json.RawMessage, error)) error {
+	r.change("create %s %q", what, name)
+	if r.opts.DryRun {
+		r.ids[oldID] = oldID
+		return nil
+	}
+	data, err := create()
+	if err != nil {
+		return fmt.Errorf("failed to create %s %q: %w", what, name, err)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil || created.ID == "" {
+		return fmt.Errorf("failed to create %s %q: Homey returned no ID", what, name)
+	}
+	r.mapID(oldID, created.ID)
+	return nil
+}
+
+// do runs a call that changes the Homey, unless this is a dry run
+func (r *restorer) do(call func() error) error {
+	if r.opts.DryRun {
+		return nil
+	}
+	return call()
+}
+
+// load decodes a section of the backup and the same section on the Homey
+func (r *restorer) load(what string, backup json.RawMessage, get func() (json.RawMessage, error)) (old, live map[string]map[string]interface{}, err error) {
+	old, err = decodeMap(backup)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s in backup: %w", what, err)
+	}
+	data, err := get()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s: %w", what, err)
+	}
+	live, err = decodeMap(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", what, err)
+	}
+	return old, live, nil
+}
+
+// names indexes entities by lowercased name
+type names map[string][]string
+
+func indexNames(m map[string]map[string]interface{}, keep func(map[string]interface{}) bool) names {
+	n := names{}
+	for id, e := range m {
+		if keep == nil || keep(e) {
+			key := strings.ToLower(str(e, "name"))
+			n[key] = append(n[key], id)
+		}
+	}
+	return n
+}
+
+// indexChildren indexes zones or flow folders by parent and name, since the
+// same name often shows up under different parents
+func indexChildren(m map[string]map[string]interface{}) names {
+	n := names{}
+	for id, e := range m {
+		key := childKey(str(e, "parent"), str(e, "name"))
+		n[key] = append(n[key], id)
+	}
+	return n
+}
+
+// childKey is the index key of a name under a parent; find lowercases names
+func childKey(parent, name string) string {
+	return strings.ToLower(parent + "/" + name)
+}
+
+// find returns the only entity with a name, or the one with oldID among
+// several, as when restoring to the same Homey. ok is false if there is none;
+// ambiguous is true if there are several and none has oldID.
+func (n names) find(name, oldID string) (id string, ok, ambiguous bool) {
+	ids := n[strings.ToLower(name)]
+	switch len(ids) {
+	case 0:
+		return "", false, false
+	case 1:
+		return ids[0], true, false
+	}
+	for _, id := range ids {
+		if id == oldID {
+			return id, true, false
+		}
+	}
+	return "", false, true
+}
+
+// users maps users by name. Users can't be created here; they have to be
+// invited from the Homey app.
+func (r *restorer) users() error {
+	old, live, err := r.load("users", r.b.Users, r.api.GetUsers)
+	if err != nil {
+		return err
+	}
+	liveNames := indexNames(live, nil)
+	for _, oldID := range sortedKeys(old) {
+		name := str(old[oldID], "name")
+		id, ok, ambiguous := liveNames.find(name, oldID)
+		if ok {
+			r.mapID(oldID, id)
+			continue
+		}
+		r.missing[oldID] = "user " + name
+		if ambiguous {
+			r.warnAmbiguous("user", name)
+		} else {
+			r.warn("user %q is not on this Homey; invite them in the Homey app", name)
+		}
+	}
+	return nil
+}
+
+// zones maps zones by name and creates the missing ones, parents first. The
+// root zone maps to the root zone whatever it is called.
+func (r *restorer) zones() error {
+	old, live, err := r.load("zones", r.b.Zones, r.api.GetZones)
+	if err != nil {
+		return err
+	}
+	liveChildren := indexChildren(live)
+	liveRoot := rootOf(live)
+
+	for _, oldID := range parentsFirst(old) {
+		z := old[oldID]
+		name := str(z, "name")
+		parent := str(z, "parent")
+		if parent == "" {
+			if liveRoot != "" {
+				r.mapID(oldID, liveRoot)
+			}
+			continue
+		}
+		if r.skipChild("zone", name, oldID, parent) {
+			continue
+		}
+		id, ok, ambiguous := liveChildren.find(childKey(r.id(parent), name), oldID)
+		if ok {
+			r.mapID(oldID, id)
+			continue
+		}
+		if ambiguous {
+			r.skipped[oldID] = true
+			r.warnAmbiguous("zone", name)
+			continue
+		}
+		zone := map[string]interface{}{
+			"name":   name,
+			"parent": r.id(parent),
+			"icon":   z["icon"],
+		}
+		if err := r.create("zone", name, oldID, func() (json.RawMessage, error) {
+			return r.api.CreateZone(zone)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipChild skips a zone or flow folder whose parent was skipped
+func (r *restorer) skipChild(what, name, oldID, parent string) bool {
+	if !r.skipped[parent] {
+		return false
+	}
+	r.skipped[oldID] = true
+	r.warn("%s %q: skipped along with its parent", what, name)
+	return true
+}
+
+// devices maps devices by name and moves them back to their zone
+func (r *restorer) devices() error {
+	old, live, err := r.load("devices", r.b.Devices, r.api.GetDevices)
+	if err != nil {
+		return err
+	}
+	notGroup := func(d map[string]interface{}) bool { return str(d, "virtualClass") != "group" }
+	liveNames := indexNames(live, notGroup)
+
+	for _, oldID := range sortedKeys(old) {
+		d := old[oldID]
+		if !notGroup(d) {
+			continue
+		}
+		name := str(d, "name")
+		id, ok, ambiguous := liveNames.find(name, oldID)
+		if !ok {
+			r.missing[oldID] = "device " + name
+			if ambiguous {
+				r.warnAmbiguous("device", name)
+			} else {
+				r.warn("device %q is not on this Homey; pair it and restore again", name)
+			}
+			continue
+		}
+		r.mapID(oldID, id)
+
+		zone := r.id(str(d, "zone"))
+		if zone == "" || zone == str(live[id], "zone") || r.skipped[str(d, "zone")] {
+			continue
+		}
+		r.change("move device %q to its zone", name)
+		if err := r.do(func() error {
+			return r.api.UpdateDevice(id, map[string]interface{}{"zone": zone})
+		}); err != nil {
+			r.warn("device %q: failed to move it to its zone: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// groups maps device groups by name and recreates the missing ones
+func (r *restorer) groups() error {
+	old, err := decodeMap(r.b.Devices)
+	if err != nil {
+		return fmt.Errorf("failed to parse devices in backup: %w", err)
+	}
+	data, err := r.api.GetDevices()
+	if err != nil {
+		return fmt.Errorf("failed to get devices: %w", err)
+	}
+	live, err := decodeMap(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse devices: %w", err)
+	}
+	isGroup := func(d map[string]interface{}) bool { return str(d, "virtualClass") == "group" }
+	liveNames := indexNames(live, isGroup)
+
+	for _, oldID := range sortedKeys(old) {
+		g := old[oldID]
+		if !isGroup(g) {
+			continue
+		}
+		name := str(g, "name")
+		id, ok, ambiguous := liveNames.find(name, oldID)
+		if ok {
+			r.mapID(oldID, id)
+			continue
+		}
+		if ambiguous {
+			r.missing[oldID] = "group " + name
+			r.warnAmbiguous("device group", name)
+			continue
+		}
+		if r.skipped[str(g, "zone")] {
+			r.missing[oldID] = "group " + name
+			r.warn("group %q: skipped along with its zone", name)
+			continue
+		}
+
+		var members []string
+		oldMembers, _ := g["devices"].([]interface{})
+		for _, m := range oldMembers {
+			if memberID, ok := m.(string); ok && r.missing[memberID] == "" {
+				members = append(members, r.id(memberID))
+			}
+		}
+		if len(members) == 0 {
+			r.missing[oldID] = "group " + name
+			r.warn("group %q: none of its devices are on this Homey", name)
+			continue
+		}
+		group := map[string]interface{}{
+			"name":      name,
+			"class":     g["class"],
+			"zoneId":    r.id(str(g, "zone")),
+			"deviceIds": members,
+		}
+		if err := r.create("device group", name, oldID, func() (json.RawMessage, error) {
+			return r.api.CreateDeviceGroup(group)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// folders maps flow folders by name and creates the missing ones, parents first
+func (r *restorer) folders() error {
+	old, live, err := r.load("flow folders", r.b.FlowFolders, r.api.GetFlowFolders)
+	if err != nil {
+		return err
+	}
+	liveChildren := indexChildren(live)
+	for _, oldID := range parentsFirst(old) {
+		f := old[oldID]
+		name := str(f, "name")
+		parent := str(f, "parent")
+		if r.skipChild("flow folder", name, oldID, parent) {
+			continue
+		}
+		liveParent := ""
+		if parent != "" {
+			liveParent = r.id(parent)
+		}
+		id, ok, ambiguous := liveChildren.find(childKey(liveParent, name), oldID)
+		if ok {
+			r.mapID(oldID, id)
+			continue
+		}
+		if ambiguous {
+			r.skipped[oldID] = true
+			r.warnAmbiguous("flow folder", name)
+			continue
+		}
+		folder := map[string]interface{}{"name": name}
+		if liveParent != "" {
+			folder["parent"] = liveParent
+		}
+		if err := r.create("flow folder", name, oldID, func() (json.RawMessage, error) {
+			return r.api.CreateFlowFolder(folder)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// variables maps logic variables by name and creates the missing ones with
+// the value they had
+func (r *restorer) variables() error {
+	old, live, err := r.load("variables", r.b.Variables, r.api.GetVariables)
+	if err != nil {
+		return err
+	}
+	liveNames := indexNames(live, nil)
+	for _, oldID := range sortedKeys(old) {
+		v := old[oldID]
+		name := str(v, "name")
+		id, ok, ambiguous := liveNames.find(name, oldID)
+		if ok {
+			r.mapID(oldID, id)
+			continue
+		}
+		if ambiguous {
+			r.warnAmbiguous("variable", name)
+			continue
+		}
+		if err := r.create("variable", name, oldID, func() (json.RawMessage, error) {
+			return r.api.CreateVariable(name, str(v, "type"), v["value"])
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deviceSettings restores the settings of every device that was matched
+func (r *restorer) deviceSettings() error {
+	old, err := decodeMap(r.b.Devices)
+	if err != nil {
+		return fmt.Errorf("failed to parse devices in backup: %w", err)
+	}
+	for _, oldID := range sortedKeys(r.b.DeviceSettings) {
+		if r.missing[oldID] != "" || old[oldID] == nil {
+			continue
+		}
+		name := str(old[oldID], "name")
+		values, err := settingValues(r.b.DeviceSettings[oldID])
+		if err != nil {
+			r.warn("device %q: failed to parse settings: %v", name, err)
+			continue
+		}
+		if len(values) == 0 {
+			continue
+		}
+		r.change("restore %d setting(s) of device %q", len(values), name)
+		id := r.id(oldID)
+		settings := r.remap(values).(map[string]interface{})
+		if err := r.do(func() error { return r.api.SetDeviceSetting(id, settings) }); err != nil {
+			r.warn("device %q: failed to restore settings: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// appSettings restores the settings of every app that is installed
+func (r *restorer) appSettings() error {
+	data, err := r.api.GetApps()
+	if err != nil {
+		return fmt.Errorf("failed to get apps: %w", err)
+	}
+	installed, err := decodeMap(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse apps: %w", err)
+	}
+
+	for _, appID := range sortedKeys(r.b.AppSettings) {
+		var values map[string]interface{}
+		if err := json.Unmarshal(r.b.AppSettings[appID], &values); err != nil {
+			r.warn("app %s: failed to parse settings: %v", appID, err)
+			continue
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if installed[appID] == nil {
+			r.warn("app %s is not installed; install it and restore again to get its settings back", appID)
+			continue
+		}
+		r.change("restore %d setting(s) of app %s", len(values), appID)
+		for _, key := range sortedKeys(values) {
+			if err := r.do(func() error { return r.api.SetAppSetting(appID, key, r.remap(values[key])) }); err != nil {
+				r.warn("app %s: failed to restore setting %s: %v", appID, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// moods recreates moods, or updates the ones that exist
+func (r *restorer) moods() error {
+	old, live, err := r.load("moods", r.b.Moods, r.api.GetMoods)
+	if err != nil {
+		return err
+	}
+	liveNames := indexNames(live, nil)
+	for _, oldID := range sortedKeys(old) {
+		m := r.remap(old[oldID]).(map[string]interface{})
+		name := str(m, "name")
+		r.warnMissing("mood", name, old[oldID])
+
+		mood := map[string]interface{}{
+			"name":    name,
+			"devices": m["devices"],
+		}
+		if zone, ok := m["zone"]; ok && zone != nil {
+			mood["zone"] = zone
+		}
+		id, ok, ambiguous := liveNames.find(name, oldID)
+		if ambiguous {
+			r.warnAmbiguous("mood", name)
+			continue
+		}
+		if ok {
+			r.change("update mood %q", name)
+			if err := r.do(func() error { return r.api.UpdateMood(id, mood) }); err != nil {
+				r.warn("mood %q: failed to update: %v", name, err)
+			}
+			continue
+		}
+		if err := r.create("mood", name, oldID, func() (json.RawMessage, error) {
+			return r.api.CreateMood(mood)
+		}); err != nil {
+			r.warn("%v", err)
+		}
+	}
+	return nil
+}
+
+// dashboards recreates dashboards, or updates the ones that exist
+func (r *restorer) dashboards() error {
+	old, live, err := r.load("dashboards", r.b.Dashboards, r.api.GetDashboards)
+	if err != nil {
+		return err
+	}
+	liveNames := indexNames(live, nil)
+	for _, oldID := range sortedKeys(old) {
+		d := r.remap(old[oldID]).(map[string]interface{})
+		delete(d, "id")
+		name := str(d, "name")
+		r.warnMissing("dashboard", name, old[oldID])
+
+		id, ok, ambiguous := liveNames.find(name, oldID)
+		if ambiguous {
+			r.warnAmbiguous("dashboard", name)
+			continue
+		}
+		if ok {
+			r.change("update dashboard %q", name)
+			if err := r.do(func() error { return r.api.UpdateDashboard(id, d) }); err != nil {
+				r.warn("dashboard %q: failed to update: %v", name, err)
+			}
+			continue
+		}
+		if err := r.create("dashboard", name, oldID, func() (json.RawMessage, error) {
+			return r.api.CreateDashboard(d)
+		}); err != nil {
+			r.warn("%v", err)
+		}
+	}
+	return nil
+}
+
+// flows recreates simple and advanced flows, or updates the ones that exist
+func (r *restorer) flows() error {
+	kinds := []struct {
+		what   string
+		backup json.RawMessage
+		get    func() (json.RawMessage, error)
+		create func(map[string]interface{}) (json.RawMessage, error)
+		update func(string, map[string]interface{}) (json.RawMessage, error)
+	}{
+		{"flow", r.b.Flows, r.api.GetFlows, r.api.CreateFlow, r.api.UpdateFlow},
+		{"advanced flow", r.b.AdvancedFlows, r.api.GetAdvancedFlows, r.api.CreateAdvancedFlow, r.api.UpdateAdvancedFlow},
+	}
+	for _, k := range kinds {
+		old, live, err := r.load(k.what+"s", k.backup, k.get)
+		if err != nil {
+			return err
+		}
+		liveNames := indexNames(live, nil)
+		for _, oldID := range sortedKeys(old) {
+			f := r.remap(old[oldID]).(map[string]interface{})
+			delete(f, "id")
+			name := str(f, "name")
+			r.warnMissing(k.what, name, old[oldID])
+
+			if r.skipped[str(old[oldID], "folder")] {
+				// Its folder wasn't restored, so it goes at the top level
+				delete(f, "folder")
+			}
+			id, ok, ambiguous := liveNames.find(name, oldID)
+			if ambiguous {
+				r.warnAmbiguous(k.what, name)
+				continue
+			}
+			if ok {
+				r.change("update %s %q", k.what, name)
+				if err := r.do(func() error {
+					_, err := k.update(id, f)
+					return err
+				}); err != nil {
+					r.warn("%s %q: failed to update: %v", k.what, name, err)
+				}
+				continue
+			}
+			if err := r.create(k.what, name, oldID, func() (json.RawMessage, error) {
+				return k.create(f)
+			}); err != nil {
+				r.warn("%v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// warnMissing warns when an entity refers to a device or user that wasn't
+// found, since that part of it won't work until the device is paired
+func (r *restorer) warnMissing(what, name string, entity map[string]interface{}) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return
+	}
+	var refs []string
+	for oldID, desc := range r.missing {
+		if strings.Contains(string(data), oldID) {
+			refs = append(refs, desc)
+		}
+	}
+	if len(refs) > 0 {
+		sort.Strings(refs)
+		r.warn("%s %q refers to %s, which is not on this Homey", what, name, strings.Join(refs, ", "))
+	}
+}
+
+// buildReplacer prepares remap once every entity is mapped
+func (r *restorer) buildReplacer() {
+	var pairs []string
+	for _, oldID := range sortedKeys(r.ids) {
+		if newID := r.ids[oldID]; newID != oldID {
+			pairs = append(pairs, oldID, newID)
+		}
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// remap rewrites every old ID in v, in values and in keys. IDs also show up
+// inside strings, e.g. "homey:device:<id>" in flow cards and droptokens.
+func (r *restorer) remap(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return r.replacer.Replace(t)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[r.replacer.Replace(k)] = r.remap(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = r.remap(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// settingValues reads device settings as a map of key to value. Homey sends
+// either a map of values or a list of setting objects with an id and value.
+func settingValues(data json.RawMessage) (map[string]interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, val := range t {
+			if obj, ok := val.(map[string]interface{}); ok {
+				if value, ok := obj["value"]; ok {
+					val = value
+				}
+			}
+			values[key] = val
+		}
+	case []interface{}:
+		for _, item := range t {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if key := str(obj, "id"); key != "" {
+				if value, ok := obj["value"]; ok {
+					values[key] = value
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+// rootOf returns the zone without a parent
+func rootOf(zones map[string]map[string]interface{}) string {
+	for _, id := range sortedKeys(zones) {
+		if str(zones[id], "parent") == "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// parentsFirst orders zones or folders so every parent comes before its
+// children, and otherwise by ID
+func parentsFirst(m map[string]map[string]interface{}) []string {
+	order := make([]string, 0, len(m))
+	seen := map[string]bool{}
+	var visit func(id string)
+	visit = func(id string) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		if parent := str(m[id], "parent"); parent != "" && m[parent] != nil {
+			visit(parent)
+		}
+		order = append(order, id)
+	}
+	for _, id := range sortedKeys(m) {
+		visit(id)
+	}
+	return order
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,208 +13,349 @@ import (
 	"github.com/langtind/homeyctl/internal/config"
 )
 
+// DefaultTimeout is the per-request timeout used by New
+const DefaultTimeout = 30 * time.Second
+
 type Client struct {
 	baseURL    string
-	token      string
+	auth       *session
 	httpClient *http.Client
+
+	ctx     context.Context
+	timeout time.Duration
+	retry   RetryPolicy
+	logf    func(format string, args ...interface{})
 }
 
 func New(cfg *config.Config) *Client {
+	retry := DefaultRetryPolicy
+	if cfg.Retry.Retries != nil {
+		retry.MaxRetries = *cfg.Retry.Retries
+	}
+	if cfg.Retry.BaseDelay > 0 {
+		retry.BaseDelay = cfg.Retry.BaseDelay
+	}
+	if cfg.Retry.MaxDelay > 0 {
+		retry.MaxDelay = cfg.Retry.MaxDelay
+	}
+
+	c := NewWithToken(cfg.BaseURL(), cfg.EffectiveToken())
+	c.retry = retry
+	return c
+}
+
+// NewWithToken creates a client for a Homey base URL and bearer token,
+// using the default timeout and retry policy
+func NewWithToken(baseURL, token string) *Client {
 	return &Client{
-		baseURL: cfg.BaseURL(),
-		token:   cfg.EffectiveToken(),
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		baseURL:    baseURL,
+		auth:       &session{token: token},
+		httpClient: &http.Client{},
+		timeout:    DefaultTimeout,
+		retry:      DefaultRetryPolicy,
 	}
 }
 
-func (c *Client) doRequest(method, path string, body interface{}) ([]byte, error) {
-	var bodyReader io.Reader
+// WithContext returns a shallow copy of the client whose requests are bound
+// to ctx. Cancelling ctx aborts in-flight requests.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// SetTimeout sets the timeout applied to each request. Zero disables it.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// SetRetryPolicy sets the retry policy for transient failures
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// RetryPolicy returns the client's current retry policy
+func (c *Client) RetryPolicy() RetryPolicy {
+	return c.retry
+}
+
+// SetLogger sets a function for verbose diagnostics such as retries
+func (c *Client) SetLogger(logf func(format string, args ...interface{})) {
+	c.logf = logf
+}
+
+func (c *Client) debugf(format string, args ...interface{}) {
+	if c.logf != nil {
+		c.logf(format, args...)
+	}
+}
+
+// context returns the client's context, defaulting to context.Background
+func (c *Client) context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		var err error
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal body: %w", err)
 		}
+	}
+
+	token := c.auth.get()
+	respBody, err := c.doWithRetry(ctx, method, path, jsonBody)
+	if !IsUnauthorized(err) || !c.canRenew() {
+		return respBody, err
+	}
+
+	// The session expired: renew it and try once more
+	c.debugf("Session rejected for %s %s, renewing", method, path)
+	if _, renewErr := c.auth.renewAfter(ctx, token); renewErr != nil {
+		c.debugf("Session renewal failed: %v", renewErr)
+		return nil, err
+	}
+	return c.doWithRetry(ctx, method, path, jsonBody)
+}
+
+// doWithRetry sends a request, retrying transient failures per the retry policy
+func (c *Client) doWithRetry(ctx context.Context, method, path string, jsonBody []byte) ([]byte, error) {
+	canRetry := c.retry.MaxRetries > 0 && c.retry.retryableMethod(method)
+
+	for attempt := 0; ; attempt++ {
+		status, header, respBody, err := c.send(ctx, method, path, jsonBody)
+
+		// Stop when the caller gave up, the request succeeded, or the failure is permanent
+		if ctx.Err() != nil {
+			if err == nil {
+				err = ctx.Err()
+			}
+			return nil, err
+		}
+		transient := err != nil || retryableStatus(status)
+		if !transient || !canRetry || attempt >= c.retry.MaxRetries {
+			if err != nil {
+				return nil, err
+			}
+			if status >= 400 {
+				return nil, newAPIError(method, path, status, respBody)
+			}
+			return respBody, nil
+		}
+
+		delay := c.retry.backoff(attempt)
+		if d, ok := retryAfter(header, time.Now()); ok {
+			delay = d
+			if c.retry.MaxDelay > 0 && delay > c.retry.MaxDelay {
+				delay = c.retry.MaxDelay
+			}
+		}
+
+		reason := fmt.Sprintf("status %d", status)
+		if err != nil {
+			reason = err.Error()
+		}
+		c.debugf("Retrying %s %s in %s (retry %d/%d): %s", method, path, delay.Round(time.Millisecond), attempt+1, c.retry.MaxRetries, reason)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// send performs a single HTTP attempt. The per-request timeout applies to
+// each attempt separately.
+func (c *Client) send(ctx context.Context, method, path string, jsonBody []byte) (int, http.Header, []byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var bodyReader io.Reader
+	if jsonBody != nil {
 		bodyReader = bytes.NewReader(jsonBody)
 	}
 
-	req, err := http.NewRequest(method, c.baseURL+path, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return 0, nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
+	if token := c.auth.get(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return 0, nil, nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+		return 0, nil, nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return respBody, nil
+	return resp.StatusCode, resp.Header, respBody, nil
 }
 
 // Devices
 
 func (c *Client) GetDevices() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/devices/device/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/devices/device/", nil)
 }
 
 func (c *Client) GetDevice(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/devices/device/"+id, nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/devices/device/"+id, nil)
 }
 
 func (c *Client) SetCapability(deviceID, capability string, value interface{}) error {
 	body := map[string]interface{}{"value": value}
-	_, err := c.doRequest("PUT", fmt.Sprintf("/api/manager/devices/device/%s/capability/%s", deviceID, capability), body)
+	_, err := c.doRequest(c.context(), "PUT", fmt.Sprintf("/api/manager/devices/device/%s/capability/%s", deviceID, capability), body)
 	return err
 }
 
 func (c *Client) DeleteDevice(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/devices/device/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/devices/device/"+id, nil)
 	return err
 }
 
 func (c *Client) UpdateDevice(id string, updates map[string]interface{}) error {
-	_, err := c.doRequest("PUT", "/api/manager/devices/device/"+id, updates)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/devices/device/"+id, updates)
 	return err
 }
 
 func (c *Client) GetDeviceSettings(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", fmt.Sprintf("/api/manager/devices/device/%s/settings_obj", id), nil)
+	return c.doRequest(c.context(), "GET", fmt.Sprintf("/api/manager/devices/device/%s/settings_obj", id), nil)
 }
 
 func (c *Client) SetDeviceSetting(deviceID string, settings map[string]interface{}) error {
-	_, err := c.doRequest("PUT", fmt.Sprintf("/api/manager/devices/device/%s/settings", deviceID), settings)
+	_, err := c.doRequest(c.context(), "PUT", fmt.Sprintf("/api/manager/devices/device/%s/settings", deviceID), settings)
 	return err
 }
 
 // Device Groups
 
 func (c *Client) CreateDeviceGroup(group map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("POST", "/api/manager/devices/group", group)
+	return c.doRequest(c.context(), "POST", "/api/manager/devices/group", group)
 }
 
 func (c *Client) UpdateDeviceGroup(id string, updates map[string]interface{}) error {
-	_, err := c.doRequest("PUT", "/api/manager/devices/group/"+id, updates)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/devices/group/"+id, updates)
 	return err
 }
 
 func (c *Client) RemoveDeviceFromGroup(groupID, deviceID string) error {
-	_, err := c.doRequest("DELETE", fmt.Sprintf("/api/manager/devices/group/%s/device/%s", groupID, deviceID), nil)
+	_, err := c.doRequest(c.context(), "DELETE", fmt.Sprintf("/api/manager/devices/group/%s/device/%s", groupID, deviceID), nil)
 	return err
 }
 
 // Flows
 
 func (c *Client) GetFlows() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/flow/flow/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/flow/flow/", nil)
 }
 
 func (c *Client) GetAdvancedFlows() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/flow/advancedflow/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/flow/advancedflow/", nil)
 }
 
 func (c *Client) TriggerFlow(id string) error {
-	_, err := c.doRequest("POST", fmt.Sprintf("/api/manager/flow/flow/%s/trigger", id), nil)
+	_, err := c.doRequest(c.context(), "POST", fmt.Sprintf("/api/manager/flow/flow/%s/trigger", id), nil)
 	return err
 }
 
 func (c *Client) TriggerAdvancedFlow(id string) error {
-	_, err := c.doRequest("POST", fmt.Sprintf("/api/manager/flow/advancedflow/%s/trigger", id), nil)
+	_, err := c.doRequest(c.context(), "POST", fmt.Sprintf("/api/manager/flow/advancedflow/%s/trigger", id), nil)
 	return err
 }
 
 func (c *Client) CreateFlow(flow map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("POST", "/api/manager/flow/flow/", flow)
+	return c.doRequest(c.context(), "POST", "/api/manager/flow/flow/", flow)
 }
 
 func (c *Client) CreateAdvancedFlow(flow map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("POST", "/api/manager/flow/advancedflow/", flow)
+	return c.doRequest(c.context(), "POST", "/api/manager/flow/advancedflow/", flow)
 }
 
 func (c *Client) UpdateFlow(id string, flow map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("PUT", "/api/manager/flow/flow/"+id, flow)
+	return c.doRequest(c.context(), "PUT", "/api/manager/flow/flow/"+id, flow)
 }
 
 func (c *Client) DeleteFlow(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/flow/flow/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/flow/flow/"+id, nil)
 	return err
 }
 
 func (c *Client) UpdateAdvancedFlow(id string, flow map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("PUT", "/api/manager/flow/advancedflow/"+id, flow)
+	return c.doRequest(c.context(), "PUT", "/api/manager/flow/advancedflow/"+id, flow)
 }
 
 func (c *Client) DeleteAdvancedFlow(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/flow/advancedflow/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/flow/advancedflow/"+id, nil)
 	return err
 }
 
 // Flow cards
 
 func (c *Client) GetFlowTriggers() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/flow/flowcardtrigger/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/flow/flowcardtrigger/", nil)
 }
 
 func (c *Client) GetFlowConditions() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/flow/flowcardcondition/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/flow/flowcardcondition/", nil)
 }
 
 func (c *Client) GetFlowActions() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/flow/flowcardaction/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/flow/flowcardaction/", nil)
 }
 
 // Zones
 
 func (c *Client) GetZones() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/zones/zone/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/zones/zone/", nil)
 }
 
 func (c *Client) GetZone(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/zones/zone/"+id, nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/zones/zone/"+id, nil)
 }
 
 func (c *Client) CreateZone(zone map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("POST", "/api/manager/zones/zone/", zone)
+	return c.doRequest(c.context(), "POST", "/api/manager/zones/zone/", zone)
 }
 
 func (c *Client) DeleteZone(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/zones/zone/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/zones/zone/"+id, nil)
 	return err
 }
 
 func (c *Client) UpdateZone(id string, updates map[string]interface{}) error {
-	_, err := c.doRequest("PUT", "/api/manager/zones/zone/"+id, updates)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/zones/zone/"+id, updates)
 	return err
 }
 
 // Apps
 
 func (c *Client) GetApps() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/apps/app/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/apps/app/", nil)
 }
 
 func (c *Client) GetApp(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/apps/app/"+id, nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/apps/app/"+id, nil)
 }
 
 func (c *Client) RestartApp(id string) error {
-	_, err := c.doRequest("POST", fmt.Sprintf("/api/manager/apps/app/%s/restart", id), nil)
+	_, err := c.doRequest(c.context(), "POST", fmt.Sprintf("/api/manager/apps/app/%s/restart", id), nil)
 	return err
 }
 
@@ -224,12 +366,12 @@ func (c *Client) SendNotification(text string) error {
 	body := map[string]interface{}{
 		"args": map[string]string{"text": text},
 	}
-	_, err := c.doRequest("POST", "/api/manager/flow/flowcardaction/homey:manager:notifications/homey:manager:notifications:create_notification/run", body)
+	_, err := c.doRequest(c.context(), "POST", "/api/manager/flow/flowcardaction/homey:manager:notifications/homey:manager:notifications:create_notification/run", body)
 	return err
 }
 
 func (c *Client) GetNotifications() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/notifications/notification/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/notifications/notification/", nil)
 }
 
 // RunFlowCardAction runs any flow card action
@@ -237,22 +379,22 @@ func (c *Client) RunFlowCardAction(uri, id string, args map[string]interface{})
 	body := map[string]interface{}{
 		"args": args,
 	}
-	return c.doRequest("POST", fmt.Sprintf("/api/manager/flow/flowcardaction/%s/%s/run", uri, id), body)
+	return c.doRequest(c.context(), "POST", fmt.Sprintf("/api/manager/flow/flowcardaction/%s/%s/run", uri, id), body)
 }
 
 // Logic variables
 
 func (c *Client) GetVariables() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/logic/variable/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/logic/variable/", nil)
 }
 
 func (c *Client) GetVariable(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/logic/variable/"+id, nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/logic/variable/"+id, nil)
 }
 
 func (c *Client) SetVariable(id string, value interface{}) error {
 	body := map[string]interface{}{"value": value}
-	_, err := c.doRequest("PUT", "/api/manager/logic/variable/"+id, body)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/logic/variable/"+id, body)
 	return err
 }
 
@@ -262,35 +404,35 @@ func (c *Client) CreateVariable(name string, varType string, value interface{})
 		"type":  varType,
 		"value": value,
 	}
-	return c.doRequest("POST", "/api/manager/logic/variable/", body)
+	return c.doRequest(c.context(), "POST", "/api/manager/logic/variable/", body)
 }
 
 func (c *Client) DeleteVariable(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/logic/variable/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/logic/variable/"+id, nil)
 	return err
 }
 
 // System
 
 func (c *Client) GetSystem() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/system/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/system/", nil)
 }
 
 func (c *Client) Reboot() error {
-	_, err := c.doRequest("POST", "/api/manager/system/reboot/", nil)
+	_, err := c.doRequest(c.context(), "POST", "/api/manager/system/reboot/", nil)
 	return err
 }
 
 // Users
 
 func (c *Client) GetUsers() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/users/user/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/users/user/", nil)
 }
 
 // Insights (logs/history)
 
 func (c *Client) GetInsights() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/insights/log/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/insights/log/", nil)
 }
 
 func (c *Client) GetInsightEntries(uri, id, resolution string) (json.RawMessage, error) {
@@ -301,13 +443,13 @@ func (c *Client) GetInsightEntries(uri, id, resolution string) (json.RawMessage,
 	if resolution != "" {
 		path += "?resolution=" + resolution
 	}
-	return c.doRequest("GET", path, nil)
+	return c.doRequest(c.context(), "GET", path, nil)
 }
 
 // Energy
 
 func (c *Client) GetEnergyLive() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/energy/live", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/energy/live", nil)
 }
 
 func (c *Client) GetEnergyReportDay(date string) (json.RawMessage, error) {
@@ -315,7 +457,7 @@ func (c *Client) GetEnergyReportDay(date string) (json.RawMessage, error) {
 	if date != "" {
 		path += "?date=" + date
 	}
-	return c.doRequest("GET", path, nil)
+	return c.doRequest(c.context(), "GET", path, nil)
 }
 
 func (c *Client) GetEnergyReportWeek(isoWeek string) (json.RawMessage, error) {
@@ -323,7 +465,7 @@ func (c *Client) GetEnergyReportWeek(isoWeek string) (json.RawMessage, error) {
 	if isoWeek != "" {
 		path += "?isoWeek=" + isoWeek
 	}
-	return c.doRequest("GET", path, nil)
+	return c.doRequest(c.context(), "GET", path, nil)
 }
 
 func (c *Client) GetEnergyReportMonth(yearMonth string) (json.RawMessage, error) {
@@ -331,11 +473,11 @@ func (c *Client) GetEnergyReportMonth(yearMonth string) (json.RawMessage, error)
 	if yearMonth != "" {
 		path += "?yearMonth=" + yearMonth
 	}
-	return c.doRequest("GET", path, nil)
+	return c.doRequest(c.context(), "GET", path, nil)
 }
 
 func (c *Client) GetEnergyReportsAvailable() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/energy/reports/available", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/energy/reports/available", nil)
 }
 
 func (c *Client) GetElectricityPrice(date string) (json.RawMessage, error) {
@@ -343,20 +485,20 @@ func (c *Client) GetElectricityPrice(date string) (json.RawMessage, error) {
 	if date != "" {
 		path += "?date=" + date
 	}
-	return c.doRequest("GET", path, nil)
+	return c.doRequest(c.context(), "GET", path, nil)
 }
 
 func (c *Client) GetElectricityPriceType() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/energy/price/electricity/type", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/energy/price/electricity/type", nil)
 }
 
 func (c *Client) SetElectricityPriceType(priceType string) error {
-	_, err := c.doRequest("PUT", "/api/manager/energy/price/electricity/"+priceType, nil)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/energy/price/electricity/"+priceType, nil)
 	return err
 }
 
 func (c *Client) GetElectricityPriceFixed() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/energy/option/electricityPriceFixed", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/energy/option/electricityPriceFixed", nil)
 }
 
 func (c *Client) SetElectricityPriceFixed(price float64) error {
@@ -369,14 +511,14 @@ func (c *Client) SetElectricityPriceFixed(price float64) error {
 			},
 		},
 	}
-	_, err := c.doRequest("PUT", "/api/manager/energy/option/electricityPriceFixed", body)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/energy/option/electricityPriceFixed", body)
 	return err
 }
 
 // Personal Access Tokens (PAT)
 
 func (c *Client) ListPATs() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/users/pat", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/users/pat", nil)
 }
 
 func (c *Client) CreatePAT(name string, scopes []string) (json.RawMessage, error) {
@@ -384,35 +526,35 @@ func (c *Client) CreatePAT(name string, scopes []string) (json.RawMessage, error
 		"name":   name,
 		"scopes": scopes,
 	}
-	return c.doRequest("POST", "/api/manager/users/pat", body)
+	return c.doRequest(c.context(), "POST", "/api/manager/users/pat", body)
 }
 
 func (c *Client) DeletePAT(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/users/pat/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/users/pat/"+id, nil)
 	return err
 }
 
 // Flow Folders
 
 func (c *Client) GetFlowFolders() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/flow/flowfolder/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/flow/flowfolder/", nil)
 }
 
 func (c *Client) GetFlowFolder(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/flow/flowfolder/"+id, nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/flow/flowfolder/"+id, nil)
 }
 
 func (c *Client) CreateFlowFolder(folder map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("POST", "/api/manager/flow/flowfolder/", folder)
+	return c.doRequest(c.context(), "POST", "/api/manager/flow/flowfolder/", folder)
 }
 
 func (c *Client) UpdateFlowFolder(id string, folder map[string]interface{}) error {
-	_, err := c.doRequest("PUT", "/api/manager/flow/flowfolder/"+id, folder)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/flow/flowfolder/"+id, folder)
 	return err
 }
 
 func (c *Client) DeleteFlowFolder(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/flow/flowfolder/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/flow/flowfolder/"+id, nil)
 	return err
 }
 
@@ -426,168 +568,173 @@ func (c *Client) InstallApp(appID string, channel string) (json.RawMessage, erro
 	if channel != "" {
 		body["channel"] = channel
 	}
-	return c.doRequest("POST", "/api/manager/apps/store", body)
+	return c.doRequest(c.context(), "POST", "/api/manager/apps/store", body)
 }
 
 func (c *Client) UninstallApp(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/apps/app/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/apps/app/"+id, nil)
 	return err
 }
 
 func (c *Client) EnableApp(id string) error {
-	_, err := c.doRequest("PUT", fmt.Sprintf("/api/manager/apps/app/%s/enable", id), nil)
+	_, err := c.doRequest(c.context(), "PUT", fmt.Sprintf("/api/manager/apps/app/%s/enable", id), nil)
 	return err
 }
 
 func (c *Client) DisableApp(id string) error {
-	_, err := c.doRequest("PUT", fmt.Sprintf("/api/manager/apps/app/%s/disable", id), nil)
+	_, err := c.doRequest(c.context(), "PUT", fmt.Sprintf("/api/manager/apps/app/%s/disable", id), nil)
 	return err
 }
 
 func (c *Client) UpdateApp(id string, updates map[string]interface{}) error {
-	_, err := c.doRequest("PUT", "/api/manager/apps/app/"+id, updates)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/apps/app/"+id, updates)
 	return err
 }
 
 func (c *Client) GetAppSettings(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", fmt.Sprintf("/api/manager/apps/app/%s/setting", id), nil)
+	return c.doRequest(c.context(), "GET", fmt.Sprintf("/api/manager/apps/app/%s/setting", id), nil)
 }
 
 func (c *Client) SetAppSetting(appID, settingName string, value interface{}) error {
 	body := map[string]interface{}{"value": value}
-	_, err := c.doRequest("PUT", fmt.Sprintf("/api/manager/apps/app/%s/setting/%s", appID, settingName), body)
+	_, err := c.doRequest(c.context(), "PUT", fmt.Sprintf("/api/manager/apps/app/%s/setting/%s", appID, settingName), body)
 	return err
 }
 
 func (c *Client) GetAppUsage(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", fmt.Sprintf("/api/manager/apps/app/%s/usage", id), nil)
+	return c.doRequest(c.context(), "GET", fmt.Sprintf("/api/manager/apps/app/%s/usage", id), nil)
 }
 
 // Users (extended)
 
 func (c *Client) GetUser(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/users/user/"+id, nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/users/user/"+id, nil)
 }
 
 func (c *Client) GetUserMe() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/users/user/me", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/users/user/me", nil)
+}
+
+// GetSessionMe returns the current session, including the scopes it grants
+func (c *Client) GetSessionMe() (json.RawMessage, error) {
+	return c.doRequest(c.context(), "GET", "/api/manager/sessions/session/me", nil)
 }
 
 func (c *Client) CreateUser(user map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("POST", "/api/manager/users/user/", user)
+	return c.doRequest(c.context(), "POST", "/api/manager/users/user/", user)
 }
 
 func (c *Client) UpdateUser(id string, updates map[string]interface{}) error {
-	_, err := c.doRequest("PUT", "/api/manager/users/user/"+id, updates)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/users/user/"+id, updates)
 	return err
 }
 
 func (c *Client) DeleteUser(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/users/user/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/users/user/"+id, nil)
 	return err
 }
 
 // Moods
 
 func (c *Client) GetMoods() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/moods/mood/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/moods/mood/", nil)
 }
 
 func (c *Client) GetMood(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/moods/mood/"+id, nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/moods/mood/"+id, nil)
 }
 
 func (c *Client) CreateMood(mood map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("POST", "/api/manager/moods/mood/", mood)
+	return c.doRequest(c.context(), "POST", "/api/manager/moods/mood/", mood)
 }
 
 func (c *Client) UpdateMood(id string, updates map[string]interface{}) error {
-	_, err := c.doRequest("PUT", "/api/manager/moods/mood/"+id, updates)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/moods/mood/"+id, updates)
 	return err
 }
 
 func (c *Client) DeleteMood(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/moods/mood/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/moods/mood/"+id, nil)
 	return err
 }
 
 func (c *Client) SetMood(id string) error {
-	_, err := c.doRequest("POST", fmt.Sprintf("/api/manager/moods/mood/%s/set", id), nil)
+	_, err := c.doRequest(c.context(), "POST", fmt.Sprintf("/api/manager/moods/mood/%s/set", id), nil)
 	return err
 }
 
 // Dashboards
 
 func (c *Client) GetDashboards() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/dashboards/dashboard/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/dashboards/dashboard/", nil)
 }
 
 func (c *Client) GetDashboard(id string) (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/dashboards/dashboard/"+id, nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/dashboards/dashboard/"+id, nil)
 }
 
 func (c *Client) CreateDashboard(dashboard map[string]interface{}) (json.RawMessage, error) {
-	return c.doRequest("POST", "/api/manager/dashboards/dashboard/", dashboard)
+	return c.doRequest(c.context(), "POST", "/api/manager/dashboards/dashboard/", dashboard)
 }
 
 func (c *Client) UpdateDashboard(id string, updates map[string]interface{}) error {
-	_, err := c.doRequest("PUT", "/api/manager/dashboards/dashboard/"+id, updates)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/dashboards/dashboard/"+id, updates)
 	return err
 }
 
 func (c *Client) DeleteDashboard(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/dashboards/dashboard/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/dashboards/dashboard/"+id, nil)
 	return err
 }
 
 // Presence
 
 func (c *Client) GetPresent(userID string) (json.RawMessage, error) {
-	return c.doRequest("GET", fmt.Sprintf("/api/manager/presence/%s/present", userID), nil)
+	return c.doRequest(c.context(), "GET", fmt.Sprintf("/api/manager/presence/%s/present", userID), nil)
 }
 
 func (c *Client) SetPresent(userID string, value bool) error {
 	body := map[string]interface{}{"value": value}
-	_, err := c.doRequest("PUT", fmt.Sprintf("/api/manager/presence/%s/present", userID), body)
+	_, err := c.doRequest(c.context(), "PUT", fmt.Sprintf("/api/manager/presence/%s/present", userID), body)
 	return err
 }
 
 func (c *Client) SetPresentMe(value bool) error {
 	body := map[string]interface{}{"value": value}
-	_, err := c.doRequest("PUT", "/api/manager/presence/me/present", body)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/presence/me/present", body)
 	return err
 }
 
 func (c *Client) GetAsleep(userID string) (json.RawMessage, error) {
-	return c.doRequest("GET", fmt.Sprintf("/api/manager/presence/%s/asleep", userID), nil)
+	return c.doRequest(c.context(), "GET", fmt.Sprintf("/api/manager/presence/%s/asleep", userID), nil)
 }
 
 func (c *Client) SetAsleep(userID string, value bool) error {
 	body := map[string]interface{}{"value": value}
-	_, err := c.doRequest("PUT", fmt.Sprintf("/api/manager/presence/%s/asleep", userID), body)
+	_, err := c.doRequest(c.context(), "PUT", fmt.Sprintf("/api/manager/presence/%s/asleep", userID), body)
 	return err
 }
 
 func (c *Client) SetAsleepMe(value bool) error {
 	body := map[string]interface{}{"value": value}
-	_, err := c.doRequest("PUT", "/api/manager/presence/me/asleep", body)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/presence/me/asleep", body)
 	return err
 }
 
 // Notifications (extended)
 
 func (c *Client) DeleteNotification(id string) error {
-	_, err := c.doRequest("DELETE", "/api/manager/notifications/notification/"+id, nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/notifications/notification/"+id, nil)
 	return err
 }
 
 func (c *Client) DeleteAllNotifications() error {
-	_, err := c.doRequest("DELETE", "/api/manager/notifications/notification/", nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/notifications/notification/", nil)
 	return err
 }
 
 func (c *Client) GetNotificationOwners() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/notifications/owner/", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/notifications/owner/", nil)
 }
 
 // Insights (extended)
@@ -595,50 +742,50 @@ func (c *Client) GetNotificationOwners() (json.RawMessage, error) {
 func (c *Client) DeleteInsightLog(uri, id string) error {
 	encodedURI := url.PathEscape(uri)
 	encodedID := url.PathEscape(id)
-	_, err := c.doRequest("DELETE", fmt.Sprintf("/api/manager/insights/log/%s/%s", encodedURI, encodedID), nil)
+	_, err := c.doRequest(c.context(), "DELETE", fmt.Sprintf("/api/manager/insights/log/%s/%s", encodedURI, encodedID), nil)
 	return err
 }
 
 func (c *Client) DeleteInsightLogEntries(uri, id string) error {
 	encodedURI := url.PathEscape(uri)
 	encodedID := url.PathEscape(id)
-	_, err := c.doRequest("DELETE", fmt.Sprintf("/api/manager/insights/log/%s/%s/entry", encodedURI, encodedID), nil)
+	_, err := c.doRequest(c.context(), "DELETE", fmt.Sprintf("/api/manager/insights/log/%s/%s/entry", encodedURI, encodedID), nil)
 	return err
 }
 
 // System (extended)
 
 func (c *Client) GetSystemName() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/system/name", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/system/name", nil)
 }
 
 func (c *Client) SetSystemName(name string) error {
 	body := map[string]interface{}{"name": name}
-	_, err := c.doRequest("PUT", "/api/manager/system/name", body)
+	_, err := c.doRequest(c.context(), "PUT", "/api/manager/system/name", body)
 	return err
 }
 
 // Weather
 
 func (c *Client) GetWeather() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/weather/weather", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/weather/weather", nil)
 }
 
 func (c *Client) GetWeatherForecast() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/weather/forecast/hourly", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/weather/forecast/hourly", nil)
 }
 
 // Energy (extended)
 
 func (c *Client) GetEnergyReportYear(year string) (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/energy/report/year?year="+year, nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/energy/report/year?year="+year, nil)
 }
 
 func (c *Client) DeleteEnergyReports() error {
-	_, err := c.doRequest("DELETE", "/api/manager/energy/reports", nil)
+	_, err := c.doRequest(c.context(), "DELETE", "/api/manager/energy/reports", nil)
 	return err
 }
 
 func (c *Client) GetEnergyCurrency() (json.RawMessage, error) {
-	return c.doRequest("GET", "/api/manager/energy/currency", nil)
+	return c.doRequest(c.context(), "GET", "/api/manager/energy/currency", nil)
 }
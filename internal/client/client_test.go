@@ -1,10 +1,13 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestUpdateDevice(t *testing.T) {
@@ -25,7 +28,7 @@ func TestUpdateDevice(t *testing.T) {
 	// Create client with test server
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -61,7 +64,7 @@ func TestUpdateDevice_Error(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -87,7 +90,7 @@ func TestUpdateZone(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -120,7 +123,7 @@ func TestUpdateZone_Error(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -149,7 +152,7 @@ func TestCreateFlowFolder(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -190,7 +193,7 @@ func TestDeleteFlowFolder(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -224,7 +227,7 @@ func TestGetMoods(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -257,7 +260,7 @@ func TestSetMood(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -291,7 +294,7 @@ func TestGetDashboards(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -329,7 +332,7 @@ func TestSetPresent(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -370,7 +373,7 @@ func TestSetAsleep(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -408,7 +411,7 @@ func TestGetWeather(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -441,7 +444,7 @@ func TestGetWeatherForecast(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -475,7 +478,7 @@ func TestDeleteNotification(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -508,7 +511,7 @@ func TestDeleteAllNotifications(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -542,7 +545,7 @@ func TestGetSystemName(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -575,7 +578,7 @@ func TestSetSystemName(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -609,7 +612,7 @@ func TestGetEnergyCurrency(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -642,7 +645,7 @@ func TestDeleteEnergyReports(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -676,7 +679,7 @@ func TestUninstallApp(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -709,7 +712,7 @@ func TestEnableApp(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -742,7 +745,7 @@ func TestDisableApp(t *testing.T) {
 
 	client := &Client{
 		baseURL:    server.URL,
-		token:      "test-token",
+		auth:       &session{token: "test-token"},
 		httpClient: server.Client(),
 	}
 
@@ -760,3 +763,51 @@ func TestDisableApp(t *testing.T) {
 		t.Errorf("expected path %s, got %s", expectedPath, receivedPath)
 	}
 }
+
+func TestDoRequest_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL:    server.URL,
+		auth:       &session{token: "test-token"},
+		httpClient: server.Client(),
+		timeout:    50 * time.Millisecond,
+	}
+
+	_, err := client.GetDevices()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestWithContext_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	base := &Client{
+		baseURL:    server.URL,
+		auth:       &session{token: "test-token"},
+		httpClient: server.Client(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := base.WithContext(ctx).GetDevices()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+
+	// The original client is not bound to the cancelled context
+	if _, err := base.GetDevices(); err != nil {
+		t.Errorf("unexpected error from original client: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned when Homey responds with an error status
+type APIError struct {
+	StatusCode  int
+	Method      string
+	Path        string
+	Message     string // Homey's "error" field (e.g. "Missing Scopes")
+	Description string // Homey's "error_description" field, if any
+	Body        []byte // Raw response body
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, string(e.Body))
+}
+
+// newAPIError builds an APIError, parsing Homey's JSON error body when present
+func newAPIError(method, path string, status int, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: status,
+		Method:     method,
+		Path:       path,
+		Body:       body,
+	}
+
+	var parsed struct {
+		Error            interface{} `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+		Message          string      `json:"message"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		switch v := parsed.Error.(type) {
+		case string:
+			apiErr.Message = v
+		case map[string]interface{}:
+			if msg, ok := v["message"].(string); ok {
+				apiErr.Message = msg
+			}
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = parsed.Message
+		}
+		apiErr.Description = parsed.ErrorDescription
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(status)
+	}
+
+	return apiErr
+}
+
+// AsAPIError returns the APIError in err's chain, if any
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+func hasStatus(err error, status int) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.StatusCode == status
+}
+
+// IsNotFound reports whether err is a 404 from Homey
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsForbidden reports whether err is a 403 from Homey, usually missing scopes
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// IsUnauthorized reports whether err is a 401 from Homey (invalid or expired token)
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDoRequest_ReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":"Missing Scopes","error_description":"homey.device.control"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL:    server.URL,
+		auth:       &session{token: "test-token"},
+		httpClient: server.Client(),
+	}
+
+	err := client.SetCapability("dev1", "onoff", true)
+	apiErr, ok := AsAPIError(err)
+	if !ok {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden || apiErr.Method != "PUT" {
+		t.Errorf("unexpected error fields: %+v", apiErr)
+	}
+	if apiErr.Path != "/api/manager/devices/device/dev1/capability/onoff" {
+		t.Errorf("unexpected path: %s", apiErr.Path)
+	}
+	if apiErr.Message != "Missing Scopes" || apiErr.Description != "homey.device.control" {
+		t.Errorf("unexpected parsed body: %+v", apiErr)
+	}
+	if !IsForbidden(err) || IsNotFound(err) || IsUnauthorized(err) {
+		t.Error("status helpers disagree with 403")
+	}
+	if !strings.Contains(err.Error(), "request failed with status 403") {
+		t.Errorf("unexpected error string: %s", err.Error())
+	}
+}
+
+func TestNewAPIError_PlainBody(t *testing.T) {
+	apiErr := newAPIError("GET", "/x", http.StatusNotFound, []byte("Not Found"))
+	if apiErr.Message != "Not Found" {
+		t.Errorf("expected plain body as message, got %q", apiErr.Message)
+	}
+
+	apiErr = newAPIError("GET", "/x", http.StatusBadGateway, nil)
+	if apiErr.Message != "Bad Gateway" {
+		t.Errorf("expected status text as message, got %q", apiErr.Message)
+	}
+}
+
+func TestStatusHelpers_Wrapped(t *testing.T) {
+	err := fmt.Errorf("failed to get device: %w", newAPIError("GET", "/x", http.StatusNotFound, nil))
+	if !IsNotFound(err) {
+		t.Error("expected IsNotFound to see through wrapping")
+	}
+	if IsNotFound(fmt.Errorf("plain error")) {
+		t.Error("expected IsNotFound to be false for non-API errors")
+	}
+	if !IsUnauthorized(newAPIError("GET", "/x", http.StatusUnauthorized, nil)) {
+		t.Error("expected IsUnauthorized for 401")
+	}
+}
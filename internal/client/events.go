@@ -0,0 +1,338 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Event types delivered by Subscribe. Events from Homey that don't match one
+// of these keep Homey's own event name as their type.
+const (
+	EventCapability         = "capability"
+	EventDeviceCreate       = "device.create"
+	EventDeviceUpdate       = "device.update"
+	EventDeviceDelete       = "device.delete"
+	EventFlowTriggered      = "flow.trigger"
+	EventVariableUpdate     = "variable.update"
+	EventPresence           = "presence.update"
+	EventNotificationCreate = "notification.create"
+)
+
+// EventManagers maps manager names accepted by Subscribe to Homey's realtime URIs
+var EventManagers = map[string]string{
+	"devices":       "homey:manager:devices",
+	"flow":          "homey:manager:flow",
+	"logic":         "homey:manager:logic",
+	"presence":      "homey:manager:presence",
+	"notifications": "homey:manager:notifications",
+}
+
+// Event is a single realtime event from Homey
+type Event struct {
+	Time       time.Time       `json:"time"`
+	Manager    string          `json:"manager"`
+	Type       string          `json:"type"`
+	ID         string          `json:"id,omitempty"`
+	Capability string          `json:"capability,omitempty"`
+	Value      interface{}     `json:"value,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// SubscribeOptions configures a realtime subscription
+type SubscribeOptions struct {
+	// Managers to subscribe to (see EventManagers). Empty means all.
+	Managers []string
+
+	// DeviceIDs limits capability subscriptions to these devices. Empty means all.
+	DeviceIDs []string
+
+	// OnError is called when the connection fails, before reconnecting
+	OnError func(error)
+}
+
+const (
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Subscribe connects to Homey's realtime API and delivers events on the
+// returned channel until ctx is cancelled. Lost connections are retried
+// with exponential backoff. The channel is closed when ctx is done.
+func (c *Client) Subscribe(ctx context.Context, opts SubscribeOptions) (<-chan Event, error) {
+	managers, err := resolveManagers(opts.Managers)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event, 64)
+
+	go func() {
+		defer close(events)
+
+		delay := minReconnectDelay
+		for {
+			connected, err := c.streamEvents(ctx, managers, opts.DeviceIDs, events)
+			if ctx.Err() != nil {
+				return
+			}
+			if connected {
+				delay = minReconnectDelay
+			}
+			if err != nil && opts.OnError != nil {
+				opts.OnError(err)
+			}
+
+			// Full jitter keeps many clients from reconnecting in lockstep
+			wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// resolveManagers validates manager names and returns them with their URIs
+func resolveManagers(names []string) (map[string]string, error) {
+	if len(names) == 0 {
+		managers := make(map[string]string, len(EventManagers))
+		for name, uri := range EventManagers {
+			managers[name] = uri
+		}
+		return managers, nil
+	}
+
+	managers := make(map[string]string, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		uri, ok := EventManagers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown manager: %s (available: devices, flow, logic, presence, notifications)", name)
+		}
+		managers[name] = uri
+	}
+	return managers, nil
+}
+
+// streamEvents runs a single realtime connection until it fails or ctx is
+// done. connected reports whether the subscription was fully established.
+func (c *Client) streamEvents(ctx context.Context, managers map[string]string, deviceIDs []string, events chan<- Event) (connected bool, err error) {
+	homeyID, err := c.homeyID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Newly paired devices need their own subscription for capability events
+	newDevices := make(chan string, 16)
+
+	onEvent := func(namespace string, data json.RawMessage) {
+		// Homey only publishes on the per-client namespace from the handshake
+		if namespace == "/" {
+			return
+		}
+		event, ok := decodeEvent(data)
+		if !ok {
+			return
+		}
+
+		if event.Type == EventDeviceCreate && event.ID != "" && len(deviceIDs) == 0 {
+			select {
+			case newDevices <- event.ID:
+			default:
+			}
+		}
+
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	sock, err := dialSocket(ctx, c.baseURL, onEvent)
+	if err != nil {
+		return false, err
+	}
+	defer sock.Close()
+
+	ack, err := sock.emitWithAck(ctx, "/", "handshakeClient", map[string]string{
+		"token":   c.auth.get(),
+		"homeyId": homeyID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("realtime handshake failed: %w", err)
+	}
+	if err := ackError(ack); err != nil {
+		return false, fmt.Errorf("realtime handshake failed: %w", err)
+	}
+
+	var result []struct {
+		Namespace string `json:"namespace"`
+	}
+	if err := json.Unmarshal(ack, &result); err != nil || len(result) < 2 || result[1].Namespace == "" {
+		return false, fmt.Errorf("realtime handshake returned no namespace")
+	}
+	homeyNamespace := result[1].Namespace
+
+	if err := sock.connectNamespace(ctx, homeyNamespace); err != nil {
+		return false, err
+	}
+
+	uris := make([]string, 0, len(managers))
+	for _, uri := range managers {
+		uris = append(uris, uri)
+	}
+
+	// Capability changes are only published on each device's own URI
+	if _, ok := managers["devices"]; ok {
+		ids := deviceIDs
+		if len(ids) == 0 {
+			data, err := c.WithContext(ctx).GetDevices()
+			if err != nil {
+				return false, fmt.Errorf("failed to list devices: %w", err)
+			}
+			var devices map[string]json.RawMessage
+			if err := json.Unmarshal(data, &devices); err != nil {
+				return false, fmt.Errorf("failed to parse devices: %w", err)
+			}
+			for id := range devices {
+				ids = append(ids, id)
+			}
+		}
+		for _, id := range ids {
+			uris = append(uris, "homey:device:"+id)
+		}
+	}
+
+	for _, uri := range uris {
+		ack, err := sock.emitWithAck(ctx, homeyNamespace, "subscribe", uri)
+		if err != nil {
+			return false, fmt.Errorf("failed to subscribe to %s: %w", uri, err)
+		}
+		if err := ackError(ack); err != nil {
+			return false, fmt.Errorf("failed to subscribe to %s: %w", uri, err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case <-sock.Done():
+			return true, sock.Err()
+		case id := <-newDevices:
+			uri := "homey:device:" + id
+			if _, err := sock.emitWithAck(ctx, homeyNamespace, "subscribe", uri); err != nil {
+				return true, fmt.Errorf("failed to subscribe to %s: %w", uri, err)
+			}
+		}
+	}
+}
+
+// homeyID reads the Homey ID from the ping endpoint, as required by the handshake
+func (c *Client) homeyID(ctx context.Context) (string, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/manager/system/ping", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	homeyID := resp.Header.Get("X-Homey-ID")
+	if homeyID == "" {
+		return "", fmt.Errorf("could not determine Homey ID from %s", c.baseURL)
+	}
+	return homeyID, nil
+}
+
+// decodeEvent converts a socket.io event payload ["<uri>", "<event>", data]
+// into an Event
+func decodeEvent(data json.RawMessage) (Event, bool) {
+	var args []json.RawMessage
+	if err := json.Unmarshal(data, &args); err != nil || len(args) < 2 {
+		return Event{}, false
+	}
+
+	var uri, name string
+	if err := json.Unmarshal(args[0], &uri); err != nil {
+		return Event{}, false
+	}
+	if err := json.Unmarshal(args[1], &name); err != nil {
+		return Event{}, false
+	}
+
+	event := Event{
+		Time: time.Now(),
+		Type: name,
+	}
+	if len(args) > 2 {
+		event.Data = args[2]
+	}
+
+	switch {
+	case strings.HasPrefix(uri, "homey:device:"):
+		event.Manager = "devices"
+		event.ID = strings.TrimPrefix(uri, "homey:device:")
+	case strings.HasPrefix(uri, "homey:manager:"):
+		event.Manager = strings.TrimPrefix(uri, "homey:manager:")
+	default:
+		event.Manager = uri
+	}
+
+	if event.Data == nil {
+		return event, true
+	}
+
+	if name == EventCapability {
+		var c struct {
+			CapabilityID    string      `json:"capabilityId"`
+			Value           interface{} `json:"value"`
+			TransactionTime int64       `json:"transactionTime"`
+		}
+		if err := json.Unmarshal(event.Data, &c); err == nil {
+			event.Capability = c.CapabilityID
+			event.Value = c.Value
+			if c.TransactionTime > 0 {
+				event.Time = time.UnixMilli(c.TransactionTime)
+			}
+			event.Data = nil
+		}
+		return event, true
+	}
+
+	if event.ID == "" {
+		var obj struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(event.Data, &obj); err == nil {
+			event.ID = obj.ID
+		}
+	}
+
+	return event, true
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEncodeDecodeSIO(t *testing.T) {
+	p := sioPacket{
+		Type:      sioEvent,
+		Namespace: "/api/abc",
+		AckID:     7,
+		Data:      json.RawMessage(`["subscribe","homey:manager:devices"]`),
+	}
+
+	encoded := encodeSIO(p)
+	expected := `42/api/abc,7["subscribe","homey:manager:devices"]`
+	if encoded != expected {
+		t.Fatalf("encodeSIO() = %q, want %q", encoded, expected)
+	}
+
+	decoded, err := decodeSIO(encoded[1:])
+	if err != nil {
+		t.Fatalf("decodeSIO failed: %v", err)
+	}
+	if decoded.Type != sioEvent || decoded.Namespace != "/api/abc" || decoded.AckID != 7 {
+		t.Errorf("unexpected packet: %+v", decoded)
+	}
+	if string(decoded.Data) != string(p.Data) {
+		t.Errorf("expected data %s, got %s", p.Data, decoded.Data)
+	}
+}
+
+func TestDecodeSIO_RootNamespaceNoAck(t *testing.T) {
+	p, err := decodeSIO(`2["hello",1]`)
+	if err != nil {
+		t.Fatalf("decodeSIO failed: %v", err)
+	}
+	if p.Namespace != "/" || p.AckID != -1 {
+		t.Errorf("unexpected packet: %+v", p)
+	}
+}
+
+func TestDecodeSIO_NamespaceConnect(t *testing.T) {
+	p, err := decodeSIO(`0/api/abc`)
+	if err != nil {
+		t.Fatalf("decodeSIO failed: %v", err)
+	}
+	if p.Type != sioConnect || p.Namespace != "/api/abc" {
+		t.Errorf("unexpected packet: %+v", p)
+	}
+}
+
+func TestSocketURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"http://192.168.1.50", "ws://192.168.1.50/socket.io/?EIO=3&transport=websocket"},
+		{"https://10-0-0-1.homey.homeylocal.com:443", "wss://10-0-0-1.homey.homeylocal.com:443/socket.io/?EIO=3&transport=websocket"},
+	}
+
+	for _, tc := range tests {
+		got, err := socketURL(tc.in)
+		if err != nil {
+			t.Fatalf("socketURL(%q) failed: %v", tc.in, err)
+		}
+		if got != tc.want {
+			t.Errorf("socketURL(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeEvent_Capability(t *testing.T) {
+	data := json.RawMessage(`["homey:device:dev1","capability",{"capabilityId":"onoff","value":true,"transactionTime":1700000000000}]`)
+
+	event, ok := decodeEvent(data)
+	if !ok {
+		t.Fatal("expected event to decode")
+	}
+	if event.Manager != "devices" || event.Type != EventCapability {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if event.ID != "dev1" || event.Capability != "onoff" || event.Value != true {
+		t.Errorf("unexpected capability event: %+v", event)
+	}
+	if event.Time.UnixMilli() != 1700000000000 {
+		t.Errorf("expected transaction time, got %v", event.Time)
+	}
+}
+
+func TestDecodeEvent_ManagerEvent(t *testing.T) {
+	data := json.RawMessage(`["homey:manager:logic","variable.update",{"id":"var1","value":3}]`)
+
+	event, ok := decodeEvent(data)
+	if !ok {
+		t.Fatal("expected event to decode")
+	}
+	if event.Manager != "logic" || event.Type != EventVariableUpdate || event.ID != "var1" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
+
+func TestResolveManagers(t *testing.T) {
+	managers, err := resolveManagers([]string{"devices", " Flow "})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(managers) != 2 || managers["flow"] != "homey:manager:flow" {
+		t.Errorf("unexpected managers: %v", managers)
+	}
+
+	if _, err := resolveManagers([]string{"bogus"}); err == nil {
+		t.Error("expected error for unknown manager")
+	}
+
+	all, _ := resolveManagers(nil)
+	if len(all) != len(EventManagers) {
+		t.Errorf("expected all managers, got %d", len(all))
+	}
+}
+
+func TestAckError(t *testing.T) {
+	if err := ackError(json.RawMessage(`[null,{"namespace":"/api"}]`)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ackError(json.RawMessage(`[{"message":"Invalid token"}]`)); err == nil || err.Error() != "Invalid token" {
+		t.Errorf("expected Invalid token error, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how transient API failures are retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt. Zero disables retries.
+	MaxRetries int
+
+	// BaseDelay is the backoff before the first retry; it doubles on each retry
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff, including delays requested by Retry-After
+	MaxDelay time.Duration
+
+	// RetryAllMethods also retries non-idempotent methods (POST). Only enable
+	// this when the calls being made are safe to repeat.
+	RetryAllMethods bool
+}
+
+// DefaultRetryPolicy is used when no policy is configured
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   10 * time.Second,
+}
+
+// retryableMethod reports whether a request with this method may be retried
+func (p RetryPolicy) retryableMethod(method string) bool {
+	if p.RetryAllMethods {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryableStatus reports whether a response status is worth retrying.
+// Homey returns 502/503 while an app restarts.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry (0-based), with jitter
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = DefaultRetryPolicy.BaseDelay
+	}
+
+	delay := base << uint(retry)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		delay = base
+	}
+
+	// Equal jitter: half fixed, half random
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter parses a Retry-After header (seconds or HTTP date)
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
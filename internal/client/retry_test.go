@@ -0,0 +1,142 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRetryTestClient(url string, httpClient *http.Client, policy RetryPolicy) *Client {
+	return &Client{
+		baseURL:    url,
+		auth:       &session{token: "test-token"},
+		httpClient: httpClient,
+		retry:      policy,
+	}
+}
+
+func TestDoRequest_RetriesTransientStatus(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	var logged []string
+	client := newRetryTestClient(server.URL, server.Client(), RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})
+	client.SetLogger(func(format string, args ...interface{}) {
+		logged = append(logged, format)
+	})
+
+	data, err := client.GetDevices()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != `{"ok":true}` {
+		t.Errorf("unexpected body: %s", data)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	if len(logged) != 2 {
+		t.Errorf("expected 2 retry log lines, got %d", len(logged))
+	}
+}
+
+func TestDoRequest_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, server.Client(), RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond})
+
+	_, err := client.GetDevices()
+	if err == nil || !strings.Contains(err.Error(), "status 502") {
+		t.Errorf("expected status 502 error, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestDoRequest_DoesNotRetryPost(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, server.Client(), RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})
+
+	if err := client.TriggerFlow("flow1"); err == nil {
+		t.Error("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("expected POST to be sent once, got %d calls", calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	client.SetRetryPolicy(RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond, RetryAllMethods: true})
+	client.TriggerFlow("flow1")
+	if calls != 2 {
+		t.Errorf("expected POST to be retried when opted in, got %d calls", calls)
+	}
+}
+
+func TestDoRequest_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, server.Client(), RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})
+
+	if _, err := client.GetDevice("missing"); err == nil {
+		t.Error("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	h := http.Header{}
+	h.Set("Retry-After", "3")
+	if d, ok := retryAfter(h, now); !ok || d != 3*time.Second {
+		t.Errorf("expected 3s, got %v (ok=%v)", d, ok)
+	}
+
+	h.Set("Retry-After", now.Add(5*time.Second).Format(http.TimeFormat))
+	if d, ok := retryAfter(h, now); !ok || d != 5*time.Second {
+		t.Errorf("expected 5s, got %v (ok=%v)", d, ok)
+	}
+
+	h.Set("Retry-After", "soon")
+	if _, ok := retryAfter(h, now); ok {
+		t.Error("expected invalid Retry-After to be ignored")
+	}
+}
+
+func TestBackoff_CappedByMaxDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 4 * time.Second}
+	for retry := 0; retry < 10; retry++ {
+		if d := p.backoff(retry); d > 4*time.Second {
+			t.Errorf("backoff(%d) = %v, exceeds max delay", retry, d)
+		}
+	}
+}
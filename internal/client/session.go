@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// SessionRenewer obtains a new session token after Homey rejected the current
+// one with 401. It is called at most once per request.
+type SessionRenewer func(ctx context.Context) (string, error)
+
+// session holds the bearer token shared by a client and its WithContext copies
+type session struct {
+	mu    sync.Mutex
+	token string
+	renew SessionRenewer
+}
+
+func (s *session) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// renewAfter replaces the rejected token. If another request already renewed
+// it, the newer token is returned without calling the renewer again.
+func (s *session) renewAfter(ctx context.Context, rejected string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != rejected {
+		return s.token, nil
+	}
+
+	token, err := s.renew(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	return token, nil
+}
+
+// SetSessionRenewer enables transparent session renewal: a request rejected
+// with 401 calls renew and is retried once with the new token
+func (c *Client) SetSessionRenewer(renew SessionRenewer) {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	c.auth.renew = renew
+}
+
+func (c *Client) canRenew() bool {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	return c.auth.renew != nil
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDoRequest_RenewsSessionOn401(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var renewals int32
+	c := NewWithToken(server.URL, "expired")
+	c.SetSessionRenewer(func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&renewals, 1)
+		return "fresh", nil
+	})
+
+	// Renewal applies to copies sharing the session
+	if _, err := c.WithContext(context.Background()).GetDevices(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetZones(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if renewals != 1 {
+		t.Errorf("expected 1 renewal, got %d", renewals)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestDoRequest_RenewalFailureReturnsUnauthorized(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	c := NewWithToken(server.URL, "expired")
+	c.SetSessionRenewer(func(ctx context.Context) (string, error) {
+		return "", errors.New("refresh token revoked")
+	})
+
+	_, err := c.GetDevices()
+	if !IsUnauthorized(err) {
+		t.Errorf("expected 401 error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestDoRequest_RetriesOnlyOnceAfterRenewal(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	c := NewWithToken(server.URL, "expired")
+	c.SetSessionRenewer(func(ctx context.Context) (string, error) {
+		return "still-bad", nil
+	})
+
+	if _, err := c.GetDevices(); !IsUnauthorized(err) {
+		t.Errorf("expected 401 error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestDoRequest_NoRenewerKeeps401(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	if _, err := NewWithToken(server.URL, "pat").GetDevices(); !IsUnauthorized(err) {
+		t.Errorf("expected 401 error, got %v", err)
+	}
+}
@@ -0,0 +1,403 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Minimal socket.io v2 (Engine.IO v3) client, just enough to talk to Homey's
+// realtime API over a websocket transport.
+
+// Engine.IO packet types
+const (
+	eioOpen    = '0'
+	eioClose   = '1'
+	eioPing    = '2'
+	eioPong    = '3'
+	eioMessage = '4'
+)
+
+// socket.io packet types
+const (
+	sioConnect    = '0'
+	sioDisconnect = '1'
+	sioEvent      = '2'
+	sioAck        = '3'
+	sioError      = '4'
+)
+
+// sioPacket is a decoded socket.io packet
+type sioPacket struct {
+	Type      byte
+	Namespace string
+	AckID     int // -1 when the packet carries no ack id
+	Data      json.RawMessage
+}
+
+// encodeSIO encodes a socket.io packet wrapped in an Engine.IO message
+func encodeSIO(p sioPacket) string {
+	var b strings.Builder
+	b.WriteByte(eioMessage)
+	b.WriteByte(p.Type)
+	if p.Namespace != "" && p.Namespace != "/" {
+		b.WriteString(p.Namespace)
+		b.WriteByte(',')
+	}
+	if p.AckID >= 0 {
+		b.WriteString(strconv.Itoa(p.AckID))
+	}
+	b.Write(p.Data)
+	return b.String()
+}
+
+// decodeSIO decodes a socket.io packet (without the Engine.IO message prefix)
+func decodeSIO(msg string) (sioPacket, error) {
+	if msg == "" {
+		return sioPacket{}, fmt.Errorf("empty socket.io packet")
+	}
+
+	p := sioPacket{Type: msg[0], Namespace: "/", AckID: -1}
+	rest := msg[1:]
+
+	if strings.HasPrefix(rest, "/") {
+		end := strings.IndexByte(rest, ',')
+		if end < 0 {
+			p.Namespace = rest
+			rest = ""
+		} else {
+			p.Namespace = rest[:end]
+			rest = rest[end+1:]
+		}
+		if q := strings.IndexByte(p.Namespace, '?'); q >= 0 {
+			p.Namespace = p.Namespace[:q]
+		}
+	}
+
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 {
+		id, err := strconv.Atoi(rest[:digits])
+		if err != nil {
+			return sioPacket{}, fmt.Errorf("invalid ack id: %w", err)
+		}
+		p.AckID = id
+		rest = rest[digits:]
+	}
+
+	if rest != "" {
+		if !json.Valid([]byte(rest)) {
+			return sioPacket{}, fmt.Errorf("invalid socket.io payload: %s", rest)
+		}
+		p.Data = json.RawMessage(rest)
+	}
+
+	return p, nil
+}
+
+// socketURL converts a Homey base URL into the socket.io websocket endpoint
+func socketURL(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	default:
+		return "", fmt.Errorf("unsupported URL scheme: %s", u.Scheme)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/socket.io/"
+	u.RawQuery = "EIO=3&transport=websocket"
+	return u.String(), nil
+}
+
+// socketConn is a single socket.io connection
+type socketConn struct {
+	ws *websocket.Conn
+
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	nextAck    int
+	acks       map[int]chan json.RawMessage
+	namespaces map[string]chan struct{}
+
+	onEvent func(namespace string, data json.RawMessage)
+
+	pingInterval time.Duration
+	done         chan struct{}
+	closeOnce    sync.Once
+	err          error
+}
+
+// dialSocket opens a socket.io connection and starts its read and ping loops.
+// onEvent is called from the read loop for every event packet received.
+func dialSocket(ctx context.Context, baseURL string, onEvent func(namespace string, data json.RawMessage)) (*socketConn, error) {
+	wsURL, err := socketURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	wsCfg, err := websocket.NewConfig(wsURL, baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create websocket config: %w", err)
+	}
+
+	ws, err := wsCfg.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to realtime API: %w", err)
+	}
+
+	s := &socketConn{
+		ws:         ws,
+		acks:       make(map[int]chan json.RawMessage),
+		namespaces: make(map[string]chan struct{}),
+		onEvent:    onEvent,
+		done:       make(chan struct{}),
+	}
+
+	// The first message is the Engine.IO open packet with the ping settings
+	ws.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var open string
+	if err := websocket.Message.Receive(ws, &open); err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("failed to read handshake: %w", err)
+	}
+	ws.SetReadDeadline(time.Time{})
+
+	if open == "" || open[0] != eioOpen {
+		ws.Close()
+		return nil, fmt.Errorf("unexpected handshake: %s", open)
+	}
+
+	var params struct {
+		PingInterval int `json:"pingInterval"`
+		PingTimeout  int `json:"pingTimeout"`
+	}
+	if err := json.Unmarshal([]byte(open[1:]), &params); err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("failed to parse handshake: %w", err)
+	}
+	s.pingInterval = time.Duration(params.PingInterval) * time.Millisecond
+	if s.pingInterval <= 0 {
+		s.pingInterval = 25 * time.Second
+	}
+	readTimeout := s.pingInterval + time.Duration(params.PingTimeout)*time.Millisecond
+	if params.PingTimeout <= 0 {
+		readTimeout += 20 * time.Second
+	}
+
+	go s.readLoop(readTimeout)
+	go s.pingLoop()
+
+	return s, nil
+}
+
+// Done is closed when the connection is lost or closed
+func (s *socketConn) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the reason the connection was closed
+func (s *socketConn) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close closes the connection
+func (s *socketConn) Close() error {
+	s.closeWithError(nil)
+	return nil
+}
+
+func (s *socketConn) closeWithError(err error) {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		s.ws.Close()
+		close(s.done)
+	})
+}
+
+func (s *socketConn) send(msg string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return websocket.Message.Send(s.ws, msg)
+}
+
+func (s *socketConn) readLoop(timeout time.Duration) {
+	for {
+		s.ws.SetReadDeadline(time.Now().Add(timeout))
+
+		var msg string
+		if err := websocket.Message.Receive(s.ws, &msg); err != nil {
+			s.closeWithError(fmt.Errorf("realtime connection lost: %w", err))
+			return
+		}
+		if msg == "" {
+			continue
+		}
+
+		switch msg[0] {
+		case eioPing:
+			s.send(string(eioPong) + msg[1:])
+		case eioClose:
+			s.closeWithError(fmt.Errorf("realtime connection closed by Homey"))
+			return
+		case eioMessage:
+			p, err := decodeSIO(msg[1:])
+			if err != nil {
+				continue
+			}
+			s.handlePacket(p)
+		}
+	}
+}
+
+func (s *socketConn) handlePacket(p sioPacket) {
+	switch p.Type {
+	case sioConnect:
+		s.mu.Lock()
+		if ch, ok := s.namespaces[p.Namespace]; ok {
+			close(ch)
+			delete(s.namespaces, p.Namespace)
+		}
+		s.mu.Unlock()
+	case sioAck:
+		s.mu.Lock()
+		ch, ok := s.acks[p.AckID]
+		delete(s.acks, p.AckID)
+		s.mu.Unlock()
+		if ok {
+			ch <- p.Data
+		}
+	case sioEvent:
+		if s.onEvent != nil {
+			s.onEvent(p.Namespace, p.Data)
+		}
+	case sioDisconnect:
+		if p.Namespace != "/" {
+			s.closeWithError(fmt.Errorf("namespace %s disconnected by Homey", p.Namespace))
+		}
+	case sioError:
+		s.closeWithError(fmt.Errorf("realtime error: %s", string(p.Data)))
+	}
+}
+
+func (s *socketConn) pingLoop() {
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.send(string(eioPing)); err != nil {
+				s.closeWithError(fmt.Errorf("failed to send ping: %w", err))
+				return
+			}
+		}
+	}
+}
+
+// connectNamespace joins a socket.io namespace and waits for confirmation
+func (s *socketConn) connectNamespace(ctx context.Context, namespace string) error {
+	ch := make(chan struct{})
+	s.mu.Lock()
+	s.namespaces[namespace] = ch
+	s.mu.Unlock()
+
+	if err := s.send(encodeSIO(sioPacket{Type: sioConnect, Namespace: namespace, AckID: -1})); err != nil {
+		return fmt.Errorf("failed to connect to namespace %s: %w", namespace, err)
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-s.done:
+		return s.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("timed out connecting to namespace %s", namespace)
+	}
+}
+
+// emitWithAck emits an event and waits for the server's acknowledgement.
+// The ack arguments are returned as a JSON array.
+func (s *socketConn) emitWithAck(ctx context.Context, namespace, event string, args ...interface{}) (json.RawMessage, error) {
+	payload, err := json.Marshal(append([]interface{}{event}, args...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", event, err)
+	}
+
+	ch := make(chan json.RawMessage, 1)
+	s.mu.Lock()
+	id := s.nextAck
+	s.nextAck++
+	s.acks[id] = ch
+	s.mu.Unlock()
+
+	if err := s.send(encodeSIO(sioPacket{Type: sioEvent, Namespace: namespace, AckID: id, Data: payload})); err != nil {
+		s.mu.Lock()
+		delete(s.acks, id)
+		s.mu.Unlock()
+		return nil, fmt.Errorf("failed to send %s: %w", event, err)
+	}
+
+	select {
+	case data := <-ch:
+		return data, nil
+	case <-s.done:
+		return nil, s.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(10 * time.Second):
+		return nil, fmt.Errorf("timed out waiting for %s", event)
+	}
+}
+
+// ackError extracts the error (first ack argument) in Homey's callback style
+func ackError(data json.RawMessage) error {
+	var args []json.RawMessage
+	if err := json.Unmarshal(data, &args); err != nil || len(args) == 0 {
+		return nil
+	}
+
+	first := strings.TrimSpace(string(args[0]))
+	if first == "null" || first == "" {
+		return nil
+	}
+
+	var e struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(args[0], &e); err == nil {
+		if e.Message != "" {
+			return fmt.Errorf("%s", e.Message)
+		}
+		if e.Error != "" {
+			return fmt.Errorf("%s", e.Error)
+		}
+	}
+	return fmt.Errorf("%s", first)
+}
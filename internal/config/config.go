@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -16,7 +18,38 @@ type LocalConfig struct {
 
 // CloudConfig holds settings for cloud connection
 type CloudConfig struct {
-	Token string `mapstructure:"token"` // Cloud token/PAT
+	Token       string `mapstructure:"token"`        // Cloud token/PAT, or a cached session token
+	HomeyID     string `mapstructure:"homey_id"`     // Homey ID, used to derive the remote URL
+	RemoteURL   string `mapstructure:"remote_url"`   // Cloud relay URL like https://<id>.connect.athom.com
+	AccessToken string `mapstructure:"access_token"` // Athom account token, used to obtain session tokens
+
+	RefreshToken string    `mapstructure:"refresh_token"` // Renews AccessToken once it expires
+	AccessExpiry time.Time `mapstructure:"access_expiry"` // When AccessToken expires (zero if unknown)
+	Session      bool      `mapstructure:"session"`       // Token is a session token that can be renewed
+}
+
+// RenewableSession reports whether Token is a session token that can be
+// renewed from the stored account tokens
+func (c CloudConfig) RenewableSession() bool {
+	return c.Session && (c.AccessToken != "" || c.RefreshToken != "")
+}
+
+// RemoteURLForHomey returns the Athom cloud relay URL for a Homey ID
+func RemoteURLForHomey(homeyID string) string {
+	return "https://" + homeyID + ".connect.athom.com"
+}
+
+// RetryConfig holds the retry policy for transient API failures
+type RetryConfig struct {
+	Retries   *int          `mapstructure:"retries"`    // Retries after the first attempt (nil = default)
+	BaseDelay time.Duration `mapstructure:"base_delay"` // Backoff before the first retry, e.g. "500ms"
+	MaxDelay  time.Duration `mapstructure:"max_delay"`  // Upper bound for backoff and Retry-After
+}
+
+// AutoConfig controls how auto mode picks between local and cloud
+type AutoConfig struct {
+	ProbeTimeout time.Duration `mapstructure:"probe_timeout"` // Timeout for the local ping, e.g. "1500ms"
+	CacheTTL     time.Duration `mapstructure:"cache_ttl"`     // How long the choice is remembered (0 = probe every run)
 }
 
 type Config struct {
@@ -31,6 +64,20 @@ type Config struct {
 	Mode  string      `mapstructure:"mode"` // auto, local, cloud
 	Local LocalConfig `mapstructure:"local"`
 	Cloud CloudConfig `mapstructure:"cloud"`
+
+	Auto  AutoConfig  `mapstructure:"auto"`
+	Retry RetryConfig `mapstructure:"retry"`
+
+	// Named profiles, one per Homey. Profile is the one used by default.
+	Profile  string             `mapstructure:"profile"`
+	Profiles map[string]Profile `mapstructure:"profiles"`
+
+	Secrets SecretsConfig `mapstructure:"secrets"`
+
+	// transport is the mode auto mode picked at runtime (see SetTransport)
+	transport string
+	// secretsLocation describes where tokens were loaded from
+	secretsLocation string
 }
 
 // BaseURL returns the API base URL based on current mode
@@ -38,20 +85,50 @@ func (c *Config) BaseURL() string {
 	mode := c.EffectiveMode()
 
 	if mode == "local" {
-		if c.Local.Address != "" {
-			return c.Local.Address
-		}
-		// Fall back to legacy host/port if local address not set
-		scheme := "http"
-		if c.TLS {
-			scheme = "https"
-		}
-		return fmt.Sprintf("%s://%s:%d", scheme, c.Host, c.Port)
+		return c.LocalURL()
+	}
+
+	return c.CloudURL()
+}
+
+// LocalURL returns the local (LAN/VPN) URL of the Homey
+func (c *Config) LocalURL() string {
+	if c.Local.Address != "" {
+		return c.Local.Address
+	}
+	// Fall back to legacy host/port if local address not set
+	scheme := "http"
+	if c.TLS {
+		scheme = "https"
 	}
+	return fmt.Sprintf("%s://%s:%d", scheme, c.Host, c.Port)
+}
 
-	// Cloud mode - use Homey cloud API
-	// Note: Cloud API requires different handling, this is a placeholder
-	return "https://api.athom.com"
+// HasLocal reports whether a local address (or legacy host) is configured
+func (c *Config) HasLocal() bool {
+	return c.Local.Address != "" || (c.Host != "" && c.Host != "localhost")
+}
+
+// HasCloud reports whether the Homey can be reached through the cloud relay
+func (c *Config) HasCloud() bool {
+	return c.CloudURL() != "" || c.Cloud.AccessToken != ""
+}
+
+// SetTransport records the mode ("local" or "cloud") auto mode picked at
+// runtime. It only affects auto mode and is never saved.
+func (c *Config) SetTransport(mode string) {
+	c.transport = mode
+}
+
+// CloudURL returns the cloud relay URL for the selected Homey, or "" if unknown
+func (c *Config) CloudURL() string {
+	if c.Cloud.RemoteURL != "" {
+		return strings.TrimRight(c.Cloud.RemoteURL, "/")
+	}
+	if c.Cloud.HomeyID != "" {
+		return RemoteURLForHomey(c.Cloud.HomeyID)
+	}
+	return ""
 }
 
 // EffectiveMode returns the actual mode to use (resolves "auto")
@@ -62,11 +139,14 @@ func (c *Config) EffectiveMode() string {
 	}
 
 	if mode == "auto" {
+		if c.transport != "" {
+			return c.transport
+		}
 		// Prefer local if address or legacy host is configured
 		if c.Local.Address != "" || c.Host != "localhost" {
 			return "local"
 		}
-		if c.Cloud.Token != "" {
+		if c.Cloud.Token != "" || c.Cloud.AccessToken != "" {
 			return "cloud"
 		}
 		// Default to local for backwards compatibility
@@ -96,30 +176,44 @@ func (c *Config) EffectiveToken() string {
 	return c.Token
 }
 
-// CheckLegacyConfig checks if the old homey-cli config exists and prints migration instructions
-func CheckLegacyConfig() {
+// LegacyConfigDir returns the homey-cli config directory if it exists and
+// has not been migrated to homeyctl yet, or "" otherwise
+func LegacyConfigDir() string {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
-		return
+		return ""
 	}
 
 	oldDir := filepath.Join(configDir, "homey-cli")
 	newDir := filepath.Join(configDir, "homeyctl")
 
 	// Check if old config exists and new one doesn't
-	if _, err := os.Stat(filepath.Join(oldDir, "config.toml")); err == nil {
-		if _, err := os.Stat(filepath.Join(newDir, "config.toml")); os.IsNotExist(err) {
-			fmt.Fprintln(os.Stderr, "")
-			fmt.Fprintln(os.Stderr, "⚠️  Found config from previous version (homey-cli)")
-			fmt.Fprintln(os.Stderr, "")
-			fmt.Fprintln(os.Stderr, "The binary has been renamed from 'homey' to 'homeyctl' to avoid")
-			fmt.Fprintln(os.Stderr, "conflicts with Athom's official Homey CLI for app development.")
-			fmt.Fprintln(os.Stderr, "")
-			fmt.Fprintln(os.Stderr, "To migrate your config, run:")
-			fmt.Fprintf(os.Stderr, "  mv %s %s\n", oldDir, newDir)
-			fmt.Fprintln(os.Stderr, "")
-		}
+	if _, err := os.Stat(filepath.Join(oldDir, "config.toml")); err != nil {
+		return ""
 	}
+	if _, err := os.Stat(filepath.Join(newDir, "config.toml")); !os.IsNotExist(err) {
+		return ""
+	}
+	return oldDir
+}
+
+// CheckLegacyConfig checks if the old homey-cli config exists and prints migration instructions
+func CheckLegacyConfig() {
+	oldDir := LegacyConfigDir()
+	if oldDir == "" {
+		return
+	}
+	newDir := filepath.Join(filepath.Dir(oldDir), "homeyctl")
+
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "⚠️  Found config from previous version (homey-cli)")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "The binary has been renamed from 'homey' to 'homeyctl' to avoid")
+	fmt.Fprintln(os.Stderr, "conflicts with Athom's official Homey CLI for app development.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "To migrate your config, run:")
+	fmt.Fprintf(os.Stderr, "  mv %s %s\n", oldDir, newDir)
+	fmt.Fprintln(os.Stderr, "")
 }
 
 func Load() (*Config, error) {
@@ -152,6 +246,8 @@ func Load() (*Config, error) {
 	viper.SetDefault("port", 4859)
 	viper.SetDefault("format", "json")
 	viper.SetDefault("mode", "auto")
+	viper.SetDefault("auto.probe_timeout", "1500ms")
+	viper.SetDefault("auto.cache_ttl", "5m")
 
 	// Read config file (optional)
 	if err := viper.ReadInConfig(); err != nil {
@@ -165,18 +261,36 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
+	profile := ActiveProfile()
+	if err := cfg.applyProfile(profile); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadSecrets(profile); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
 func Save(cfg *Config) error {
-	configDir, err := os.UserConfigDir()
-	if err != nil {
-		return fmt.Errorf("failed to get config dir: %w", err)
+	if name := ActiveProfile(); name != "" {
+		p := profileFromConfig(cfg)
+		p.Format = cfg.Profiles[name].Format
+		if err := stashSecrets(name, profileSecretFields(&p)); err != nil {
+			return err
+		}
+		setProfile(name, p)
+		if err := secureSecrets(); err != nil {
+			return err
+		}
+		return writeConfig()
 	}
 
-	dir := filepath.Join(configDir, "homeyctl")
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return fmt.Errorf("failed to create config dir: %w", err)
+	// Work on a copy so the caller's tokens stay set
+	saved := *cfg
+	cfg = &saved
+	if err := stashSecrets("", cfg.secretFields("")); err != nil {
+		return err
 	}
 
 	// Legacy fields
@@ -191,7 +305,55 @@ func Save(cfg *Config) error {
 	viper.Set("local.address", cfg.Local.Address)
 	viper.Set("local.token", cfg.Local.Token)
 	viper.Set("cloud.token", cfg.Cloud.Token)
+	viper.Set("cloud.homey_id", cfg.Cloud.HomeyID)
+	viper.Set("cloud.remote_url", cfg.Cloud.RemoteURL)
+	viper.Set("cloud.access_token", cfg.Cloud.AccessToken)
+	viper.Set("cloud.refresh_token", cfg.Cloud.RefreshToken)
+	viper.Set("cloud.access_expiry", cfg.Cloud.AccessExpiry)
+	viper.Set("cloud.session", cfg.Cloud.Session)
+
+	if err := secureSecrets(); err != nil {
+		return err
+	}
+	return writeConfig()
+}
 
-	configPath := filepath.Join(dir, "config.toml")
-	return viper.WriteConfigAs(configPath)
+// configPath returns the path of config.toml, creating its directory
+func configPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config dir: %w", err)
+	}
+
+	dir := filepath.Join(configDir, "homeyctl")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create config dir: %w", err)
+	}
+	// MkdirAll leaves an existing directory as it was
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to secure config dir: %w", err)
+	}
+
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+// writeConfig writes the current viper settings to config.toml
+func writeConfig() error {
+	return writeConfigFrom(viper.GetViper())
+}
+
+// writeConfigFrom writes v's settings to config.toml. The file holds tokens,
+// so it is only readable by the current user, also while it is written.
+func writeConfigFrom(v *viper.Viper) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	// New files are created 0600; an existing file keeps its mode, so
+	// tighten it before the tokens go in
+	v.SetConfigPermissions(0o600)
+	if err := os.Chmod(path, 0o600); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to secure config: %w", err)
+	}
+	return v.WriteConfigAs(path)
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 )
 
 func TestBaseURL(t *testing.T) {
@@ -197,3 +198,50 @@ func TestBaseURLLocalFallsBackToLegacy(t *testing.T) {
 		t.Errorf("BaseURL() = %q, want %q", got, expected)
 	}
 }
+
+func TestBaseURLWithCloudMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		cloud    CloudConfig
+		expected string
+	}{
+		{"remote url", CloudConfig{RemoteURL: "https://abc.connect.athom.com/"}, "https://abc.connect.athom.com"},
+		{"derived from homey id", CloudConfig{HomeyID: "abc"}, "https://abc.connect.athom.com"},
+		{"remote url wins over homey id", CloudConfig{HomeyID: "abc", RemoteURL: "https://other.example"}, "https://other.example"},
+		{"unknown homey", CloudConfig{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Mode: "cloud", Cloud: tt.cloud}
+			if got := cfg.BaseURL(); got != tt.expected {
+				t.Errorf("BaseURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEffectiveModeAutoWithAccountToken(t *testing.T) {
+	cfg := &Config{Mode: "auto", Host: "localhost", Cloud: CloudConfig{AccessToken: "athom"}}
+	if got := cfg.EffectiveMode(); got != "cloud" {
+		t.Errorf("EffectiveMode() = %q, want cloud", got)
+	}
+}
+
+func TestTransportCacheValid(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tc := &TransportCache{Mode: "cloud", Local: "http://10.0.0.2", CheckedAt: now.Add(-time.Minute)}
+
+	if !tc.Valid("http://10.0.0.2", 5*time.Minute, now) {
+		t.Error("expected fresh cache to be valid")
+	}
+	if tc.Valid("http://10.0.0.2", 30*time.Second, now) {
+		t.Error("expected expired cache to be invalid")
+	}
+	if tc.Valid("http://10.0.0.3", 5*time.Minute, now) {
+		t.Error("expected cache for another address to be invalid")
+	}
+	if tc.Valid("http://10.0.0.2", 0, now) {
+		t.Error("expected zero TTL to disable the cache")
+	}
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultProfile names the top-level settings in config.toml
+const DefaultProfile = "default"
+
+// Profile holds the connection settings for one Homey
+type Profile struct {
+	Mode   string      `mapstructure:"mode"`   // auto, local, cloud
+	Format string      `mapstructure:"format"` // Output format; empty uses the top-level format
+	Local  LocalConfig `mapstructure:"local"`
+	Cloud  CloudConfig `mapstructure:"cloud"`
+}
+
+var (
+	profileOverride string
+	profileNameRe   = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+)
+
+// UseProfile selects a profile for this run, overriding HOMEY_PROFILE and the
+// profile saved in config.toml. An empty name keeps the normal selection.
+func UseProfile(name string) {
+	profileOverride = strings.ToLower(name)
+}
+
+// ActiveProfile returns the selected profile name, or "" for the top-level
+// settings. The --profile flag wins over HOMEY_PROFILE, which wins over the
+// "profile" key in config.toml.
+func ActiveProfile() string {
+	name := profileOverride
+	if name == "" {
+		name = strings.ToLower(os.Getenv("HOMEY_PROFILE"))
+	}
+	if name == "" {
+		name = strings.ToLower(viper.GetString("profile"))
+	}
+	if name == DefaultProfile {
+		return ""
+	}
+	return name
+}
+
+// ValidateProfileName checks that a name can be used as a config.toml key
+func ValidateProfileName(name string) error {
+	if !profileNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, '-' and '_'", name)
+	}
+	if name == DefaultProfile {
+		return fmt.Errorf("%q is reserved for the top-level settings", DefaultProfile)
+	}
+	return nil
+}
+
+// ProfileNames returns the configured profile names, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile replaces the connection settings with those of a profile
+func (c *Config) applyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	p, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (see: homeyctl config profiles list)", name)
+	}
+
+	c.Mode = p.Mode
+	if p.Format != "" {
+		c.Format = p.Format
+	}
+	c.Local = p.Local
+	c.Cloud = p.Cloud
+
+	// Legacy host/token settings belong to the top-level settings only
+	c.Host = "localhost"
+	c.Token = ""
+	c.TLS = false
+	return nil
+}
+
+// profileFromConfig converts connection settings to a profile, folding the
+// legacy host/token fields into the local settings. The format is left
+// empty, since c.Format may come from the top-level settings.
+func profileFromConfig(c *Config) Profile {
+	p := Profile{
+		Mode:  c.Mode,
+		Local: c.Local,
+		Cloud: c.Cloud,
+	}
+	if p.Local.Address == "" && c.HasLocal() {
+		p.Local.Address = c.LocalURL()
+	}
+	if p.Local.Token == "" {
+		p.Local.Token = c.Token
+	}
+	return p
+}
+
+func setProfile(name string, p Profile) {
+	prefix := "profiles." + name + "."
+	viper.Set(prefix+"mode", p.Mode)
+	viper.Set(prefix+"format", p.Format)
+	viper.Set(prefix+"local.address", p.Local.Address)
+	viper.Set(prefix+"local.token", p.Local.Token)
+	viper.Set(prefix+"cloud.token", p.Cloud.Token)
+	viper.Set(prefix+"cloud.homey_id", p.Cloud.HomeyID)
+	viper.Set(prefix+"cloud.remote_url", p.Cloud.RemoteURL)
+	viper.Set(prefix+"cloud.access_token", p.Cloud.AccessToken)
+	viper.Set(prefix+"cloud.refresh_token", p.Cloud.RefreshToken)
+	viper.Set(prefix+"cloud.access_expiry", p.Cloud.AccessExpiry)
+	viper.Set(prefix+"cloud.session", p.Cloud.Session)
+}
+
+// SaveProfile adds or replaces a named profile
+func SaveProfile(name string, p Profile) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	if err := stashSecrets(name, profileSecretFields(&p)); err != nil {
+		return err
+	}
+	setProfile(name, p)
+	if err := secureSecrets(); err != nil {
+		return err
+	}
+	return writeConfig()
+}
+
+// SetDefaultProfile saves the profile used when none is selected.
+// DefaultProfile or "" selects the top-level settings.
+func SetDefaultProfile(name string) error {
+	if name == DefaultProfile {
+		name = ""
+	}
+	viper.Set("profile", name)
+	return updateConfigFile(func(settings map[string]interface{}) {
+		settings["profile"] = name
+	})
+}
+
+// RemoveProfile deletes a named profile. If it was the default profile, the
+// top-level settings become the default again.
+func RemoveProfile(name string) error {
+	if err := deleteProfileSecrets(name); err != nil {
+		return err
+	}
+
+	return updateConfigFile(func(settings map[string]interface{}) {
+		if profiles, ok := settings["profiles"].(map[string]interface{}); ok {
+			delete(profiles, name)
+		}
+		if p, _ := settings["profile"].(string); p == name {
+			settings["profile"] = ""
+		}
+	})
+}
+
+// updateConfigFile rewrites config.toml with update applied to the file's
+// own settings. viper can't unset keys, and its global settings also hold
+// defaults and environment values, which don't belong in the file.
+func updateConfigFile(update func(settings map[string]interface{})) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	file := viper.New()
+	file.SetConfigFile(path)
+	file.SetConfigType("toml")
+	if err := file.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	settings := file.AllSettings()
+	update(settings)
+
+	v := viper.New()
+	if err := v.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	return writeConfigFrom(v)
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/zalando/go-keyring"
+)
+
+const profilesTOML = `
+mode = "local"
+format = "json"
+
+[local]
+address = "http://192.168.1.50"
+token = "home-token"
+
+[profiles.cabin]
+mode = "cloud"
+format = "table"
+
+[profiles.cabin.cloud]
+token = "cabin-token"
+homey_id = "abc"
+`
+
+func setupProfiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOMEY_PROFILE", "")
+	viper.Reset()
+	UseProfile("")
+	// Never touch the real keyring; tests that want one call setupKeyring
+	keyring.MockInitWithError(errors.New("no keyring in tests"))
+	openedStore, openedStoreKey, defaultBackend = nil, "", ""
+	t.Cleanup(func() {
+		viper.Reset()
+		UseProfile("")
+		openedStore, openedStoreKey, defaultBackend = nil, "", ""
+	})
+
+	path := filepath.Join(dir, "homeyctl", "config.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(profilesTOML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_DefaultProfile(t *testing.T) {
+	setupProfiles(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.BaseURL() != "http://192.168.1.50" || cfg.EffectiveToken() != "home-token" {
+		t.Errorf("expected top-level settings, got %s / %s", cfg.BaseURL(), cfg.EffectiveToken())
+	}
+	if names := cfg.ProfileNames(); len(names) != 1 || names[0] != "cabin" {
+		t.Errorf("unexpected profile names: %v", names)
+	}
+}
+
+func TestLoad_SelectsProfile(t *testing.T) {
+	for _, selectBy := range []string{"flag", "env"} {
+		t.Run(selectBy, func(t *testing.T) {
+			setupProfiles(t)
+			if selectBy == "flag" {
+				UseProfile("Cabin")
+			} else {
+				t.Setenv("HOMEY_PROFILE", "cabin")
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.EffectiveMode() != "cloud" || cfg.Format != "table" {
+				t.Errorf("expected cabin profile, got mode %s format %s", cfg.EffectiveMode(), cfg.Format)
+			}
+			if cfg.BaseURL() != "https://abc.connect.athom.com" || cfg.EffectiveToken() != "cabin-token" {
+				t.Errorf("unexpected connection: %s / %s", cfg.BaseURL(), cfg.EffectiveToken())
+			}
+		})
+	}
+}
+
+func TestLoad_UnknownProfile(t *testing.T) {
+	setupProfiles(t)
+	UseProfile("office")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "unknown profile") {
+		t.Errorf("expected unknown profile error, got %v", err)
+	}
+}
+
+func TestSave_WritesToActiveProfile(t *testing.T) {
+	setupProfiles(t)
+	UseProfile("cabin")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Cloud.Token = "new-cabin-token"
+	if err := Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	UseProfile("")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.EffectiveToken() != "home-token" {
+		t.Errorf("top-level token changed: %s", cfg.EffectiveToken())
+	}
+	if got := cfg.Profiles["cabin"].Cloud.Token; got != "new-cabin-token" {
+		t.Errorf("expected profile token to be saved, got %q", got)
+	}
+}
+
+func TestSave_KeepsProfileFormatEmpty(t *testing.T) {
+	setupProfiles(t)
+
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveProfile("office", Profile{Mode: "local", Local: LocalConfig{Address: "http://10.0.0.5", Token: "office-token"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	UseProfile("office")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	UseProfile("")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Profiles["office"].Format; got != "" {
+		t.Errorf("expected the profile to keep using the top-level format, got %q", got)
+	}
+}
+
+func TestRemoveProfile(t *testing.T) {
+	path := setupProfiles(t)
+	t.Setenv("HOMEY_TOKEN", "env-token")
+
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetDefaultProfile("cabin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveProfile("cabin"); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error after removing default profile: %v", err)
+	}
+	if len(cfg.Profiles) != 0 {
+		t.Errorf("expected no profiles, got %v", cfg.ProfileNames())
+	}
+	if cfg.Local.Token != "home-token" {
+		t.Errorf("expected top-level settings to be kept, got %s", cfg.Local.Token)
+	}
+
+	// Only the file's own settings are written back
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "env-token") || strings.Contains(string(data), "cache_ttl") {
+		t.Errorf("expected no environment values or defaults in the file, got:\n%s", data)
+	}
+}
+
+func TestValidateProfileName(t *testing.T) {
+	for _, name := range []string{"home", "cabin-2", "office_1"} {
+		if err := ValidateProfileName(name); err != nil {
+			t.Errorf("ValidateProfileName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "default", "my.homey", "-x", "a b"} {
+		if err := ValidateProfileName(name); err == nil {
+			t.Errorf("ValidateProfileName(%q) should fail", name)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+
+	"github.com/langtind/homeyctl/internal/secrets"
+)
+
+// PlainSecrets keeps tokens in config.toml
+const PlainSecrets = "plain"
+
+// SecretsConfig selects where tokens are stored
+type SecretsConfig struct {
+	Backend    string `mapstructure:"backend"`      // plain, keyring or file
+	AgeKeyFile string `mapstructure:"age_key_file"` // age identity for the file backend
+}
+
+// secretKeys are the token fields stored outside config.toml, relative to a
+// profile (or the top level)
+var secretKeys = []string{"local.token", "cloud.token", "cloud.access_token", "cloud.refresh_token"}
+
+var (
+	openedStore    secrets.Store
+	openedStoreKey string
+
+	// defaultBackend caches the backend picked when none is configured
+	defaultBackend string
+)
+
+// secretsBackend returns the configured backend; HOMEY_SECRETS_BACKEND wins.
+// Without one, tokens go to the keyring if there is one.
+func secretsBackend() string {
+	if backend := configuredSecretsBackend(); backend != "" {
+		return backend
+	}
+	if defaultBackend == "" {
+		defaultBackend = pickSecretsBackend()
+	}
+	return defaultBackend
+}
+
+func configuredSecretsBackend() string {
+	if backend := os.Getenv("HOMEY_SECRETS_BACKEND"); backend != "" {
+		return backend
+	}
+	return viper.GetString("secrets.backend")
+}
+
+// pickSecretsBackend returns the keyring if it answers, else the encrypted
+// file if a passphrase or age key is set, else plain
+func pickSecretsBackend() string {
+	if store, err := openSecrets("keyring"); err == nil {
+		_, err := store.Get(DefaultProfile + "/local.token")
+		if err == nil || errors.Is(err, secrets.ErrNotFound) {
+			return "keyring"
+		}
+	}
+	if os.Getenv("HOMEY_SECRETS_PASSPHRASE") != "" || os.Getenv("HOMEY_AGE_KEY_FILE") != "" || viper.GetString("secrets.age_key_file") != "" {
+		return "file"
+	}
+	return PlainSecrets
+}
+
+// secureSecrets runs before config.toml is written. It saves the backend
+// picked by default, so tokens aren't lost if it later fails to open, and
+// moves tokens still in plain text into it. With no secure backend to use,
+// it warns that tokens are stored in plain text.
+func secureSecrets() error {
+	backend := secretsBackend()
+	store, err := openSecrets(backend)
+	if err != nil {
+		return err
+	}
+
+	if store == nil {
+		if configuredSecretsBackend() == "" && len(plainSecrets()) > 0 {
+			fmt.Fprintln(os.Stderr, "Warning: no system keyring is available, so tokens are stored in plain text in config.toml.")
+			fmt.Fprintln(os.Stderr, "To encrypt them, set HOMEY_SECRETS_PASSPHRASE or run: homeyctl config secrets use file --age-key-file <key>")
+		}
+		return nil
+	}
+
+	if configuredSecretsBackend() == "" {
+		viper.Set("secrets.backend", backend)
+	}
+	paths := secretPaths()
+	for path, value := range plainSecrets() {
+		if err := store.Set(paths[path], value); err != nil {
+			return fmt.Errorf("failed to store %s: %w", path, err)
+		}
+		viper.Set(path, "")
+	}
+	return nil
+}
+
+// secretPaths maps the config.toml path of every token (top level and each
+// profile) to its key in the secret store
+func secretPaths() map[string]string {
+	paths := map[string]string{}
+	for _, key := range append([]string{"token"}, secretKeys...) {
+		paths[key] = DefaultProfile + "/" + key
+	}
+	// AllSettings merges the file with values set in this run; GetStringMap
+	// would return only one of them
+	profiles, _ := viper.AllSettings()["profiles"].(map[string]interface{})
+	for name := range profiles {
+		for _, key := range secretKeys {
+			paths["profiles."+name+"."+key] = name + "/" + key
+		}
+	}
+	return paths
+}
+
+// plainSecrets returns the tokens that writing config.toml would store in
+// plain text, by path: those already in the file and those set in this run
+func plainSecrets() map[string]string {
+	values := map[string]string{}
+	for path := range secretPaths() {
+		if value := viper.GetString(path); value != "" {
+			values[path] = value
+		}
+	}
+	return values
+}
+
+// openSecrets opens the store for a backend, or returns nil for plain
+func openSecrets(backend string) (secrets.Store, error) {
+	if backend == PlainSecrets {
+		return nil, nil
+	}
+
+	keyFile := os.Getenv("HOMEY_AGE_KEY_FILE")
+	if keyFile == "" {
+		keyFile = viper.GetString("secrets.age_key_file")
+	}
+
+	// Opening the file backend decrypts it, so reuse the store within a run
+	cacheKey := backend + "\x00" + keyFile
+	if openedStore != nil && openedStoreKey == cacheKey {
+		return openedStore, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config dir: %w", err)
+	}
+
+	store, err := secrets.Open(backend, secrets.Options{
+		Dir:        filepath.Join(configDir, "homeyctl"),
+		Passphrase: os.Getenv("HOMEY_SECRETS_PASSPHRASE"),
+		AgeKeyFile: keyFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	openedStore, openedStoreKey = store, cacheKey
+	return store, nil
+}
+
+// secretScope names the settings a secret belongs to in the store
+func secretScope(profile string) string {
+	if profile == "" {
+		return DefaultProfile
+	}
+	return profile
+}
+
+// secretFields returns the token fields of the active settings by key
+func (c *Config) secretFields(profile string) map[string]*string {
+	fields := map[string]*string{
+		"local.token":         &c.Local.Token,
+		"cloud.token":         &c.Cloud.Token,
+		"cloud.access_token":  &c.Cloud.AccessToken,
+		"cloud.refresh_token": &c.Cloud.RefreshToken,
+	}
+	if profile == "" {
+		fields["token"] = &c.Token
+	}
+	return fields
+}
+
+func profileSecretFields(p *Profile) map[string]*string {
+	return map[string]*string{
+		"local.token":         &p.Local.Token,
+		"cloud.token":         &p.Cloud.Token,
+		"cloud.access_token":  &p.Cloud.AccessToken,
+		"cloud.refresh_token": &p.Cloud.RefreshToken,
+	}
+}
+
+// loadSecrets fills empty token fields from the secret store
+func (c *Config) loadSecrets(profile string) error {
+	store, err := openSecrets(secretsBackend())
+	if err != nil || store == nil {
+		return err
+	}
+	c.secretsLocation = store.Location()
+
+	scope := secretScope(profile)
+	for key, field := range c.secretFields(profile) {
+		if *field != "" {
+			continue
+		}
+		value, err := store.Get(scope + "/" + key)
+		if errors.Is(err, secrets.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		*field = value
+	}
+	return nil
+}
+
+// stashSecrets moves token fields into the secret store and blanks them, so
+// they are not written to config.toml. Empty fields are removed from the store.
+func stashSecrets(profile string, fields map[string]*string) error {
+	store, err := openSecrets(secretsBackend())
+	if err != nil || store == nil {
+		return err
+	}
+
+	scope := secretScope(profile)
+	for key, field := range fields {
+		if *field == "" {
+			if err := store.Delete(scope + "/" + key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := store.Set(scope+"/"+key, *field); err != nil {
+			return fmt.Errorf("failed to store %s: %w", key, err)
+		}
+		*field = ""
+	}
+	return nil
+}
+
+// SecretsLocation describes where tokens are stored
+func (c *Config) SecretsLocation() string {
+	if c.secretsLocation != "" {
+		return c.secretsLocation
+	}
+	return "config.toml"
+}
+
+// deleteProfileSecrets removes a profile's tokens from the secret store
+func deleteProfileSecrets(name string) error {
+	store, err := openSecrets(secretsBackend())
+	if err != nil || store == nil {
+		return err
+	}
+	for _, key := range secretKeys {
+		if err := store.Delete(name + "/" + key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateSecrets moves all tokens (top level and every profile) to another
+// backend and makes it the configured one
+func MigrateSecrets(backend string) error {
+	from := secretsBackend()
+	if backend == from && backend == configuredSecretsBackend() {
+		return nil
+	}
+
+	oldStore, err := openSecrets(from)
+	if err != nil {
+		return err
+	}
+	newStore, err := openSecrets(backend)
+	if err != nil {
+		return err
+	}
+
+	// Collect every secret, keyed by its config.toml path
+	paths := secretPaths()
+
+	values := map[string]string{}
+	for path, storeKey := range paths {
+		value := viper.GetString(path)
+		if value == "" && oldStore != nil {
+			value, err = oldStore.Get(storeKey)
+			if errors.Is(err, secrets.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+		}
+		if value != "" {
+			values[path] = value
+		}
+	}
+
+	for path, value := range values {
+		if newStore == nil {
+			viper.Set(path, value)
+			continue
+		}
+		if err := newStore.Set(paths[path], value); err != nil {
+			return fmt.Errorf("failed to store %s: %w", path, err)
+		}
+		viper.Set(path, "")
+	}
+
+	viper.Set("secrets.backend", backend)
+	if err := writeConfig(); err != nil {
+		return err
+	}
+
+	// Only clean up the old store once the new config is written. The old
+	// store is the new one when a default backend is made the configured one.
+	if oldStore != nil && from != backend {
+		for path := range values {
+			if err := oldStore.Delete(paths[path]); err != nil {
+				return fmt.Errorf("tokens moved, but failed to remove %s from %s: %w", path, oldStore.Location(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetAgeKeyFile sets the age identity file used by the file backend
+func SetAgeKeyFile(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("age key file: %w", err)
+	}
+	viper.Set("secrets.age_key_file", path)
+	return nil
+}
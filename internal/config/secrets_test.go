@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/zalando/go-keyring"
+)
+
+func setupKeyring(t *testing.T) string {
+	t.Helper()
+	path := setupProfiles(t)
+	keyring.MockInit()
+	return path
+}
+
+func TestSave_KeyringKeepsTokensOutOfConfig(t *testing.T) {
+	path := setupKeyring(t)
+	t.Setenv("HOMEY_SECRETS_BACKEND", "keyring")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Local.Token = "new-token"
+	if err := Save(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "new-token") {
+		t.Errorf("token written to config.toml:\n%s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if info, _ := os.Stat(filepath.Dir(path)); info.Mode().Perm() != 0o700 {
+		t.Errorf("expected the config dir to be tightened to 0700, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded.Local.Token != "new-token" {
+		t.Errorf("expected token from keyring, got %q", loaded.Local.Token)
+	}
+	if loaded.SecretsLocation() != "system keyring" {
+		t.Errorf("unexpected location %q", loaded.SecretsLocation())
+	}
+}
+
+func TestMigrateSecrets_RoundTrip(t *testing.T) {
+	path := setupKeyring(t)
+
+	if _, err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := MigrateSecrets("keyring"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, token := range []string{"home-token", "cabin-token"} {
+		if strings.Contains(string(data), token) {
+			t.Errorf("%s still in config.toml after migrating:\n%s", token, data)
+		}
+	}
+
+	UseProfile("cabin")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Cloud.Token != "cabin-token" {
+		t.Errorf("expected cabin token from keyring, got %q", cfg.Cloud.Token)
+	}
+
+	if err := MigrateSecrets(PlainSecrets); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	for _, token := range []string{"home-token", "cabin-token"} {
+		if !strings.Contains(string(data), token) {
+			t.Errorf("expected %s back in config.toml:\n%s", token, data)
+		}
+	}
+}
+
+func TestSave_DefaultsToKeyringAndMovesPlainTokens(t *testing.T) {
+	path := setupKeyring(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, token := range []string{"home-token", "cabin-token"} {
+		if strings.Contains(string(data), token) {
+			t.Errorf("%s left in config.toml:\n%s", token, data)
+		}
+	}
+	if !strings.Contains(string(data), "backend = 'keyring'") && !strings.Contains(string(data), `backend = "keyring"`) {
+		t.Errorf("expected the keyring backend to be saved:\n%s", data)
+	}
+
+	if value, err := keyring.Get("homeyctl", "cabin/cloud.token"); err != nil || value != "cabin-token" {
+		t.Errorf("expected cabin token in keyring, got %q (%v)", value, err)
+	}
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded.Local.Token != "home-token" {
+		t.Errorf("expected token from keyring, got %q", loaded.Local.Token)
+	}
+}
+
+func TestSave_NoKeyringKeepsPlainTokens(t *testing.T) {
+	path := setupProfiles(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "home-token") || strings.Contains(string(data), "backend") {
+		t.Errorf("expected plain tokens and no backend without a keyring:\n%s", data)
+	}
+}
+
+func TestPlainSecrets_IncludesTokensSetInThisRun(t *testing.T) {
+	setupProfiles(t)
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	setProfile("office", Profile{Local: LocalConfig{Token: "office-token"}})
+	viper.Set("token", "abc")
+
+	plain := plainSecrets()
+	for path, want := range map[string]string{
+		"token":                       "abc",
+		"local.token":                 "home-token",
+		"profiles.office.local.token": "office-token",
+		"profiles.cabin.cloud.token":  "cabin-token",
+	} {
+		if plain[path] != want {
+			t.Errorf("%s = %q, want %q", path, plain[path], want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TransportCache remembers which transport auto mode picked, so the local
+// probe doesn't run on every command
+type TransportCache struct {
+	Mode      string    `json:"mode"`      // local or cloud
+	Local     string    `json:"local"`     // Local address the choice was made for
+	CheckedAt time.Time `json:"checkedAt"` // When the local address was probed
+}
+
+// Valid reports whether the cached choice applies to localURL and is younger than ttl
+func (tc *TransportCache) Valid(localURL string, ttl time.Duration, now time.Time) bool {
+	if tc == nil || ttl <= 0 || tc.Local != localURL {
+		return false
+	}
+	if tc.Mode != "local" && tc.Mode != "cloud" {
+		return false
+	}
+	return now.Sub(tc.CheckedAt) < ttl
+}
+
+func transportCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "homeyctl", "transport.json"), nil
+}
+
+// LoadTransportCache reads the cached auto mode choice
+func LoadTransportCache() (*TransportCache, error) {
+	path, err := transportCachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tc TransportCache
+	if err := json.Unmarshal(data, &tc); err != nil {
+		return nil, fmt.Errorf("failed to parse transport cache: %w", err)
+	}
+	return &tc, nil
+}
+
+// SaveTransportCache stores the auto mode choice
+func SaveTransportCache(tc *TransportCache) error {
+	path, err := transportCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	data, err := json.Marshal(tc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// ClearTransportCache forgets the auto mode choice so the next run probes again
+func ClearTransportCache() {
+	if path, err := transportCachePath(); err == nil {
+		os.Remove(path)
+	}
+}
@@ -5,125 +5,391 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
 )
 
 // HomeyCandidate represents a discovered Homey device
 type HomeyCandidate struct {
-	Address  string
-	Host     string
-	Port     int
-	HomeyID  string
-	Name     string
-	Model    string
-	Version  string
-	Instance string
+	Address  string `json:"address"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	HomeyID  string `json:"homeyId,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Instance string `json:"instance,omitempty"`
 }
 
-// mDNS multicast address
-var mdnsAddr = &net.UDPAddr{IP: net.ParseIP("224.0.0.251"), Port: 5353}
+// mDNS multicast addresses
+var (
+	mdnsAddr4 = &net.UDPAddr{IP: net.ParseIP("224.0.0.251"), Port: 5353}
+	mdnsAddr6 = &net.UDPAddr{IP: net.ParseIP("ff02::fb"), Port: 5353}
+)
+
+// Services to query
+var services = []string{
+	"_homey._tcp.local.",
+	"_athom._tcp.local.",
+}
+
+// Options controls an mDNS search
+type Options struct {
+	// Timeout is how long Discover listens for answers; zero means 5 seconds
+	Timeout time.Duration
+
+	// Interface limits queries to one network interface by name (e.g. "en0");
+	// empty queries on all of them
+	Interface string
+
+	// QueryInterval is how often queries are repeated while listening. Zero
+	// means every second for Discover and every 10 seconds for Watch.
+	QueryInterval time.Duration
+}
 
 // DiscoverHomeys searches for Homey devices on the local network via mDNS
 func DiscoverHomeys(ctx context.Context, timeout time.Duration) ([]HomeyCandidate, error) {
-	if timeout == 0 {
-		timeout = 5 * time.Second
-	}
+	return Discover(ctx, Options{Timeout: timeout})
+}
 
-	// Services to query
-	services := []string{
-		"_homey._tcp.local.",
-		"_athom._tcp.local.",
+// Discover searches for Homeys over IPv4 and IPv6 mDNS, repeating the query
+// across the timeout so Homeys that miss the first one still answer
+func Discover(ctx context.Context, opts Options) ([]HomeyCandidate, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.QueryInterval == 0 {
+		opts.QueryInterval = time.Second
 	}
 
-	var mu sync.Mutex
-	var candidates []HomeyCandidate
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
 
-	// Create UDP socket for mDNS
-	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	var mu sync.Mutex
+	var found []HomeyCandidate
+	index := make(map[string]int)
+
+	err := listen(ctx, opts, func(candidates []HomeyCandidate, _ []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range candidates {
+			key := c.key()
+			if i, ok := index[key]; ok {
+				if preferred(c, found[i]) {
+					found[i] = c
+				}
+				continue
+			}
+			index[key] = len(found)
+			found = append(found, c)
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create mDNS socket: %w", err)
+		return nil, err
 	}
-	defer conn.Close()
 
-	// Set read deadline based on timeout
-	deadline := time.Now().Add(timeout)
-	conn.SetReadDeadline(deadline)
+	mu.Lock()
+	defer mu.Unlock()
+	return found, nil
+}
 
-	// Send queries for each service type
-	for _, service := range services {
-		m := new(dns.Msg)
-		m.SetQuestion(service, dns.TypePTR)
-		m.RecursionDesired = false
+// key identifies a Homey across answers: by Homey ID, else by mDNS instance
+func (c HomeyCandidate) key() string {
+	if c.HomeyID != "" {
+		return "id:" + c.HomeyID
+	}
+	if c.Instance != "" {
+		return "instance:" + strings.ToLower(c.Instance)
+	}
+	return "address:" + c.Address
+}
 
-		data, err := m.Pack()
-		if err != nil {
-			continue
+// preferred reports whether a is a better answer than b for the same Homey.
+// IPv4 addresses are preferred, since IPv6 ones are often link-local.
+func preferred(a, b HomeyCandidate) bool {
+	return isIPv4(a.Host) && !isIPv4(b.Host)
+}
+
+func isIPv4(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() != nil
+}
+
+// mdnsSocket queries one address family
+type mdnsSocket struct {
+	conn *net.UDPConn
+	dsts []*net.UDPAddr
+}
+
+// packet is an mDNS answer and the address it came from
+type packet struct {
+	msg  *dns.Msg
+	from *net.UDPAddr
+}
+
+// listen queries every opts.QueryInterval and passes the Homeys in each
+// answer, and the instances that said goodbye, to handle until ctx is done
+func listen(ctx context.Context, opts Options, handle func(found []HomeyCandidate, gone []string)) error {
+	sockets, err := openSockets(opts.Interface)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, s := range sockets {
+			s.conn.Close()
 		}
+	}()
 
-		_, err = conn.WriteToUDP(data, mdnsAddr)
-		if err != nil {
-			continue
+	queries, err := buildQueries()
+	if err != nil {
+		return err
+	}
+
+	packets := make(chan packet)
+	var wg sync.WaitGroup
+	for _, s := range sockets {
+		wg.Add(1)
+		go func(conn *net.UDPConn) {
+			defer wg.Done()
+			readPackets(ctx, conn, packets)
+		}(s.conn)
+	}
+	defer wg.Wait()
+
+	query := func() {
+		for _, s := range sockets {
+			for _, dst := range s.dsts {
+				for _, q := range queries {
+					// A failed send on one interface shouldn't stop the others
+					s.conn.WriteToUDP(q, dst)
+				}
+			}
 		}
 	}
+	query()
+
+	ticker := time.NewTicker(opts.QueryInterval)
+	defer ticker.Stop()
 
-	// Read responses until timeout
-	buf := make([]byte, 65535)
 	for {
 		select {
 		case <-ctx.Done():
-			goto done
-		default:
+			return nil
+		case <-ticker.C:
+			query()
+		case p := <-packets:
+			found, gone := parseMessage(p.msg)
+			for i := range found {
+				found[i].setZone(p.from)
+			}
+			if len(found) > 0 || len(gone) > 0 {
+				handle(found, gone)
+			}
 		}
+	}
+}
 
-		n, _, err := conn.ReadFromUDP(buf)
+// readPackets forwards mDNS messages from conn until ctx is done
+func readPackets(ctx context.Context, conn *net.UDPConn, packets chan<- packet) {
+	buf := make([]byte, 65535)
+	for {
+		// Wake up regularly to notice cancellation
+		conn.SetReadDeadline(time.Now().Add(250 * time.Millisecond))
+		n, from, err := conn.ReadFromUDP(buf)
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				break
+				continue
 			}
-			continue
+			return
 		}
 
-		resp := new(dns.Msg)
-		if err := resp.Unpack(buf[:n]); err != nil {
+		msg := new(dns.Msg)
+		if err := msg.Unpack(buf[:n]); err != nil || !msg.Response {
 			continue
 		}
+		select {
+		case packets <- packet{msg: msg, from: from}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-		// Parse response into candidate
-		candidate := parseHomeyResponse(resp)
-		if candidate != nil {
-			mu.Lock()
-			candidates = append(candidates, *candidate)
-			mu.Unlock()
+// buildQueries packs one PTR query per service
+func buildQueries() ([][]byte, error) {
+	var queries [][]byte
+	for _, service := range services {
+		m := new(dns.Msg)
+		m.SetQuestion(service, dns.TypePTR)
+		m.RecursionDesired = false
+
+		data, err := m.Pack()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build mDNS query: %w", err)
 		}
+		queries = append(queries, data)
 	}
+	return queries, nil
+}
 
-done:
-	mu.Lock()
-	defer mu.Unlock()
+// openSockets opens an IPv4 and (where available) an IPv6 socket. Queries go
+// out on the named interface, or on every multicast interface if name is empty.
+func openSockets(name string) ([]*mdnsSocket, error) {
+	ifaces, err := multicastInterfaces(name)
+	if err != nil {
+		return nil, err
+	}
 
-	// Deduplicate by address
-	seen := make(map[string]bool)
-	var unique []HomeyCandidate
-	for _, c := range candidates {
-		if !seen[c.Address] {
-			seen[c.Address] = true
-			unique = append(unique, c)
+	var sockets []*mdnsSocket
+	if s, err := openSocket4(name, ifaces); err == nil {
+		sockets = append(sockets, s)
+	} else if name != "" {
+		return nil, err
+	}
+	if s, err := openSocket6(ifaces); err == nil {
+		sockets = append(sockets, s)
+	}
+
+	if len(sockets) == 0 {
+		return nil, fmt.Errorf("failed to create mDNS socket")
+	}
+	return sockets, nil
+}
+
+func openSocket4(name string, ifaces []net.Interface) (*mdnsSocket, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mDNS socket: %w", err)
+	}
+
+	// Without an interface, the system's multicast route picks one
+	if name != "" {
+		if err := ipv4.NewPacketConn(conn).SetMulticastInterface(&ifaces[0]); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to use interface %s: %w", name, err)
+		}
+	}
+	return &mdnsSocket{conn: conn, dsts: []*net.UDPAddr{mdnsAddr4}}, nil
+}
+
+// openSocket6 sends to ff02::fb on each interface with IPv6; link-local
+// multicast needs the interface as the zone
+func openSocket6(ifaces []net.Interface) (*mdnsSocket, error) {
+	var dsts []*net.UDPAddr
+	for _, iface := range ifaces {
+		if hasIPv6(iface) {
+			dsts = append(dsts, &net.UDPAddr{IP: mdnsAddr6.IP, Port: mdnsAddr6.Port, Zone: iface.Name})
 		}
 	}
+	if len(dsts) == 0 {
+		return nil, fmt.Errorf("no interface with IPv6")
+	}
 
-	return unique, nil
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6unspecified, Port: 0})
+	if err != nil {
+		return nil, err
+	}
+	return &mdnsSocket{conn: conn, dsts: dsts}, nil
 }
 
-// parseHomeyResponse extracts Homey info from mDNS response
-func parseHomeyResponse(resp *dns.Msg) *HomeyCandidate {
-	var candidate HomeyCandidate
-	var host string
-	var port uint16
-	var ip net.IP
+// multicastInterfaces returns the named interface, or all interfaces that
+// are up, not loopback and support multicast
+func multicastInterfaces(name string) ([]net.Interface, error) {
+	if name != "" {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown interface %q: %w", name, err)
+		}
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 {
+			return nil, fmt.Errorf("interface %s is down or doesn't support multicast", name)
+		}
+		return []net.Interface{*iface}, nil
+	}
+
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces: %w", err)
+	}
+	var ifaces []net.Interface
+	for _, iface := range all {
+		if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagMulticast != 0 && iface.Flags&net.FlagLoopback == 0 {
+			ifaces = append(ifaces, iface)
+		}
+	}
+	return ifaces, nil
+}
+
+func hasIPv6(iface net.Interface) bool {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// setZone adds the interface an answer arrived on to link-local IPv6
+// addresses, which are unusable without it
+func (c *HomeyCandidate) setZone(from *net.UDPAddr) {
+	ip := net.ParseIP(c.Host)
+	if ip == nil || ip.To4() != nil || !ip.IsLinkLocalUnicast() || from == nil || from.Zone == "" {
+		return
+	}
+	c.Host = ip.String() + "%" + from.Zone
+	c.Address = buildAddress(c.Host, c.Port)
+}
+
+// buildAddress returns the URL for a host and port
+func buildAddress(host string, port int) string {
+	scheme := "http"
+	if port == 443 {
+		scheme = "https"
+	}
+	// A zone's % has to be escaped in a URL
+	host = strings.Replace(host, "%", "%25", 1)
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// instanceRecords collects the records of one service instance
+type instanceRecords struct {
+	candidate HomeyCandidate
+	target    string
+	port      uint16
+}
+
+// parseMessage extracts the Homeys in an mDNS answer. One answer can describe
+// several Homeys, so SRV and TXT records are grouped by instance and A/AAAA
+// records matched to each SRV target. It also returns the instances that
+// announced they are leaving (records with TTL 0).
+func parseMessage(resp *dns.Msg) ([]HomeyCandidate, []string) {
+	instances := make(map[string]*instanceRecords)
+	var order []string
+	addrs := make(map[string][]net.IP)
+	var allAddrs []net.IP
+	var gone []string
+
+	instance := func(name string) *instanceRecords {
+		key := normalizeName(name)
+		if r, ok := instances[key]; ok {
+			return r
+		}
+		r := &instanceRecords{candidate: HomeyCandidate{Instance: strings.TrimSuffix(name, ".")}}
+		instances[key] = r
+		order = append(order, key)
+		return r
+	}
 
 	// Combine all records for parsing
 	allRecords := append(resp.Answer, resp.Extra...)
@@ -131,55 +397,98 @@ func parseHomeyResponse(resp *dns.Msg) *HomeyCandidate {
 	for _, rr := range allRecords {
 		switch r := rr.(type) {
 		case *dns.PTR:
-			candidate.Instance = strings.TrimSuffix(r.Ptr, ".")
+			if r.Hdr.Ttl == 0 {
+				gone = append(gone, strings.TrimSuffix(r.Ptr, "."))
+				continue
+			}
+			instance(r.Ptr)
 		case *dns.TXT:
+			c := &instance(r.Hdr.Name).candidate
 			for _, txt := range r.Txt {
 				if strings.HasPrefix(txt, "id=") {
-					candidate.HomeyID = strings.TrimPrefix(txt, "id=")
+					c.HomeyID = strings.TrimPrefix(txt, "id=")
 				} else if strings.HasPrefix(txt, "name=") {
-					candidate.Name = strings.TrimPrefix(txt, "name=")
+					c.Name = strings.TrimPrefix(txt, "name=")
 				} else if strings.HasPrefix(txt, "model=") {
-					candidate.Model = strings.TrimPrefix(txt, "model=")
+					c.Model = strings.TrimPrefix(txt, "model=")
 				} else if strings.HasPrefix(txt, "version=") {
-					candidate.Version = strings.TrimPrefix(txt, "version=")
+					c.Version = strings.TrimPrefix(txt, "version=")
 				}
 			}
 		case *dns.SRV:
-			port = r.Port
-			host = strings.TrimSuffix(r.Target, ".")
+			inst := instance(r.Hdr.Name)
+			inst.port = r.Port
+			inst.target = normalizeName(r.Target)
 		case *dns.A:
-			ip = r.A
+			addrs[normalizeName(r.Hdr.Name)] = append(addrs[normalizeName(r.Hdr.Name)], r.A)
+			allAddrs = append(allAddrs, r.A)
 		case *dns.AAAA:
-			if ip == nil {
-				ip = r.AAAA
-			}
+			addrs[normalizeName(r.Hdr.Name)] = append(addrs[normalizeName(r.Hdr.Name)], r.AAAA)
+			allAddrs = append(allAddrs, r.AAAA)
 		}
 	}
 
-	// Need at least host/IP and port
-	if port == 0 {
-		return nil
+	// Some responders name address records inconsistently; with a single
+	// instance in the answer, its addresses can't belong to anyone else
+	srvCount := 0
+	for _, key := range order {
+		if instances[key].port != 0 {
+			srvCount++
+		}
 	}
 
-	// Prefer IP over hostname for address
-	if ip != nil {
-		host = ip.String()
-	}
-	if host == "" {
-		return nil
+	var found []HomeyCandidate
+	for _, key := range order {
+		r := instances[key]
+		// Need at least host/IP and port
+		if r.port == 0 {
+			continue
+		}
+
+		ips := addrs[r.target]
+		if len(ips) == 0 && srvCount == 1 {
+			ips = allAddrs
+		}
+
+		// Prefer IP over hostname for address, and IPv4 over IPv6
+		host := strings.TrimSuffix(r.target, ".")
+		if ip := pickIP(ips); ip != nil {
+			host = ip.String()
+		}
+		if host == "" {
+			continue
+		}
+
+		c := r.candidate
+		c.Host = host
+		c.Port = int(r.port)
+		c.Address = buildAddress(host, c.Port)
+		found = append(found, c)
 	}
 
-	candidate.Host = host
-	candidate.Port = int(port)
+	return found, gone
+}
 
-	// Build URL
-	scheme := "http"
-	if port == 443 {
-		scheme = "https"
+// pickIP returns the first IPv4 address, or the first IPv6 one if there is none
+func pickIP(ips []net.IP) net.IP {
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
 	}
-	candidate.Address = fmt.Sprintf("%s://%s:%d", scheme, host, port)
+	if len(ips) > 0 {
+		return ips[0]
+	}
+	return nil
+}
 
-	return &candidate
+// normalizeName makes DNS names comparable: lowercase with a trailing dot
+func normalizeName(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
 }
 
 // VerifyHomey checks if an address is a valid Homey by pinging it
@@ -188,8 +497,12 @@ func VerifyHomey(ctx context.Context, address string, timeout time.Duration) (st
 		timeout = 2 * time.Second
 	}
 
-	client := &http.Client{Timeout: timeout}
+	return pingHomey(ctx, &http.Client{Timeout: timeout}, address)
+}
 
+// pingHomey calls the ping endpoint at address with client and returns the
+// Homey ID from the response
+func pingHomey(ctx context.Context, client *http.Client, address string) (string, bool) {
 	// Try the ping endpoint
 	url := address + "/api/manager/system/ping"
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -213,8 +526,8 @@ func VerifyHomey(ctx context.Context, address string, timeout time.Duration) (st
 }
 
 // DiscoverAndVerify discovers Homeys and verifies they respond
-func DiscoverAndVerify(ctx context.Context, timeout time.Duration) ([]HomeyCandidate, error) {
-	candidates, err := DiscoverHomeys(ctx, timeout)
+func DiscoverAndVerify(ctx context.Context, opts Options) ([]HomeyCandidate, error) {
+	candidates, err := Discover(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
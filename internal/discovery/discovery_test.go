@@ -1,6 +1,8 @@
 package discovery
 
 import (
+	"net"
+	"net/url"
 	"testing"
 
 	"github.com/miekg/dns"
@@ -90,17 +92,17 @@ func TestParseHomeyResponse(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := parseHomeyResponse(tt.response)
+			got := firstCandidate(tt.response)
 
 			if tt.want == nil {
 				if got != nil {
-					t.Errorf("parseHomeyResponse() = %v, want nil", got)
+					t.Errorf("parseMessage() = %v, want nil", got)
 				}
 				return
 			}
 
 			if got == nil {
-				t.Fatalf("parseHomeyResponse() = nil, want %v", tt.want)
+				t.Fatalf("parseMessage() = nil, want %v", tt.want)
 			}
 
 			if got.Address != tt.want.Address {
@@ -149,9 +151,9 @@ func TestParseHomeyResponse_TXTFields(t *testing.T) {
 		},
 	}
 
-	got := parseHomeyResponse(m)
+	got := firstCandidate(m)
 	if got == nil {
-		t.Fatal("parseHomeyResponse() returned nil")
+		t.Fatal("parseMessage() returned nil")
 	}
 
 	if got.HomeyID != "homey-12345" {
@@ -182,9 +184,9 @@ func TestParseHomeyResponse_IPv6(t *testing.T) {
 		},
 	}
 
-	got := parseHomeyResponse(m)
+	got := firstCandidate(m)
 	if got == nil {
-		t.Fatal("parseHomeyResponse() returned nil")
+		t.Fatal("parseMessage() returned nil")
 	}
 
 	// Should use IPv6 address
@@ -211,9 +213,9 @@ func TestParseHomeyResponse_PreferIPv4(t *testing.T) {
 		},
 	}
 
-	got := parseHomeyResponse(m)
+	got := firstCandidate(m)
 	if got == nil {
-		t.Fatal("parseHomeyResponse() returned nil")
+		t.Fatal("parseMessage() returned nil")
 	}
 
 	// Should prefer IPv4 over IPv6
@@ -221,3 +223,79 @@ func TestParseHomeyResponse_PreferIPv4(t *testing.T) {
 		t.Errorf("Host = %q, want %q", got.Host, "192.168.1.100")
 	}
 }
+
+// firstCandidate returns the first Homey parseMessage finds, or nil
+func firstCandidate(m *dns.Msg) *HomeyCandidate {
+	found, _ := parseMessage(m)
+	if len(found) == 0 {
+		return nil
+	}
+	return &found[0]
+}
+
+func TestParseMessage_SeveralHomeys(t *testing.T) {
+	// Two Homeys answering in one packet must not be merged
+	m := new(dns.Msg)
+	m.Answer = []dns.RR{
+		&dns.PTR{Hdr: dns.RR_Header{Name: "_homey._tcp.local.", Rrtype: dns.TypePTR, Ttl: 4500}, Ptr: "Kitchen._homey._tcp.local."},
+		&dns.PTR{Hdr: dns.RR_Header{Name: "_homey._tcp.local.", Rrtype: dns.TypePTR, Ttl: 4500}, Ptr: "Cabin._homey._tcp.local."},
+	}
+	m.Extra = []dns.RR{
+		&dns.SRV{Hdr: dns.RR_Header{Name: "Kitchen._homey._tcp.local.", Rrtype: dns.TypeSRV, Ttl: 120}, Port: 80, Target: "homey-kitchen.local."},
+		&dns.TXT{Hdr: dns.RR_Header{Name: "Kitchen._homey._tcp.local.", Rrtype: dns.TypeTXT, Ttl: 120}, Txt: []string{"id=kitchen-id", "name=Kitchen"}},
+		&dns.SRV{Hdr: dns.RR_Header{Name: "Cabin._homey._tcp.local.", Rrtype: dns.TypeSRV, Ttl: 120}, Port: 443, Target: "homey-cabin.local."},
+		&dns.TXT{Hdr: dns.RR_Header{Name: "Cabin._homey._tcp.local.", Rrtype: dns.TypeTXT, Ttl: 120}, Txt: []string{"id=cabin-id", "name=Cabin"}},
+		&dns.A{Hdr: dns.RR_Header{Name: "HOMEY-CABIN.local.", Rrtype: dns.TypeA, Ttl: 120}, A: []byte{10, 0, 0, 2}},
+		&dns.A{Hdr: dns.RR_Header{Name: "homey-kitchen.local.", Rrtype: dns.TypeA, Ttl: 120}, A: []byte{10, 0, 0, 1}},
+		&dns.AAAA{Hdr: dns.RR_Header{Name: "homey-kitchen.local.", Rrtype: dns.TypeAAAA, Ttl: 120}, AAAA: []byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}},
+	}
+
+	found, gone := parseMessage(m)
+	if len(gone) != 0 {
+		t.Errorf("unexpected goodbyes: %v", gone)
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 Homeys, got %d: %+v", len(found), found)
+	}
+
+	kitchen, cabin := found[0], found[1]
+	if kitchen.HomeyID != "kitchen-id" || kitchen.Address != "http://10.0.0.1:80" || kitchen.Instance != "Kitchen._homey._tcp.local" {
+		t.Errorf("unexpected kitchen Homey: %+v", kitchen)
+	}
+	if cabin.HomeyID != "cabin-id" || cabin.Address != "https://10.0.0.2:443" || cabin.Name != "Cabin" {
+		t.Errorf("unexpected cabin Homey: %+v", cabin)
+	}
+}
+
+func TestParseMessage_Goodbye(t *testing.T) {
+	m := new(dns.Msg)
+	m.Answer = []dns.RR{
+		&dns.PTR{Hdr: dns.RR_Header{Name: "_homey._tcp.local.", Rrtype: dns.TypePTR, Ttl: 0}, Ptr: "Kitchen._homey._tcp.local."},
+	}
+
+	found, gone := parseMessage(m)
+	if len(found) != 0 || len(gone) != 1 || gone[0] != "Kitchen._homey._tcp.local" {
+		t.Errorf("expected a goodbye for Kitchen, got %v / %v", found, gone)
+	}
+}
+
+func TestSetZone_LinkLocalIPv6(t *testing.T) {
+	c := HomeyCandidate{Host: "fe80::1", Port: 4859}
+	c.setZone(&net.UDPAddr{IP: net.ParseIP("fe80::1"), Zone: "eth0"})
+
+	if c.Host != "fe80::1%eth0" {
+		t.Errorf("Host = %q, want fe80::1%%eth0", c.Host)
+	}
+	if c.Address != "http://[fe80::1%25eth0]:4859" {
+		t.Errorf("Address = %q", c.Address)
+	}
+	if _, err := url.Parse(c.Address); err != nil {
+		t.Errorf("address is not a valid URL: %v", err)
+	}
+
+	v4 := HomeyCandidate{Host: "10.0.0.1", Port: 80, Address: "http://10.0.0.1:80"}
+	v4.setZone(&net.UDPAddr{IP: net.ParseIP("10.0.0.1")})
+	if v4.Host != "10.0.0.1" {
+		t.Errorf("IPv4 host changed: %q", v4.Host)
+	}
+}
@@ -0,0 +1,188 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultScanPorts are the ports Homey's API listens on
+var DefaultScanPorts = []int{80, 443, 4859}
+
+// maxScanHosts caps a scan at a /16, so a typo can't start a scan of millions of hosts
+const maxScanHosts = 1 << 16
+
+// maxScanRate caps ScanOptions.Rate at a rate a time.Ticker can keep up with
+const maxScanRate = 10000
+
+// ScanOptions controls a subnet scan
+type ScanOptions struct {
+	// Ports to probe on each host, in order; nil means DefaultScanPorts
+	Ports []int
+
+	// Workers is how many hosts are probed at once; zero means 64
+	Workers int
+
+	// Rate caps how many probes start per second, up to 10000; zero means 200
+	Rate int
+
+	// ProbeTimeout is how long to wait for each host and port; zero means 1 second
+	ProbeTimeout time.Duration
+
+	// Progress, if set, is called after each host with the number done and the total
+	Progress func(done, total int)
+}
+
+// probeTransport skips certificate verification: a Homey's certificate is
+// issued for its homeylocal.com name, not its IP address, and the probe only
+// reads the Homey ID
+var probeTransport = &http.Transport{
+	TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	DisableKeepAlives: true,
+}
+
+// probeHomey checks one address (replaced in tests)
+var probeHomey = func(ctx context.Context, address string, timeout time.Duration) (string, bool) {
+	return pingHomey(ctx, &http.Client{Timeout: timeout, Transport: probeTransport}, address)
+}
+
+// Scan probes every host in a subnet (e.g. "192.168.10.0/24") for a Homey,
+// for networks where mDNS doesn't reach. Each host is probed on opts.Ports in
+// order until one answers the ping endpoint.
+func Scan(ctx context.Context, cidr string, opts ScanOptions) ([]HomeyCandidate, error) {
+	hosts, err := scanHosts(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Rate < 0 || opts.Rate > maxScanRate {
+		return nil, fmt.Errorf("invalid scan rate %d, expected 1 to %d probes per second", opts.Rate, maxScanRate)
+	}
+
+	if opts.Ports == nil {
+		opts.Ports = DefaultScanPorts
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 64
+	}
+	if opts.Rate == 0 {
+		opts.Rate = 200
+	}
+	if opts.ProbeTimeout == 0 {
+		opts.ProbeTimeout = time.Second
+	}
+
+	limiter := time.NewTicker(time.Second / time.Duration(opts.Rate))
+	defer limiter.Stop()
+
+	jobs := make(chan netip.Addr)
+	var (
+		mu    sync.Mutex
+		found []HomeyCandidate
+		done  int
+		wg    sync.WaitGroup
+	)
+
+	worker := func() {
+		defer wg.Done()
+		for host := range jobs {
+			candidate := probeHost(ctx, host, opts, limiter.C)
+
+			mu.Lock()
+			if candidate != nil {
+				found = append(found, *candidate)
+			}
+			done++
+			if opts.Progress != nil {
+				opts.Progress(done, len(hosts))
+			}
+			mu.Unlock()
+		}
+	}
+
+	for i := 0; i < opts.Workers && i < len(hosts); i++ {
+		wg.Add(1)
+		go worker()
+	}
+
+feed:
+	for _, host := range hosts {
+		select {
+		case jobs <- host:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, _ := netip.ParseAddr(found[i].Host)
+		b, _ := netip.ParseAddr(found[j].Host)
+		return a.Less(b)
+	})
+	return found, nil
+}
+
+// probeHost tries each port on a host and returns the first that answers
+func probeHost(ctx context.Context, host netip.Addr, opts ScanOptions, limiter <-chan time.Time) *HomeyCandidate {
+	for _, port := range opts.Ports {
+		select {
+		case <-limiter:
+		case <-ctx.Done():
+			return nil
+		}
+
+		address := buildAddress(host.String(), port)
+		if homeyID, ok := probeHomey(ctx, address, opts.ProbeTimeout); ok {
+			return &HomeyCandidate{
+				Address: address,
+				Host:    host.String(),
+				Port:    port,
+				HomeyID: homeyID,
+			}
+		}
+	}
+	return nil
+}
+
+// scanHosts lists the host addresses in a subnet, without the network and
+// broadcast addresses of IPv4 subnets larger than /31
+func scanHosts(cidr string) ([]netip.Addr, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		// A bare address scans just that host
+		addr, addrErr := netip.ParseAddr(cidr)
+		if addrErr != nil {
+			return nil, fmt.Errorf("invalid subnet %q, expected e.g. 192.168.1.0/24", cidr)
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	prefix = prefix.Masked()
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 16 {
+		return nil, fmt.Errorf("subnet %s is too large to scan (at most %d addresses, e.g. a /16)", prefix, maxScanHosts)
+	}
+
+	var hosts []netip.Addr
+	for addr := prefix.Addr(); prefix.Contains(addr); addr = addr.Next() {
+		hosts = append(hosts, addr)
+		if !addr.Next().IsValid() {
+			break
+		}
+	}
+
+	if prefix.Addr().Is4() && hostBits >= 2 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	return hosts, nil
+}
@@ -0,0 +1,148 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScanHosts(t *testing.T) {
+	tests := []struct {
+		cidr      string
+		wantCount int
+		wantFirst string
+		wantLast  string
+		wantErr   bool
+	}{
+		{cidr: "192.168.10.0/24", wantCount: 254, wantFirst: "192.168.10.1", wantLast: "192.168.10.254"},
+		{cidr: "192.168.10.77/24", wantCount: 254, wantFirst: "192.168.10.1", wantLast: "192.168.10.254"},
+		{cidr: "10.0.0.0/31", wantCount: 2, wantFirst: "10.0.0.0", wantLast: "10.0.0.1"},
+		{cidr: "10.0.0.5", wantCount: 1, wantFirst: "10.0.0.5", wantLast: "10.0.0.5"},
+		{cidr: "fd00::/126", wantCount: 4, wantFirst: "fd00::", wantLast: "fd00::3"},
+		{cidr: "10.0.0.0/8", wantErr: true},
+		{cidr: "not-a-subnet", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		hosts, err := scanHosts(tt.cidr)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("scanHosts(%q): expected error", tt.cidr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("scanHosts(%q): unexpected error: %v", tt.cidr, err)
+			continue
+		}
+		if len(hosts) != tt.wantCount || hosts[0].String() != tt.wantFirst || hosts[len(hosts)-1].String() != tt.wantLast {
+			t.Errorf("scanHosts(%q) = %d hosts %v..%v, want %d hosts %s..%s",
+				tt.cidr, len(hosts), hosts[0], hosts[len(hosts)-1], tt.wantCount, tt.wantFirst, tt.wantLast)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		probed  []string
+		running int32
+		peak    int32
+	)
+	orig := probeHomey
+	probeHomey = func(ctx context.Context, address string, timeout time.Duration) (string, bool) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		probed = append(probed, address)
+		mu.Unlock()
+
+		switch address {
+		case "http://192.168.10.20:4859":
+			return "homey-b", true
+		case "http://192.168.10.5:80":
+			return "homey-a", true
+		}
+		return "", false
+	}
+	t.Cleanup(func() { probeHomey = orig })
+
+	var lastDone, total int
+	found, err := Scan(context.Background(), "192.168.10.0/27", ScanOptions{
+		Workers: 4,
+		Rate:    10000,
+		Progress: func(done, n int) {
+			lastDone, total = done, n
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(found) != 2 {
+		t.Fatalf("expected 2 Homeys, got %+v", found)
+	}
+	if found[0].HomeyID != "homey-a" || found[0].Port != 80 || found[1].HomeyID != "homey-b" || found[1].Address != "http://192.168.10.20:4859" {
+		t.Errorf("unexpected results (should be sorted by address): %+v", found)
+	}
+
+	if peak > 4 {
+		t.Errorf("expected at most 4 concurrent probes, saw %d", peak)
+	}
+	if lastDone != 30 || total != 30 {
+		t.Errorf("expected progress 30/30, got %d/%d", lastDone, total)
+	}
+
+	// A host that answers on 80 isn't probed on the other ports
+	for _, address := range probed {
+		if strings.HasPrefix(address, "https://192.168.10.5:") || strings.HasPrefix(address, "http://192.168.10.5:4859") {
+			t.Errorf("probed %s after the host already answered", address)
+		}
+	}
+}
+
+func TestScan_Cancelled(t *testing.T) {
+	orig := probeHomey
+	probeHomey = func(ctx context.Context, address string, timeout time.Duration) (string, bool) {
+		return "", false
+	}
+	t.Cleanup(func() { probeHomey = orig })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Scan(ctx, "192.168.10.0/24", ScanOptions{}); err == nil {
+		t.Error("expected an error for a cancelled scan")
+	}
+}
+
+func TestScan_InvalidRate(t *testing.T) {
+	for _, rate := range []int{-1, 2_000_000_000} {
+		if _, err := Scan(context.Background(), "192.168.10.1", ScanOptions{Rate: rate}); err == nil || !strings.Contains(err.Error(), "scan rate") {
+			t.Errorf("rate %d: expected a scan rate error, got %v", rate, err)
+		}
+	}
+}
+
+func TestProbeHomey_SelfSignedTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Homey-ID", "homey-tls")
+	}))
+	defer server.Close()
+
+	if id, ok := probeHomey(context.Background(), server.URL, time.Second); !ok || id != "homey-tls" {
+		t.Errorf("expected the probe to accept a self-signed certificate, got %q, %v", id, ok)
+	}
+}
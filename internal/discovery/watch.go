@@ -0,0 +1,149 @@
+package discovery
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType says whether a Homey appeared or disappeared
+type EventType string
+
+const (
+	Appeared    EventType = "appeared"
+	Disappeared EventType = "disappeared"
+)
+
+// Event is a change in the Homeys visible on the network
+type Event struct {
+	Type  EventType      `json:"type"`
+	Time  time.Time      `json:"time"`
+	Homey HomeyCandidate `json:"homey"`
+}
+
+// missedQueries is how many query rounds a Homey may miss before it is
+// reported as gone, so one lost packet doesn't cause a flap
+const missedQueries = 3
+
+// Watch keeps querying for Homeys until ctx is cancelled and reports each one
+// that appears, and each one that says goodbye or stops answering. The
+// channel is closed when ctx is done.
+func Watch(ctx context.Context, opts Options) (<-chan Event, error) {
+	if opts.QueryInterval == 0 {
+		opts.QueryInterval = 10 * time.Second
+	}
+
+	// Open the sockets up front so a bad interface is reported to the caller
+	sockets, err := openSockets(opts.Interface)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sockets {
+		s.conn.Close()
+	}
+
+	events := make(chan Event, 16)
+	w := &watcher{
+		events: events,
+		seen:   make(map[string]*seenHomey),
+		expiry: time.Duration(missedQueries) * opts.QueryInterval,
+	}
+
+	go w.run(ctx, opts.QueryInterval, func(ctx context.Context, handle func([]HomeyCandidate, []string)) {
+		listen(ctx, opts, handle)
+	})
+
+	return events, nil
+}
+
+// seenHomey is a Homey the watcher has reported as present
+type seenHomey struct {
+	homey    HomeyCandidate
+	lastSeen time.Time
+}
+
+type watcher struct {
+	mu     sync.Mutex
+	events chan<- Event
+	seen   map[string]*seenHomey
+	expiry time.Duration
+}
+
+// run listens until ctx is done, expiring silent Homeys every interval,
+// then closes the events channel. The expiry goroutine is stopped before
+// the channel is closed so it can't send on a closed channel.
+func (w *watcher) run(ctx context.Context, interval time.Duration, listen func(context.Context, func([]HomeyCandidate, []string))) {
+	defer close(w.events)
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				w.expire(ctx, now)
+			}
+		}
+	}()
+
+	listen(ctx, func(found []HomeyCandidate, gone []string) {
+		w.update(ctx, found, gone, time.Now())
+	})
+	cancel()
+	wg.Wait()
+}
+
+func (w *watcher) emit(ctx context.Context, event Event) {
+	select {
+	case w.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// update records answers and goodbyes
+func (w *watcher) update(ctx context.Context, found []HomeyCandidate, gone []string, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, c := range found {
+		key := c.key()
+		if s, ok := w.seen[key]; ok {
+			s.lastSeen = now
+			if preferred(c, s.homey) {
+				s.homey = c
+			}
+			continue
+		}
+		w.seen[key] = &seenHomey{homey: c, lastSeen: now}
+		w.emit(ctx, Event{Type: Appeared, Time: now, Homey: c})
+	}
+
+	for _, instance := range gone {
+		for key, s := range w.seen {
+			if strings.EqualFold(s.homey.Instance, instance) {
+				delete(w.seen, key)
+				w.emit(ctx, Event{Type: Disappeared, Time: now, Homey: s.homey})
+			}
+		}
+	}
+}
+
+// expire reports Homeys that haven't answered for a while
+func (w *watcher) expire(ctx context.Context, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for key, s := range w.seen {
+		if now.Sub(s.lastSeen) > w.expiry {
+			delete(w.seen, key)
+			w.emit(ctx, Event{Type: Disappeared, Time: now, Homey: s.homey})
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatcher_AppearAndDisappear(t *testing.T) {
+	events := make(chan Event, 10)
+	w := &watcher{events: events, seen: make(map[string]*seenHomey), expiry: 30 * time.Second}
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	kitchen := HomeyCandidate{HomeyID: "kitchen", Instance: "Kitchen._homey._tcp.local", Host: "10.0.0.1", Address: "http://10.0.0.1:80"}
+	cabin := HomeyCandidate{HomeyID: "cabin", Instance: "Cabin._homey._tcp.local", Host: "10.0.0.2", Address: "http://10.0.0.2:80"}
+
+	w.update(ctx, []HomeyCandidate{kitchen, cabin}, nil, start)
+	// Repeated answers don't repeat the event
+	w.update(ctx, []HomeyCandidate{kitchen}, nil, start.Add(10*time.Second))
+
+	if got := drain(events); len(got) != 2 || got[0].Type != Appeared || got[1].Type != Appeared {
+		t.Fatalf("expected two appeared events, got %+v", got)
+	}
+
+	// Cabin stops answering, kitchen keeps answering
+	w.update(ctx, []HomeyCandidate{kitchen}, nil, start.Add(35*time.Second))
+	w.expire(ctx, start.Add(40*time.Second))
+	got := drain(events)
+	if len(got) != 1 || got[0].Type != Disappeared || got[0].Homey.HomeyID != "cabin" {
+		t.Fatalf("expected cabin to disappear, got %+v", got)
+	}
+
+	// Kitchen says goodbye
+	w.update(ctx, nil, []string{"kitchen._homey._tcp.local"}, start.Add(45*time.Second))
+	got = drain(events)
+	if len(got) != 1 || got[0].Type != Disappeared || got[0].Homey.HomeyID != "kitchen" {
+		t.Fatalf("expected kitchen to disappear, got %+v", got)
+	}
+}
+
+func TestWatch_UnknownInterface(t *testing.T) {
+	if _, err := Watch(context.Background(), Options{Interface: "does-not-exist0"}); err == nil {
+		t.Error("expected an error for an unknown interface")
+	}
+}
+
+func drain(events chan Event) []Event {
+	var got []Event
+	for {
+		select {
+		case e := <-events:
+			got = append(got, e)
+		default:
+			return got
+		}
+	}
+}
+
+func TestWatcher_CancelWhileExpiring(t *testing.T) {
+	// Nobody reads the events, so an expiry blocks sending until cancelled
+	events := make(chan Event)
+	w := &watcher{events: events, seen: make(map[string]*seenHomey), expiry: time.Millisecond}
+	w.seen["kitchen"] = &seenHomey{homey: HomeyCandidate{HomeyID: "kitchen"}, lastSeen: time.Now().Add(-time.Hour)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(ctx, time.Millisecond, func(ctx context.Context, _ func([]HomeyCandidate, []string)) {
+			// Give the ticker time to start expiring, then stop like a consumer would
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+			<-ctx.Done()
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("run didn't return after cancel")
+	}
+	// The channel is closed only after the expiry goroutine has stopped
+	for range events {
+	}
+}
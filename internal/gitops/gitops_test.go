@@ -0,0 +1,447 @@
+package gitops
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeAPI serves canned GET responses and records every change made
+type fakeAPI struct {
+	zones, devices, variables, moods, folders, flows, advancedFlows string
+
+	calls   []string
+	bodies  []map[string]interface{}
+	created int
+}
+
+func (f *fakeAPI) get(body string) (json.RawMessage, error) {
+	if body == "" {
+		body = "{}"
+	}
+	return json.RawMessage(body), nil
+}
+
+func (f *fakeAPI) record(call string, body map[string]interface{}) {
+	f.calls = append(f.calls, call)
+	f.bodies = append(f.bodies, body)
+}
+
+func (f *fakeAPI) create(call string, body map[string]interface{}) (json.RawMessage, error) {
+	f.created++
+	f.record(call, body)
+	return json.RawMessage(fmt.Sprintf(`{"id":"new%d"}`, f.created)), nil
+}
+
+func (f *fakeAPI) GetZones() (json.RawMessage, error)       { return f.get(f.zones) }
+func (f *fakeAPI) GetDevices() (json.RawMessage, error)     { return f.get(f.devices) }
+func (f *fakeAPI) GetVariables() (json.RawMessage, error)   { return f.get(f.variables) }
+func (f *fakeAPI) GetMoods() (json.RawMessage, error)       { return f.get(f.moods) }
+func (f *fakeAPI) GetFlowFolders() (json.RawMessage, error) { return f.get(f.folders) }
+func (f *fakeAPI) GetFlows() (json.RawMessage, error)       { return f.get(f.flows) }
+func (f *fakeAPI) GetAdvancedFlows() (json.RawMessage, error) {
+	return f.get(f.advancedFlows)
+}
+
+func (f *fakeAPI) CreateZone(zone map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateZone", zone)
+}
+func (f *fakeAPI) UpdateZone(id string, updates map[string]interface{}) error {
+	f.record("UpdateZone "+id, updates)
+	return nil
+}
+func (f *fakeAPI) DeleteZone(id string) error { f.record("DeleteZone "+id, nil); return nil }
+
+func (f *fakeAPI) UpdateDevice(id string, updates map[string]interface{}) error {
+	f.record("UpdateDevice "+id, updates)
+	return nil
+}
+func (f *fakeAPI) DeleteDevice(id string) error { f.record("DeleteDevice "+id, nil); return nil }
+func (f *fakeAPI) CreateDeviceGroup(group map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateDeviceGroup", group)
+}
+func (f *fakeAPI) UpdateDeviceGroup(id string, updates map[string]interface{}) error {
+	f.record("UpdateDeviceGroup "+id, updates)
+	return nil
+}
+
+func (f *fakeAPI) CreateVariable(name string, varType string, value interface{}) (json.RawMessage, error) {
+	return f.create("CreateVariable", map[string]interface{}{"name": name, "type": varType, "value": value})
+}
+func (f *fakeAPI) DeleteVariable(id string) error { f.record("DeleteVariable "+id, nil); return nil }
+
+func (f *fakeAPI) CreateMood(mood map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateMood", mood)
+}
+func (f *fakeAPI) UpdateMood(id string, updates map[string]interface{}) error {
+	f.record("UpdateMood "+id, updates)
+	return nil
+}
+func (f *fakeAPI) DeleteMood(id string) error { f.record("DeleteMood "+id, nil); return nil }
+
+func (f *fakeAPI) CreateFlowFolder(folder map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateFlowFolder", folder)
+}
+func (f *fakeAPI) UpdateFlowFolder(id string, folder map[string]interface{}) error {
+	f.record("UpdateFlowFolder "+id, folder)
+	return nil
+}
+func (f *fakeAPI) DeleteFlowFolder(id string) error {
+	f.record("DeleteFlowFolder "+id, nil)
+	return nil
+}
+
+func (f *fakeAPI) CreateFlow(flow map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateFlow", flow)
+}
+func (f *fakeAPI) UpdateFlow(id string, flow map[string]interface{}) (json.RawMessage, error) {
+	f.record("UpdateFlow "+id, flow)
+	return nil, nil
+}
+func (f *fakeAPI) DeleteFlow(id string) error { f.record("DeleteFlow "+id, nil); return nil }
+func (f *fakeAPI) CreateAdvancedFlow(flow map[string]interface{}) (json.RawMessage, error) {
+	return f.create("CreateAdvancedFlow", flow)
+}
+func (f *fakeAPI) UpdateAdvancedFlow(id string, flow map[string]interface{}) (json.RawMessage, error) {
+	f.record("UpdateAdvancedFlow "+id, flow)
+	return nil, nil
+}
+func (f *fakeAPI) DeleteAdvancedFlow(id string) error {
+	f.record("DeleteAdvancedFlow "+id, nil)
+	return nil
+}
+
+func newFakeHomey() *fakeAPI {
+	return &fakeAPI{
+		zones: `{
+			"home": {"id": "home", "name": "Home", "parent": null, "icon": "home"},
+			"kitchen": {"id": "kitchen", "name": "Kitchen", "parent": "home", "icon": "kitchen"},
+			"attic": {"id": "attic", "name": "Attic", "parent": "home", "icon": "default"},
+			"loft": {"id": "loft", "name": "Loft", "parent": "attic", "icon": "default"}
+		}`,
+		devices: `{
+			"lamp": {"id": "lamp", "name": "Lamp", "zone": "home", "class": "light"},
+			"spot": {"id": "spot", "name": "Spot", "zone": "kitchen", "class": "light"},
+			"grp": {"id": "grp", "name": "Old Group", "zone": "home", "class": "light", "virtualClass": "group", "devices": ["lamp"]}
+		}`,
+		variables: `{"v1": {"id": "v1", "name": "guests", "type": "boolean", "value": true}}`,
+		moods:     `{"m1": {"id": "m1", "name": "Dinner", "zone": "kitchen", "devices": {"spot": {"onoff": true, "dim": 0.5}}}}`,
+		flows: `{"f1": {
+			"id": "f1", "name": "Sunset", "enabled": true, "folder": null,
+			"trigger": {"id": "homey:manager:geolocation:sunset", "args": {}},
+			"actions": [{"id": "homey:device:lamp:on", "group": "then", "args": {}}]
+		}}`,
+		advancedFlows: `{"a1": {"id": "a1", "name": "Old advanced", "enabled": true, "cards": {}}}`,
+	}
+}
+
+func writeSpec(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_MergesFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeSpec(t, dir, "zones.yaml", "zones:\n  - name: Kitchen\n")
+	writeSpec(t, dir, "floors/upstairs.yml", "zones:\n  - name: Bedroom\n    parent: Upstairs\n---\nmoods: []\n")
+	writeSpec(t, dir, ".git/config.yaml", "zones:\n  - name: Ignored\n")
+	writeSpec(t, dir, "README.md", "not yaml")
+
+	spec, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Files are read in path order
+	if len(spec.Zones) != 2 || spec.Zones[0].Parent != "Upstairs" || spec.Zones[1].Name != "Kitchen" {
+		t.Errorf("unexpected zones: %+v", spec.Zones)
+	}
+	if !spec.declares(KindZone) || !spec.declares(KindMood) {
+		t.Error("expected zones and moods to be declared")
+	}
+	if spec.declares(KindFlow) {
+		t.Error("expected flows not to be declared")
+	}
+}
+
+func TestLoad_Rejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown field", "zones:\n  - name: Kitchen\n    colour: red\n", "colour"},
+		{"duplicate", "zones:\n  - name: Kitchen\n  - name: kitchen\n", "more than once"},
+		{"missing name", "variables:\n  - type: number\n", "without a name"},
+		{"bad type", "variables:\n  - name: x\n    type: list\n", "type must be"},
+		{"group without class", "groups:\n  - name: g\n    zone: Home\n    devices: [Lamp]\n", "class is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeSpec(t, dir, "home.yaml", tt.content)
+			_, err := Load(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoad_FlowDefinition(t *testing.T) {
+	dir := t.TempDir()
+	writeSpec(t, dir, "flows.yaml", `flows:
+  - name: Sunset
+    folder: Lights
+    enabled: false
+    trigger: {id: "homey:manager:geolocation:sunset"}
+    actions:
+      - id: "homey:device:lamp:on"
+`)
+	spec, err := Load(filepath.Join(dir, "flows.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f := spec.Flows[0]
+	if f.Folder != "Lights" || f.Enabled == nil || *f.Enabled {
+		t.Errorf("unexpected flow: %+v", f)
+	}
+	if _, ok := f.Definition["trigger"]; !ok {
+		t.Errorf("expected trigger in definition, got %v", f.Definition)
+	}
+	if _, ok := f.Definition["folder"]; ok {
+		t.Error("folder should not be part of the definition")
+	}
+}
+
+func summary(p *Plan) []string {
+	var out []string
+	for _, c := range p.Changes {
+		out = append(out, fmt.Sprintf("%s %s %s", c.Action, c.Kind, c.Name))
+	}
+	return out
+}
+
+func TestCompute_OrderAndDiffs(t *testing.T) {
+	api := newFakeHomey()
+	spec := &Spec{
+		// Bedroom is listed before its parent
+		Zones: []Zone{
+			{Name: "Bedroom", Parent: "Upstairs"},
+			{Name: "Upstairs"},
+			{Name: "Kitchen", Icon: "kitchen"},
+		},
+		Devices:   []Device{{Name: "Lamp", Zone: "Bedroom"}, {Name: "Spot", Zone: "Kitchen"}},
+		Variables: []Variable{{Name: "guests", Type: "boolean"}, {Name: "temp", Type: "number"}},
+		Flows: []Flow{{
+			Name: "Sunset",
+			Definition: map[string]interface{}{
+				"trigger": map[string]interface{}{"id": "homey:manager:geolocation:sunset"},
+				"actions": []interface{}{map[string]interface{}{"id": "homey:device:spot:on", "group": "then"}},
+			},
+		}},
+	}
+
+	plan, err := Compute(api, spec, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"create zone Upstairs",
+		"create zone Bedroom",
+		"update device Lamp",
+		"create variable temp",
+		"update flow Sunset",
+	}
+	if got := summary(plan); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected plan:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	lamp := plan.Changes[2]
+	if len(lamp.Fields) != 1 || lamp.Fields[0].From != "Home" || lamp.Fields[0].To != "Bedroom" {
+		t.Errorf("unexpected device fields: %+v", lamp.Fields)
+	}
+	flow := plan.Changes[4]
+	if len(flow.Fields) != 1 || flow.Fields[0].Field != "actions" {
+		t.Errorf("expected only actions to change, got %+v", flow.Fields)
+	}
+
+	// Without --prune, resources not in the spec are only reported
+	if len(plan.Unmanaged) == 0 {
+		t.Error("expected unmanaged resources to be reported")
+	}
+	for _, c := range plan.Changes {
+		if c.Action == ActionDelete {
+			t.Errorf("unexpected delete without prune: %+v", c)
+		}
+	}
+}
+
+func TestCompute_SubsetsAndRenames(t *testing.T) {
+	api := newFakeHomey()
+	spec := &Spec{
+		Zones:   []Zone{{Name: "Home"}, {Name: "Kitchen"}},
+		Devices: []Device{{Name: "spot", ID: "spot", Zone: "kitchen"}},
+		Moods: []Mood{{Name: "Dinner", Zone: "Kitchen", Devices: map[string]interface{}{
+			"Spot": map[string]interface{}{"onoff": true},
+		}}},
+	}
+	plan, err := Compute(api, spec, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Pinned by ID, a different case is a rename
+	if got := summary(plan); len(got) != 1 || got[0] != "update device spot" {
+		t.Errorf("unexpected plan: %v", got)
+	}
+}
+
+func TestCompute_Prune(t *testing.T) {
+	api := newFakeHomey()
+	spec := &Spec{
+		Zones:  []Zone{{Name: "Kitchen"}},
+		Groups: []Group{},
+		Flows:  []Flow{},
+		declared: map[Kind]bool{
+			KindZone:  true,
+			KindGroup: true,
+			KindFlow:  true,
+		},
+	}
+	plan, err := Compute(api, spec, Options{Prune: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Flows go first, zones last and children before parents. The root zone,
+	// devices and undeclared kinds are left alone.
+	want := []string{
+		"delete flow Old advanced",
+		"delete flow Sunset",
+		"delete group Old Group",
+		"delete zone Loft",
+		"delete zone Attic",
+	}
+	if got := summary(plan); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected plan:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCompute_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec *Spec
+		want string
+	}{
+		{"unknown parent", &Spec{Zones: []Zone{{Name: "Bedroom", Parent: "Nowhere"}}}, "zone not found: Nowhere"},
+		{"zone cycle", &Spec{Zones: []Zone{{Name: "A", Parent: "B"}, {Name: "B", Parent: "A"}}}, "inside itself"},
+		{"unpaired device", &Spec{Devices: []Device{{Name: "Toaster"}}}, "device not found: Toaster"},
+		{"group as device", &Spec{Devices: []Device{{Name: "Old Group"}}}, "is a group"},
+		{"group class", &Spec{Groups: []Group{{Name: "Old Group", Class: "socket", Zone: "Home", Devices: []string{"Lamp"}}}}, "delete it"},
+		{"variable type", &Spec{Variables: []Variable{{Name: "guests", Type: "string"}}}, "is a boolean"},
+		{"mood device", &Spec{Moods: []Mood{{Name: "Dinner", Devices: map[string]interface{}{"Toaster": map[string]interface{}{}}}}}, "device not found: Toaster"},
+		{"flow folder", &Spec{Flows: []Flow{{Name: "x", Folder: "Nowhere"}}}, "flow folder not found: Nowhere"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compute(newFakeHomey(), tt.spec, Options{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestApply_UsesCreatedIDs(t *testing.T) {
+	api := newFakeHomey()
+	spec := &Spec{
+		Zones:       []Zone{{Name: "Upstairs"}, {Name: "Bedroom", Parent: "Upstairs"}},
+		Devices:     []Device{{ID: "lamp", Name: "Bedside Lamp", Zone: "Bedroom"}},
+		Groups:      []Group{{Name: "Bedroom Lights", Class: "light", Zone: "Bedroom", Devices: []string{"Bedside Lamp"}}},
+		FlowFolders: []FlowFolder{{Name: "Night"}},
+		Flows: []Flow{{
+			Name:   "Goodnight",
+			Folder: "Night",
+			Definition: map[string]interface{}{
+				"trigger": map[string]interface{}{"id": "homey:manager:logic:x"},
+			},
+		}},
+	}
+	var prepared []string
+	plan, err := Compute(api, spec, Options{PrepareFlow: func(flow map[string]interface{}, advanced bool) error {
+		prepared = append(prepared, flow["name"].(string))
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prepared) != 1 || prepared[0] != "Goodnight" {
+		t.Errorf("expected PrepareFlow to see the flow, got %v", prepared)
+	}
+
+	var done int
+	if err := plan.Apply(api, func(Change) { done++ }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"CreateZone",
+		"CreateZone",
+		"CreateFlowFolder",
+		"UpdateDevice lamp",
+		"CreateDeviceGroup",
+		"CreateFlow",
+	}
+	if strings.Join(api.calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected calls: %v", api.calls)
+	}
+	if done != len(want) {
+		t.Errorf("expected done for each change, got %d", done)
+	}
+
+	// Upstairs is new1, Bedroom new2 under it, the folder new3
+	if api.bodies[0]["parent"] != "home" || api.bodies[1]["parent"] != "new1" {
+		t.Errorf("unexpected zone parents: %v, %v", api.bodies[0], api.bodies[1])
+	}
+	if api.bodies[3]["zone"] != "new2" || api.bodies[3]["name"] != "Bedside Lamp" {
+		t.Errorf("unexpected device update: %v", api.bodies[3])
+	}
+	group := api.bodies[4]
+	if group["zoneId"] != "new2" || fmt.Sprint(group["deviceIds"]) != "[lamp]" {
+		t.Errorf("unexpected group: %v", group)
+	}
+	if flow := api.bodies[5]; flow["folder"] != "new3" || flow["name"] != "Goodnight" {
+		t.Errorf("unexpected flow: %v", flow)
+	}
+}
+
+func TestContains(t *testing.T) {
+	have := map[string]interface{}{
+		"id":   "x",
+		"args": map[string]interface{}{"a": 1.0, "b": "two"},
+		"list": []interface{}{1.0, 2.0},
+	}
+	tests := []struct {
+		want interface{}
+		ok   bool
+	}{
+		{map[string]interface{}{"args": map[string]interface{}{"a": 1.0}}, true},
+		{map[string]interface{}{"args": map[string]interface{}{"a": 2.0}}, false},
+		{map[string]interface{}{"list": []interface{}{1.0}}, false},
+		{map[string]interface{}{"missing": "x"}, false},
+		{map[string]interface{}{}, true},
+	}
+	for i, tt := range tests {
+		if got := contains(have, tt.want); got != tt.ok {
+			t.Errorf("case %d: contains = %v, want %v", i, got, tt.ok)
+		}
+	}
+}
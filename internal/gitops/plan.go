@@ -0,0 +1,321 @@
+package gitops
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Action is what a change does to a resource
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// FieldChange is one field a change sets. From is empty for new resources.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from,omitempty"`
+	To    interface{} `json:"to,omitempty"`
+}
+
+// Change is one create, update or delete
+type Change struct {
+	Action Action        `json:"action"`
+	Kind   Kind          `json:"kind"`
+	Name   string        `json:"name"`
+	ID     string        `json:"id,omitempty"`
+	Fields []FieldChange `json:"fields,omitempty"`
+
+	// run makes the change. References are resolved through the index at
+	// that point, so they can name resources created earlier in the plan.
+	run func(api API, x *index) error
+}
+
+// Options controls how a plan is computed
+type Options struct {
+	// Prune deletes resources the spec doesn't list, for the kinds it lists
+	// at all. Devices are never deleted.
+	Prune bool
+
+	// PrepareFlow is called with every flow definition before it is compared
+	// or sent, to validate it and fill in defaults
+	PrepareFlow func(flow map[string]interface{}, advanced bool) error
+}
+
+// Plan is the list of changes that bring a Homey in line with a spec
+type Plan struct {
+	Changes []Change `json:"changes"`
+
+	// Unmanaged lists the resources that Prune would delete
+	Unmanaged []Change `json:"unmanaged,omitempty"`
+
+	index *index
+}
+
+// Counts returns how many resources the plan creates, updates and deletes
+func (p *Plan) Counts() (create, update, remove int) {
+	for _, c := range p.Changes {
+		switch c.Action {
+		case ActionCreate:
+			create++
+		case ActionUpdate:
+			update++
+		case ActionDelete:
+			remove++
+		}
+	}
+	return create, update, remove
+}
+
+// Empty reports whether the Homey already matches the spec
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Apply makes the changes in order, calling done after each one. It stops at
+// the first error; changes made before it stay made, and running plan again
+// shows what is left. A plan can only be applied once.
+func (p *Plan) Apply(api API, done func(Change)) error {
+	for i, c := range p.Changes {
+		if err := c.run(api, p.index); err != nil {
+			return fmt.Errorf("failed to %s %s %q: %w (%d of %d changes applied)", c.Action, c.Kind, c.Name, err, i, len(p.Changes))
+		}
+		if done != nil {
+			done(c)
+		}
+	}
+	p.Changes = nil
+	return nil
+}
+
+// planner holds the state of one Compute
+type planner struct {
+	spec    *Spec
+	live    *state
+	opts    Options
+	x       *index
+	changes []Change
+	deletes map[Kind][]Change
+}
+
+// Compute compares the spec with the Homey and returns the changes that
+// apply would make. Nothing is changed on the Homey.
+func Compute(api API, spec *Spec, opts Options) (*Plan, error) {
+	live, err := fetch(api, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	pl := &planner{
+		spec:    spec,
+		live:    live,
+		opts:    opts,
+		x:       newIndex(),
+		deletes: map[Kind][]Change{},
+	}
+	pl.indexLive()
+
+	steps := map[Kind]func() error{
+		KindZone:       pl.zones,
+		KindFlowFolder: pl.folders,
+		KindDevice:     pl.devices,
+		KindGroup:      pl.groups,
+		KindVariable:   pl.variables,
+		KindMood:       pl.moods,
+		KindFlow:       pl.flows,
+	}
+	for _, kind := range kindOrder {
+		if err := steps[kind](); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &Plan{Changes: pl.changes, index: pl.x}
+	for i := len(kindOrder) - 1; i >= 0; i-- {
+		deletes := pl.deletes[kindOrder[i]]
+		if opts.Prune {
+			plan.Changes = append(plan.Changes, deletes...)
+		} else {
+			plan.Unmanaged = append(plan.Unmanaged, deletes...)
+		}
+	}
+	if plan.Changes == nil {
+		plan.Changes = []Change{}
+	}
+	return plan, nil
+}
+
+// indexLive adds every live resource to the index
+func (pl *planner) indexLive() {
+	for id, z := range pl.live.zones {
+		pl.x.add(nsZone, z.Name, id)
+	}
+	for id, d := range pl.live.devices {
+		pl.x.add(nsDevice, d.Name, id)
+	}
+	for id, v := range pl.live.variables {
+		pl.x.add(nsVariable, v.Name, id)
+	}
+	for id, m := range pl.live.moods {
+		pl.x.add(nsMood, m.Name, id)
+	}
+	for id, f := range pl.live.folders {
+		pl.x.add(nsFolder, f.Name, id)
+	}
+	for id, f := range pl.live.flows {
+		pl.x.add(nsFlow, str(f, "name"), id)
+	}
+	for id, f := range pl.live.advancedFlows {
+		pl.x.add(nsAdvancedFlow, str(f, "name"), id)
+	}
+}
+
+// add queues a create or update
+func (pl *planner) add(c Change) {
+	pl.changes = append(pl.changes, c)
+}
+
+// remove queues a delete of a resource the spec doesn't list
+func (pl *planner) remove(kind Kind, name, id string, run func(api API, x *index) error) {
+	pl.deletes[kind] = append(pl.deletes[kind], Change{
+		Action: ActionDelete,
+		Kind:   kind,
+		Name:   name,
+		ID:     id,
+		run:    run,
+	})
+}
+
+// parentsFirst orders items so every parent that is also in the list comes
+// before its children. Otherwise the list order is kept.
+func parentsFirst(kind Kind, names []string, parent func(i int) string) ([]int, error) {
+	pos := map[string]int{}
+	for i, name := range names {
+		pos[strings.ToLower(name)] = i
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	mark := make([]int, len(names))
+	order := make([]int, 0, len(names))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch mark[i] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("%s %q is inside itself", kind, names[i])
+		}
+		mark[i] = visiting
+		if p, ok := pos[strings.ToLower(parent(i))]; ok && parent(i) != "" {
+			if err := visit(p); err != nil {
+				return err
+			}
+		}
+		mark[i] = done
+		order = append(order, i)
+		return nil
+	}
+	for i := range names {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// depth returns how many ancestors an item has, to delete children first
+func depth(id string, parentOf func(id string) string) int {
+	n := 0
+	seen := map[string]bool{}
+	for p := parentOf(id); p != "" && !seen[p]; p = parentOf(p) {
+		seen[p] = true
+		n++
+	}
+	return n
+}
+
+// sortDeletes orders deletes deepest first, then by name
+func sortDeletes(changes []Change, parentOf func(id string) string) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		di, dj := depth(changes[i].ID, parentOf), depth(changes[j].ID, parentOf)
+		if di != dj {
+			return di > dj
+		}
+		return strings.ToLower(changes[i].Name) < strings.ToLower(changes[j].Name)
+	})
+}
+
+// byName sorts deletes by name so plans read the same every run
+func byName(changes []Change) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		return strings.ToLower(changes[i].Name) < strings.ToLower(changes[j].Name)
+	})
+}
+
+// normalize round-trips v through JSON so values read from YAML compare
+// equal to the same values read from the Homey
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// contains reports whether have matches want in every field want sets.
+// Fields want leaves out are ignored, so a spec only needs to list what it
+// cares about; lists must match element for element.
+func contains(have, want interface{}) bool {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		h, ok := have.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range w {
+			if !contains(h[k], v) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		h, ok := have.([]interface{})
+		if !ok || len(h) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !contains(h[i], w[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return have == want
+	}
+}
+
+// createdID reads the ID of a resource from Homey's create response
+func createdID(data json.RawMessage) (string, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if created.ID == "" {
+		return "", fmt.Errorf("Homey returned no ID")
+	}
+	return created.ID, nil
+}
@@ -0,0 +1,909 @@
+package gitops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// zones plans zone changes. Parents are created before their children.
+func (pl *planner) zones() error {
+	zones := pl.spec.Zones
+	root := pl.rootZone()
+
+	ids, matched, err := pl.match(nsZone, len(zones), func(i int) string { return zones[i].Name })
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(zones))
+	for i, z := range zones {
+		names[i] = z.Name
+	}
+	order, err := parentsFirst(KindZone, names, func(i int) string { return zones[i].Parent })
+	if err != nil {
+		return err
+	}
+
+	for _, i := range order {
+		z := zones[i]
+		if z.Parent != "" {
+			if err := pl.x.check(nsZone, z.Parent); err != nil {
+				return fmt.Errorf("zone %q: parent %w", z.Name, err)
+			}
+		} else if root == "" && (ids[i] == "" || pl.live.zones[ids[i]].Parent != "") {
+			return fmt.Errorf("zone %q: no parent given and the Homey has no root zone", z.Name)
+		}
+		parentName := z.Parent
+		if parentName == "" {
+			parentName = pl.live.zones[root].Name
+		}
+
+		if ids[i] == "" {
+			icon := z.Icon
+			if icon == "" {
+				icon = "default"
+			}
+			pl.add(Change{
+				Action: ActionCreate,
+				Kind:   KindZone,
+				Name:   z.Name,
+				Fields: []FieldChange{{Field: "parent", To: parentName}, {Field: "icon", To: icon}},
+				run: func(api API, x *index) error {
+					parent, err := zoneParentID(x, z.Parent, root)
+					if err != nil {
+						return err
+					}
+					data, err := api.CreateZone(map[string]interface{}{
+						"name":   z.Name,
+						"parent": parent,
+						"icon":   icon,
+					})
+					if err != nil {
+						return err
+					}
+					id, err := createdID(data)
+					if err != nil {
+						return err
+					}
+					x.set(nsZone, z.Name, id)
+					return nil
+				},
+			})
+			continue
+		}
+
+		id := ids[i]
+		live := pl.live.zones[id]
+		if live.Parent == "" && z.Parent != "" {
+			return fmt.Errorf("zone %q is the root zone and can't have a parent", z.Name)
+		}
+
+		var fields []FieldChange
+		moveParent := live.Parent != "" && !strings.EqualFold(pl.zoneName(live.Parent), parentName)
+		if moveParent {
+			fields = append(fields, FieldChange{Field: "parent", From: pl.zoneName(live.Parent), To: parentName})
+		}
+		setIcon := z.Icon != "" && z.Icon != live.Icon
+		if setIcon {
+			fields = append(fields, FieldChange{Field: "icon", From: live.Icon, To: z.Icon})
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		pl.add(Change{
+			Action: ActionUpdate,
+			Kind:   KindZone,
+			Name:   z.Name,
+			ID:     id,
+			Fields: fields,
+			run: func(api API, x *index) error {
+				updates := map[string]interface{}{}
+				if moveParent {
+					parent, err := zoneParentID(x, z.Parent, root)
+					if err != nil {
+						return err
+					}
+					updates["parent"] = parent
+				}
+				if setIcon {
+					updates["icon"] = z.Icon
+				}
+				return api.UpdateZone(id, updates)
+			},
+		})
+	}
+
+	if !pl.spec.declares(KindZone) {
+		return nil
+	}
+	for id, z := range pl.live.zones {
+		// The root zone can't be deleted
+		if matched[id] || z.Parent == "" {
+			continue
+		}
+		pl.remove(KindZone, z.Name, id, func(api API, x *index) error {
+			return api.DeleteZone(id)
+		})
+	}
+	sortDeletes(pl.deletes[KindZone], func(id string) string { return pl.live.zones[id].Parent })
+	return nil
+}
+
+// rootZone returns the ID of the zone without a parent
+func (pl *planner) rootZone() string {
+	var root string
+	for id, z := range pl.live.zones {
+		if z.Parent == "" && (root == "" || id < root) {
+			root = id
+		}
+	}
+	return root
+}
+
+// zoneParentID resolves a zone's parent, which is the root zone if none is given
+func zoneParentID(x *index, parent, root string) (string, error) {
+	if parent == "" {
+		return root, nil
+	}
+	return x.resolve(nsZone, parent)
+}
+
+// folders plans flow folder changes. Parents are created before their children.
+func (pl *planner) folders() error {
+	folders := pl.spec.FlowFolders
+
+	ids, matched, err := pl.match(nsFolder, len(folders), func(i int) string { return folders[i].Name })
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(folders))
+	for i, f := range folders {
+		names[i] = f.Name
+	}
+	order, err := parentsFirst(KindFlowFolder, names, func(i int) string { return folders[i].Parent })
+	if err != nil {
+		return err
+	}
+
+	for _, i := range order {
+		f := folders[i]
+		if f.Parent != "" {
+			if err := pl.x.check(nsFolder, f.Parent); err != nil {
+				return fmt.Errorf("flow folder %q: parent %w", f.Name, err)
+			}
+		}
+
+		if ids[i] == "" {
+			var fields []FieldChange
+			if f.Parent != "" {
+				fields = append(fields, FieldChange{Field: "parent", To: f.Parent})
+			}
+			pl.add(Change{
+				Action: ActionCreate,
+				Kind:   KindFlowFolder,
+				Name:   f.Name,
+				Fields: fields,
+				run: func(api API, x *index) error {
+					folder := map[string]interface{}{"name": f.Name}
+					if f.Parent != "" {
+						parent, err := x.resolve(nsFolder, f.Parent)
+						if err != nil {
+							return err
+						}
+						folder["parent"] = parent
+					}
+					data, err := api.CreateFlowFolder(folder)
+					if err != nil {
+						return err
+					}
+					id, err := createdID(data)
+					if err != nil {
+						return err
+					}
+					x.set(nsFolder, f.Name, id)
+					return nil
+				},
+			})
+			continue
+		}
+
+		id := ids[i]
+		live := pl.live.folders[id]
+		if strings.EqualFold(pl.folderName(live.Parent), f.Parent) {
+			continue
+		}
+		pl.add(Change{
+			Action: ActionUpdate,
+			Kind:   KindFlowFolder,
+			Name:   f.Name,
+			ID:     id,
+			Fields: []FieldChange{{Field: "parent", From: pl.folderName(live.Parent), To: f.Parent}},
+			run: func(api API, x *index) error {
+				parent, err := folderID(x, f.Parent)
+				if err != nil {
+					return err
+				}
+				return api.UpdateFlowFolder(id, map[string]interface{}{"parent": parent})
+			},
+		})
+	}
+
+	if !pl.spec.declares(KindFlowFolder) {
+		return nil
+	}
+	for id, f := range pl.live.folders {
+		if matched[id] {
+			continue
+		}
+		pl.remove(KindFlowFolder, f.Name, id, func(api API, x *index) error {
+			return api.DeleteFlowFolder(id)
+		})
+	}
+	sortDeletes(pl.deletes[KindFlowFolder], func(id string) string { return pl.live.folders[id].Parent })
+	return nil
+}
+
+// folderID resolves a folder name, where no name means the top level
+func folderID(x *index, name string) (interface{}, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return x.resolve(nsFolder, name)
+}
+
+// devices plans renames and zone moves of paired devices
+func (pl *planner) devices() error {
+	for _, d := range pl.spec.Devices {
+		id := d.ID
+		if id == "" {
+			found, ok, err := pl.x.lookup(nsDevice, d.Name)
+			if err != nil {
+				return err
+			}
+			if !ok || found == "" {
+				return fmt.Errorf("device not found: %s (devices are paired in the Homey app, apply can't create them)", d.Name)
+			}
+			id = found
+		}
+		dev, ok := pl.live.devices[id]
+		if !ok {
+			return fmt.Errorf("device not found: %s", id)
+		}
+		if dev.VirtualClass == "group" {
+			return fmt.Errorf("device %q is a group, list it under groups", d.Name)
+		}
+		if d.Zone != "" {
+			if err := pl.x.check(nsZone, d.Zone); err != nil {
+				return fmt.Errorf("device %q: %w", d.Name, err)
+			}
+		}
+
+		var fields []FieldChange
+		rename := d.Name != dev.Name
+		if rename {
+			fields = append(fields, FieldChange{Field: "name", From: dev.Name, To: d.Name})
+			pl.x.forget(nsDevice, dev.Name)
+			pl.x.set(nsDevice, d.Name, id)
+		}
+		move := d.Zone != "" && !strings.EqualFold(pl.zoneName(dev.Zone), d.Zone)
+		if move {
+			fields = append(fields, FieldChange{Field: "zone", From: pl.zoneName(dev.Zone), To: d.Zone})
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		pl.add(Change{
+			Action: ActionUpdate,
+			Kind:   KindDevice,
+			Name:   d.Name,
+			ID:     id,
+			Fields: fields,
+			run: func(api API, x *index) error {
+				updates := map[string]interface{}{}
+				if rename {
+					updates["name"] = d.Name
+				}
+				if move {
+					zone, err := x.resolve(nsZone, d.Zone)
+					if err != nil {
+						return err
+					}
+					updates["zone"] = zone
+				}
+				return api.UpdateDevice(id, updates)
+			},
+		})
+	}
+	return nil
+}
+
+// groups plans device group changes. A group's class can't change, so that
+// takes deleting it first.
+func (pl *planner) groups() error {
+	matched := map[string]bool{}
+	for _, g := range pl.spec.Groups {
+		if err := pl.x.check(nsZone, g.Zone); err != nil {
+			return fmt.Errorf("group %q: %w", g.Name, err)
+		}
+		for _, name := range g.Devices {
+			if err := pl.x.check(nsDevice, name); err != nil {
+				return fmt.Errorf("group %q: %w", g.Name, err)
+			}
+		}
+
+		id, ok, err := pl.x.lookup(nsDevice, g.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			pl.x.set(nsDevice, g.Name, "")
+			pl.add(Change{
+				Action: ActionCreate,
+				Kind:   KindGroup,
+				Name:   g.Name,
+				Fields: []FieldChange{
+					{Field: "class", To: g.Class},
+					{Field: "zone", To: g.Zone},
+					{Field: "devices", To: strings.Join(g.Devices, ", ")},
+				},
+				run: func(api API, x *index) error {
+					zone, err := x.resolve(nsZone, g.Zone)
+					if err != nil {
+						return err
+					}
+					members, err := resolveAll(x, nsDevice, g.Devices)
+					if err != nil {
+						return err
+					}
+					data, err := api.CreateDeviceGroup(map[string]interface{}{
+						"name":      g.Name,
+						"class":     g.Class,
+						"zoneId":    zone,
+						"deviceIds": members,
+					})
+					if err != nil {
+						return err
+					}
+					id, err := createdID(data)
+					if err != nil {
+						return err
+					}
+					x.set(nsDevice, g.Name, id)
+					return nil
+				},
+			})
+			continue
+		}
+
+		dev := pl.live.devices[id]
+		if dev.VirtualClass != "group" {
+			return fmt.Errorf("group %q: there is a device with that name that isn't a group", g.Name)
+		}
+		if !strings.EqualFold(dev.Class, g.Class) {
+			return fmt.Errorf("group %q is a %s group on the Homey; delete it to make it a %s group", g.Name, dev.Class, g.Class)
+		}
+		matched[id] = true
+
+		var fields []FieldChange
+		move := !strings.EqualFold(pl.zoneName(dev.Zone), g.Zone)
+		if move {
+			fields = append(fields, FieldChange{Field: "zone", From: pl.zoneName(dev.Zone), To: g.Zone})
+		}
+		setMembers := !pl.sameMembers(dev.Devices, g.Devices)
+		if setMembers {
+			fields = append(fields, FieldChange{Field: "devices", From: pl.deviceNames(dev.Devices), To: strings.Join(g.Devices, ", ")})
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		pl.add(Change{
+			Action: ActionUpdate,
+			Kind:   KindGroup,
+			Name:   g.Name,
+			ID:     id,
+			Fields: fields,
+			run: func(api API, x *index) error {
+				if move {
+					zone, err := x.resolve(nsZone, g.Zone)
+					if err != nil {
+						return err
+					}
+					if err := api.UpdateDevice(id, map[string]interface{}{"zone": zone}); err != nil {
+						return err
+					}
+				}
+				if setMembers {
+					members, err := resolveAll(x, nsDevice, g.Devices)
+					if err != nil {
+						return err
+					}
+					return api.UpdateDeviceGroup(id, map[string]interface{}{"deviceIds": members})
+				}
+				return nil
+			},
+		})
+	}
+
+	if !pl.spec.declares(KindGroup) {
+		return nil
+	}
+	for id, d := range pl.live.devices {
+		if d.VirtualClass != "group" || matched[id] {
+			continue
+		}
+		pl.remove(KindGroup, d.Name, id, func(api API, x *index) error {
+			return api.DeleteDevice(id)
+		})
+	}
+	byName(pl.deletes[KindGroup])
+	return nil
+}
+
+// sameMembers reports whether a group's device IDs are the named devices
+func (pl *planner) sameMembers(ids []string, names []string) bool {
+	if len(ids) != len(names) {
+		return false
+	}
+	have := map[string]bool{}
+	for _, id := range ids {
+		have[id] = true
+	}
+	for _, name := range names {
+		id, _, _ := pl.x.lookup(nsDevice, name)
+		if id == "" || !have[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveAll resolves a list of names
+func resolveAll(x *index, ns namespace, names []string) ([]string, error) {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id, err := x.resolve(ns, name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// variables plans logic variables. Existing variables are left alone unless
+// their type differs, which takes deleting them first.
+func (pl *planner) variables() error {
+	matched := map[string]bool{}
+	for _, v := range pl.spec.Variables {
+		id, ok, err := pl.x.lookup(nsVariable, v.Name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if live := pl.live.variables[id]; live.Type != v.Type {
+				return fmt.Errorf("variable %q is a %s on the Homey; delete it to make it a %s", v.Name, live.Type, v.Type)
+			}
+			matched[id] = true
+			continue
+		}
+
+		value := v.Value
+		if value == nil {
+			switch v.Type {
+			case "boolean":
+				value = false
+			case "number":
+				value = 0
+			default:
+				value = ""
+			}
+		}
+		pl.x.set(nsVariable, v.Name, "")
+		pl.add(Change{
+			Action: ActionCreate,
+			Kind:   KindVariable,
+			Name:   v.Name,
+			Fields: []FieldChange{{Field: "type", To: v.Type}, {Field: "value", To: value}},
+			run: func(api API, x *index) error {
+				data, err := api.CreateVariable(v.Name, v.Type, value)
+				if err != nil {
+					return err
+				}
+				id, err := createdID(data)
+				if err != nil {
+					return err
+				}
+				x.set(nsVariable, v.Name, id)
+				return nil
+			},
+		})
+	}
+
+	if !pl.spec.declares(KindVariable) {
+		return nil
+	}
+	for id, v := range pl.live.variables {
+		if matched[id] {
+			continue
+		}
+		pl.remove(KindVariable, v.Name, id, func(api API, x *index) error {
+			return api.DeleteVariable(id)
+		})
+	}
+	byName(pl.deletes[KindVariable])
+	return nil
+}
+
+// moods plans mood changes. Device states only need to list the fields the
+// spec cares about.
+func (pl *planner) moods() error {
+	matched := map[string]bool{}
+	for _, m := range pl.spec.Moods {
+		if m.Zone != "" {
+			if err := pl.x.check(nsZone, m.Zone); err != nil {
+				return fmt.Errorf("mood %q: %w", m.Name, err)
+			}
+		}
+		want := map[string]interface{}{}
+		deviceNames := make([]string, 0, len(m.Devices))
+		for name, st := range m.Devices {
+			if err := pl.x.check(nsDevice, name); err != nil {
+				return fmt.Errorf("mood %q: %w", m.Name, err)
+			}
+			v, err := normalize(st)
+			if err != nil {
+				return fmt.Errorf("mood %q: device %q: %w", m.Name, name, err)
+			}
+			want[name] = v
+			deviceNames = append(deviceNames, name)
+		}
+		sort.Strings(deviceNames)
+
+		devices := func(x *index) (map[string]interface{}, error) {
+			byID := map[string]interface{}{}
+			for name, st := range want {
+				id, err := x.resolve(nsDevice, name)
+				if err != nil {
+					return nil, err
+				}
+				byID[id] = st
+			}
+			return byID, nil
+		}
+
+		id, ok, err := pl.x.lookup(nsMood, m.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			var fields []FieldChange
+			if m.Zone != "" {
+				fields = append(fields, FieldChange{Field: "zone", To: m.Zone})
+			}
+			fields = append(fields, FieldChange{Field: "devices", To: strings.Join(deviceNames, ", ")})
+			pl.x.set(nsMood, m.Name, "")
+			pl.add(Change{
+				Action: ActionCreate,
+				Kind:   KindMood,
+				Name:   m.Name,
+				Fields: fields,
+				run: func(api API, x *index) error {
+					mood := map[string]interface{}{"name": m.Name}
+					if m.Zone != "" {
+						zone, err := x.resolve(nsZone, m.Zone)
+						if err != nil {
+							return err
+						}
+						mood["zone"] = zone
+					}
+					byID, err := devices(x)
+					if err != nil {
+						return err
+					}
+					mood["devices"] = byID
+					data, err := api.CreateMood(mood)
+					if err != nil {
+						return err
+					}
+					id, err := createdID(data)
+					if err != nil {
+						return err
+					}
+					x.set(nsMood, m.Name, id)
+					return nil
+				},
+			})
+			continue
+		}
+
+		live := pl.live.moods[id]
+		matched[id] = true
+
+		var fields []FieldChange
+		move := m.Zone != "" && !strings.EqualFold(pl.zoneName(live.Zone), m.Zone)
+		if move {
+			fields = append(fields, FieldChange{Field: "zone", From: pl.zoneName(live.Zone), To: m.Zone})
+		}
+		var changed []string
+		if m.Devices != nil {
+			wanted := map[string]bool{}
+			for _, name := range deviceNames {
+				devID, _, _ := pl.x.lookup(nsDevice, name)
+				wanted[devID] = true
+				have, ok := live.Devices[devID]
+				switch {
+				case devID == "" || !ok:
+					changed = append(changed, name+" added")
+				case !contains(have, want[name]):
+					changed = append(changed, name+" changed")
+				}
+			}
+			var removed []string
+			for devID := range live.Devices {
+				if !wanted[devID] {
+					removed = append(removed, pl.deviceName(devID)+" removed")
+				}
+			}
+			sort.Strings(removed)
+			changed = append(changed, removed...)
+		}
+		if len(changed) > 0 {
+			fields = append(fields, FieldChange{Field: "devices", To: strings.Join(changed, ", ")})
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		pl.add(Change{
+			Action: ActionUpdate,
+			Kind:   KindMood,
+			Name:   m.Name,
+			ID:     id,
+			Fields: fields,
+			run: func(api API, x *index) error {
+				updates := map[string]interface{}{}
+				if move {
+					zone, err := x.resolve(nsZone, m.Zone)
+					if err != nil {
+						return err
+					}
+					updates["zone"] = zone
+				}
+				if len(changed) > 0 {
+					byID, err := devices(x)
+					if err != nil {
+						return err
+					}
+					updates["devices"] = byID
+				}
+				return api.UpdateMood(id, updates)
+			},
+		})
+	}
+
+	if !pl.spec.declares(KindMood) {
+		return nil
+	}
+	for id, m := range pl.live.moods {
+		if matched[id] {
+			continue
+		}
+		pl.remove(KindMood, m.Name, id, func(api API, x *index) error {
+			return api.DeleteMood(id)
+		})
+	}
+	byName(pl.deletes[KindMood])
+	return nil
+}
+
+// flows plans simple and advanced flows. The definition only needs to list
+// the fields the spec cares about; flows are matched by name.
+func (pl *planner) flows() error {
+	matched := map[string]bool{}
+	for _, f := range pl.spec.Flows {
+		ns, liveFlows := nsFlow, pl.live.flows
+		create, update := API.CreateFlow, API.UpdateFlow
+		if f.Advanced {
+			ns, liveFlows = nsAdvancedFlow, pl.live.advancedFlows
+			create, update = API.CreateAdvancedFlow, API.UpdateAdvancedFlow
+		}
+
+		v, err := normalize(f.Definition)
+		if err != nil {
+			return fmt.Errorf("flow %q: %w", f.Name, err)
+		}
+		body, _ := v.(map[string]interface{})
+		if body == nil {
+			body = map[string]interface{}{}
+		}
+		delete(body, "id")
+		body["name"] = f.Name
+		if pl.opts.PrepareFlow != nil {
+			if err := pl.opts.PrepareFlow(body, f.Advanced); err != nil {
+				return fmt.Errorf("flow %q: %w", f.Name, err)
+			}
+		}
+		if f.Folder != "" {
+			if err := pl.x.check(nsFolder, f.Folder); err != nil {
+				return fmt.Errorf("flow %q: %w", f.Name, err)
+			}
+		}
+
+		id, ok, err := pl.x.lookup(ns, f.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			var fields []FieldChange
+			if f.Advanced {
+				fields = append(fields, FieldChange{Field: "advanced", To: true})
+			}
+			if f.Folder != "" {
+				fields = append(fields, FieldChange{Field: "folder", To: f.Folder})
+			}
+			if f.Enabled != nil {
+				fields = append(fields, FieldChange{Field: "enabled", To: *f.Enabled})
+			}
+			pl.x.set(ns, f.Name, "")
+			pl.add(Change{
+				Action: ActionCreate,
+				Kind:   KindFlow,
+				Name:   f.Name,
+				Fields: fields,
+				run: func(api API, x *index) error {
+					flow, err := flowPayload(x, f, body, f.Folder != "")
+					if err != nil {
+						return err
+					}
+					data, err := create(api, flow)
+					if err != nil {
+						return err
+					}
+					id, err := createdID(data)
+					if err != nil {
+						return err
+					}
+					x.set(ns, f.Name, id)
+					return nil
+				},
+			})
+			continue
+		}
+
+		live := liveFlows[id]
+		matched[id] = true
+
+		var fields []FieldChange
+		liveFolder := pl.folderName(str(live, "folder"))
+		move := !strings.EqualFold(liveFolder, f.Folder)
+		if move {
+			fields = append(fields, FieldChange{Field: "folder", From: liveFolder, To: f.Folder})
+		}
+		if enabled, _ := live["enabled"].(bool); f.Enabled != nil && enabled != *f.Enabled {
+			fields = append(fields, FieldChange{Field: "enabled", From: enabled, To: *f.Enabled})
+		}
+		keys := make([]string, 0, len(body))
+		for k := range body {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !contains(live[k], body[k]) {
+				fields = append(fields, FieldChange{Field: k, To: "changed"})
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		pl.add(Change{
+			Action: ActionUpdate,
+			Kind:   KindFlow,
+			Name:   f.Name,
+			ID:     id,
+			Fields: fields,
+			run: func(api API, x *index) error {
+				flow, err := flowPayload(x, f, body, move)
+				if err != nil {
+					return err
+				}
+				_, err = update(api, id, flow)
+				return err
+			},
+		})
+	}
+
+	if !pl.spec.declares(KindFlow) {
+		return nil
+	}
+	for id, f := range pl.live.flows {
+		if !matched[id] {
+			pl.remove(KindFlow, str(f, "name"), id, func(api API, x *index) error {
+				return api.DeleteFlow(id)
+			})
+		}
+	}
+	for id, f := range pl.live.advancedFlows {
+		if !matched[id] {
+			pl.remove(KindFlow, str(f, "name"), id, func(api API, x *index) error {
+				return api.DeleteAdvancedFlow(id)
+			})
+		}
+	}
+	byName(pl.deletes[KindFlow])
+	return nil
+}
+
+// flowPayload builds the body sent to Homey for a flow
+func flowPayload(x *index, f Flow, body map[string]interface{}, setFolder bool) (map[string]interface{}, error) {
+	flow := make(map[string]interface{}, len(body)+2)
+	for k, v := range body {
+		flow[k] = v
+	}
+	if setFolder {
+		folder, err := folderID(x, f.Folder)
+		if err != nil {
+			return nil, err
+		}
+		flow["folder"] = folder
+	}
+	if f.Enabled != nil {
+		flow["enabled"] = *f.Enabled
+	}
+	return flow, nil
+}
+
+// match looks up each of n names on the Homey. It returns the ID for each one
+// found, empty for the rest, which are added to the index as to be created.
+func (pl *planner) match(ns namespace, n int, name func(i int) string) ([]string, map[string]bool, error) {
+	ids := make([]string, n)
+	matched := map[string]bool{}
+	for i := 0; i < n; i++ {
+		id, ok, err := pl.x.lookup(ns, name(i))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			ids[i] = id
+			matched[id] = true
+		}
+	}
+	for i := 0; i < n; i++ {
+		if ids[i] == "" {
+			pl.x.set(ns, name(i), "")
+		}
+	}
+	return ids, matched, nil
+}
+
+// zoneName returns the name of a live zone
+func (pl *planner) zoneName(id string) string {
+	if z, ok := pl.live.zones[id]; ok {
+		return z.Name
+	}
+	return id
+}
+
+// folderName returns the name of a live flow folder
+func (pl *planner) folderName(id string) string {
+	if f, ok := pl.live.folders[id]; ok {
+		return f.Name
+	}
+	return id
+}
+
+// deviceName returns the name of a live device
+func (pl *planner) deviceName(id string) string {
+	if d, ok := pl.live.devices[id]; ok {
+		return d.Name
+	}
+	return id
+}
+
+// deviceNames lists the names of live devices, sorted
+func (pl *planner) deviceNames(ids []string) string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, pl.deviceName(id))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
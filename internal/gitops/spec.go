@@ -0,0 +1,338 @@
+// Package gitops keeps a Homey in line with a description of the home kept
+// in YAML files, typically in a git repository. Compute compares the files
+// with the live Homey and returns a Plan; Plan.Apply makes the changes.
+package gitops
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Kind is a type of resource the spec manages
+type Kind string
+
+const (
+	KindZone       Kind = "zone"
+	KindFlowFolder Kind = "flowFolder"
+	KindDevice     Kind = "device"
+	KindGroup      Kind = "group"
+	KindVariable   Kind = "variable"
+	KindMood       Kind = "mood"
+	KindFlow       Kind = "flow"
+)
+
+// kindOrder is the order resources are created and updated in, so everything
+// a resource refers to exists before it. Deletes run in reverse.
+var kindOrder = []Kind{KindZone, KindFlowFolder, KindDevice, KindGroup, KindVariable, KindMood, KindFlow}
+
+// Spec is the desired state of a Homey. Resources refer to each other by name.
+type Spec struct {
+	Zones       []Zone       `yaml:"zones,omitempty"`
+	FlowFolders []FlowFolder `yaml:"flowFolders,omitempty"`
+	Devices     []Device     `yaml:"devices,omitempty"`
+	Groups      []Group      `yaml:"groups,omitempty"`
+	Variables   []Variable   `yaml:"variables,omitempty"`
+	Moods       []Mood       `yaml:"moods,omitempty"`
+	Flows       []Flow       `yaml:"flows,omitempty"`
+
+	// declared holds the kinds that appear in the files, even as an empty
+	// list. Pruning only deletes resources of these kinds.
+	declared map[Kind]bool
+}
+
+// Zone is a zone. An empty parent puts it directly under the root zone.
+type Zone struct {
+	Name   string `yaml:"name"`
+	Parent string `yaml:"parent,omitempty"`
+	Icon   string `yaml:"icon,omitempty"`
+}
+
+// FlowFolder is a flow folder. An empty parent puts it at the top level.
+type FlowFolder struct {
+	Name   string `yaml:"name"`
+	Parent string `yaml:"parent,omitempty"`
+}
+
+// Device sets the name and zone of a paired device. Devices can't be created
+// or deleted this way. With an ID the device can be renamed; without one it
+// is found by name.
+type Device struct {
+	ID   string `yaml:"id,omitempty"`
+	Name string `yaml:"name"`
+	Zone string `yaml:"zone,omitempty"`
+}
+
+// Group is a device group
+type Group struct {
+	Name    string   `yaml:"name"`
+	Class   string   `yaml:"class"`
+	Zone    string   `yaml:"zone"`
+	Devices []string `yaml:"devices"`
+}
+
+// Variable is a logic variable. Value is only used when the variable is
+// created; after that it belongs to the flows that set it.
+type Variable struct {
+	Name  string      `yaml:"name"`
+	Type  string      `yaml:"type"`
+	Value interface{} `yaml:"value,omitempty"`
+}
+
+// Mood is a mood. Devices maps device names to their state, in the same
+// shape 'homeyctl moods get' prints.
+type Mood struct {
+	Name    string                 `yaml:"name"`
+	Zone    string                 `yaml:"zone,omitempty"`
+	Devices map[string]interface{} `yaml:"devices,omitempty"`
+}
+
+// Flow is a simple or advanced flow. Definition holds the remaining fields
+// as 'homeyctl flows get' prints them: trigger, conditions and actions, or
+// cards for an advanced flow. An empty folder puts the flow at the top level.
+type Flow struct {
+	Name       string                 `yaml:"name"`
+	Folder     string                 `yaml:"folder,omitempty"`
+	Enabled    *bool                  `yaml:"enabled,omitempty"`
+	Advanced   bool                   `yaml:"advanced,omitempty"`
+	Definition map[string]interface{} `yaml:",inline"`
+}
+
+// specFile is one YAML document. Lists are pointers so a kind written as
+// an empty list can be told apart from one that is left out.
+type specFile struct {
+	Zones       *[]Zone       `yaml:"zones"`
+	FlowFolders *[]FlowFolder `yaml:"flowFolders"`
+	Devices     *[]Device     `yaml:"devices"`
+	Groups      *[]Group      `yaml:"groups"`
+	Variables   *[]Variable   `yaml:"variables"`
+	Moods       *[]Mood       `yaml:"moods"`
+	Flows       *[]Flow       `yaml:"flows"`
+}
+
+// Load reads a spec from a YAML file, or from every .yaml and .yml file in a
+// directory tree. Files are merged, so a home can be split up any way that
+// suits it, e.g. one file per floor.
+func Load(path string) (*Spec, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// Skip .git and friends
+				if p != path && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ext := filepath.Ext(p); ext == ".yaml" || ext == ".yml" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no .yaml or .yml files in %s", path)
+		}
+	}
+
+	spec := &Spec{declared: map[Kind]bool{}}
+	for _, file := range files {
+		if err := spec.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// loadFile merges every document in a YAML file into s
+func (s *Spec) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	for {
+		var doc specFile
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		s.merge(doc)
+	}
+}
+
+// merge appends a document's resources to s
+func (s *Spec) merge(doc specFile) {
+	if s.declared == nil {
+		s.declared = map[Kind]bool{}
+	}
+	if doc.Zones != nil {
+		s.Zones = append(s.Zones, *doc.Zones...)
+		s.declared[KindZone] = true
+	}
+	if doc.FlowFolders != nil {
+		s.FlowFolders = append(s.FlowFolders, *doc.FlowFolders...)
+		s.declared[KindFlowFolder] = true
+	}
+	if doc.Devices != nil {
+		s.Devices = append(s.Devices, *doc.Devices...)
+		s.declared[KindDevice] = true
+	}
+	if doc.Groups != nil {
+		s.Groups = append(s.Groups, *doc.Groups...)
+		s.declared[KindGroup] = true
+	}
+	if doc.Variables != nil {
+		s.Variables = append(s.Variables, *doc.Variables...)
+		s.declared[KindVariable] = true
+	}
+	if doc.Moods != nil {
+		s.Moods = append(s.Moods, *doc.Moods...)
+		s.declared[KindMood] = true
+	}
+	if doc.Flows != nil {
+		s.Flows = append(s.Flows, *doc.Flows...)
+		s.declared[KindFlow] = true
+	}
+}
+
+// declares reports whether the files mention a kind at all
+func (s *Spec) declares(kind Kind) bool {
+	if s.declared == nil {
+		// Built in code rather than loaded: whatever has entries
+		switch kind {
+		case KindZone:
+			return len(s.Zones) > 0
+		case KindFlowFolder:
+			return len(s.FlowFolders) > 0
+		case KindDevice:
+			return len(s.Devices) > 0
+		case KindGroup:
+			return len(s.Groups) > 0
+		case KindVariable:
+			return len(s.Variables) > 0
+		case KindMood:
+			return len(s.Moods) > 0
+		case KindFlow:
+			return len(s.Flows) > 0
+		}
+		return false
+	}
+	return s.declared[kind]
+}
+
+// validate checks the spec on its own, before it is compared with a Homey
+func (s *Spec) validate() error {
+	var names []string
+	for _, z := range s.Zones {
+		names = append(names, z.Name)
+	}
+	if err := checkNames(KindZone, names); err != nil {
+		return err
+	}
+
+	names = names[:0]
+	for _, f := range s.FlowFolders {
+		names = append(names, f.Name)
+	}
+	if err := checkNames(KindFlowFolder, names); err != nil {
+		return err
+	}
+
+	names = names[:0]
+	for _, d := range s.Devices {
+		names = append(names, d.Name)
+	}
+	for _, g := range s.Groups {
+		if g.Class == "" {
+			return fmt.Errorf("group %q: class is required", g.Name)
+		}
+		if g.Zone == "" {
+			return fmt.Errorf("group %q: zone is required", g.Name)
+		}
+		if len(g.Devices) == 0 {
+			return fmt.Errorf("group %q: at least one device is required", g.Name)
+		}
+		names = append(names, g.Name)
+	}
+	// Groups are devices too, so they share one namespace
+	if err := checkNames(KindDevice, names); err != nil {
+		return err
+	}
+
+	names = names[:0]
+	for _, v := range s.Variables {
+		switch v.Type {
+		case "boolean", "number", "string":
+		default:
+			return fmt.Errorf("variable %q: type must be boolean, number or string, got %q", v.Name, v.Type)
+		}
+		names = append(names, v.Name)
+	}
+	if err := checkNames(KindVariable, names); err != nil {
+		return err
+	}
+
+	names = names[:0]
+	for _, m := range s.Moods {
+		names = append(names, m.Name)
+	}
+	if err := checkNames(KindMood, names); err != nil {
+		return err
+	}
+
+	var simple, advanced []string
+	for _, f := range s.Flows {
+		if f.Advanced {
+			advanced = append(advanced, f.Name)
+		} else {
+			simple = append(simple, f.Name)
+		}
+	}
+	if err := checkNames(KindFlow, simple); err != nil {
+		return err
+	}
+	return checkNames(KindFlow, advanced)
+}
+
+// checkNames fails on empty names and on names used twice. Homey matches
+// names without regard to case, so the spec does too.
+func checkNames(kind Kind, names []string) error {
+	seen := map[string]bool{}
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%s without a name", kind)
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			return fmt.Errorf("%s %q is listed more than once", kind, name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
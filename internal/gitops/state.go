@@ -0,0 +1,243 @@
+package gitops
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// API is the part of the Homey client that plan and apply use.
+// *client.Client satisfies it.
+type API interface {
+	GetZones() (json.RawMessage, error)
+	CreateZone(zone map[string]interface{}) (json.RawMessage, error)
+	UpdateZone(id string, updates map[string]interface{}) error
+	DeleteZone(id string) error
+
+	GetDevices() (json.RawMessage, error)
+	UpdateDevice(id string, updates map[string]interface{}) error
+	DeleteDevice(id string) error
+	CreateDeviceGroup(group map[string]interface{}) (json.RawMessage, error)
+	UpdateDeviceGroup(id string, updates map[string]interface{}) error
+
+	GetVariables() (json.RawMessage, error)
+	CreateVariable(name string, varType string, value interface{}) (json.RawMessage, error)
+	DeleteVariable(id string) error
+
+	GetMoods() (json.RawMessage, error)
+	CreateMood(mood map[string]interface{}) (json.RawMessage, error)
+	UpdateMood(id string, updates map[string]interface{}) error
+	DeleteMood(id string) error
+
+	GetFlowFolders() (json.RawMessage, error)
+	CreateFlowFolder(folder map[string]interface{}) (json.RawMessage, error)
+	UpdateFlowFolder(id string, folder map[string]interface{}) error
+	DeleteFlowFolder(id string) error
+
+	GetFlows() (json.RawMessage, error)
+	CreateFlow(flow map[string]interface{}) (json.RawMessage, error)
+	UpdateFlow(id string, flow map[string]interface{}) (json.RawMessage, error)
+	DeleteFlow(id string) error
+	GetAdvancedFlows() (json.RawMessage, error)
+	CreateAdvancedFlow(flow map[string]interface{}) (json.RawMessage, error)
+	UpdateAdvancedFlow(id string, flow map[string]interface{}) (json.RawMessage, error)
+	DeleteAdvancedFlow(id string) error
+}
+
+type liveZone struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+	Icon   string `json:"icon"`
+}
+
+type liveDevice struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Zone         string   `json:"zone"`
+	Class        string   `json:"class"`
+	VirtualClass string   `json:"virtualClass"`
+	Devices      []string `json:"devices"`
+}
+
+type liveVariable struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type liveMood struct {
+	ID      string                 `json:"id"`
+	Name    string                 `json:"name"`
+	Zone    string                 `json:"zone"`
+	Devices map[string]interface{} `json:"devices"`
+}
+
+type liveFlowFolder struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+}
+
+// state is what is on the Homey right now, each kind keyed by ID
+type state struct {
+	zones         map[string]liveZone
+	devices       map[string]liveDevice
+	variables     map[string]liveVariable
+	moods         map[string]liveMood
+	folders       map[string]liveFlowFolder
+	flows         map[string]map[string]interface{}
+	advancedFlows map[string]map[string]interface{}
+}
+
+// fetch reads the kinds the spec mentions from the Homey. Zones and devices
+// are always read since most other kinds refer to them.
+func fetch(api API, spec *Spec) (*state, error) {
+	s := &state{}
+	if err := fetchInto(api.GetZones, "zones", &s.zones); err != nil {
+		return nil, err
+	}
+	if err := fetchInto(api.GetDevices, "devices", &s.devices); err != nil {
+		return nil, err
+	}
+	if spec.declares(KindVariable) {
+		if err := fetchInto(api.GetVariables, "variables", &s.variables); err != nil {
+			return nil, err
+		}
+	}
+	if spec.declares(KindMood) {
+		if err := fetchInto(api.GetMoods, "moods", &s.moods); err != nil {
+			return nil, err
+		}
+	}
+	if spec.declares(KindFlowFolder) || spec.declares(KindFlow) {
+		if err := fetchInto(api.GetFlowFolders, "flow folders", &s.folders); err != nil {
+			return nil, err
+		}
+	}
+	if spec.declares(KindFlow) {
+		if err := fetchInto(api.GetFlows, "flows", &s.flows); err != nil {
+			return nil, err
+		}
+		if err := fetchInto(api.GetAdvancedFlows, "advanced flows", &s.advancedFlows); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// fetchInto calls get and decodes the response into v
+func fetchInto(get func() (json.RawMessage, error), what string, v interface{}) error {
+	data, err := get()
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", what, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", what, err)
+	}
+	return nil
+}
+
+// namespace is a kind of name that references are resolved in. Simple and
+// advanced flows are separate; groups share the device namespace.
+type namespace string
+
+const (
+	nsZone         namespace = "zone"
+	nsFolder       namespace = "flow folder"
+	nsDevice       namespace = "device"
+	nsVariable     namespace = "variable"
+	nsMood         namespace = "mood"
+	nsFlow         namespace = "flow"
+	nsAdvancedFlow namespace = "advanced flow"
+)
+
+// index resolves names to IDs. It starts from the live Homey and follows the
+// plan: renamed resources answer to their new name, and resources that are
+// still to be created resolve to an empty ID until Apply creates them.
+type index struct {
+	ids   map[namespace]map[string]string
+	dupes map[namespace]map[string]bool
+}
+
+func newIndex() *index {
+	return &index{
+		ids:   map[namespace]map[string]string{},
+		dupes: map[namespace]map[string]bool{},
+	}
+}
+
+// add records a name. A name seen twice on the Homey can't be referred to.
+func (x *index) add(ns namespace, name, id string) {
+	key := strings.ToLower(name)
+	if x.ids[ns] == nil {
+		x.ids[ns] = map[string]string{}
+		x.dupes[ns] = map[string]bool{}
+	}
+	if prev, ok := x.ids[ns][key]; ok && prev != id {
+		x.dupes[ns][key] = true
+	}
+	x.ids[ns][key] = id
+}
+
+// set points a name at id, replacing whatever it pointed at
+func (x *index) set(ns namespace, name, id string) {
+	if x.ids[ns] == nil {
+		x.add(ns, name, id)
+		return
+	}
+	key := strings.ToLower(name)
+	x.ids[ns][key] = id
+	delete(x.dupes[ns], key)
+}
+
+// forget drops a name, e.g. the old name of a renamed resource
+func (x *index) forget(ns namespace, name string) {
+	key := strings.ToLower(name)
+	delete(x.ids[ns], key)
+	delete(x.dupes[ns], key)
+}
+
+// lookup returns the ID for a name. ok is false if the name is unknown; the
+// ID is empty if the resource has yet to be created.
+func (x *index) lookup(ns namespace, name string) (id string, ok bool, err error) {
+	key := strings.ToLower(name)
+	if x.dupes[ns][key] {
+		return "", false, fmt.Errorf("there is more than one %s named %q on the Homey", ns, name)
+	}
+	id, ok = x.ids[ns][key]
+	return id, ok, nil
+}
+
+// resolve returns the ID of an existing resource
+func (x *index) resolve(ns namespace, name string) (string, error) {
+	id, ok, err := x.lookup(ns, name)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%s not found: %s", ns, name)
+	}
+	if id == "" {
+		return "", fmt.Errorf("%s %q has not been created yet", ns, name)
+	}
+	return id, nil
+}
+
+// check fails if a name is unknown to both the Homey and the spec
+func (x *index) check(ns namespace, name string) error {
+	_, ok, err := x.lookup(ns, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s not found: %s", ns, name)
+	}
+	return nil
+}
+
+// str returns a string field of a decoded JSON object
+func str(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
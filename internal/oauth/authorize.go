@@ -0,0 +1,243 @@
+package oauth
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultCallbackPort is the port of the local OAuth callback server
+const DefaultCallbackPort = 8484
+
+// authTimeout is how long to wait for the user to finish logging in
+const authTimeout = 5 * time.Minute
+
+// LoginOptions controls how the OAuth login is carried out
+type LoginOptions struct {
+	// CallbackPort is the localhost port the browser is redirected to.
+	// Zero uses DefaultCallbackPort, the only one registered with Athom.
+	CallbackPort int
+
+	// NoBrowser prints the login URL instead of opening a browser and reads
+	// the redirect URL (or just the code) from In. No callback server is started.
+	NoBrowser bool
+
+	// In and Out are used for headless login; nil means stdin and stdout
+	In  io.Reader
+	Out io.Writer
+
+	// Choose picks the Homey to log in to; nil uses the first Homey
+	Choose ChooseHomey
+}
+
+// authRequest is one authorization attempt: the PKCE verifier and the state
+// the callback has to echo back
+type authRequest struct {
+	port     int
+	state    string
+	verifier string
+}
+
+func newAuthRequest(port int) (*authRequest, error) {
+	if port == 0 {
+		port = DefaultCallbackPort
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	return &authRequest{port: port, state: state, verifier: verifier}, nil
+}
+
+func (a *authRequest) redirectURI() string {
+	return fmt.Sprintf("http://localhost:%d/callback", a.port)
+}
+
+// randomString returns n random bytes, base64url encoded
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// challenge returns the S256 PKCE code challenge for the verifier
+func (a *authRequest) challenge() string {
+	sum := sha256.Sum256([]byte(a.verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// url builds the authorization URL (Athom uses 'scopes' not standard 'scope')
+func (a *authRequest) url() string {
+	query := url.Values{
+		"client_id":             {ClientID},
+		"redirect_uri":          {a.redirectURI()},
+		"response_type":         {"code"},
+		"scopes":                {strings.Join(AllScopes, " ")},
+		"state":                 {a.state},
+		"code_challenge":        {a.challenge()},
+		"code_challenge_method": {"S256"},
+	}
+	return AuthURL + "?" + query.Encode()
+}
+
+// code extracts the authorization code from callback query parameters
+func (a *authRequest) code(query url.Values) (string, error) {
+	if e := query.Get("error"); e != "" {
+		if desc := query.Get("error_description"); desc != "" {
+			e += ": " + desc
+		}
+		return "", fmt.Errorf("authorization failed: %s", e)
+	}
+	if query.Get("state") != a.state {
+		return "", fmt.Errorf("state mismatch in callback, login aborted")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("no authorization code received")
+	}
+	return code, nil
+}
+
+// parsePasted accepts the redirect URL copied from the browser, its query
+// string, or a bare authorization code
+func (a *authRequest) parsePasted(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("no authorization code entered")
+	}
+
+	if !strings.Contains(input, "=") {
+		return input, nil
+	}
+
+	rawQuery := input
+	if u, err := url.Parse(input); err == nil && u.RawQuery != "" {
+		rawQuery = u.RawQuery
+	}
+	query, err := url.ParseQuery(strings.TrimPrefix(rawQuery, "?"))
+	if err != nil {
+		return "", fmt.Errorf("could not parse the redirect URL: %w", err)
+	}
+	return a.code(query)
+}
+
+// waitForCallback serves the redirect on the loopback listeners and returns
+// the code
+func (a *authRequest) waitForCallback(ctx context.Context, listeners ...net.Listener) (string, error) {
+	codeChan := make(chan string, 1)
+	errChan := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		code, err := a.code(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "<html><body><h1>Error</h1><p>%s</p></body></html>", html.EscapeString(err.Error()))
+			select {
+			case errChan <- err:
+			default:
+			}
+			return
+		}
+		fmt.Fprintf(w, "<html><body><h1>Success!</h1><p>You can close this window and return to the terminal.</p></body></html>")
+		select {
+		case codeChan <- code:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	for _, listener := range listeners {
+		go func() {
+			if err := server.Serve(listener); err != http.ErrServerClosed {
+				select {
+				case errChan <- err:
+				default:
+				}
+			}
+		}()
+	}
+	defer server.Shutdown(context.Background())
+
+	select {
+	case code := <-codeChan:
+		return code, nil
+	case err := <-errChan:
+		return "", err
+	case <-ctx.Done():
+		return "", fmt.Errorf("authentication timed out")
+	}
+}
+
+// readPasted reads the redirect URL or code pasted by the user
+func (a *authRequest) readPasted(ctx context.Context, in io.Reader) (string, error) {
+	lineChan := make(chan string, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+			errChan <- fmt.Errorf("failed to read authorization code: %w", err)
+			return
+		}
+		lineChan <- line
+	}()
+
+	select {
+	case line := <-lineChan:
+		return a.parsePasted(line)
+	case err := <-errChan:
+		return "", err
+	case <-ctx.Done():
+		return "", fmt.Errorf("authentication timed out")
+	}
+}
+
+// authorize sends the user to the Athom login page and returns the
+// authorization code
+func authorize(a *authRequest, opts LoginOptions, in io.Reader, out io.Writer) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), authTimeout)
+	defer cancel()
+
+	if opts.NoBrowser {
+		fmt.Fprintf(out, "Open this URL in a browser and log in:\n\n  %s\n\n", a.url())
+		fmt.Fprintln(out, "The browser is then sent to a localhost page that won't load.")
+		fmt.Fprint(out, "Paste that page's full URL (or just the code) here: ")
+		return a.readPasted(ctx, in)
+	}
+
+	// Only accept the callback on the loopback interface. The redirect goes
+	// to localhost, which the browser may resolve to either address; IPv6 is
+	// optional since it may be disabled.
+	addr := fmt.Sprintf("127.0.0.1:%d", a.port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("could not listen for the login callback on %s: %w\n\nUse --no-browser to paste the code instead", addr, err)
+	}
+	listeners := []net.Listener{listener}
+	if listener6, err := net.Listen("tcp", fmt.Sprintf("[::1]:%d", a.port)); err == nil {
+		listeners = append(listeners, listener6)
+	}
+
+	fmt.Fprintln(out, "Opening browser for authentication...")
+	if err := openBrowser(a.url()); err != nil {
+		fmt.Fprintf(out, "Could not open browser. Please visit:\n%s\n", a.url())
+	}
+	return a.waitForCallback(ctx, listeners...)
+}
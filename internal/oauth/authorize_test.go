@@ -0,0 +1,165 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthRequest_URL(t *testing.T) {
+	a, err := newAuthRequest(9999)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(a.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("redirect_uri") != "http://localhost:9999/callback" {
+		t.Errorf("unexpected redirect_uri %q", q.Get("redirect_uri"))
+	}
+	if q.Get("state") != a.state || a.state == "" {
+		t.Errorf("expected state %q, got %q", a.state, q.Get("state"))
+	}
+	if q.Get("code_challenge_method") != "S256" {
+		t.Errorf("expected S256, got %q", q.Get("code_challenge_method"))
+	}
+
+	sum := sha256.Sum256([]byte(a.verifier))
+	if q.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Error("code_challenge does not match the verifier")
+	}
+
+	other, _ := newAuthRequest(0)
+	if other.state == a.state || other.verifier == a.verifier {
+		t.Error("expected a fresh state and verifier per request")
+	}
+	if other.redirectURI() != "http://localhost:8484/callback" {
+		t.Errorf("expected default port, got %q", other.redirectURI())
+	}
+}
+
+func TestAuthRequest_ParsePasted(t *testing.T) {
+	a := &authRequest{port: 8484, state: "xyz", verifier: "v"}
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{input: "http://localhost:8484/callback?code=abc&state=xyz\n", want: "abc"},
+		{input: "?code=abc&state=xyz", want: "abc"},
+		{input: "code=abc&state=xyz", want: "abc"},
+		{input: "  abc  ", want: "abc"},
+		{input: "http://localhost:8484/callback?code=abc&state=evil", wantErr: "state mismatch"},
+		{input: "http://localhost:8484/callback?error=access_denied&state=xyz", wantErr: "access_denied"},
+		{input: "", wantErr: "no authorization code"},
+	}
+
+	for _, tt := range tests {
+		got, err := a.parsePasted(tt.input)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parsePasted(%q): expected error containing %q, got %v", tt.input, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parsePasted(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestAuthRequest_WaitForCallback(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &authRequest{port: listener.Addr().(*net.TCPAddr).Port, state: "xyz", verifier: "v"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result := make(chan string, 1)
+	go func() {
+		code, err := a.waitForCallback(ctx, listener)
+		if err != nil {
+			code = "error: " + err.Error()
+		}
+		result <- code
+	}()
+
+	base := fmt.Sprintf("http://127.0.0.1:%d/callback", a.port)
+
+	// A callback with the wrong state must not be accepted
+	resp, err := http.Get(base + "?code=stolen&state=other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for wrong state, got %d", resp.StatusCode)
+	}
+
+	if got := <-result; !strings.Contains(got, "state mismatch") {
+		t.Errorf("expected state mismatch, got %q", got)
+	}
+}
+
+func TestAuthRequest_WaitForCallback_IPv6(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener6, err := net.Listen("tcp", fmt.Sprintf("[::1]:%d", port))
+	if err != nil {
+		listener.Close()
+		t.Skipf("IPv6 loopback not available: %v", err)
+	}
+	a := &authRequest{port: port, state: "xyz", verifier: "v"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result := make(chan string, 1)
+	go func() {
+		code, err := a.waitForCallback(ctx, listener, listener6)
+		if err != nil {
+			code = "error: " + err.Error()
+		}
+		result <- code
+	}()
+
+	// A browser that resolves localhost to ::1 reaches the callback too
+	resp, err := http.Get(fmt.Sprintf("http://[::1]:%d/callback?code=abc&state=xyz", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if got := <-result; got != "abc" {
+		t.Errorf("expected code abc, got %q", got)
+	}
+}
+
+func TestAuthRequest_ReadPasted(t *testing.T) {
+	a := &authRequest{port: 8484, state: "xyz", verifier: "v"}
+
+	code, err := a.readPasted(context.Background(), strings.NewReader("http://localhost:8484/callback?code=abc&state=xyz"))
+	if err != nil || code != "abc" {
+		t.Errorf("expected abc, got %q, %v", code, err)
+	}
+}
@@ -2,12 +2,12 @@ package oauth
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -17,7 +17,6 @@ import (
 const (
 	ClientID     = "69637f960b43d2064df4fa70"
 	ClientSecret = "922a71f421653b83b99d7a98be74d8ab"
-	RedirectURI  = "http://localhost:8484/callback"
 	AuthURL      = "https://api.athom.com/oauth2/authorise"
 	TokenURL     = "https://api.athom.com/oauth2/token"
 )
@@ -76,6 +75,14 @@ type TokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 }
 
+// Expiry returns when the access token expires, or the zero time if unknown
+func (t *TokenResponse) Expiry() time.Time {
+	if t.ExpiresIn <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+}
+
 // User represents an Athom user
 type User struct {
 	ID        string  `json:"_id"`
@@ -94,66 +101,37 @@ type Homey struct {
 	LocalURLSecure string `json:"localUrlSecure"`
 	RemoteURL      string `json:"remoteUrl"`
 	Token          string // Session token for this specific Homey
+	AccessToken    string // Athom account token the session was obtained with
+	RefreshToken   string // Renews AccessToken once it expires
+	AccessExpiry   time.Time
 }
 
-// Login performs the OAuth login flow and returns a delegation token for the selected Homey
-func Login() (*Homey, error) {
-	// Create channel to receive auth code
-	codeChan := make(chan string, 1)
-	errChan := make(chan error, 1)
-
-	// Start local server
-	server := &http.Server{Addr: ":8484"}
-	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		code := r.URL.Query().Get("code")
-		if code == "" {
-			errChan <- fmt.Errorf("no authorization code received")
-			fmt.Fprintf(w, "<html><body><h1>Error</h1><p>No authorization code received.</p></body></html>")
-			return
-		}
-		codeChan <- code
-		fmt.Fprintf(w, "<html><body><h1>Success!</h1><p>You can close this window and return to the terminal.</p></body></html>")
-	})
+// ChooseHomey picks the Homey to log in to from the Homeys on the account
+type ChooseHomey func(homeys []Homey) (*Homey, error)
 
-	go func() {
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			errChan <- err
-		}
-	}()
-
-	// Build authorization URL (Athom uses 'scopes' not standard 'scope')
-	scopes := strings.Join(AllScopes, " ")
-	authURL := fmt.Sprintf("%s?client_id=%s&redirect_uri=%s&response_type=code&scopes=%s",
-		AuthURL,
-		ClientID,
-		url.QueryEscape(RedirectURI),
-		url.QueryEscape(scopes),
-	)
-
-	// Open browser
-	fmt.Println("Opening browser for authentication...")
-	if err := openBrowser(authURL); err != nil {
-		fmt.Printf("Could not open browser. Please visit:\n%s\n", authURL)
-	}
-
-	// Wait for auth code or error
-	var code string
-	select {
-	case code = <-codeChan:
-	case err := <-errChan:
-		server.Shutdown(context.Background())
-		return nil, err
-	case <-time.After(5 * time.Minute):
-		server.Shutdown(context.Background())
-		return nil, fmt.Errorf("authentication timed out")
+// Login performs the OAuth login flow (authorization code with PKCE) and
+// returns a delegation token for the Homey picked by opts.Choose
+func Login(opts LoginOptions) (*Homey, error) {
+	in, out := opts.In, opts.Out
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stdout
 	}
 
-	// Shutdown server
-	server.Shutdown(context.Background())
+	authReq, err := newAuthRequest(opts.CallbackPort)
+	if err != nil {
+		return nil, err
+	}
+	code, err := authorize(authReq, opts, in, out)
+	if err != nil {
+		return nil, err
+	}
 
 	// Exchange code for token
 	fmt.Println("Exchanging authorization code for token...")
-	tokenResp, err := exchangeCodeForToken(code)
+	tokenResp, err := exchangeCodeForToken(code, authReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
 	}
@@ -171,23 +149,15 @@ func Login() (*Homey, error) {
 		return nil, fmt.Errorf("no Homeys found on your account")
 	}
 
-	// Debug: print Homey info
-	for _, h := range user.Homeys {
-		fmt.Printf("  - %s (ID: %s)\n", h.Name, h.ID)
-		fmt.Printf("    LocalURL: %s\n", h.LocalURL)
-		fmt.Printf("    LocalURLSecure: %s\n", h.LocalURLSecure)
-		fmt.Printf("    RemoteURL: %s\n", h.RemoteURL)
-	}
-
-	// Select Homey (for now just use the first one, could add selection UI later)
 	selectedHomey := user.Homeys[0]
-	if len(user.Homeys) > 1 {
-		fmt.Println("\nFound multiple Homeys:")
-		for i, h := range user.Homeys {
-			fmt.Printf("  %d. %s (%s)\n", i+1, h.Name, h.LocalAddress)
+	if opts.Choose != nil {
+		chosen, err := opts.Choose(user.Homeys)
+		if err != nil {
+			return nil, err
 		}
-		fmt.Printf("\nUsing: %s\n", selectedHomey.Name)
+		selectedHomey = *chosen
 	}
+	fmt.Printf("Using: %s\n", selectedHomey.Name)
 
 	// Use RemoteURL for OAuth authentication (required for delegation token flow)
 	// LocalURL can be used after getting a session token
@@ -221,6 +191,7 @@ func Login() (*Homey, error) {
 		}
 		selectedHomey.Token = sessionToken
 		selectedHomey.LocalURL = localURL // Use local URL for subsequent API calls
+		setAccountTokens(&selectedHomey, tokenResp)
 		return &selectedHomey, nil
 	}
 
@@ -233,17 +204,127 @@ func Login() (*Homey, error) {
 
 	selectedHomey.Token = sessionToken
 	selectedHomey.LocalURL = localURL // Use local URL for subsequent API calls
+	setAccountTokens(&selectedHomey, tokenResp)
 	return &selectedHomey, nil
 }
 
-func exchangeCodeForToken(code string) (*TokenResponse, error) {
-	data := url.Values{
+// setAccountTokens records the account tokens a Homey session was obtained with
+func setAccountTokens(h *Homey, tokenResp *TokenResponse) {
+	h.AccessToken = tokenResp.AccessToken
+	h.RefreshToken = tokenResp.RefreshToken
+	h.AccessExpiry = tokenResp.Expiry()
+}
+
+// ConnectCloud logs in to a Homey through the Athom cloud relay using an Athom
+// account token. It looks up the Homey's remote URL, exchanges the account token
+// for a delegation token and returns the Homey with a fresh session token.
+func ConnectCloud(accessToken, homeyID string) (*Homey, error) {
+	user, err := getUser(accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+
+	homey, err := FindHomey(user.Homeys, homeyID)
+	if err != nil {
+		return nil, err
+	}
+	if homey.RemoteURL == "" {
+		return nil, fmt.Errorf("no remote URL available for Homey %s", homey.Name)
+	}
+
+	delegationToken, err := getDelegationToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionToken, err := loginToHomey(homey.RemoteURL, delegationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	homey.Token = sessionToken
+	homey.AccessToken = accessToken
+	return homey, nil
+}
+
+// FindHomey returns the Homey matching an ID or (case-insensitive) name.
+// An empty nameOrID matches the only Homey on the account.
+func FindHomey(homeys []Homey, nameOrID string) (*Homey, error) {
+	if len(homeys) == 0 {
+		return nil, fmt.Errorf("no Homeys found on your account")
+	}
+
+	if nameOrID == "" {
+		if len(homeys) == 1 {
+			return &homeys[0], nil
+		}
+		return nil, fmt.Errorf("multiple Homeys on your account (%s), pick one by name or ID", homeyNames(homeys))
+	}
+
+	for i := range homeys {
+		if homeys[i].ID == nameOrID {
+			return &homeys[i], nil
+		}
+	}
+
+	var matches []*Homey
+	for i := range homeys {
+		if strings.EqualFold(homeys[i].Name, nameOrID) {
+			matches = append(matches, &homeys[i])
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return nil, fmt.Errorf("Homey %q not found on your account (%s)", nameOrID, homeyNames(homeys))
+	}
+
+	ids := make([]string, len(matches))
+	for i, h := range matches {
+		ids[i] = h.ID
+	}
+	return nil, fmt.Errorf("multiple Homeys named %q, use an ID instead: %s", nameOrID, strings.Join(ids, ", "))
+}
+
+func homeyNames(homeys []Homey) string {
+	names := make([]string, len(homeys))
+	for i, h := range homeys {
+		names[i] = h.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func exchangeCodeForToken(code string, authReq *authRequest) (*TokenResponse, error) {
+	return requestToken(url.Values{
 		"client_id":     {ClientID},
 		"client_secret": {ClientSecret},
 		"grant_type":    {"authorization_code"},
 		"code":          {code},
+		"redirect_uri":  {authReq.redirectURI()},
+		"code_verifier": {authReq.verifier},
+	})
+}
+
+// RefreshAccessToken exchanges a refresh token for a new account token
+func RefreshAccessToken(refreshToken string) (*TokenResponse, error) {
+	tokenResp, err := requestToken(url.Values{
+		"client_id":     {ClientID},
+		"client_secret": {ClientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
+	if tokenResp.RefreshToken == "" {
+		// Athom may keep the refresh token unchanged
+		tokenResp.RefreshToken = refreshToken
+	}
+	return tokenResp, nil
+}
 
+func requestToken(data url.Values) (*TokenResponse, error) {
 	resp, err := http.PostForm(TokenURL, data)
 	if err != nil {
 		return nil, err
@@ -256,7 +337,7 @@ func exchangeCodeForToken(code string) (*TokenResponse, error) {
 	}
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("token exchange failed: %s", string(body))
+		return nil, fmt.Errorf("token request failed: %s", string(body))
 	}
 
 	var tokenResp TokenResponse
@@ -0,0 +1,36 @@
+package oauth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindHomey(t *testing.T) {
+	homeys := []Homey{
+		{ID: "aaa", Name: "Home"},
+		{ID: "bbb", Name: "Cabin"},
+		{ID: "ccc", Name: "cabin"},
+	}
+
+	if h, err := FindHomey(homeys, "aaa"); err != nil || h.Name != "Home" {
+		t.Errorf("expected match by ID, got %v, %v", h, err)
+	}
+	if h, err := FindHomey(homeys, "HOME"); err != nil || h.ID != "aaa" {
+		t.Errorf("expected case-insensitive match by name, got %v, %v", h, err)
+	}
+	if _, err := FindHomey(homeys, "Cabin"); err == nil || !strings.Contains(err.Error(), "bbb, ccc") {
+		t.Errorf("expected ambiguous name error, got %v", err)
+	}
+	if _, err := FindHomey(homeys, "Office"); err == nil {
+		t.Error("expected not found error")
+	}
+	if _, err := FindHomey(homeys, ""); err == nil {
+		t.Error("expected error when several Homeys and none chosen")
+	}
+	if h, err := FindHomey(homeys[:1], ""); err != nil || h.ID != "aaa" {
+		t.Errorf("expected the only Homey, got %v, %v", h, err)
+	}
+	if _, err := FindHomey(nil, ""); err == nil {
+		t.Error("expected error with no Homeys")
+	}
+}
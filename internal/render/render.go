@@ -0,0 +1,566 @@
+// Package render prints command output in the format chosen with --format:
+// json, table, yaml, csv, ndjson, a Go template or custom columns. Commands
+// describe their table columns once and the renderer does the rest, so every
+// list supports --no-headers, --sort-by, --wide and --query the same way.
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/itchyny/gojq"
+	"go.yaml.in/yaml/v3"
+)
+
+// Formats accepted by --format, besides template=... and custom-columns=...
+const (
+	JSON   = "json"
+	Table  = "table"
+	YAML   = "yaml"
+	CSV    = "csv"
+	NDJSON = "ndjson"
+
+	templatePrefix      = "template="
+	customColumnsPrefix = "custom-columns="
+)
+
+// FormatHelp describes the accepted formats, for flag usage
+const FormatHelp = "Output format: json, table, yaml, csv, ndjson, template=<go-template>, custom-columns=<HEADER:.path,...> (default: json)"
+
+// Options are the output flags shared by all commands
+type Options struct {
+	Format    string
+	NoHeaders bool
+	// SortBy is a column header or a path such as .name
+	SortBy string
+	// Wide shows the columns marked Wide
+	Wide bool
+	// Query is a jq expression applied to the JSON before it is formatted
+	Query string
+}
+
+// Column is a table column
+type Column struct {
+	Header string
+	// Path selects the cell from an item, e.g. .name or .capabilitiesObj.onoff.value
+	Path string
+	// Value computes the cell instead of Path
+	Value func(item map[string]interface{}) interface{}
+	// Wide columns are only shown with --wide
+	Wide bool
+}
+
+// Renderer writes output in one format
+type Renderer struct {
+	out      io.Writer
+	opts     Options
+	format   string
+	tmpl     *template.Template
+	override []Column
+	query    *gojq.Code
+}
+
+// New returns a renderer writing to out. It fails for unknown formats,
+// templates and queries that don't parse, and malformed custom columns.
+func New(out io.Writer, opts Options) (*Renderer, error) {
+	r := &Renderer{out: out, opts: opts, format: opts.Format}
+	if opts.Query != "" {
+		query, err := gojq.Parse(opts.Query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+		if r.query, err = gojq.Compile(query); err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+	}
+	switch {
+	case r.format == "":
+		r.format = JSON
+	case strings.HasPrefix(r.format, templatePrefix):
+		text := strings.TrimPrefix(r.format, templatePrefix)
+		tmpl, err := template.New("format").Funcs(template.FuncMap{"json": toJSON}).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		r.format, r.tmpl = "template", tmpl
+	case strings.HasPrefix(r.format, customColumnsPrefix):
+		columns, err := parseColumns(strings.TrimPrefix(r.format, customColumnsPrefix))
+		if err != nil {
+			return nil, err
+		}
+		r.format, r.override = "custom-columns", columns
+	}
+
+	switch r.format {
+	case JSON, Table, YAML, CSV, NDJSON, "template", "custom-columns":
+		return r, nil
+	}
+	return nil, fmt.Errorf("unknown output format: %s (use json, table, yaml, csv, ndjson, template=... or custom-columns=...)", opts.Format)
+}
+
+// parseColumns parses NAME:.name,ZONE:.zone
+func parseColumns(spec string) ([]Column, error) {
+	var columns []Column
+	for _, part := range strings.Split(spec, ",") {
+		header, path, ok := strings.Cut(part, ":")
+		header, path = strings.TrimSpace(header), strings.TrimSpace(path)
+		if !ok || header == "" || path == "" {
+			return nil, fmt.Errorf("invalid custom column: %q (use HEADER:.path)", part)
+		}
+		columns = append(columns, Column{Header: header, Path: path})
+	}
+	return columns, nil
+}
+
+// List renders a collection: a slice, or a map keyed by ID as Homey returns
+// them. JSON and YAML print data as it is; the other formats print one row
+// or line per item, in the order of --sort-by or else by the first column.
+func (r *Renderer) List(data interface{}, columns []Column) error {
+	data, err := r.apply(data)
+	if err != nil {
+		return err
+	}
+
+	switch r.format {
+	case JSON:
+		return r.json(data)
+	case YAML:
+		return r.yaml(data)
+	}
+
+	doc, err := normalize(data)
+	if err != nil {
+		return err
+	}
+	items, ordered := itemsOf(doc)
+	r.sort(items, ordered, columns)
+	return r.items(items, columns)
+}
+
+// Rows renders a single value whose table view is a list of rows, such as
+// a device and its capabilities. JSON, YAML and queries use data; the other
+// formats print rows with columns, as List does.
+func (r *Renderer) Rows(data, rows interface{}, columns []Column) error {
+	if r.query != nil || r.format == JSON || r.format == YAML {
+		return r.Object(data)
+	}
+	return r.List(rows, columns)
+}
+
+// Object renders a single value. Commands print their own table for it, so
+// the table format falls back to JSON, unless a query picked plain values.
+// CSV uses its top-level fields as columns.
+func (r *Renderer) Object(data interface{}) error {
+	data, err := r.apply(data)
+	if err != nil {
+		return err
+	}
+
+	switch r.format {
+	case Table:
+		if r.query != nil {
+			if items, _ := itemsOf(data); plain(items) {
+				return r.lines(items)
+			}
+		}
+		return r.json(data)
+	case JSON:
+		return r.json(data)
+	case YAML:
+		return r.yaml(data)
+	}
+
+	doc, err := normalize(data)
+	if err != nil {
+		return err
+	}
+	// A query may turn the object into a list; print that item by item
+	if list, ok := doc.([]interface{}); ok && r.query != nil {
+		return r.items(list, nil)
+	}
+	var columns []Column
+	if m, ok := doc.(map[string]interface{}); ok && r.format == CSV {
+		for _, key := range sortedKeys(m) {
+			columns = append(columns, Column{Header: key, Path: "." + key})
+		}
+	}
+	return r.items([]interface{}{doc}, columns)
+}
+
+// items prints items in every format but JSON and YAML
+func (r *Renderer) items(items []interface{}, columns []Column) error {
+	switch r.format {
+	case NDJSON:
+		for _, item := range items {
+			line, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(r.out, string(line))
+		}
+		return nil
+	case "template":
+		for _, item := range items {
+			var b strings.Builder
+			if err := r.tmpl.Execute(&b, templateData(item)); err != nil {
+				return fmt.Errorf("failed to render template: %w", err)
+			}
+			s := b.String()
+			if !strings.HasSuffix(s, "\n") {
+				s += "\n"
+			}
+			fmt.Fprint(r.out, s)
+		}
+		return nil
+	}
+
+	// Plain values, such as names picked by a query, print one per line
+	if plain(items) {
+		return r.lines(items)
+	}
+	switch r.format {
+	case CSV:
+		return r.csv(items, r.visible(columns))
+	case "custom-columns":
+		return r.table(items, r.override)
+	default:
+		return r.table(items, r.visible(columns))
+	}
+}
+
+func (r *Renderer) lines(items []interface{}) error {
+	for _, item := range items {
+		fmt.Fprintln(r.out, Format(item))
+	}
+	return nil
+}
+
+// plain reports whether there are items and none is an object
+func plain(items []interface{}) bool {
+	for _, item := range items {
+		if _, ok := item.(map[string]interface{}); ok {
+			return false
+		}
+	}
+	return len(items) > 0
+}
+
+// apply runs --query on data. One result replaces data; several are
+// collected in a list, like jq's [...].
+func (r *Renderer) apply(data interface{}) (interface{}, error) {
+	if r.query == nil {
+		return data, nil
+	}
+	doc, err := normalize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []interface{}
+	iter := r.query.Run(doc)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, fmt.Errorf("query failed: %w", err)
+		}
+		results = append(results, v)
+	}
+
+	switch len(results) {
+	case 0:
+		return nil, nil
+	case 1:
+		return results[0], nil
+	}
+	return results, nil
+}
+
+func (r *Renderer) json(data interface{}) error {
+	if raw, ok := rawJSON(data); ok {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			// Not JSON; print it as Homey sent it
+			fmt.Fprintln(r.out, string(raw))
+			return nil
+		}
+		data = v
+	}
+	out, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, string(out))
+	return nil
+}
+
+func (r *Renderer) yaml(data interface{}) error {
+	doc, err := normalize(data)
+	if err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = r.out.Write(out)
+	return err
+}
+
+func (r *Renderer) table(items []interface{}, columns []Column) error {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	if !r.opts.NoHeaders {
+		headers := make([]string, len(columns))
+		dashes := make([]string, len(columns))
+		for i, c := range columns {
+			headers[i] = c.Header
+			dashes[i] = strings.Repeat("-", len(c.Header))
+		}
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+		fmt.Fprintln(w, strings.Join(dashes, "\t"))
+	}
+	for _, item := range items {
+		fmt.Fprintln(w, strings.Join(row(item, columns), "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// Empty cells at the end of a row leave padding behind
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line != "" {
+			fmt.Fprintln(r.out, strings.TrimRight(line, " \n"))
+		}
+	}
+	return nil
+}
+
+func (r *Renderer) csv(items []interface{}, columns []Column) error {
+	w := csv.NewWriter(r.out)
+	if !r.opts.NoHeaders {
+		headers := make([]string, len(columns))
+		for i, c := range columns {
+			headers[i] = c.Header
+		}
+		w.Write(headers)
+	}
+	for _, item := range items {
+		w.Write(row(item, columns))
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// visible drops wide columns unless --wide is set
+func (r *Renderer) visible(columns []Column) []Column {
+	if r.opts.Wide {
+		return columns
+	}
+	var shown []Column
+	for _, c := range columns {
+		if !c.Wide {
+			shown = append(shown, c)
+		}
+	}
+	return shown
+}
+
+// sort orders items by --sort-by. Without it, items of a map are ordered by
+// the first column and slices keep their order.
+func (r *Renderer) sort(items []interface{}, ordered bool, columns []Column) {
+	key := r.opts.SortBy
+	var by Column
+	switch {
+	case key != "":
+		found := false
+		for _, c := range append(r.override, columns...) {
+			if strings.EqualFold(c.Header, key) {
+				by, found = c, true
+				break
+			}
+		}
+		if !found {
+			by = Column{Path: "." + strings.TrimPrefix(key, ".")}
+		}
+	case !ordered && len(columns) > 0:
+		by = columns[0]
+	default:
+		return
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return less(cell(items[i], by), cell(items[j], by))
+	})
+}
+
+// less compares cells as numbers when both are, else as text
+func less(a, b string) bool {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return fa < fb
+	}
+	return strings.ToLower(a) < strings.ToLower(b)
+}
+
+func row(item interface{}, columns []Column) []string {
+	cells := make([]string, len(columns))
+	for i, c := range columns {
+		cells[i] = cell(item, c)
+	}
+	return cells
+}
+
+func cell(item interface{}, c Column) string {
+	if c.Value != nil {
+		m, _ := item.(map[string]interface{})
+		return Format(c.Value(m))
+	}
+	return Format(Lookup(item, c.Path))
+}
+
+// Lookup follows a path such as .capabilitiesObj.onoff.value into an item.
+// Numeric segments index into lists. It returns nil for missing fields.
+func Lookup(item interface{}, path string) interface{} {
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return item
+	}
+	v := item
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// Format turns a value into a cell: text as is, whole numbers without a
+// decimal point, nothing for null, and JSON for objects and lists
+func Format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool, int, int64:
+		return fmt.Sprint(v)
+	default:
+		return toJSON(v)
+	}
+}
+
+func toJSON(v interface{}) string {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
+
+// rawJSON reports whether data is already encoded JSON
+func rawJSON(data interface{}) ([]byte, bool) {
+	switch d := data.(type) {
+	case json.RawMessage:
+		return d, true
+	case []byte:
+		return d, true
+	}
+	return nil, false
+}
+
+// normalize turns data into plain maps, lists and values, as decoded from
+// its JSON, so structs and API responses render alike
+func normalize(data interface{}) (interface{}, error) {
+	raw, ok := rawJSON(data)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return nil, err
+		}
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse output: %w", err)
+	}
+	return v, nil
+}
+
+// itemsOf returns the items of a list, or the values of a map keyed by ID.
+// ordered is false for maps, whose order means nothing.
+func itemsOf(doc interface{}) (items []interface{}, ordered bool) {
+	switch d := doc.(type) {
+	case []interface{}:
+		return d, true
+	case map[string]interface{}:
+		for _, key := range sortedKeys(d) {
+			items = append(items, d[key])
+		}
+		return items, false
+	case nil:
+		return nil, true
+	default:
+		return []interface{}{d}, true
+	}
+}
+
+// templateData lets templates use fields as they appear in the JSON output
+// or capitalized, so {{.name}} and {{.Name}} both work
+func templateData(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v)*2)
+		for key, value := range v {
+			m[key] = templateData(value)
+		}
+		for key := range v {
+			if key == "" {
+				continue
+			}
+			alias := strings.ToUpper(key[:1]) + key[1:]
+			if _, exists := m[alias]; !exists {
+				m[alias] = m[key]
+			}
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = templateData(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
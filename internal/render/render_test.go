@@ -0,0 +1,301 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var devices = json.RawMessage(`{
+	"b": {"id": "b", "name": "Lamp", "zone": "Kitchen", "power": 12.5, "capabilitiesObj": {"onoff": {"value": true}}},
+	"a": {"id": "a", "name": "Heater", "zone": "Office", "power": 1200, "capabilitiesObj": {"onoff": {"value": false}}},
+	"c": {"id": "c", "name": "Sensor", "zone": "Hall", "power": null}
+}`)
+
+var columns = []Column{
+	{Header: "NAME", Path: ".name"},
+	{Header: "ZONE", Path: ".zone"},
+	{Header: "POWER", Path: ".power", Wide: true},
+}
+
+func render(t *testing.T, opts Options, list bool, data interface{}) string {
+	t.Helper()
+	var out bytes.Buffer
+	r, err := New(&out, opts)
+	if err != nil {
+		t.Fatalf("New(%+v) failed: %v", opts, err)
+	}
+	if list {
+		err = r.List(data, columns)
+	} else {
+		err = r.Object(data)
+	}
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	return out.String()
+}
+
+func TestList_Table(t *testing.T) {
+	got := render(t, Options{Format: Table}, true, devices)
+	want := "NAME    ZONE\n" +
+		"----    ----\n" +
+		"Heater  Office\n" +
+		"Lamp    Kitchen\n" +
+		"Sensor  Hall\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestList_TableOptions(t *testing.T) {
+	got := render(t, Options{Format: Table, NoHeaders: true, Wide: true, SortBy: "power"}, true, devices)
+	// Sensor has no power, so it sorts first as an empty cell
+	want := "Sensor  Hall\n" +
+		"Lamp    Kitchen  12.5\n" +
+		"Heater  Office   1200\n"
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	got = render(t, Options{Format: Table, SortBy: "ZONE"}, true, devices)
+	if !strings.HasPrefix(got, "NAME    ZONE\n----    ----\nSensor  Hall\n") {
+		t.Errorf("expected sort by the ZONE column, got:\n%s", got)
+	}
+}
+
+func TestList_JSONKeepsData(t *testing.T) {
+	got := render(t, Options{Format: JSON, SortBy: "name"}, true, devices)
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, got)
+	}
+	if len(decoded) != 3 || decoded["a"] == nil {
+		t.Errorf("expected the map keyed by ID, got %s", got)
+	}
+
+	// Structs keep their field order
+	got = render(t, Options{}, true, []struct {
+		Name string `json:"name"`
+		ID   string `json:"id"`
+	}{{"Lamp", "b"}})
+	if got != "[\n  {\n    \"name\": \"Lamp\",\n    \"id\": \"b\"\n  }\n]\n" {
+		t.Errorf("unexpected JSON: %s", got)
+	}
+}
+
+func TestList_YAML(t *testing.T) {
+	got := render(t, Options{Format: YAML}, true, devices)
+	if !strings.Contains(got, "a:\n    capabilitiesObj:\n        onoff:\n            value: false\n") || !strings.Contains(got, "power: 1200\n") {
+		t.Errorf("unexpected YAML:\n%s", got)
+	}
+}
+
+func TestList_CSV(t *testing.T) {
+	got := render(t, Options{Format: CSV, Wide: true}, true, devices)
+	want := "NAME,ZONE,POWER\nHeater,Office,1200\nLamp,Kitchen,12.5\nSensor,Hall,\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestList_NDJSON(t *testing.T) {
+	got := render(t, Options{Format: NDJSON}, true, devices)
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"name":"Heater"`) {
+		t.Errorf("unexpected NDJSON:\n%s", got)
+	}
+}
+
+func TestList_Template(t *testing.T) {
+	got := render(t, Options{Format: "template={{.Name}} is in {{.zone}}"}, true, devices)
+	want := "Heater is in Office\nLamp is in Kitchen\nSensor is in Hall\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = render(t, Options{Format: "template={{.CapabilitiesObj.onoff.Value}}"}, true, devices)
+	if got != "false\ntrue\n<no value>\n" {
+		t.Errorf("unexpected nested template output: %q", got)
+	}
+}
+
+func TestList_CustomColumns(t *testing.T) {
+	got := render(t, Options{Format: "custom-columns=NAME:.name,ON:.capabilitiesObj.onoff.value"}, true, devices)
+	want := "NAME    ON\n" +
+		"----    --\n" +
+		"Heater  false\n" +
+		"Lamp    true\n" +
+		"Sensor\n"
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestList_ColumnValue(t *testing.T) {
+	var out bytes.Buffer
+	r, _ := New(&out, Options{Format: Table, NoHeaders: true})
+	r.List(devices, []Column{{Header: "NAME", Value: func(item map[string]interface{}) interface{} {
+		return strings.ToUpper(item["name"].(string))
+	}}})
+	if out.String() != "HEATER\nLAMP\nSENSOR\n" {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+}
+
+func TestList_SliceKeepsOrder(t *testing.T) {
+	got := render(t, Options{Format: Table, NoHeaders: true}, true, []map[string]string{{"name": "b"}, {"name": "a"}})
+	if got != "b\na\n" {
+		t.Errorf("unexpected order: %q", got)
+	}
+}
+
+func TestObject(t *testing.T) {
+	obj := map[string]interface{}{"name": "Lamp", "on": true}
+
+	if got := render(t, Options{Format: Table}, false, obj); !strings.Contains(got, `"name": "Lamp"`) {
+		t.Errorf("table format of an object should print JSON, got:\n%s", got)
+	}
+	if got := render(t, Options{Format: CSV}, false, obj); got != "name,on\nLamp,true\n" {
+		t.Errorf("unexpected CSV: %q", got)
+	}
+	if got := render(t, Options{Format: NDJSON}, false, obj); got != `{"name":"Lamp","on":true}`+"\n" {
+		t.Errorf("unexpected NDJSON: %q", got)
+	}
+	if got := render(t, Options{Format: "template={{.Name}}"}, false, obj); got != "Lamp\n" {
+		t.Errorf("unexpected template output: %q", got)
+	}
+	if got := render(t, Options{}, false, []byte("not json")); got != "not json\n" {
+		t.Errorf("non-JSON data should print as is, got %q", got)
+	}
+}
+
+func TestNew_RejectsBadFormats(t *testing.T) {
+	for _, format := range []string{"xml", "template={{.Name", "custom-columns=NAME", "custom-columns=:.name"} {
+		if _, err := New(&bytes.Buffer{}, Options{Format: format}); err == nil {
+			t.Errorf("expected error for --format %s", format)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, ""},
+		{"text", "text"},
+		{21.0, "21"},
+		{0.25, "0.25"},
+		{true, "true"},
+		{[]interface{}{"a", 1.0}, `["a",1]`},
+		{map[string]interface{}{"W": 3.0}, `{"W":3}`},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.in); got != tt.want {
+			t.Errorf("Format(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	item := map[string]interface{}{
+		"devices": []interface{}{"a", "b"},
+		"nested":  map[string]interface{}{"x": 1.0},
+	}
+	if got := Lookup(item, ".devices.1"); got != "b" {
+		t.Errorf("Lookup(.devices.1) = %v", got)
+	}
+	if got := Lookup(item, "nested.x"); got != 1.0 {
+		t.Errorf("Lookup(nested.x) = %v", got)
+	}
+	if got := Lookup(item, ".missing.x"); got != nil {
+		t.Errorf("Lookup(.missing.x) = %v", got)
+	}
+	if got := Lookup(item, "."); got == nil {
+		t.Error("Lookup(.) should return the item")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	// Several results are collected in a list
+	got := render(t, Options{Query: `.[] | select(.zone != "Hall") | .name`}, true, devices)
+	var names []string
+	if err := json.Unmarshal([]byte(got), &names); err != nil || len(names) != 2 {
+		t.Errorf("expected a JSON list of two names, got %s", got)
+	}
+
+	// One result replaces the data
+	if got := render(t, Options{Query: `.a.name`}, true, devices); got != "\"Heater\"\n" {
+		t.Errorf("unexpected single result: %q", got)
+	}
+	if got := render(t, Options{Query: `[.[] | .power // 0] | add`}, false, devices); got != "1212.5\n" {
+		t.Errorf("unexpected sum: %q", got)
+	}
+
+	// Objects picked by a query still render with the command's columns
+	got = render(t, Options{Format: Table, NoHeaders: true, Query: `[.[] | select(.power > 100)]`}, true, devices)
+	if got != "Heater  Office\n" {
+		t.Errorf("unexpected table: %q", got)
+	}
+
+	// Plain values print one per line in table and csv output
+	for _, format := range []string{Table, CSV} {
+		for _, list := range []bool{true, false} {
+			got := render(t, Options{Format: format, Query: `[.[] | .name] | sort | .[]`}, list, devices)
+			if got != "Heater\nLamp\nSensor\n" {
+				t.Errorf("format %s: unexpected plain values: %q", format, got)
+			}
+		}
+	}
+
+	if got := render(t, Options{Query: `.[] | select(.zone == "Attic")`}, true, devices); got != "null\n" {
+		t.Errorf("expected null for no results, got %q", got)
+	}
+}
+
+func TestQuery_Errors(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, Options{Query: ".["}); err == nil {
+		t.Error("expected error for a query that doesn't parse")
+	}
+
+	r, err := New(&bytes.Buffer{}, Options{Query: `.a.name + 1`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.List(devices, columns); err == nil || !strings.Contains(err.Error(), "query failed") {
+		t.Errorf("expected query error, got %v", err)
+	}
+}
+
+func TestRows(t *testing.T) {
+	device := map[string]interface{}{"name": "Lamp", "capabilitiesObj": map[string]interface{}{"onoff": map[string]interface{}{"value": true}}}
+	rows := []map[string]interface{}{{"capability": "onoff", "value": true}}
+	rowColumns := []Column{{Header: "CAPABILITY", Path: ".capability"}, {Header: "VALUE", Path: ".value"}}
+
+	render := func(opts Options) string {
+		var out bytes.Buffer
+		r, err := New(&out, opts)
+		if err != nil {
+			t.Fatalf("New(%+v) failed: %v", opts, err)
+		}
+		if err := r.Rows(device, rows, rowColumns); err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		return out.String()
+	}
+
+	if got := render(Options{Format: Table}); got != "CAPABILITY  VALUE\n----------  -----\nonoff       true\n" {
+		t.Errorf("unexpected table: %q", got)
+	}
+	if got := render(Options{Format: CSV, NoHeaders: true}); got != "onoff,true\n" {
+		t.Errorf("unexpected CSV: %q", got)
+	}
+	if got := render(Options{Format: JSON}); !strings.Contains(got, `"capabilitiesObj"`) {
+		t.Errorf("JSON should print the data, got %s", got)
+	}
+	if got := render(Options{Format: Table, Query: ".name"}); got != "Lamp\n" {
+		t.Errorf("a query should run on the data, got %q", got)
+	}
+}
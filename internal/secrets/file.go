@@ -0,0 +1,170 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+)
+
+// scryptWorkFactor keeps passphrase decryption well under a second, since
+// it runs on every command
+const scryptWorkFactor = 15
+
+// fileStore keeps all secrets in one age-encrypted JSON file, protected by a
+// passphrase or an age identity
+type fileStore struct {
+	path      string
+	recipient age.Recipient
+	identity  age.Identity
+
+	cache map[string]string
+}
+
+func init() {
+	Register("file", openFileStore)
+}
+
+func openFileStore(opts Options) (Store, error) {
+	s := &fileStore{path: filepath.Join(opts.Dir, "secrets.age")}
+
+	switch {
+	case opts.AgeKeyFile != "":
+		f, err := os.Open(opts.AgeKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open age key file: %w", err)
+		}
+		defer f.Close()
+
+		identities, err := age.ParseIdentities(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse age key file: %w", err)
+		}
+		x25519, ok := identities[0].(*age.X25519Identity)
+		if !ok {
+			return nil, fmt.Errorf("age key file must contain an X25519 identity")
+		}
+		s.identity = x25519
+		s.recipient = x25519.Recipient()
+	case opts.Passphrase != "":
+		recipient, err := age.NewScryptRecipient(opts.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		recipient.SetWorkFactor(scryptWorkFactor)
+		identity, err := age.NewScryptIdentity(opts.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		s.recipient = recipient
+		s.identity = identity
+	default:
+		return nil, fmt.Errorf("encrypted file secrets need HOMEY_SECRETS_PASSPHRASE or an age key file (secrets.age_key_file)")
+	}
+
+	return s, nil
+}
+
+func (s *fileStore) Name() string     { return "file" }
+func (s *fileStore) Location() string { return "encrypted file " + s.path }
+
+// load decrypts the file once and caches its contents
+func (s *fileStore) load() (map[string]string, error) {
+	if s.cache != nil {
+		return s.cache, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.cache = map[string]string{}
+		return s.cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(data), s.identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", s.path, err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", s.path, err)
+	}
+
+	values := map[string]string{}
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	s.cache = values
+	return values, nil
+}
+
+func (s *fileStore) save(values map[string]string) error {
+	plain, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, s.recipient)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(plain); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *fileStore) Get(key string) (string, error) {
+	values, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *fileStore) Set(key, value string) error {
+	values, err := s.load()
+	if err != nil {
+		return err
+	}
+	if values[key] == value {
+		return nil
+	}
+	values[key] = value
+	return s.save(values)
+}
+
+func (s *fileStore) Delete(key string) error {
+	values, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := values[key]; !ok {
+		return nil
+	}
+	delete(values, key)
+	return s.save(values)
+}
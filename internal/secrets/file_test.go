@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestFileStore_PassphraseRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := Open("file", Options{Dir: dir, Passphrase: "hunter2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Set("default/local.token", "secret-token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "secrets.age"))
+	if err != nil {
+		t.Fatalf("expected secrets.age: %v", err)
+	}
+	if strings.Contains(string(data), "secret-token") {
+		t.Error("secrets.age contains the token in plain text")
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "secrets.age")); info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// A fresh store has to decrypt the file
+	reopened, err := Open("file", Options{Dir: dir, Passphrase: "hunter2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	value, err := reopened.Get("default/local.token")
+	if err != nil || value != "secret-token" {
+		t.Errorf("expected secret-token, got %q (%v)", value, err)
+	}
+
+	if err := reopened.Delete("default/local.token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := reopened.Get("default/local.token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestFileStore_WrongPassphrase(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _ := Open("file", Options{Dir: dir, Passphrase: "right"})
+	if err := store.Set("default/local.token", "secret-token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wrong, err := Open("file", Options{Dir: dir, Passphrase: "wrong"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := wrong.Get("default/local.token"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a decryption error, got %v", err)
+	}
+}
+
+func TestFileStore_AgeKeyFile(t *testing.T) {
+	dir := t.TempDir()
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(keyFile, []byte("# test key\n"+identity.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open("file", Options{Dir: dir, AgeKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Set("cabin/cloud.token", "cabin-token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, _ := Open("file", Options{Dir: dir, AgeKeyFile: keyFile})
+	if value, err := reopened.Get("cabin/cloud.token"); err != nil || value != "cabin-token" {
+		t.Errorf("expected cabin-token, got %q (%v)", value, err)
+	}
+}
+
+func TestFileStore_NeedsKey(t *testing.T) {
+	if _, err := Open("file", Options{Dir: t.TempDir()}); err == nil {
+		t.Error("expected an error without a passphrase or key file")
+	}
+}
+
+func TestOpen_UnknownBackend(t *testing.T) {
+	if _, err := Open("vault", Options{}); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+}
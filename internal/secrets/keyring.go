@@ -0,0 +1,50 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService is the service name secrets are stored under
+const keyringService = "homeyctl"
+
+// keyringStore uses the OS secret store: Secret Service (libsecret) on
+// Linux, Keychain on macOS and Credential Manager on Windows
+type keyringStore struct{}
+
+func init() {
+	Register("keyring", func(Options) (Store, error) {
+		return keyringStore{}, nil
+	})
+}
+
+func (keyringStore) Name() string     { return "keyring" }
+func (keyringStore) Location() string { return "system keyring" }
+
+func (keyringStore) Get(key string) (string, error) {
+	value, err := keyring.Get(keyringService, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("keyring: %w", err)
+	}
+	return value, nil
+}
+
+func (keyringStore) Set(key, value string) error {
+	if err := keyring.Set(keyringService, key, value); err != nil {
+		return fmt.Errorf("keyring: %w", err)
+	}
+	return nil
+}
+
+func (keyringStore) Delete(key string) error {
+	err := keyring.Delete(keyringService, key)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("keyring: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Package secrets stores tokens outside config.toml, in the OS keyring or an
+// age-encrypted file.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when a secret is not stored
+var ErrNotFound = errors.New("secret not found")
+
+// Store keeps secrets by key. Keys look like "default/local.token".
+type Store interface {
+	// Name identifies the backend, e.g. "keyring"
+	Name() string
+	// Location describes where secrets live, for display
+	Location() string
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+// Options configures the backends
+type Options struct {
+	Dir        string // Directory for file-based backends
+	Passphrase string // Passphrase for the encrypted file backend
+	AgeKeyFile string // age identity file for the encrypted file backend
+}
+
+// Factory opens a backend
+type Factory func(opts Options) (Store, error)
+
+var backends = map[string]Factory{}
+
+// Register makes a backend available to Open
+func Register(name string, factory Factory) {
+	backends[name] = factory
+}
+
+// Backends returns the registered backend names, sorted
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open returns the named backend
+func Open(name string, opts Options) (Store, error) {
+	factory, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown secrets backend %q (available: %s)", name, strings.Join(Backends(), ", "))
+	}
+	return factory(opts)
+}
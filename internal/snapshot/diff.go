@@ -0,0 +1,412 @@
+// Package snapshot compares two snapshots of a Homey, as printed by
+// homeyctl snapshot. Changes are reported per device, flow and variable
+// rather than per JSON line, and can be written as a JSON Patch (RFC 6902).
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Snapshot is the document homeyctl snapshot prints. Each section is the API
+// response as Homey sent it, keyed by ID.
+type Snapshot map[string]json.RawMessage
+
+// Sections compared by Compare, in report order
+const (
+	SectionDevices       = "devices"
+	SectionFlows         = "flows"
+	SectionAdvancedFlows = "advancedFlows"
+	SectionVariables     = "variables"
+)
+
+// Kinds of entity a Change is about
+const (
+	KindDevice       = "device"
+	KindFlow         = "flow"
+	KindAdvancedFlow = "advanced flow"
+	KindVariable     = "variable"
+)
+
+// What a Change did
+const (
+	Added        = "added"
+	Removed      = "removed"
+	Renamed      = "renamed"
+	Moved        = "moved"
+	Available    = "available"
+	Unavailable  = "unavailable"
+	CapAdded     = "capability added"
+	CapRemoved   = "capability removed"
+	ValueChanged = "value"
+	Broken       = "broken"
+	Fixed        = "fixed"
+	Enabled      = "enabled"
+	Disabled     = "disabled"
+)
+
+// Change is one difference between two snapshots
+type Change struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	// Name is the entity's name in the newer snapshot, or the older one if
+	// it was removed
+	Name string `json:"name"`
+	What string `json:"change"`
+	// Capability is set for capability changes
+	Capability string `json:"capability,omitempty"`
+	// From and To are the values shown to people, e.g. zone names
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+
+	// path, old and value describe the change as a JSON Patch operation
+	path  string
+	old   interface{}
+	value interface{}
+}
+
+// Diff is the result of Compare
+type Diff struct {
+	Changes []Change
+	// Skipped lists sections that are missing from either snapshot, so
+	// were not compared
+	Skipped []string
+}
+
+// Compare reports what changed from a to b
+func Compare(a, b Snapshot) (*Diff, error) {
+	d := &Diff{}
+	compare := []struct {
+		section string
+		diff    func(a, b Snapshot) ([]Change, error)
+	}{
+		{SectionDevices, diffDevices},
+		{SectionFlows, flowDiffer(SectionFlows, KindFlow)},
+		{SectionAdvancedFlows, flowDiffer(SectionAdvancedFlows, KindAdvancedFlow)},
+		{SectionVariables, diffVariables},
+	}
+	for _, c := range compare {
+		if a[c.section] == nil || b[c.section] == nil {
+			d.Skipped = append(d.Skipped, c.section)
+			continue
+		}
+		changes, err := c.diff(a, b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s: %w", c.section, err)
+		}
+		d.Changes = append(d.Changes, changes...)
+	}
+	return d, nil
+}
+
+type device struct {
+	Name               string                `json:"name"`
+	Zone               string                `json:"zone"`
+	Available          *bool                 `json:"available"`
+	UnavailableMessage string                `json:"unavailableMessage"`
+	CapabilitiesObj    map[string]capability `json:"capabilitiesObj"`
+}
+
+type capability struct {
+	Value interface{} `json:"value"`
+}
+
+type flow struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Broken  bool   `json:"broken"`
+}
+
+type variable struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type zone struct {
+	Name string `json:"name"`
+}
+
+func diffDevices(a, b Snapshot) ([]Change, error) {
+	var before, after map[string]device
+	if err := decode(a, b, SectionDevices, &before, &after); err != nil {
+		return nil, err
+	}
+	var zonesBefore, zonesAfter map[string]zone
+	json.Unmarshal(a["zones"], &zonesBefore)
+	json.Unmarshal(b["zones"], &zonesAfter)
+	zoneName := func(zones map[string]zone, id string) string {
+		if z, ok := zones[id]; ok {
+			return z.Name
+		}
+		return id
+	}
+
+	var changes []Change
+	for _, id := range ids(before, after, func(d device) string { return d.Name }) {
+		old, hadOld := before[id]
+		cur, hasCur := after[id]
+		path := pointer(SectionDevices, id)
+		change := func(what string) Change {
+			name := cur.Name
+			if !hasCur {
+				name = old.Name
+			}
+			return Change{Kind: KindDevice, ID: id, Name: name, What: what, path: path}
+		}
+
+		switch {
+		case !hadOld:
+			c := change(Added)
+			c.value = b.raw(SectionDevices, id)
+			changes = append(changes, c)
+			continue
+		case !hasCur:
+			changes = append(changes, change(Removed))
+			continue
+		}
+
+		if old.Name != cur.Name {
+			c := change(Renamed)
+			c.From, c.To = old.Name, cur.Name
+			c.path, c.old, c.value = path+"/name", old.Name, cur.Name
+			changes = append(changes, c)
+		}
+		if old.Zone != cur.Zone {
+			c := change(Moved)
+			c.From, c.To = zoneName(zonesBefore, old.Zone), zoneName(zonesAfter, cur.Zone)
+			c.path, c.old, c.value = path+"/zone", old.Zone, cur.Zone
+			changes = append(changes, c)
+		}
+		if old.Available != nil && cur.Available != nil && *old.Available != *cur.Available {
+			c := change(Available)
+			if !*cur.Available {
+				c.What = Unavailable
+				if cur.UnavailableMessage != "" {
+					c.To = cur.UnavailableMessage
+				}
+			}
+			c.path, c.old, c.value = path+"/available", *old.Available, *cur.Available
+			changes = append(changes, c)
+		}
+
+		for _, capID := range sortedKeys(old.CapabilitiesObj, cur.CapabilitiesObj) {
+			oldCap, hadCap := old.CapabilitiesObj[capID]
+			curCap, hasCap := cur.CapabilitiesObj[capID]
+			capPath := path + "/capabilitiesObj/" + escape(capID)
+			switch {
+			case !hadCap:
+				c := change(CapAdded)
+				c.Capability, c.To = capID, curCap.Value
+				c.path, c.value = capPath, b.raw(SectionDevices, id, "capabilitiesObj", capID)
+				changes = append(changes, c)
+			case !hasCap:
+				c := change(CapRemoved)
+				c.Capability, c.From = capID, oldCap.Value
+				c.path = capPath
+				changes = append(changes, c)
+			case !reflect.DeepEqual(oldCap.Value, curCap.Value):
+				c := change(ValueChanged)
+				c.Capability, c.From, c.To = capID, oldCap.Value, curCap.Value
+				c.path, c.old, c.value = capPath+"/value", oldCap.Value, curCap.Value
+				changes = append(changes, c)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// flowDiffer compares simple or advanced flows, which share the fields
+// compared
+func flowDiffer(section, kind string) func(a, b Snapshot) ([]Change, error) {
+	return func(a, b Snapshot) ([]Change, error) {
+		var before, after map[string]flow
+		if err := decode(a, b, section, &before, &after); err != nil {
+			return nil, err
+		}
+
+		var changes []Change
+		for _, id := range ids(before, after, func(f flow) string { return f.Name }) {
+			old, hadOld := before[id]
+			cur, hasCur := after[id]
+			path := pointer(section, id)
+
+			switch {
+			case !hadOld:
+				changes = append(changes, Change{Kind: kind, ID: id, Name: cur.Name, What: Added, path: path, value: b.raw(section, id)})
+				continue
+			case !hasCur:
+				changes = append(changes, Change{Kind: kind, ID: id, Name: old.Name, What: Removed, path: path})
+				continue
+			}
+
+			if old.Name != cur.Name {
+				changes = append(changes, Change{Kind: kind, ID: id, Name: cur.Name, What: Renamed, From: old.Name, To: cur.Name,
+					path: path + "/name", old: old.Name, value: cur.Name})
+			}
+			if old.Broken != cur.Broken {
+				what := Broken
+				if !cur.Broken {
+					what = Fixed
+				}
+				changes = append(changes, Change{Kind: kind, ID: id, Name: cur.Name, What: what,
+					path: path + "/broken", old: old.Broken, value: cur.Broken})
+			}
+			if old.Enabled != cur.Enabled {
+				what := Enabled
+				if !cur.Enabled {
+					what = Disabled
+				}
+				changes = append(changes, Change{Kind: kind, ID: id, Name: cur.Name, What: what,
+					path: path + "/enabled", old: old.Enabled, value: cur.Enabled})
+			}
+		}
+		return changes, nil
+	}
+}
+
+func diffVariables(a, b Snapshot) ([]Change, error) {
+	var before, after map[string]variable
+	if err := decode(a, b, SectionVariables, &before, &after); err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, id := range ids(before, after, func(v variable) string { return v.Name }) {
+		old, hadOld := before[id]
+		cur, hasCur := after[id]
+		path := pointer(SectionVariables, id)
+
+		switch {
+		case !hadOld:
+			changes = append(changes, Change{Kind: KindVariable, ID: id, Name: cur.Name, What: Added, To: cur.Value,
+				path: path, value: b.raw(SectionVariables, id)})
+			continue
+		case !hasCur:
+			changes = append(changes, Change{Kind: KindVariable, ID: id, Name: old.Name, What: Removed, From: old.Value, path: path})
+			continue
+		}
+
+		if old.Name != cur.Name {
+			changes = append(changes, Change{Kind: KindVariable, ID: id, Name: cur.Name, What: Renamed, From: old.Name, To: cur.Name,
+				path: path + "/name", old: old.Name, value: cur.Name})
+		}
+		if !reflect.DeepEqual(old.Value, cur.Value) {
+			changes = append(changes, Change{Kind: KindVariable, ID: id, Name: cur.Name, What: ValueChanged, From: old.Value, To: cur.Value,
+				path: path + "/value", old: old.Value, value: cur.Value})
+		}
+	}
+	return changes, nil
+}
+
+// Operation is a JSON Patch operation
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON leaves out the value of remove operations, and keeps null
+// values of the others
+func (o Operation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+	type plain Operation
+	return json.Marshal(plain(o))
+}
+
+// Patch returns the changes as a JSON Patch that turns the older snapshot
+// into the newer one, for the fields compared. Each replace is preceded by a
+// test of the old value.
+func (d *Diff) Patch() []Operation {
+	ops := []Operation{}
+	for _, c := range d.Changes {
+		switch c.What {
+		case Added, CapAdded:
+			ops = append(ops, Operation{Op: "add", Path: c.path, Value: c.value})
+		case Removed, CapRemoved:
+			ops = append(ops, Operation{Op: "remove", Path: c.path})
+		default:
+			ops = append(ops,
+				Operation{Op: "test", Path: c.path, Value: c.old},
+				Operation{Op: "replace", Path: c.path, Value: c.value},
+			)
+		}
+	}
+	return ops
+}
+
+// decode decodes the same section of both snapshots
+func decode(a, b Snapshot, section string, before, after interface{}) error {
+	if err := json.Unmarshal(a[section], before); err != nil {
+		return err
+	}
+	return json.Unmarshal(b[section], after)
+}
+
+// raw returns the JSON at a path in the snapshot, for patch values
+func (s Snapshot) raw(section string, keys ...string) json.RawMessage {
+	data := s[section]
+	for _, key := range keys {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil
+		}
+		data = m[key]
+	}
+	return data
+}
+
+// ids returns the IDs in either map, ordered by name and then ID so reports
+// read alphabetically
+func ids[V any](before, after map[string]V, name func(V) string) []string {
+	names := map[string]string{}
+	for id, v := range before {
+		names[id] = name(v)
+	}
+	for id, v := range after {
+		names[id] = name(v)
+	}
+	keys := make([]string, 0, len(names))
+	for id := range names {
+		keys = append(keys, id)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ni, nj := strings.ToLower(names[keys[i]]), strings.ToLower(names[keys[j]])
+		if ni != nj {
+			return ni < nj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func sortedKeys[V any](maps ...map[string]V) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pointer builds a JSON Pointer (RFC 6901) to an entity
+func pointer(section, id string) string {
+	return "/" + section + "/" + escape(id)
+}
+
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,152 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, data string) Snapshot {
+	t.Helper()
+	var s Snapshot
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		t.Fatalf("bad snapshot: %v", err)
+	}
+	return s
+}
+
+var before = `{
+	"zones": {"z1": {"name": "Kitchen"}, "z2": {"name": "Hall"}},
+	"devices": {
+		"d1": {"name": "Lamp", "zone": "z1", "available": true, "capabilitiesObj": {"onoff": {"value": true}, "dim": {"value": 0.5}}},
+		"d2": {"name": "Old Sensor", "zone": "z1", "available": true},
+		"d3": {"name": "Plug", "zone": "z1", "available": true, "capabilitiesObj": {"onoff": {"value": false}}}
+	},
+	"flows": {
+		"f1": {"name": "Sunset", "enabled": true, "broken": false},
+		"f2": {"name": "Night", "enabled": true, "broken": false}
+	},
+	"advancedFlows": {},
+	"variables": {"v1": {"name": "guests", "value": false}}
+}`
+
+var after = `{
+	"zones": {"z1": {"name": "Kitchen"}, "z2": {"name": "Hall"}},
+	"devices": {
+		"d1": {"name": "Ceiling Lamp", "zone": "z2", "available": true, "capabilitiesObj": {"onoff": {"value": false}, "measure_power": {"value": 4}}},
+		"d3": {"name": "Plug", "zone": "z1", "available": false, "unavailableMessage": "App crashed", "capabilitiesObj": {"onoff": {"value": false}}},
+		"d4": {"name": "New Sensor", "zone": "z1", "available": true}
+	},
+	"flows": {
+		"f1": {"name": "Sunset", "enabled": true, "broken": true},
+		"f2": {"name": "Night", "enabled": false, "broken": false}
+	},
+	"advancedFlows": {"a1": {"name": "Alarm", "enabled": true}},
+	"variables": {"v1": {"name": "guests", "value": true}}
+}`
+
+func TestCompare(t *testing.T) {
+	diff, err := Compare(parse(t, before), parse(t, after))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, c := range diff.Changes {
+		line := c.Kind + " " + c.Name + ": " + c.What
+		if c.Capability != "" {
+			line += " " + c.Capability
+		}
+		if c.From != nil || c.To != nil {
+			line += " " + diffString(c.From) + " -> " + diffString(c.To)
+		}
+		got = append(got, line)
+	}
+
+	want := []string{
+		"device Ceiling Lamp: renamed Lamp -> Ceiling Lamp",
+		"device Ceiling Lamp: moved Kitchen -> Hall",
+		"device Ceiling Lamp: capability removed dim 0.5 -> ",
+		"device Ceiling Lamp: capability added measure_power  -> 4",
+		"device Ceiling Lamp: value onoff true -> false",
+		"device New Sensor: added",
+		"device Old Sensor: removed",
+		"device Plug: unavailable  -> App crashed",
+		"flow Night: disabled",
+		"flow Sunset: broken",
+		"advanced flow Alarm: added",
+		"variable guests: value false -> true",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(diff.Skipped) != 0 {
+		t.Errorf("expected nothing skipped, got %v", diff.Skipped)
+	}
+}
+
+func diffString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, _ := json.Marshal(v)
+	return strings.Trim(string(data), `"`)
+}
+
+func TestCompare_SkipsMissingSections(t *testing.T) {
+	a := parse(t, `{"devices": {}}`)
+	b := parse(t, after)
+
+	diff, err := Compare(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(diff.Skipped, ",") != "flows,advancedFlows,variables" {
+		t.Errorf("unexpected skipped sections: %v", diff.Skipped)
+	}
+	for _, c := range diff.Changes {
+		if c.Kind != KindDevice {
+			t.Errorf("unexpected change to %s", c.Kind)
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+	diff, err := Compare(parse(t, before), parse(t, after))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := json.Marshal(diff.Patch())
+	if err != nil {
+		t.Fatalf("failed to marshal patch: %v", err)
+	}
+	patch := string(data)
+
+	for _, want := range []string{
+		`{"op":"test","path":"/devices/d1/name","value":"Lamp"},{"op":"replace","path":"/devices/d1/name","value":"Ceiling Lamp"}`,
+		`{"op":"replace","path":"/devices/d1/zone","value":"z2"}`,
+		`{"op":"remove","path":"/devices/d1/capabilitiesObj/dim"}`,
+		`{"op":"add","path":"/devices/d1/capabilitiesObj/measure_power","value":{"value":4}}`,
+		`{"op":"remove","path":"/devices/d2"}`,
+		`{"op":"replace","path":"/flows/f1/broken","value":true}`,
+		`{"op":"replace","path":"/variables/v1/value","value":true}`,
+	} {
+		if !strings.Contains(patch, want) {
+			t.Errorf("expected patch to contain %s, got:\n%s", want, patch)
+		}
+	}
+}
+
+func TestPatch_Empty(t *testing.T) {
+	diff, _ := Compare(parse(t, before), parse(t, before))
+	data, _ := json.Marshal(diff.Patch())
+	if string(data) != "[]" {
+		t.Errorf("expected empty patch, got %s", data)
+	}
+}
+
+func TestPointerEscapes(t *testing.T) {
+	if got := pointer("devices", "a/b~c"); got != "/devices/a~1b~0c" {
+		t.Errorf("pointer() = %q", got)
+	}
+}
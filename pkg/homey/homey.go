@@ -0,0 +1,287 @@
+// Package homey is a typed Go client for the Homey Web API.
+//
+// It is built on the same HTTP client homeyctl uses, so requests get the
+// same timeouts, retries and error types:
+//
+//	h := homey.New("http://192.168.1.50", token)
+//	devices, err := h.Devices(ctx)
+//	if homey.IsForbidden(err) {
+//		// token is missing a scope
+//	}
+//
+// List methods return maps keyed by ID, the way the Homey API does.
+package homey
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/langtind/homeyctl/internal/client"
+)
+
+// APIError is returned when Homey responds with an error status
+type APIError = client.APIError
+
+// RetryPolicy controls how transient API failures are retried
+type RetryPolicy = client.RetryPolicy
+
+// Event is a realtime event received from Homey
+type Event = client.Event
+
+// SubscribeOptions selects which realtime events to receive
+type SubscribeOptions = client.SubscribeOptions
+
+// IsNotFound reports whether err is a 404 from Homey
+func IsNotFound(err error) bool { return client.IsNotFound(err) }
+
+// IsForbidden reports whether err is a 403 from Homey, usually missing scopes
+func IsForbidden(err error) bool { return client.IsForbidden(err) }
+
+// IsUnauthorized reports whether err is a 401 from Homey (invalid or expired token)
+func IsUnauthorized(err error) bool { return client.IsUnauthorized(err) }
+
+// Client is a typed Homey API client. It is safe for concurrent use.
+type Client struct {
+	api *client.Client
+}
+
+// New creates a client for a Homey base URL (e.g. http://192.168.1.50) and bearer token
+func New(baseURL, token string) *Client {
+	return &Client{api: client.NewWithToken(baseURL, token)}
+}
+
+// SetTimeout sets the per-request timeout. Zero disables it.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.api.SetTimeout(timeout)
+}
+
+// SetRetryPolicy replaces the retry policy for transient failures
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.api.SetRetryPolicy(policy)
+}
+
+// with binds the underlying client to ctx for a single call
+func (c *Client) with(ctx context.Context) *client.Client {
+	return c.api.WithContext(ctx)
+}
+
+// decode unmarshals a raw API response into T
+func decode[T any](data json.RawMessage, err error) (T, error) {
+	var v T
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return v, nil
+}
+
+// Devices
+
+// Devices returns all devices keyed by ID
+func (c *Client) Devices(ctx context.Context) (map[string]Device, error) {
+	return decode[map[string]Device](c.with(ctx).GetDevices())
+}
+
+// Device returns a single device
+func (c *Client) Device(ctx context.Context, id string) (*Device, error) {
+	return decode[*Device](c.with(ctx).GetDevice(id))
+}
+
+// SetCapability sets a capability value on a device
+func (c *Client) SetCapability(ctx context.Context, deviceID, capability string, value interface{}) error {
+	return c.with(ctx).SetCapability(deviceID, capability, value)
+}
+
+// Flows
+
+// Flows returns all simple flows keyed by ID
+func (c *Client) Flows(ctx context.Context) (map[string]Flow, error) {
+	return decode[map[string]Flow](c.with(ctx).GetFlows())
+}
+
+// AdvancedFlows returns all advanced flows keyed by ID
+func (c *Client) AdvancedFlows(ctx context.Context) (map[string]AdvancedFlow, error) {
+	return decode[map[string]AdvancedFlow](c.with(ctx).GetAdvancedFlows())
+}
+
+// FlowFolders returns all flow folders keyed by ID
+func (c *Client) FlowFolders(ctx context.Context) (map[string]FlowFolder, error) {
+	return decode[map[string]FlowFolder](c.with(ctx).GetFlowFolders())
+}
+
+// TriggerFlow starts a simple flow
+func (c *Client) TriggerFlow(ctx context.Context, id string) error {
+	return c.with(ctx).TriggerFlow(id)
+}
+
+// TriggerAdvancedFlow starts an advanced flow
+func (c *Client) TriggerAdvancedFlow(ctx context.Context, id string) error {
+	return c.with(ctx).TriggerAdvancedFlow(id)
+}
+
+// FlowTriggerCards returns all available trigger cards
+func (c *Client) FlowTriggerCards(ctx context.Context) ([]FlowCard, error) {
+	return decode[[]FlowCard](c.with(ctx).GetFlowTriggers())
+}
+
+// FlowConditionCards returns all available condition cards
+func (c *Client) FlowConditionCards(ctx context.Context) ([]FlowCard, error) {
+	return decode[[]FlowCard](c.with(ctx).GetFlowConditions())
+}
+
+// FlowActionCards returns all available action cards
+func (c *Client) FlowActionCards(ctx context.Context) ([]FlowCard, error) {
+	return decode[[]FlowCard](c.with(ctx).GetFlowActions())
+}
+
+// Zones
+
+// Zones returns all zones keyed by ID
+func (c *Client) Zones(ctx context.Context) (map[string]Zone, error) {
+	return decode[map[string]Zone](c.with(ctx).GetZones())
+}
+
+// Zone returns a single zone
+func (c *Client) Zone(ctx context.Context, id string) (*Zone, error) {
+	return decode[*Zone](c.with(ctx).GetZone(id))
+}
+
+// Apps
+
+// Apps returns all installed apps keyed by ID
+func (c *Client) Apps(ctx context.Context) (map[string]App, error) {
+	return decode[map[string]App](c.with(ctx).GetApps())
+}
+
+// App returns a single app
+func (c *Client) App(ctx context.Context, id string) (*App, error) {
+	return decode[*App](c.with(ctx).GetApp(id))
+}
+
+// RestartApp restarts an app
+func (c *Client) RestartApp(ctx context.Context, id string) error {
+	return c.with(ctx).RestartApp(id)
+}
+
+// Users
+
+// Users returns all users keyed by ID
+func (c *Client) Users(ctx context.Context) (map[string]User, error) {
+	return decode[map[string]User](c.with(ctx).GetUsers())
+}
+
+// User returns a single user
+func (c *Client) User(ctx context.Context, id string) (*User, error) {
+	return decode[*User](c.with(ctx).GetUser(id))
+}
+
+// Me returns the user the token belongs to
+func (c *Client) Me(ctx context.Context) (*User, error) {
+	return decode[*User](c.with(ctx).GetUserMe())
+}
+
+// Moods
+
+// Moods returns all moods keyed by ID
+func (c *Client) Moods(ctx context.Context) (map[string]Mood, error) {
+	return decode[map[string]Mood](c.with(ctx).GetMoods())
+}
+
+// Mood returns a single mood
+func (c *Client) Mood(ctx context.Context, id string) (*Mood, error) {
+	return decode[*Mood](c.with(ctx).GetMood(id))
+}
+
+// SetMood activates a mood
+func (c *Client) SetMood(ctx context.Context, id string) error {
+	return c.with(ctx).SetMood(id)
+}
+
+// Dashboards
+
+// Dashboards returns all dashboards keyed by ID
+func (c *Client) Dashboards(ctx context.Context) (map[string]Dashboard, error) {
+	return decode[map[string]Dashboard](c.with(ctx).GetDashboards())
+}
+
+// Logic variables
+
+// Variables returns all logic variables keyed by ID
+func (c *Client) Variables(ctx context.Context) (map[string]Variable, error) {
+	return decode[map[string]Variable](c.with(ctx).GetVariables())
+}
+
+// Variable returns a single logic variable
+func (c *Client) Variable(ctx context.Context, id string) (*Variable, error) {
+	return decode[*Variable](c.with(ctx).GetVariable(id))
+}
+
+// SetVariable updates the value of a logic variable
+func (c *Client) SetVariable(ctx context.Context, id string, value interface{}) error {
+	return c.with(ctx).SetVariable(id, value)
+}
+
+// Notifications
+
+// Notifications returns all timeline notifications keyed by ID
+func (c *Client) Notifications(ctx context.Context) (map[string]Notification, error) {
+	return decode[map[string]Notification](c.with(ctx).GetNotifications())
+}
+
+// Insights
+
+// InsightLogs returns all insights logs
+func (c *Client) InsightLogs(ctx context.Context) ([]InsightLog, error) {
+	return decode[[]InsightLog](c.with(ctx).GetInsights())
+}
+
+// InsightEntries returns the data points of a log. Resolution is one of
+// Homey's resolutions (e.g. "last24Hours", "last7Days"); empty uses the default.
+func (c *Client) InsightEntries(ctx context.Context, log InsightLog, resolution string) ([]InsightEntry, error) {
+	return decode[[]InsightEntry](c.with(ctx).GetInsightEntries(log.OwnerURI, log.OwnerID, resolution))
+}
+
+// System
+
+// System returns general system information
+func (c *Client) System(ctx context.Context) (*System, error) {
+	return decode[*System](c.with(ctx).GetSystem())
+}
+
+// Energy
+
+// EnergyLive returns the current power usage
+func (c *Client) EnergyLive(ctx context.Context) (*EnergyLive, error) {
+	return decode[*EnergyLive](c.with(ctx).GetEnergyLive())
+}
+
+// EnergyReportDay returns the report for a date (YYYY-MM-DD); empty means today
+func (c *Client) EnergyReportDay(ctx context.Context, date string) (*EnergyReport, error) {
+	return decode[*EnergyReport](c.with(ctx).GetEnergyReportDay(date))
+}
+
+// EnergyReportWeek returns the report for an ISO week (YYYY-Www); empty means this week
+func (c *Client) EnergyReportWeek(ctx context.Context, isoWeek string) (*EnergyReport, error) {
+	return decode[*EnergyReport](c.with(ctx).GetEnergyReportWeek(isoWeek))
+}
+
+// EnergyReportMonth returns the report for a month (YYYY-MM); empty means this month
+func (c *Client) EnergyReportMonth(ctx context.Context, yearMonth string) (*EnergyReport, error) {
+	return decode[*EnergyReport](c.with(ctx).GetEnergyReportMonth(yearMonth))
+}
+
+// EnergyReportYear returns the report for a year (YYYY)
+func (c *Client) EnergyReportYear(ctx context.Context, year string) (*EnergyReport, error) {
+	return decode[*EnergyReport](c.with(ctx).GetEnergyReportYear(year))
+}
+
+// Events
+
+// Subscribe streams realtime events until ctx is cancelled
+func (c *Client) Subscribe(ctx context.Context, opts SubscribeOptions) (<-chan Event, error) {
+	return c.api.Subscribe(ctx, opts)
+}
@@ -0,0 +1,129 @@
+package homey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestServer(t *testing.T, routes map[string]string) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("unexpected Authorization header: %q", got)
+		}
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"Not Found"}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return New(server.URL, "test-token")
+}
+
+func TestDevices_DecodesCapabilities(t *testing.T) {
+	h := newTestServer(t, map[string]string{
+		"/api/manager/devices/device/": `{
+			"dev1": {
+				"id": "dev1",
+				"name": "Dimmer",
+				"class": "light",
+				"zone": "zone1",
+				"available": true,
+				"capabilities": ["dim", "onoff"],
+				"capabilitiesObj": {
+					"dim": {
+						"id": "dim", "type": "number", "title": "Dim level",
+						"value": 0.5, "units": "%", "min": 0, "max": 1, "step": 0.01, "decimals": 2,
+						"getable": true, "setable": true,
+						"lastUpdated": "2024-01-01T12:00:00.000Z"
+					}
+				}
+			}
+		}`,
+	})
+
+	devices, err := h.Devices(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dev, ok := devices["dev1"]
+	if !ok {
+		t.Fatal("expected device dev1")
+	}
+	if dev.Name != "Dimmer" || dev.Class != "light" || !dev.Available {
+		t.Errorf("unexpected device: %+v", dev)
+	}
+
+	dim := dev.CapabilitiesObj["dim"]
+	if dim.Units != "%" || !dim.Setable || dim.Value != 0.5 {
+		t.Errorf("unexpected capability: %+v", dim)
+	}
+	if dim.Min == nil || *dim.Min != 0 || dim.Max == nil || *dim.Max != 1 || dim.Step == nil || *dim.Step != 0.01 {
+		t.Errorf("expected min/max/step to be decoded, got %v/%v/%v", dim.Min, dim.Max, dim.Step)
+	}
+	if dim.Decimals == nil || *dim.Decimals != 2 {
+		t.Errorf("expected decimals 2, got %v", dim.Decimals)
+	}
+	if dim.LastUpdated == nil || dim.LastUpdated.Year() != 2024 {
+		t.Errorf("expected lastUpdated to be decoded, got %v", dim.LastUpdated)
+	}
+}
+
+func TestFlowCards_DecodesArgs(t *testing.T) {
+	h := newTestServer(t, map[string]string{
+		"/api/manager/flow/flowcardaction/": `[
+			{"id": "homey:manager:logic:set_number", "title": "Set number",
+			 "args": [{"name": "value", "type": "number", "min": 0, "max": 100}]}
+		]`,
+	})
+
+	cards, err := h.FlowActionCards(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cards) != 1 || len(cards[0].Args) != 1 {
+		t.Fatalf("unexpected cards: %+v", cards)
+	}
+	if arg := cards[0].Args[0]; arg.Name != "value" || arg.Max == nil || *arg.Max != 100 {
+		t.Errorf("unexpected arg: %+v", arg)
+	}
+}
+
+func TestInsightEntries(t *testing.T) {
+	h := newTestServer(t, map[string]string{
+		"/api/manager/insights/log/homey:device:dev1/measure_power/entry": `[
+			{"t": "2024-01-01T12:00:00.000Z", "v": 42.5}
+		]`,
+	})
+
+	entries, err := h.InsightEntries(context.Background(), InsightLog{OwnerURI: "homey:device:dev1", OwnerID: "measure_power"}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].V != 42.5 || entries[0].T.Hour() != 12 {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestNotFoundIsTyped(t *testing.T) {
+	h := newTestServer(t, map[string]string{})
+
+	_, err := h.Zone(context.Background(), "missing")
+	if !IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	var apiErr *APIError
+	if e, ok := err.(*APIError); ok {
+		apiErr = e
+	}
+	if apiErr == nil || apiErr.Message != "Not Found" {
+		t.Errorf("expected *APIError with message, got %#v", err)
+	}
+}
@@ -0,0 +1,323 @@
+package homey
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Device is a Homey device
+type Device struct {
+	ID                 string                 `json:"id"`
+	Name               string                 `json:"name"`
+	DriverURI          string                 `json:"driverUri,omitempty"`
+	DriverID           string                 `json:"driverId,omitempty"`
+	OwnerURI           string                 `json:"ownerUri,omitempty"`
+	Class              string                 `json:"class"`
+	VirtualClass       string                 `json:"virtualClass,omitempty"`
+	Zone               string                 `json:"zone"`
+	ZoneName           string                 `json:"zoneName,omitempty"`
+	Icon               string                 `json:"icon,omitempty"`
+	IconOverride       string                 `json:"iconOverride,omitempty"`
+	Note               string                 `json:"note,omitempty"`
+	Hidden             bool                   `json:"hidden,omitempty"`
+	Available          bool                   `json:"available"`
+	UnavailableMessage string                 `json:"unavailableMessage,omitempty"`
+	WarningMessage     string                 `json:"warningMessage,omitempty"`
+	Ready              bool                   `json:"ready"`
+	Capabilities       []string               `json:"capabilities"`
+	CapabilitiesObj    map[string]Capability  `json:"capabilitiesObj"`
+	Settings           map[string]interface{} `json:"settings,omitempty"`
+	Flags              []string               `json:"flags,omitempty"`
+	UI                 json.RawMessage        `json:"ui,omitempty"`
+	Energy             json.RawMessage        `json:"energyObj,omitempty"`
+	Data               json.RawMessage        `json:"data,omitempty"`
+}
+
+// Capability is the definition and current value of a device capability
+type Capability struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"` // boolean, number, string, enum
+	Title       string          `json:"title"`
+	Description string          `json:"desc,omitempty"`
+	Value       interface{}     `json:"value"`
+	LastUpdated *time.Time      `json:"lastUpdated,omitempty"`
+	Getable     bool            `json:"getable"`
+	Setable     bool            `json:"setable"`
+	Insights    bool            `json:"insights,omitempty"`
+	Units       string          `json:"units,omitempty"`
+	Min         *float64        `json:"min,omitempty"`
+	Max         *float64        `json:"max,omitempty"`
+	Step        *float64        `json:"step,omitempty"`
+	Decimals    *int            `json:"decimals,omitempty"`
+	Values      []EnumValue     `json:"values,omitempty"`
+	ChartType   string          `json:"chartType,omitempty"`
+	Options     json.RawMessage `json:"options,omitempty"`
+}
+
+// EnumValue is one allowed value of an enum capability
+type EnumValue struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// DeviceGroup is a group of devices that acts as a single device
+type DeviceGroup struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Class        string   `json:"class"`
+	VirtualClass string   `json:"virtualClass,omitempty"`
+	Zone         string   `json:"zone"`
+	Devices      []string `json:"devices"`
+}
+
+// Zone is a Homey zone (room or area). Zones form a tree through Parent.
+type Zone struct {
+	ID                string     `json:"id"`
+	Name              string     `json:"name"`
+	Parent            string     `json:"parent"`
+	Icon              string     `json:"icon"`
+	Active            bool       `json:"active"`
+	ActiveLastUpdated *time.Time `json:"activeLastUpdated,omitempty"`
+	ActiveOrigins     []string   `json:"activeOrigins,omitempty"`
+}
+
+// FlowCardRef is a card used inside a simple flow
+type FlowCardRef struct {
+	ID        string                 `json:"id"`
+	URI       string                 `json:"uri,omitempty"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+	Droptoken string                 `json:"droptoken,omitempty"`
+	Group     string                 `json:"group,omitempty"`
+	Inverted  bool                   `json:"inverted,omitempty"`
+	Delay     json.RawMessage        `json:"delay,omitempty"`
+	Duration  json.RawMessage        `json:"duration,omitempty"`
+}
+
+// Flow is a simple (when/and/then) flow
+type Flow struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Folder      string        `json:"folder,omitempty"`
+	Enabled     bool          `json:"enabled"`
+	Broken      bool          `json:"broken"`
+	Triggerable bool          `json:"triggerable"`
+	Trigger     *FlowCardRef  `json:"trigger,omitempty"`
+	Conditions  []FlowCardRef `json:"conditions,omitempty"`
+	Actions     []FlowCardRef `json:"actions,omitempty"`
+}
+
+// AdvancedFlowCard is a card on an advanced flow canvas
+type AdvancedFlowCard struct {
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"` // trigger, condition, action, delay, ...
+	OwnerURI      string                 `json:"ownerUri,omitempty"`
+	Args          map[string]interface{} `json:"args,omitempty"`
+	Droptoken     string                 `json:"droptoken,omitempty"`
+	Inverted      bool                   `json:"inverted,omitempty"`
+	X             float64                `json:"x"`
+	Y             float64                `json:"y"`
+	OutputSuccess []string               `json:"outputSuccess,omitempty"`
+	OutputTrue    []string               `json:"outputTrue,omitempty"`
+	OutputFalse   []string               `json:"outputFalse,omitempty"`
+	OutputError   []string               `json:"outputError,omitempty"`
+}
+
+// AdvancedFlow is an advanced (canvas) flow
+type AdvancedFlow struct {
+	ID          string                      `json:"id"`
+	Name        string                      `json:"name"`
+	Folder      string                      `json:"folder,omitempty"`
+	Enabled     bool                        `json:"enabled"`
+	Broken      bool                        `json:"broken"`
+	Triggerable bool                        `json:"triggerable"`
+	Cards       map[string]AdvancedFlowCard `json:"cards,omitempty"`
+}
+
+// FlowFolder groups flows in the Homey app
+type FlowFolder struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Parent string `json:"parent,omitempty"`
+}
+
+// FlowCard is a trigger, condition or action card definition
+type FlowCard struct {
+	ID             string          `json:"id"`
+	URI            string          `json:"uri,omitempty"`
+	OwnerURI       string          `json:"ownerUri,omitempty"`
+	Title          string          `json:"title"`
+	TitleFormatted string          `json:"titleFormatted,omitempty"`
+	Hint           string          `json:"hint,omitempty"`
+	Args           []FlowCardArg   `json:"args,omitempty"`
+	Tokens         []FlowCardToken `json:"tokens,omitempty"`
+	Droptoken      interface{}     `json:"droptoken,omitempty"`
+}
+
+// FlowCardArg is an argument of a flow card
+type FlowCardArg struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Title       string      `json:"title,omitempty"`
+	Placeholder string      `json:"placeholder,omitempty"`
+	Min         *float64    `json:"min,omitempty"`
+	Max         *float64    `json:"max,omitempty"`
+	Step        *float64    `json:"step,omitempty"`
+	Values      []EnumValue `json:"values,omitempty"`
+}
+
+// FlowCardToken is a token a trigger card provides to the rest of the flow
+type FlowCardToken struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+}
+
+// App is an installed Homey app
+type App struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	Origin         string `json:"origin,omitempty"`
+	Channel        string `json:"channel,omitempty"`
+	SDK            int    `json:"sdk,omitempty"`
+	Enabled        bool   `json:"enabled"`
+	Ready          bool   `json:"ready"`
+	Crashed        bool   `json:"crashed"`
+	CrashedMessage string `json:"crashedMessage,omitempty"`
+	State          string `json:"state,omitempty"`
+	AutoUpdate     bool   `json:"autoupdate,omitempty"`
+	BrandColor     string `json:"brandColor,omitempty"`
+}
+
+// User is a Homey user
+type User struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email,omitempty"`
+	Role     string `json:"role"`
+	Enabled  bool   `json:"enabled"`
+	Verified bool   `json:"verified,omitempty"`
+	Present  bool   `json:"present"`
+	Asleep   bool   `json:"asleep"`
+	Avatar   string `json:"avatar,omitempty"`
+	AthomID  string `json:"athomId,omitempty"`
+}
+
+// Variable is a logic variable
+type Variable struct {
+	ID    string      `json:"id"`
+	Name  string      `json:"name"`
+	Type  string      `json:"type"` // boolean, number, string
+	Value interface{} `json:"value"`
+}
+
+// MoodDevice is the state a mood applies to one device
+type MoodDevice struct {
+	State map[string]interface{} `json:"state"`
+}
+
+// Mood is a saved scene for a zone
+type Mood struct {
+	ID      string                `json:"id"`
+	Name    string                `json:"name"`
+	Zone    string                `json:"zone"`
+	Preset  string                `json:"preset,omitempty"`
+	Active  bool                  `json:"active"`
+	Devices map[string]MoodDevice `json:"devices,omitempty"`
+}
+
+// Dashboard is a Homey dashboard
+type Dashboard struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Columns []json.RawMessage `json:"columns,omitempty"`
+}
+
+// Notification is a timeline notification
+type Notification struct {
+	ID       string `json:"id"`
+	Excerpt  string `json:"excerpt"`
+	OwnerURI string `json:"ownerUri"`
+	Date     string `json:"date"`
+}
+
+// InsightLog describes a recorded insights log
+type InsightLog struct {
+	ID        string      `json:"id"`
+	URI       string      `json:"uri,omitempty"`
+	OwnerURI  string      `json:"ownerUri"`
+	OwnerID   string      `json:"ownerId"`
+	OwnerName string      `json:"ownerName,omitempty"`
+	Title     string      `json:"title"`
+	Type      string      `json:"type"` // number, boolean
+	Units     string      `json:"units,omitempty"`
+	Decimals  *int        `json:"decimals,omitempty"`
+	LastValue interface{} `json:"lastValue,omitempty"`
+}
+
+// InsightEntry is a single data point in an insights log
+type InsightEntry struct {
+	T time.Time   `json:"t"`
+	V interface{} `json:"v"`
+}
+
+// System holds general Homey system information
+type System struct {
+	HomeyVersion         string      `json:"homeyVersion"`
+	HomeyModelID         string      `json:"homeyModelId"`
+	HomeyModelName       string      `json:"homeyModelName"`
+	HomeyPlatform        string      `json:"homeyPlatform,omitempty"`
+	HomeyPlatformVersion interface{} `json:"homeyPlatformVersion,omitempty"`
+	Hostname             string      `json:"hostname,omitempty"`
+	Uptime               float64     `json:"uptime"`
+	Date                 string      `json:"date"`
+	BootDate             string      `json:"bootDate,omitempty"`
+	WifiSSID             string      `json:"wifiSsid,omitempty"`
+	CloudConnected       bool        `json:"cloudConnected"`
+	Address              string      `json:"address,omitempty"`
+	Country              string      `json:"country,omitempty"`
+}
+
+// EnergyLive is the live power usage of a zone and its devices
+type EnergyLive struct {
+	ZoneID         string           `json:"zoneId,omitempty"`
+	ZoneName       string           `json:"zoneName"`
+	TotalConsumed  EnergyPower      `json:"totalConsumed"`
+	TotalGenerated EnergyPower      `json:"totalGenerated"`
+	Items          []EnergyLiveItem `json:"items"`
+}
+
+// EnergyPower is an instantaneous power value in watts
+type EnergyPower struct {
+	W *float64 `json:"W"`
+}
+
+// EnergyLiveItem is a device or zone in a live energy report
+type EnergyLiveItem struct {
+	Type   string      `json:"type"` // device, zone
+	ID     string      `json:"id"`
+	Name   *string     `json:"name"`
+	Values EnergyPower `json:"values"`
+}
+
+// EnergyDeviceUsage is one device's energy in a report, in kWh
+type EnergyDeviceUsage struct {
+	Name   string   `json:"name"`
+	Period *float64 `json:"period"`
+	Total  *float64 `json:"total"`
+}
+
+// EnergyReport is a day, week, month or year energy report (kWh)
+type EnergyReport struct {
+	Date        string `json:"date"`
+	Electricity struct {
+		ConsumedPeriod  *float64 `json:"consumedPeriod"`
+		GeneratedPeriod *float64 `json:"generatedPeriod"`
+		ImportedPeriod  *float64 `json:"importedPeriod"`
+		Devices         struct {
+			Consumed         map[string]EnergyDeviceUsage `json:"consumed"`
+			EVChargerCharged map[string]EnergyDeviceUsage `json:"evChargerCharged"`
+			Imported         map[string]EnergyDeviceUsage `json:"imported"`
+		} `json:"devices"`
+	} `json:"electricity"`
+}